@@ -0,0 +1,108 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// BaseURL sets the canonical URL the application is served at, eg "https://example.com" or
+// "https://example.com/app", and returns the router. AbsoluteUrlFor uses it to build URLs outside of a request,
+// eg in emails sent from background jobs. Note that only the root router can have a base URL.
+func (r *Router) BaseURL(baseURL string) *Router {
+	if r.parent != nil {
+		panic("You can only set the base URL on the root router.")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic(fmt.Sprintf("web: invalid base URL %q: it must include a scheme and a host", baseURL))
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	r.baseURL = u
+	return r
+}
+
+// MustUrlFor is like UrlFor, but panics if the URL can't be built.
+func (r *Router) MustUrlFor(routeName string, pathParams ...string) string {
+	url, err := r.MappedUrlFor(routeName, nil, pathParams...)
+	if err != nil {
+		panic(err)
+	}
+	return url
+}
+
+// UrlFor returns the path of the route named routeName, with pathParams filled in like Request.UrlFor. The
+// whole tree of routers r belongs to is searched, so any router can build URLs for any other, outside of a
+// request (eg, from background jobs or mailers).
+func (r *Router) UrlFor(routeName string, pathParams ...string) (string, error) {
+	return r.MappedUrlFor(routeName, nil, pathParams...)
+}
+
+// MappedUrlFor is like UrlFor, but params named in namedParams are filled in by name first, like
+// Request.MappedUrlFor.
+func (r *Router) MappedUrlFor(routeName string, namedParams map[string]string, pathParams ...string) (string, error) {
+	route := findNamedRoute(r, routeName)
+	if route == nil {
+		return "", fmt.Errorf("Route with name %s was not found.", routeName)
+	}
+	return fillPathParams(route.path, namedParams, pathParams...)
+}
+
+// AbsoluteUrlFor returns the absolute URL of the route named routeName, with pathParams filled in like
+// Request.UrlFor. It doesn't need a request, so it works from background jobs, but the root router must
+// have a BaseURL.
+func (r *Router) AbsoluteUrlFor(routeName string, pathParams ...string) (string, error) {
+	base := getRootRouter(r).baseURL
+	if base == nil {
+		return "", fmt.Errorf("Router has no base URL.")
+	}
+	path, err := r.UrlFor(routeName, pathParams...)
+	if err != nil {
+		return "", err
+	}
+	return base.String() + path, nil
+}
+
+// AbsoluteUrlFor is like Router.AbsoluteUrlFor. If the root router has no BaseURL, the scheme and host come
+// from the request instead: its Host header, and X-Forwarded-Proto or whether it arrived over TLS. Clients
+// control those headers, so set a BaseURL if the URL is sent anywhere that matters, eg in emails.
+func (r *Request) AbsoluteUrlFor(routeName string, pathParams ...string) (string, error) {
+	if r.route == nil {
+		return "", fmt.Errorf("Request to %s is not associated with any route.", r.RequestURI)
+	}
+	router := r.route.router
+	if getRootRouter(router).baseURL != nil {
+		return router.AbsoluteUrlFor(routeName, pathParams...)
+	}
+	path, err := router.UrlFor(routeName, pathParams...)
+	if err != nil {
+		return "", err
+	}
+	return requestOrigin(r.Request) + path, nil
+}
+
+// requestOrigin returns the scheme and host req was sent to, eg "https://example.com".
+func requestOrigin(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	proto := req.Header.Get("X-Forwarded-Proto")
+	if i := strings.IndexByte(proto, ','); i >= 0 {
+		proto = proto[:i] // the proxy closest to the client comes first
+	}
+	if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + req.Host
+}
+
+// TemplateFuncs returns template functions for MessageTemplate (or any text/template) bound to the router:
+// urlFor returns an absolute URL like AbsoluteUrlFor, eg {{urlFor "reset_password" .Token}}.
+func (r *Router) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"urlFor": r.AbsoluteUrlFor,
+	}
+}
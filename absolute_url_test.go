@@ -0,0 +1,111 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterUrlFor(t *testing.T) {
+	router := New(Context{})
+	router.Get("/posts/:id", (*Context).A).Named("post")
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Get("/users/:id:\\d+/:tab", (*AdminContext).B).Named("admin_user")
+
+	url, err := router.UrlFor("admin_user", "3", "profile")
+	assert.NoError(t, err)
+	assert.Equal(t, "/admin/users/3/profile", url)
+
+	url, err = admin.MappedUrlFor("admin_user", map[string]string{"tab": "billing"}, "7")
+	assert.NoError(t, err)
+	assert.Equal(t, "/admin/users/7/billing", url)
+
+	assert.Equal(t, "/posts/12", admin.MustUrlFor("post", "12"))
+
+	_, err = router.UrlFor("admin_user", "x", "profile")
+	assert.Error(t, err)
+	_, err = router.UrlFor("missing")
+	assert.Error(t, err)
+	assert.Panics(t, func() { router.MustUrlFor("post") })
+}
+
+func TestUrlForQuery(t *testing.T) {
+	router := New(Context{})
+	router.Get("/search", (*Context).A).Named("search")
+	router.Get("/users/:id/posts", (*Context).A).Named("user_posts")
+
+	url, err := router.MappedUrlFor("search", Query{"q": "foo bar", "page": "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/search?page=2&q=foo+bar", url)
+
+	url, err = router.MappedUrlFor("user_posts", Query{"id": "3", "sort": "new"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/3/posts?sort=new", url)
+
+	url, err = router.MappedUrlFor("user_posts", map[string]string{}, "3")
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/3/posts", url)
+}
+
+func TestAbsoluteUrlFor(t *testing.T) {
+	router := New(Context{})
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Get("/users/:id:\\d+", (*AdminContext).B).Named("admin_user")
+
+	_, err := admin.AbsoluteUrlFor("admin_user", "3")
+	assert.Error(t, err)
+
+	router.BaseURL("https://example.com/app/")
+	url, err := admin.AbsoluteUrlFor("admin_user", "3")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/app/admin/users/3", url)
+
+	_, err = router.AbsoluteUrlFor("admin_user", "x")
+	assert.Error(t, err)
+	_, err = router.AbsoluteUrlFor("missing")
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { admin.BaseURL("https://example.com") })
+	assert.Panics(t, func() { router.BaseURL("/relative") })
+}
+
+func TestRequestAbsoluteUrlFor(t *testing.T) {
+	router := New(Context{})
+	var urls []string
+	router.Get("/posts/:id", func(rw ResponseWriter, req *Request) {
+		url, err := req.AbsoluteUrlFor("post", req.PathParams["id"])
+		assert.NoError(t, err)
+		urls = append(urls, url)
+	}).Named("post")
+
+	rw, req := newTestRequest("GET", "/posts/1")
+	req.Host = "example.com"
+	router.ServeHTTP(rw, req)
+
+	rw, req = newTestRequest("GET", "/posts/2")
+	req.Host = "example.com:8443"
+	req.Header.Set("X-Forwarded-Proto", "HTTPS, http")
+	router.ServeHTTP(rw, req)
+
+	router.BaseURL("https://canonical.example.com")
+	rw, req = newTestRequest("GET", "/posts/3")
+	req.Host = "evil.example.com"
+	router.ServeHTTP(rw, req)
+
+	assert.Equal(t, []string{"http://example.com/posts/1", "https://example.com:8443/posts/2", "https://canonical.example.com/posts/3"}, urls)
+}
+
+func TestTemplateFuncsUrlFor(t *testing.T) {
+	router := New(Context{}).BaseURL("https://example.com")
+	router.Get("/reset/:token", (*Context).A).Named("reset_password")
+
+	tmpl, err := NewMessageTemplate("Reset your password", "Go to {{urlFor \"reset_password\" .Token}}", router.TemplateFuncs())
+	assert.NoError(t, err)
+	msg, err := tmpl.Render([]string{"alice@example.com"}, map[string]string{"Token": "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Go to https://example.com/reset/abc", msg.Body)
+
+	tmpl, _ = NewMessageTemplate("", "{{urlFor \"missing\"}}", router.TemplateFuncs())
+	_, err = tmpl.Render(nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,369 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogEntry describes a request that was served, for an AccessLogSink.
+type AccessLogEntry struct {
+	Time         time.Time     `json:"time"`
+	Method       string        `json:"method"`
+	Path         string        `json:"path"`
+	Route        string        `json:"route,omitempty"` // the route's path, eg "/users/:id"; empty if nothing matched
+	Status       int           `json:"status"`
+	Duration     time.Duration `json:"duration_ns"`
+	RemoteAddr   string        `json:"remote_addr"`
+	UserAgent    string        `json:"user_agent,omitempty"`
+	RequestID    string        `json:"request_id,omitempty"`
+	Disconnected bool          `json:"disconnected,omitempty"`
+}
+
+// AccessLogSink is somewhere access logs go. See NewAccessLogWriter, SampleAccessLog and NewOTLPAccessLog.
+type AccessLogSink interface {
+	LogAccess(e AccessLogEntry)
+}
+
+// AccessLog logs every request to each of sinks, and returns the router. Sinks are kept on the root router,
+// like Subscribe's subscribers, and called once the request is done. Each sink has its own format and
+// sampling, so one router can log to several places at once:
+//
+//	rotated, err := web.NewRotatingFile("/var/log/app/access.log", 100<<20, 5)
+//	...
+//	router.AccessLog(
+//		web.NewAccessLogWriter(os.Stdout, web.AccessLogJSON),
+//		web.SampleAccessLog(web.NewAccessLogWriter(rotated, web.AccessLogText), 0.1),
+//		web.NewAccessLogWriter(syslogWriter, web.AccessLogText), // from syslog.New
+//		web.NewOTLPAccessLog("http://otel-collector:4318/v1/logs"),
+//	)
+func (r *Router) AccessLog(sinks ...AccessLogSink) *Router {
+	return r.Subscribe(func(e Event) {
+		entry := AccessLogEntry{
+			Time:         time.Now().Add(-e.Duration),
+			Method:       e.Request.Method,
+			Path:         e.Request.URL.Path,
+			Route:        e.Request.RoutePath(),
+			Status:       e.Status,
+			Duration:     e.Duration,
+			RemoteAddr:   e.Request.RemoteAddr,
+			UserAgent:    e.Request.UserAgent(),
+			RequestID:    e.Request.RequestID(),
+			Disconnected: e.Request.Disconnected(),
+		}
+		for _, sink := range sinks {
+			sink.LogAccess(entry)
+		}
+	}, EventHandlerFinished)
+}
+
+// AccessLogFormat formats an entry as a line, without the trailing newline.
+type AccessLogFormat func(e AccessLogEntry) []byte
+
+// AccessLogText formats entries like LoggerMiddleware.
+func AccessLogText(e AccessLogEntry) []byte {
+	return []byte(accessLogLine(e.Duration, e.Status, e.Path, e.Disconnected, e.RequestID))
+}
+
+// AccessLogJSON formats entries as JSON objects, one per line.
+func AccessLogJSON(e AccessLogEntry) []byte {
+	line, _ := json.Marshal(e)
+	return line
+}
+
+type accessLogWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format AccessLogFormat
+}
+
+// NewAccessLogWriter returns an AccessLogSink writing entries formatted with format to w, one per line. Writes
+// are serialized, so w needn't be safe for concurrent use. Write errors are ignored.
+func NewAccessLogWriter(w io.Writer, format AccessLogFormat) AccessLogSink {
+	return &accessLogWriter{w: w, format: format}
+}
+
+func (s *accessLogWriter) LogAccess(e AccessLogEntry) {
+	line := append(s.format(e), '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(line)
+}
+
+type sampledAccessLog struct {
+	sink AccessLogSink
+	rate float64
+}
+
+// SampleAccessLog returns an AccessLogSink passing a fraction rate (between 0 and 1) of entries on to sink,
+// picked at random. Server errors (5xx) are always passed on.
+func SampleAccessLog(sink AccessLogSink, rate float64) AccessLogSink {
+	return sampledAccessLog{sink: sink, rate: rate}
+}
+
+func (s sampledAccessLog) LogAccess(e AccessLogEntry) {
+	if e.Status >= 500 || rand.Float64() < s.rate {
+		s.sink.LogAccess(e)
+	}
+}
+
+// RotatingFile is a log file that's rotated once it grows past a size: access.log is renamed to access.log.1,
+// access.log.1 to access.log.2, and so on, keeping a number of old files.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens (or creates) the file at path for appending, rotating it before it grows past maxSize
+// bytes, and keeping maxBackups old files.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first if p would take it past its size.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files along, and starts a new one. The caller must hold mu.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	for i := f.maxBackups - 1; i > 0; i-- {
+		os.Rename(f.path+"."+strconv.Itoa(i), f.path+"."+strconv.Itoa(i+1))
+	}
+	if f.maxBackups > 0 {
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// OTLPAccessLog is an AccessLogSink exporting entries as OpenTelemetry log records, to a collector's OTLP/HTTP
+// logs endpoint (JSON encoding). Entries are queued, and sent in batches by a single goroutine, once BatchSize have
+// been queued or every FlushInterval. When the collector can't keep up, entries beyond QueueSize are dropped
+// rather than slowing down requests; see Dropped. Call Flush before the process exits.
+type OTLPAccessLog struct {
+	Endpoint    string       // eg "http://localhost:4318/v1/logs"
+	ServiceName string       // the service.name resource attribute, if not empty
+	Client      *http.Client // a client with a 10 second timeout if nil
+	OnError     func(error)  // called with export errors, if not nil
+
+	BatchSize     int           // defaults to 100
+	FlushInterval time.Duration // defaults to 5 seconds
+	QueueSize     int           // defaults to 10000
+
+	start   sync.Once
+	queue   chan AccessLogEntry
+	flushes chan chan struct{}
+	dropped atomic.Int64
+}
+
+// otlpClient is the client of OTLPAccessLogs without one, so that a collector that hangs doesn't stall exports
+// forever.
+var otlpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewOTLPAccessLog returns an OTLPAccessLog exporting to endpoint, with the default options.
+func NewOTLPAccessLog(endpoint string) *OTLPAccessLog {
+	return &OTLPAccessLog{Endpoint: endpoint}
+}
+
+// LogAccess implements AccessLogSink. It queues e, or drops it if the queue is full.
+func (o *OTLPAccessLog) LogAccess(e AccessLogEntry) {
+	o.start.Do(o.startExporter)
+	select {
+	case o.queue <- e:
+	default:
+		o.dropped.Add(1)
+	}
+}
+
+// Flush exports the queued entries, and returns once they're sent.
+func (o *OTLPAccessLog) Flush() {
+	o.start.Do(o.startExporter)
+	done := make(chan struct{})
+	o.flushes <- done
+	<-done
+}
+
+// Dropped returns how many entries were dropped because the queue was full.
+func (o *OTLPAccessLog) Dropped() int64 {
+	return o.dropped.Load()
+}
+
+func (o *OTLPAccessLog) startExporter() {
+	batchSize, interval, queueSize := o.BatchSize, o.FlushInterval, o.QueueSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if queueSize <= 0 {
+		queueSize = 10000
+	}
+	o.queue = make(chan AccessLogEntry, queueSize)
+	o.flushes = make(chan chan struct{})
+	go o.exportLoop(batchSize, interval)
+}
+
+// exportLoop exports the queued entries in batches of up to batchSize, forever.
+func (o *OTLPAccessLog) exportLoop(batchSize int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]AccessLogEntry, 0, batchSize)
+	for {
+		select {
+		case e := <-o.queue:
+			batch = append(batch, e)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		case done := <-o.flushes:
+			for n := len(o.queue); n > 0; n-- {
+				if batch = append(batch, <-o.queue); len(batch) == batchSize {
+					o.export(batch)
+					batch = batch[:0]
+				}
+			}
+			o.export(batch)
+			batch = batch[:0]
+			close(done)
+			continue
+		}
+		o.export(batch)
+		batch = batch[:0]
+	}
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]interface{}{"stringValue": value}}
+}
+
+func otlpInt(key string, value int64) otlpAttribute {
+	// OTLP/JSON encodes 64-bit integers as strings.
+	return otlpAttribute{Key: key, Value: map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}}
+}
+
+func (o *OTLPAccessLog) export(batch []AccessLogEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	records := make([]map[string]interface{}, 0, len(batch))
+	for _, e := range batch {
+		severity, severityText := 9, "INFO"
+		if e.Status >= 500 {
+			severity, severityText = 17, "ERROR"
+		} else if e.Status >= 400 {
+			severity, severityText = 13, "WARN"
+		}
+		attributes := []otlpAttribute{
+			otlpString("http.request.method", e.Method),
+			otlpString("url.path", e.Path),
+			otlpInt("http.response.status_code", int64(e.Status)),
+			otlpInt("http.server.request.duration_ns", int64(e.Duration)),
+			otlpString("client.address", e.RemoteAddr),
+		}
+		if e.Route != "" {
+			attributes = append(attributes, otlpString("http.route", e.Route))
+		}
+		if e.UserAgent != "" {
+			attributes = append(attributes, otlpString("user_agent.original", e.UserAgent))
+		}
+		if e.RequestID != "" {
+			attributes = append(attributes, otlpString("request_id", e.RequestID))
+		}
+		records = append(records, map[string]interface{}{
+			"timeUnixNano":   strconv.FormatInt(e.Time.UnixNano(), 10),
+			"severityNumber": severity,
+			"severityText":   severityText,
+			"body":           map[string]interface{}{"stringValue": string(AccessLogText(e))},
+			"attributes":     attributes,
+		})
+	}
+	resource := map[string]interface{}{"attributes": []otlpAttribute{}}
+	if o.ServiceName != "" {
+		resource["attributes"] = []otlpAttribute{otlpString("service.name", o.ServiceName)}
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": resource,
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]interface{}{"name": "github.com/gocraft/web"},
+				"logRecords": records,
+			}},
+		}},
+	})
+
+	client := o.Client
+	if client == nil {
+		client = otlpClient
+	}
+	resp, err := client.Post(o.Endpoint, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = fmt.Errorf("web: exporting access logs to %s: %s", o.Endpoint, resp.Status)
+		}
+	}
+	if err != nil && o.OnError != nil {
+		o.OnError(err)
+	}
+}
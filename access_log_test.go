@@ -0,0 +1,141 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	var text, jsonLines, sampled bytes.Buffer
+	router := New(Context{})
+	router.AccessLog(
+		NewAccessLogWriter(&text, AccessLogText),
+		NewAccessLogWriter(&jsonLines, AccessLogJSON),
+		SampleAccessLog(NewAccessLogWriter(&sampled, AccessLogText), 0),
+	)
+	router.Get("/users/:id", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("user"))
+	})
+	router.Get("/broken", func(rw ResponseWriter, req *Request) {
+		panic("broken")
+	})
+
+	rw, req := newTestRequest("GET", "/users/5")
+	req.Header.Set("User-Agent", "test")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "user", 200)
+
+	assert.True(t, regexp.MustCompile(`^\[\d+ .{2}\] 200 '/users/5'\n$`).MatchString(text.String()), text.String())
+	var entry AccessLogEntry
+	assert.NoError(t, json.Unmarshal(jsonLines.Bytes(), &entry))
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, "/users/5", entry.Path)
+	assert.Equal(t, "/users/:id", entry.Route)
+	assert.Equal(t, 200, entry.Status)
+	assert.Equal(t, "test", entry.UserAgent)
+	assert.Equal(t, "", sampled.String())
+
+	rw, req = newTestRequest("GET", "/broken")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 500, rw.Code)
+	assert.True(t, strings.Contains(sampled.String(), "500 '/broken'"), sampled.String())
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "access_log")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	f, err := NewRotatingFile(path, 10, 2)
+	assert.NoError(t, err)
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		_, err := f.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, f.Close())
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(dir, name))
+		return string(b)
+	}
+	assert.Equal(t, "four\nfive\n", read("access.log"))
+	assert.Equal(t, "three\n", read("access.log.1"))
+	assert.Equal(t, "one\ntwo\n", read("access.log.2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOTLPAccessLog(t *testing.T) {
+	var mu sync.Mutex
+	var exports []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var export map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&export)
+		mu.Lock()
+		exports = append(exports, export)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	otlp := NewOTLPAccessLog(server.URL)
+	otlp.ServiceName = "shop"
+	router := New(Context{}).AccessLog(otlp)
+	router.Get("/users/:id", func(rw ResponseWriter, req *Request) {})
+	for _, path := range []string{"/users/1", "/missing"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+	}
+	otlp.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Equal(t, 1, len(exports)) {
+		encoded, _ := json.Marshal(exports[0])
+		body := string(encoded)
+		assert.True(t, strings.Contains(body, `{"key":"service.name","value":{"stringValue":"shop"}}`), body)
+		assert.True(t, strings.Contains(body, `{"key":"http.route","value":{"stringValue":"/users/:id"}}`), body)
+		assert.True(t, strings.Contains(body, `{"key":"http.response.status_code","value":{"intValue":"404"}}`), body)
+		assert.True(t, strings.Contains(body, `"severityText":"WARN"`), body)
+	}
+}
+
+func TestOTLPAccessLogQueueFull(t *testing.T) {
+	received, unblock := make(chan bool, 3), make(chan bool)
+	var mu sync.Mutex
+	records := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		records += strings.Count(string(body), `"timeUnixNano"`)
+		mu.Unlock()
+		received <- true
+		<-unblock
+	}))
+	defer server.Close()
+
+	otlp := &OTLPAccessLog{Endpoint: server.URL, BatchSize: 1, QueueSize: 1}
+	otlp.LogAccess(AccessLogEntry{Path: "/1"})
+	<-received // the exporter is busy with the first entry
+
+	otlp.LogAccess(AccessLogEntry{Path: "/2"})
+	otlp.LogAccess(AccessLogEntry{Path: "/3"})
+	assert.Equal(t, int64(1), otlp.Dropped())
+
+	close(unblock)
+	otlp.Flush()
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, records)
+}
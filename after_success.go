@@ -0,0 +1,41 @@
+package web
+
+import "fmt"
+
+// AfterSuccess registers fn to be called once the request has succeeded: after the whole middleware stack has
+// returned without panicking, with a 2xx or 3xx response (or none written). Use it to publish the domain events
+// of a request, eg to enqueue them on an outbox or a message broker, only once its outcome is final:
+//
+//	func (c *Context) CreateOrder(rw web.ResponseWriter, req *web.Request) {
+//		order := c.createOrder(req)
+//		req.AfterSuccess(func() { c.events.Publish(OrderCreated{ID: order.ID}) })
+//		rw.WriteHeader(http.StatusCreated)
+//	}
+//
+// With TransactionMiddleware, functions are only called once the transaction has been committed: a failed commit
+// panics, and responses that roll the transaction back aren't successes. Functions run in the order they were
+// registered, on the request's goroutine, before ServeHTTP returns. A panicking function is reported to the
+// PanicHandler and doesn't stop the others.
+func (r *Request) AfterSuccess(fn func()) {
+	more := r.extras()
+	more.afterSuccess = append(more.afterSuccess, fn)
+}
+
+// runAfterSuccess calls the functions registered with AfterSuccess.
+func (r *Request) runAfterSuccess() {
+	if r.more == nil {
+		return
+	}
+	callbacks := r.more.afterSuccess
+	r.more.afterSuccess = nil
+	for _, fn := range callbacks {
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					PanicHandler.Panic(fmt.Sprint(r.URL), recovered, formatFrames(panicFrames()))
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAfterSuccess(t *testing.T) {
+	reporter := &stackCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	var log []string
+	var commitErr error
+	provider := DBProviderFunc(func(ctx context.Context) (Tx, error) {
+		return &testTx{log: &log, commitErr: commitErr}, nil
+	})
+
+	router := New(Context{})
+	router.Middleware(TransactionMiddleware(provider))
+	handler := func(status int) func(ResponseWriter, *Request) {
+		return func(rw ResponseWriter, req *Request) {
+			req.Tx()
+			req.AfterSuccess(func() { log = append(log, "published 1") })
+			req.AfterSuccess(func() { panic("broker down") })
+			req.AfterSuccess(func() { log = append(log, "published 2") })
+			rw.WriteHeader(status)
+		}
+	}
+	router.Post("/created", handler(http.StatusCreated))
+	router.Post("/invalid", handler(http.StatusBadRequest))
+	router.Post("/panic", func(rw ResponseWriter, req *Request) {
+		req.AfterSuccess(func() { log = append(log, "published") })
+		panic("boom")
+	})
+	router.Subrouter(Context{}, "/plain").Post("/ok", func(rw ResponseWriter, req *Request) {
+		req.AfterSuccess(func() { log = append(log, "published") })
+	})
+
+	for _, tt := range []struct {
+		path string
+		log  []string
+	}{
+		{"/created", []string{"commit", "published 1", "published 2"}},
+		{"/invalid", []string{"rollback"}},
+		{"/panic", nil},
+		{"/plain/ok", []string{"published"}},
+	} {
+		log = nil
+		rw, req := newTestRequest("POST", tt.path)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, tt.log, log, tt.path)
+	}
+	assert.True(t, reporter.stack != "")
+
+	// Nothing is published if the transaction can't be committed.
+	log, commitErr = nil, errors.New("serialization failure")
+	rw, req := newTestRequest("POST", "/created")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, []string{"commit"}, log)
+}
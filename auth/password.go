@@ -0,0 +1,165 @@
+// Package auth provides credential helpers for applications built on gocraft/web.
+//
+// Passwords hashes and verifies passwords, and transparently upgrades old hashes when a user logs in:
+//
+//	ok, upgraded, err := auth.DefaultPasswords.Verify(req.FormValue("password"), user.PasswordHash)
+//	if ok && upgraded != "" {
+//		user.PasswordHash = upgraded // save it
+//	}
+//
+// The built-in Hasher is PBKDF2-HMAC-SHA256, which needs nothing outside the standard library. Argon2id or bcrypt
+// (from golang.org/x/crypto) can be plugged in by implementing Hasher, keeping PBKDF2 as a legacy hasher so
+// existing hashes are upgraded on the next login.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Hasher is a password hashing scheme. Encoded hashes carry everything needed to verify them, including their
+// parameters, so parameters can be raised over time.
+type Hasher interface {
+	// Hash returns the encoded hash of password with a fresh salt.
+	Hash(password string) (string, error)
+	// Recognizes returns true if encoded was produced by this scheme, with any parameters.
+	Recognizes(encoded string) bool
+	// Verify compares password to encoded in constant time.
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash returns true if encoded was produced with weaker parameters than the hasher's current ones.
+	NeedsRehash(encoded string) bool
+}
+
+// Passwords hashes new passwords with Current and verifies hashes made by Current or any of Legacy.
+type Passwords struct {
+	Current Hasher
+	Legacy  []Hasher
+}
+
+// DefaultPasswords hashes with PBKDF2-HMAC-SHA256 at 600,000 iterations, as OWASP recommends.
+var DefaultPasswords = &Passwords{Current: PBKDF2(600000)}
+
+// ErrUnknownHash is returned when no hasher recognizes an encoded hash.
+var ErrUnknownHash = errors.New("auth: unrecognized password hash")
+
+// Hash returns the encoded hash of password.
+func (p *Passwords) Hash(password string) (string, error) {
+	return p.Current.Hash(password)
+}
+
+// Verify checks password against encoded. If the password is correct but encoded was made by a legacy hasher or
+// with weaker parameters, upgraded is a new hash of password to store in place of encoded.
+func (p *Passwords) Verify(password, encoded string) (ok bool, upgraded string, err error) {
+	hasher := p.hasherFor(encoded)
+	if hasher == nil {
+		return false, "", ErrUnknownHash
+	}
+	ok, err = hasher.Verify(password, encoded)
+	if !ok || err != nil {
+		return false, "", err
+	}
+	if hasher != p.Current || hasher.NeedsRehash(encoded) {
+		upgraded, err = p.Current.Hash(password)
+	}
+	return true, upgraded, err
+}
+
+func (p *Passwords) hasherFor(encoded string) Hasher {
+	if p.Current.Recognizes(encoded) {
+		return p.Current
+	}
+	for _, hasher := range p.Legacy {
+		if hasher.Recognizes(encoded) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// PBKDF2 returns a Hasher using PBKDF2-HMAC-SHA256 with iterations rounds. Its hashes look like
+// "$pbkdf2-sha256$600000$<salt>$<key>", with the salt and key base64 encoded.
+func PBKDF2(iterations int) Hasher {
+	return pbkdf2Hasher{iterations: iterations}
+}
+
+type pbkdf2Hasher struct {
+	iterations int
+}
+
+const pbkdf2Prefix = "$pbkdf2-sha256$"
+
+func (h pbkdf2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2SHA256([]byte(password), salt, h.iterations, sha256.Size)
+	return fmt.Sprintf("%s%d$%s$%s", pbkdf2Prefix, h.iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h pbkdf2Hasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, pbkdf2Prefix)
+}
+
+func (h pbkdf2Hasher) Verify(password, encoded string) (bool, error) {
+	iterations, salt, key, err := parsePBKDF2(encoded)
+	if err != nil {
+		return false, err
+	}
+	actual := pbkdf2SHA256([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func (h pbkdf2Hasher) NeedsRehash(encoded string) bool {
+	iterations, _, _, err := parsePBKDF2(encoded)
+	return err != nil || iterations < h.iterations
+}
+
+func parsePBKDF2(encoded string) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(encoded, pbkdf2Prefix), "$")
+	if len(parts) != 3 {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	if iterations, err = strconv.Atoi(parts[0]); err != nil || iterations < 1 {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil || len(key) == 0 {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	return iterations, salt, key, nil
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	u := make([]byte, 0, sha256.Size)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
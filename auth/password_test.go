@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPBKDF2Vectors(t *testing.T) {
+	// From RFC 7914, section 11.
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"+
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key))
+	key = pbkdf2SHA256([]byte("Password"), []byte("NaCl"), 80000, 64)
+	assert.Equal(t, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"+
+		"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d", hex.EncodeToString(key))
+}
+
+func TestPasswords(t *testing.T) {
+	passwords := &Passwords{Current: PBKDF2(1000)}
+	encoded, err := passwords.Hash("hunter2")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$pbkdf2-sha256$1000$"))
+
+	other, _ := passwords.Hash("hunter2")
+	assert.NotEqual(t, encoded, other)
+
+	ok, upgraded, err := passwords.Verify("hunter2", encoded)
+	assert.True(t, ok)
+	assert.Equal(t, "", upgraded)
+	assert.NoError(t, err)
+
+	ok, _, err = passwords.Verify("hunter3", encoded)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	_, _, err = passwords.Verify("hunter2", "$2a$10$bcrypthash")
+	assert.Equal(t, ErrUnknownHash, err)
+	_, _, err = passwords.Verify("hunter2", "$pbkdf2-sha256$x$y$z")
+	assert.Equal(t, ErrUnknownHash, err)
+}
+
+func TestPasswordsUpgrade(t *testing.T) {
+	old := &Passwords{Current: PBKDF2(500)}
+	encoded, _ := old.Hash("hunter2")
+
+	passwords := &Passwords{Current: PBKDF2(1000)}
+	ok, upgraded, err := passwords.Verify("hunter2", encoded)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(upgraded, "$pbkdf2-sha256$1000$"))
+
+	ok, _, _ = passwords.Verify("hunter2", upgraded)
+	assert.True(t, ok)
+
+	ok, upgraded, _ = passwords.Verify("wrong", encoded)
+	assert.False(t, ok)
+	assert.Equal(t, "", upgraded)
+}
+
+type plainHasher struct{}
+
+func (plainHasher) Hash(password string) (string, error) { return "plain:" + password, nil }
+func (plainHasher) Recognizes(encoded string) bool       { return strings.HasPrefix(encoded, "plain:") }
+func (plainHasher) NeedsRehash(encoded string) bool      { return false }
+func (plainHasher) Verify(password, encoded string) (bool, error) {
+	return encoded == "plain:"+password, nil
+}
+
+func TestPasswordsLegacy(t *testing.T) {
+	passwords := &Passwords{Current: PBKDF2(1000), Legacy: []Hasher{plainHasher{}}}
+	ok, upgraded, err := passwords.Verify("hunter2", "plain:hunter2")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(upgraded, "$pbkdf2-sha256$1000$"))
+}
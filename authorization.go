@@ -0,0 +1,169 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AccessRequirements are the scopes and roles a route declares with RequireScopes and RequireRole.
+type AccessRequirements struct {
+	Scopes []string `json:"scopes,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+// Authorizer enforces the AccessRequirements declared on routes. Authorize is called after all middleware
+// has run (so authentication middleware has already identified the principal), right before the handler.
+// ctx is the context of the router the Authorizer was set on, eg *YourContext.
+//
+// Return nil to let the request through, ErrUnauthenticated to respond with 401, or any other error to respond with 403.
+type Authorizer interface {
+	Authorize(ctx interface{}, req *Request, required AccessRequirements) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface.
+type AuthorizerFunc func(ctx interface{}, req *Request, required AccessRequirements) error
+
+// Authorize calls f(ctx, req, required).
+func (f AuthorizerFunc) Authorize(ctx interface{}, req *Request, required AccessRequirements) error {
+	return f(ctx, req, required)
+}
+
+// ErrUnauthenticated can be returned by an Authorizer when there is no principal at all.
+var ErrUnauthenticated = errors.New("web: request is not authenticated")
+
+// DefaultUnauthorizedResponse is the default text rendered when an Authorizer returns ErrUnauthenticated.
+var DefaultUnauthorizedResponse = "Unauthorized"
+
+// DefaultForbiddenResponse is the default text rendered when an Authorizer denies a request.
+var DefaultForbiddenResponse = "Forbidden"
+
+// Authorizer sets the Authorizer for routes on this router and its subrouters, and returns the router.
+// A subrouter can set its own Authorizer to override its parent's.
+func (r *Router) Authorizer(a Authorizer) *Router {
+	r.authorizer = a
+	return r
+}
+
+// RequireScopes declares that the route may only be invoked by principals holding all of scopes.
+// The requirement is enforced by the nearest Authorizer set on the route's router or its parents. If there is
+// none, Router.Validate reports the route, and its requests get a 500 rather than being served unprotected.
+func (r *Route) RequireScopes(scopes ...string) *Route {
+	r.checkConfigurable()
+	if len(scopes) == 0 {
+		return r
+	}
+	if r.access == nil {
+		r.access = &AccessRequirements{}
+	}
+	r.access.Scopes = append(r.access.Scopes, scopes...)
+	return r
+}
+
+// RequireRole declares that the route may only be invoked by principals with role. Calling it more than once
+// requires all of the roles. The requirement is enforced like RequireScopes.
+func (r *Route) RequireRole(role string) *Route {
+	r.checkConfigurable()
+	if r.access == nil {
+		r.access = &AccessRequirements{}
+	}
+	r.access.Roles = append(r.access.Roles, role)
+	return r
+}
+
+// Requirements returns the access requirements declared on the route.
+func (r *Route) Requirements() AccessRequirements {
+	if r.access == nil {
+		return AccessRequirements{}
+	}
+	return *r.access
+}
+
+func (r *Route) hasAccessRequirements() bool {
+	return r.access != nil
+}
+
+// authorize runs the nearest Authorizer for the routed request. If the request is denied, the error response
+// is rendered and false is returned.
+func (closure *middlewareClosure) authorize(rw ResponseWriter, req *Request) bool {
+	for i := len(closure.Routers) - 1; i >= 0; i-- {
+		authorizer := closure.Routers[i].authorizer
+		if authorizer == nil {
+			continue
+		}
+
+		err := authorizer.Authorize(closure.Contexts[i].Interface(), req, *req.route.access)
+		if err == ErrUnauthenticated {
+			renderError(rw, req, http.StatusUnauthorized, DefaultUnauthorizedResponse)
+			return false
+		} else if err != nil {
+			renderError(rw, req, http.StatusForbidden, DefaultForbiddenResponse)
+			return false
+		}
+		return true
+	}
+
+	// Validate reports such routes; fail closed rather than serve them unprotected.
+	renderError(rw, req, http.StatusInternalServerError, DefaultPanicResponse)
+	return false
+}
+
+// unenforced returns what the route requires that no router in its chain enforces, or "".
+func (r *Route) unenforced() string {
+	var authorizer, challenge bool
+	for _, router := range r.router.chain {
+		authorizer = authorizer || router.authorizer != nil
+		challenge = challenge || router.challenge != nil
+	}
+	switch {
+	case r.access != nil && !authorizer:
+		return "declares access requirements but no Authorizer is set"
+	case r.challenged && !challenge:
+		return "requires a challenge but no Challenge is set"
+	}
+	return ""
+}
+
+// Validate returns an error naming the routes of r's whole router tree whose access requirements or challenge no
+// router enforces, or nil if there are none. Call it once the routes are set up, eg before serving:
+//
+//	if err := router.Validate(); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// If it isn't called, the error is logged to Logger when the first request is served. Either way, requests to
+// the routes it names get a 500.
+func (r *Router) Validate() error {
+	r.tables.mu.Lock()
+	defer r.tables.mu.Unlock()
+	return r.validate()
+}
+
+// validate is Validate for a caller that holds the route tables' lock.
+func (r *Router) validate() error {
+	var problems []string
+	routers := []*Router{getRootRouter(r)}
+	for len(routers) > 0 {
+		var router *Router
+		router, routers = routers[0], routers[1:]
+		for _, route := range router.routes {
+			if problem := route.unenforced(); problem != "" {
+				problems = append(problems, fmt.Sprintf("%s %s %s", route.method, route.path, problem))
+			}
+		}
+		routers = append(routers, router.children...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("web: %s", strings.Join(problems, "; "))
+}
+
+// logInvalid logs the error from Validate, if any. It's called when the router serves its first request, with
+// the route tables' lock held.
+func (r *Router) logInvalid() {
+	if err := r.validate(); err != nil {
+		Logger.Printf("[web] %v\n", err)
+	}
+}
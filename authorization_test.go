@@ -0,0 +1,114 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/http"
+	"testing"
+)
+
+type authContext struct {
+	Scopes map[string]bool
+}
+
+func (c *authContext) Authenticate(w ResponseWriter, r *Request, next NextMiddlewareFunc) {
+	if token := r.Header.Get("X-Scopes"); token != "" {
+		c.Scopes = map[string]bool{token: true}
+	}
+	next(w, r)
+}
+
+func (c *authContext) Action(w ResponseWriter, r *Request) {
+	w.Write([]byte("ok"))
+}
+
+var scopeAuthorizer = AuthorizerFunc(func(ctx interface{}, req *Request, required AccessRequirements) error {
+	c := ctx.(*authContext)
+	if c.Scopes == nil {
+		return ErrUnauthenticated
+	}
+	for _, scope := range required.Scopes {
+		if !c.Scopes[scope] {
+			return errors.New("missing scope " + scope)
+		}
+	}
+	return nil
+})
+
+func TestRequireScopes(t *testing.T) {
+	router := New(authContext{}).Authorizer(scopeAuthorizer)
+	router.Middleware((*authContext).Authenticate)
+	router.Get("/orders", (*authContext).Action).RequireScopes("orders:read")
+	router.Get("/public", (*authContext).Action)
+
+	rw, req := newTestRequest("GET", "/orders")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unauthorized", http.StatusUnauthorized)
+
+	rw, req = newTestRequest("GET", "/orders")
+	req.Header.Set("X-Scopes", "orders:write")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	rw, req = newTestRequest("GET", "/orders")
+	req.Header.Set("X-Scopes", "orders:read")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "ok", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/public")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "ok", http.StatusOK)
+}
+
+func TestRequireRoleSubrouterAuthorizer(t *testing.T) {
+	var gotCtx interface{}
+	var gotRequired AccessRequirements
+
+	router := New(Context{})
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Authorizer(AuthorizerFunc(func(ctx interface{}, req *Request, required AccessRequirements) error {
+		gotCtx, gotRequired = ctx, required
+		return nil
+	}))
+	admin.Get("/users", (*AdminContext).B).RequireRole("admin").RequireRole("auditor")
+
+	rw, req := newTestRequest("GET", "/admin/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "admin-B", http.StatusOK)
+	_, ok := gotCtx.(*AdminContext)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"admin", "auditor"}, gotRequired.Roles)
+}
+
+func TestRequirementsWithoutAuthorizer(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *log.Logger) { Logger = l }(Logger)
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{})
+	router.Get("/action", (*Context).A).RequireRole("admin")
+	router.Post("/signup", (*Context).A).RequireChallenge()
+	router.Get("/public", (*Context).A)
+
+	err := router.Validate()
+	if assert.Error(t, err) {
+		assert.Equal(t, "web: GET /action declares access requirements but no Authorizer is set; POST /signup requires a challenge but no Challenge is set", err.Error())
+	}
+
+	// Only the unprotected routes fail, and the problem is logged once rather than per request.
+	for i := 0; i < 2; i++ {
+		rw, req := newTestRequest("GET", "/action")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Application Error", http.StatusInternalServerError)
+		rw, req = newTestRequest("GET", "/public")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "context-A", 200)
+	}
+	assert.Equal(t, "[web] "+err.Error()+"\n", buf.String())
+
+	router.Authorizer(AuthorizerFunc(func(ctx interface{}, req *Request, required AccessRequirements) error { return nil }))
+	router.Challenge(&ProofOfWorkChallenge{Key: []byte("k")}, nil)
+	assert.NoError(t, router.Validate())
+}
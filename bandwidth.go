@@ -0,0 +1,154 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BandwidthLimit configures BandwidthMiddleware.
+type BandwidthLimit struct {
+	// BytesPerSecond caps how fast response bodies are written.
+	BytesPerSecond int64
+
+	// Key, if set, makes concurrent responses with the same key share one limit, eg ClientIP to cap each client's
+	// total download rate. Otherwise every response is limited on its own.
+	Key func(*Request) string
+}
+
+// BandwidthMiddleware returns middleware that slows down writes to the response body so they don't exceed
+// limit. Use it on a router, or on single routes with Route.Use, eg for large file downloads:
+//
+//	router.Get("/exports/:id", (*Context).Export).Use(web.BandwidthMiddleware(web.BandwidthLimit{
+//		BytesPerSecond: 1 << 20,
+//		Key:            web.ClientIP,
+//	}))
+//
+// Writes stop waiting, and fail, when the client disconnects.
+func BandwidthMiddleware(limit BandwidthLimit) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if limit.BytesPerSecond <= 0 {
+		panic("web: BandwidthLimit.BytesPerSecond must be positive")
+	}
+	shared := &bandwidthBuckets{buckets: make(map[string]*bandwidthBucket)}
+
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		var bucket *bandwidthBucket
+		if limit.Key != nil {
+			key := limit.Key(req)
+			bucket = shared.acquire(key, limit.BytesPerSecond)
+			defer shared.release(key)
+		} else {
+			bucket = &bandwidthBucket{rate: limit.BytesPerSecond}
+		}
+		next(&bandwidthWriter{ResponseWriter: rw, ctx: req.Context(), bucket: bucket}, req)
+	}
+}
+
+// bandwidthBucket schedules writes so that they don't exceed rate bytes per second.
+type bandwidthBucket struct {
+	mu   sync.Mutex
+	rate int64
+	free time.Time // when the writes scheduled so far will have finished
+	refs int       // responses using the bucket; see bandwidthBuckets
+}
+
+// reserve schedules a write of n bytes and returns how long to wait before doing it.
+func (b *bandwidthBucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.free.Before(now) {
+		b.free = now
+	}
+	wait := b.free.Sub(now)
+	b.free = b.free.Add(time.Duration(int64(n) * int64(time.Second) / b.rate))
+	return wait
+}
+
+// chunk returns how many bytes to write at once: a tenth of a second's worth.
+func (b *bandwidthBucket) chunk() int {
+	if n := b.rate / 10; n > 1 {
+		return int(n)
+	}
+	return 1
+}
+
+// bandwidthBuckets holds the buckets of the keys that have responses in flight.
+type bandwidthBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*bandwidthBucket
+}
+
+func (bs *bandwidthBuckets) acquire(key string, rate int64) *bandwidthBucket {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bucket, ok := bs.buckets[key]
+	if !ok {
+		bucket = &bandwidthBucket{rate: rate}
+		bs.buckets[key] = bucket
+	}
+	bucket.refs++
+	return bucket
+}
+
+func (bs *bandwidthBuckets) release(key string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bucket := bs.buckets[key]; bucket != nil {
+		bucket.refs--
+		if bucket.refs == 0 {
+			delete(bs.buckets, key)
+		}
+	}
+}
+
+// bandwidthWriter is a ResponseWriter that writes its body at the pace of bucket.
+type bandwidthWriter struct {
+	ResponseWriter
+	ctx    context.Context
+	bucket *bandwidthBucket
+}
+
+// Unwrap returns the wrapped ResponseWriter, so BeforeWrite and SetCookie reach it.
+func (w *bandwidthWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bandwidthWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		n := w.bucket.chunk()
+		if n > len(data) {
+			n = len(data)
+		}
+		if err := bandwidthSleep(w.ctx, w.bucket.reserve(n)); err != nil {
+			return written, err
+		}
+		m, err := w.ResponseWriter.Write(data[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		data = data[n:]
+	}
+	return written, nil
+}
+
+// bandwidthSleep waits for d, or until ctx is done. Tests replace it.
+var bandwidthSleep = func(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBandwidthMiddleware(t *testing.T) {
+	var waited time.Duration
+	defer func(old func(context.Context, time.Duration) error) { bandwidthSleep = old }(bandwidthSleep)
+	bandwidthSleep = func(ctx context.Context, d time.Duration) error {
+		waited += d
+		return nil
+	}
+
+	router := New(Context{})
+	router.Get("/download", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(strings.Repeat("x", 300)))
+	}).Use(BandwidthMiddleware(BandwidthLimit{BytesPerSecond: 1000}))
+
+	rw, req := newTestRequest("GET", "/download")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, strings.Repeat("x", 300), 200)
+	// 3 chunks of 100 bytes: the 2nd waits for 100ms, the 3rd for 200ms.
+	assert.True(t, waited > 290*time.Millisecond && waited <= 300*time.Millisecond, "waited %v", waited)
+}
+
+func TestBandwidthBuckets(t *testing.T) {
+	buckets := &bandwidthBuckets{buckets: make(map[string]*bandwidthBucket)}
+	a := buckets.acquire("a", 1000)
+	assert.True(t, a == buckets.acquire("a", 1000))
+	assert.Equal(t, time.Duration(0), a.reserve(500))
+	assert.True(t, a.reserve(100) > 490*time.Millisecond)
+
+	buckets.release("a")
+	assert.Equal(t, 1, len(buckets.buckets))
+	buckets.release("a")
+	assert.Equal(t, 0, len(buckets.buckets))
+
+	assert.Panics(t, func() { BandwidthMiddleware(BandwidthLimit{}) })
+}
+
+func TestBandwidthDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bucket := &bandwidthBucket{rate: 10}
+	bucket.reserve(100)
+	w := &bandwidthWriter{ctx: ctx, bucket: bucket}
+	n, err := w.Write([]byte("data"))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+)
+
+// BasicAuthValidator checks a username and password, and returns the principal they belong to (eg a *User, or
+// just the username) and true, or false if they're wrong. It should compare them in constant time, eg with
+// subtle.ConstantTimeCompare, as BasicAuthUsers does.
+type BasicAuthValidator func(username, password string) (principal interface{}, ok bool)
+
+type basicAuthContextKey struct{}
+
+// BasicAuthMiddleware returns middleware that requires HTTP Basic authentication, eg for internal tools and
+// metrics endpoints. Requests without valid credentials get a 401 with DefaultUnauthorizedResponse and a
+// WWW-Authenticate challenge for realm, which makes browsers ask for a username and password:
+//
+//	admin.Middleware(web.BasicAuthMiddleware("admin", web.BasicAuthUsers(map[string]string{"ops": opsPassword})))
+//
+// The principal is available as Request.BasicAuthPrincipal. Basic authentication sends the password with every
+// request, so only use it over HTTPS.
+func BasicAuthMiddleware(realm string, validator BasicAuthValidator) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if username, password, ok := req.BasicAuth(); ok {
+			if principal, ok := validator(username, password); ok {
+				req.SetContext(context.WithValue(req.Context(), basicAuthContextKey{}, principal))
+				next(rw, req)
+				return
+			}
+		}
+		rw.Header().Set("WWW-Authenticate", challenge)
+		renderError(rw, req, http.StatusUnauthorized, DefaultUnauthorizedResponse)
+	}
+}
+
+// BasicAuthPrincipal returns the principal BasicAuthMiddleware authenticated the request as, or nil.
+func (r *Request) BasicAuthPrincipal() interface{} {
+	return r.Context().Value(basicAuthContextKey{})
+}
+
+// BasicAuthUsers returns a BasicAuthValidator accepting the usernames and passwords in users, whose principal is
+// the username. Credentials are compared in constant time, so response times don't reveal how much of them was
+// right.
+func BasicAuthUsers(users map[string]string) BasicAuthValidator {
+	hashed := make(map[[sha256.Size]byte][sha256.Size]byte, len(users))
+	for username, password := range users {
+		hashed[sha256.Sum256([]byte(username))] = sha256.Sum256([]byte(password))
+	}
+	return func(username, password string) (interface{}, bool) {
+		// Hashing first makes the comparison independent of the lengths, and of which users exist.
+		usernameHash, passwordHash := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+		ok := 0
+		for u, p := range hashed {
+			ok |= subtle.ConstantTimeCompare(u[:], usernameHash[:]) & subtle.ConstantTimeCompare(p[:], passwordHash[:])
+		}
+		if ok != 1 {
+			return nil, false
+		}
+		return username, true
+	}
+}
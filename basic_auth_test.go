@@ -0,0 +1,33 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(BasicAuthMiddleware("internal tools", BasicAuthUsers(map[string]string{"ops": "s3cret"})))
+	router.Get("/metrics", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("hello " + req.BasicAuthPrincipal().(string)))
+	})
+
+	rw, req := newTestRequest("GET", "/metrics")
+	req.SetBasicAuth("ops", "s3cret")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "hello ops", 200)
+	assert.Equal(t, "", rw.Header().Get("WWW-Authenticate"))
+
+	for _, creds := range [][2]string{{"ops", "wrong"}, {"root", "s3cret"}, {"", ""}} {
+		rw, req = newTestRequest("GET", "/metrics")
+		req.SetBasicAuth(creds[0], creds[1])
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Unauthorized", 401)
+		assert.Equal(t, `Basic realm="internal tools", charset="UTF-8"`, rw.Header().Get("WWW-Authenticate"))
+	}
+
+	rw, req = newTestRequest("GET", "/metrics")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unauthorized", 401)
+}
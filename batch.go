@@ -0,0 +1,158 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// BatchOptions configures BatchHandler.
+type BatchOptions struct {
+	// MaxRequests caps how many sub-requests a batch may have. Defaults to 20.
+	MaxRequests int
+
+	// SharedHeaders are copied from the batch request to every sub-request that doesn't set them, so that
+	// sub-requests are authenticated like the batch. Defaults to Authorization and Cookie.
+	SharedHeaders []string
+
+	// MaxBodySize caps the size of the batch request's body; larger batches get a 413. Defaults to 1MB.
+	MaxBodySize int64
+}
+
+// BatchRequest is a sub-request of a batch.
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"` // with the query, eg "/users/1?fields=name"
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"` // sent as is, with a JSON Content-Type unless Headers has one
+}
+
+// BatchResponse is the response to a sub-request of a batch.
+type BatchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"` // as is if it's JSON, and as a JSON string otherwise
+}
+
+// DefaultBadBatchResponse is the default text rendered when BatchHandler gets a malformed batch.
+var DefaultBadBatchResponse = "Bad Request"
+
+// batchContextKey marks the context of sub-requests, so a batch can't contain another batch.
+type batchContextKey struct{}
+
+// BatchHandler returns a handler that runs a batch of requests, to save clients round trips. The body of a
+// batch is a JSON array of BatchRequests, eg:
+//
+//	[{"method": "GET", "path": "/users/1"}, {"method": "POST", "path": "/users/1/follow", "body": {"notify": true}}]
+//
+// and the response is a JSON array of the BatchResponses, in the same order:
+//
+//	[{"status": 200, "headers": {"Content-Type": "application/json"}, "body": {"id": 1}}, {"status": 204}]
+//
+// Sub-requests run one after the other through the whole router (middleware, routing and handlers) that the
+// batch route belongs to, with the batch request's context, remote address and SharedHeaders. Register it like
+// any handler:
+//
+//	router.Post("/batch", web.BatchHandler(web.BatchOptions{}))
+func BatchHandler(opts BatchOptions) func(ResponseWriter, *Request) {
+	if opts.MaxRequests <= 0 {
+		opts.MaxRequests = 20
+	}
+	if opts.SharedHeaders == nil {
+		opts.SharedHeaders = []string{"Authorization", "Cookie"}
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+
+	return func(rw ResponseWriter, req *Request) {
+		if req.Context().Value(batchContextKey{}) != nil {
+			renderError(rw, req, http.StatusBadRequest, DefaultBadBatchResponse)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, opts.MaxBodySize+1))
+		if err != nil {
+			renderError(rw, req, http.StatusBadRequest, DefaultBadBatchResponse)
+			return
+		}
+		if int64(len(body)) > opts.MaxBodySize {
+			renderError(rw, req, http.StatusRequestEntityTooLarge, DefaultBodyTooLargeResponse)
+			return
+		}
+		var batch []BatchRequest
+		if json.Unmarshal(body, &batch) != nil || len(batch) > opts.MaxRequests {
+			renderError(rw, req, http.StatusBadRequest, DefaultBadBatchResponse)
+			return
+		}
+
+		rootRouter := getRootRouter(req.route.router)
+		ctx := context.WithValue(req.Context(), batchContextKey{}, true)
+		responses := make([]BatchResponse, len(batch))
+		for i, sub := range batch {
+			responses[i] = rootRouter.runBatchRequest(ctx, req, sub, opts.SharedHeaders)
+		}
+
+		encoded, err := json.Marshal(responses)
+		if err != nil {
+			panic(err)
+		}
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.Write(encoded)
+	}
+}
+
+// runBatchRequest dispatches sub, a sub-request of batch, through rootRouter.
+func (rootRouter *Router) runBatchRequest(ctx context.Context, batch *Request, sub BatchRequest, sharedHeaders []string) BatchResponse {
+	var body io.Reader
+	if len(sub.Body) > 0 {
+		body = bytes.NewReader(sub.Body)
+	}
+	if sub.Method == "" {
+		return BatchResponse{Status: http.StatusBadRequest, Body: batchBody([]byte("the sub-request needs a method"))}
+	}
+	r, err := newDispatchRequest(ctx, sub.Method, sub.Path, body)
+	if err != nil {
+		return BatchResponse{Status: http.StatusBadRequest, Body: batchBody([]byte(err.Error()))}
+	}
+	r.Host = batch.Host
+	r.RemoteAddr = batch.RemoteAddr
+	r.TLS = batch.TLS
+	for k, v := range sub.Headers {
+		r.Header.Set(k, v)
+	}
+	for _, k := range sharedHeaders {
+		if r.Header.Get(k) == "" {
+			if v := batch.Header.Values(k); len(v) > 0 {
+				r.Header[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+	}
+	if len(sub.Body) > 0 && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	dispatched := rootRouter.dispatch(r)
+	resp := BatchResponse{Status: dispatched.StatusCode}
+	if len(dispatched.Header) > 0 {
+		resp.Headers = make(map[string]string, len(dispatched.Header))
+		for k := range dispatched.Header {
+			resp.Headers[k] = dispatched.Header.Get(k)
+		}
+	}
+	if len(dispatched.Body) > 0 {
+		resp.Body = batchBody(dispatched.Body)
+	}
+	return resp
+}
+
+// batchBody returns body as is if it's JSON, and as a JSON string otherwise.
+func batchBody(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return json.RawMessage(append([]byte(nil), body...))
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
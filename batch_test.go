@@ -0,0 +1,94 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchHandler(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(rw, req)
+	})
+	router.Post("/batch", BatchHandler(BatchOptions{MaxRequests: 3}))
+	router.Get("/users/:id", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(rw, `{"id":%s,"fields":%q}`, req.PathParams["id"], req.URL.Query().Get("fields"))
+	})
+	router.Post("/echo", func(rw ResponseWriter, req *Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		fmt.Fprintf(rw, "%s %s", req.Header.Get("Content-Type"), body)
+	})
+
+	batch := func(body string) *httptest.ResponseRecorder {
+		rw, req := newTestRequest("POST", "/batch")
+		req.Body = ioutil.NopCloser(strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := batch(`[
+		{"method": "GET", "path": "/users/1?fields=name"},
+		{"method": "POST", "path": "/echo", "body": {"a": 1}},
+		{"method": "GET", "path": "/missing"}
+	]`)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rw.Header().Get("Content-Type"))
+	var responses []BatchResponse
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &responses))
+	assert.Equal(t, 3, len(responses))
+	assert.Equal(t, http.StatusOK, responses[0].Status)
+	assert.Equal(t, "application/json", responses[0].Headers["Content-Type"])
+	assert.Equal(t, `{"id":1,"fields":"name"}`, string(responses[0].Body))
+	assert.Equal(t, http.StatusOK, responses[1].Status)
+	assert.Equal(t, `"application/json {\"a\": 1}"`, string(responses[1].Body))
+	assert.Equal(t, http.StatusNotFound, responses[2].Status)
+
+	// Sub-requests can't be absolute URLs, nor batches themselves.
+	rw = batch(`[{"method": "GET", "path": "http://example.com/users/1"}, {"method": "POST", "path": "/batch", "body": []}]`)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	responses = nil
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &responses))
+	assert.Equal(t, http.StatusBadRequest, responses[0].Status)
+	assert.Equal(t, http.StatusBadRequest, responses[1].Status)
+
+	// Malformed and oversized batches.
+	rw = batch(`{"method": "GET"}`)
+	assertResponse(t, rw, "Bad Request", http.StatusBadRequest)
+	rw = batch(`[{}, {}, {}, {}]`)
+	assertResponse(t, rw, "Bad Request", http.StatusBadRequest)
+}
+
+func TestBatchHandlerSharedHeaders(t *testing.T) {
+	router := New(Context{})
+	router.Post("/batch", BatchHandler(BatchOptions{SharedHeaders: []string{"X-Tenant"}}))
+	router.Get("/whoami", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "%s|%s", req.Header.Get("X-Tenant"), req.Header.Get("Authorization"))
+	})
+
+	rw, req := newTestRequest("POST", "/batch")
+	req.Body = ioutil.NopCloser(strings.NewReader(`[
+		{"method": "GET", "path": "/whoami"},
+		{"method": "GET", "path": "/whoami", "headers": {"X-Tenant": "other"}}
+	]`))
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(rw, req)
+
+	var responses []BatchResponse
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &responses))
+	assert.Equal(t, `"acme|"`, string(responses[0].Body))
+	assert.Equal(t, `"other|"`, string(responses[1].Body))
+}
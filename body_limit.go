@@ -0,0 +1,85 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// MaxBodySize caps the size of the request bodies of the router's routes, and returns the router. The nearest
+// router's MaxBodySize applies, and Route.MaxBodySize overrides it, eg for uploads:
+//
+//	router.MaxBodySize(1 << 20)
+//	router.Post("/photos", (*Context).Upload).MaxBodySize(50 << 20)
+//
+// Requests whose Content-Length is over the limit get a 413 with DefaultBodyTooLargeResponse before any of the
+// route's middleware runs. Other bodies fail to read past the limit, with an *http.MaxBytesError, like
+// http.MaxBytesReader's. Handlers that then panic with the error, or don't respond at all, get the 413 too.
+func (r *Router) MaxBodySize(n int64) *Router {
+	r.maxBodySize = n
+	r.tables.settingsChanged()
+	return r
+}
+
+// MaxBodySize overrides the router's MaxBodySize for this route, and returns the route. A negative n turns it
+// off.
+func (r *Route) MaxBodySize(n int64) *Route {
+	r.checkConfigurable()
+	r.maxBodySize = n
+	r.router.tables.settingsChanged()
+	return r
+}
+
+// maxBodySizeFor returns the body size limit of route's requests, or 0 if they have none.
+func maxBodySizeFor(route *Route) int64 {
+	n := route.maxBodySize
+	for i := len(route.router.chain) - 1; n == 0 && i >= 0; i-- {
+		n = route.router.chain[i].maxBodySize
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// limitedBody is a request body cut off at a MaxBodySize.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if isBodyTooLarge(err) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitBody caps req's body at n bytes. It responds with a 413 and returns false if the body is known to be
+// larger.
+func limitBody(rw ResponseWriter, req *Request, n int64) bool {
+	if req.ContentLength > n {
+		renderError(rw, req, http.StatusRequestEntityTooLarge, DefaultBodyTooLargeResponse)
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &limitedBody{ReadCloser: http.MaxBytesReader(rw, req.Body, n)}
+	}
+	return true
+}
+
+// bodyTooLarge returns true if the request's body was cut off at its MaxBodySize.
+func (r *Request) bodyTooLarge() bool {
+	b, ok := r.Body.(*limitedBody)
+	return ok && b.exceeded
+}
+
+func isBodyTooLarge(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var tooLarge *http.MaxBytesError
+	return errors.As(e, &tooLarge)
+}
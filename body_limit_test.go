@@ -0,0 +1,52 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	router := New(Context{}).MaxBodySize(5)
+	router.Post("/echo", func(rw ResponseWriter, req *Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			panic(err)
+		}
+		rw.Write(body)
+	})
+	router.Post("/ignore", func(rw ResponseWriter, req *Request) {
+		ioutil.ReadAll(req.Body)
+	})
+	router.Post("/upload", func(rw ResponseWriter, req *Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.Write(body)
+	}).MaxBodySize(-1)
+
+	post := func(path, body string, knownLength bool) *httpResponse {
+		rw, req := newTestRequest("POST", path)
+		req.Body = ioutil.NopCloser(strings.NewReader(body))
+		if knownLength {
+			req.ContentLength = int64(len(body))
+		}
+		router.ServeHTTP(rw, req)
+		return &httpResponse{rw.Code, rw.Header(), strings.TrimSpace(rw.Body.String())}
+	}
+
+	resp := post("/echo", "small", true)
+	assert.Equal(t, http.StatusOK, resp.code)
+	assert.Equal(t, "small", resp.body)
+	resp = post("/echo", "much too large", true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.code)
+	assert.Equal(t, "Request Entity Too Large", resp.body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/echo", "much too large", false).code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/ignore", "much too large", false).code)
+	assert.Equal(t, "much too large", post("/upload", "much too large", true).body)
+
+	// Routes resolve their limit once, but pick up changes made after they were served.
+	router.MaxBodySize(100)
+	assert.Equal(t, "much too large", post("/echo", "much too large", true).body)
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"io"
+	"net/http"
+)
+
+// OnBodyProgress calls fn as the request body is read, with the number of bytes read so far and the size of the
+// body (its Content-Length, or -1 if the client didn't send one). fn is called by whatever reads the body, eg
+// ParseMultipartForm or an io.Copy in a handler, after each read that returns data. When the end of the body is
+// reached, fn is called with total set to read, so uploads of unknown length report completion too.
+//
+// fn may be called often for large uploads, so throttle anything expensive, like saving progress to a database.
+func (r *Request) OnBodyProgress(fn func(read, total int64)) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	r.Body = &progressReader{ReadCloser: r.Body, total: r.ContentLength, fn: fn}
+}
+
+// progressReader reports reads from a request body to fn.
+type progressReader struct {
+	io.ReadCloser
+	read  int64
+	total int64
+	done  bool
+	fn    func(read, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		if err != io.EOF {
+			p.fn(p.read, p.total)
+		}
+	}
+	if err == io.EOF && !p.done {
+		p.done = true
+		p.fn(p.read, p.read)
+	}
+	return n, err
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnBodyProgress(t *testing.T) {
+	router := New(Context{})
+	var progress [][2]int64
+	router.Post("/upload", func(rw ResponseWriter, req *Request) {
+		req.OnBodyProgress(func(read, total int64) {
+			progress = append(progress, [2]int64{read, total})
+		})
+		buf := make([]byte, 4)
+		for {
+			if _, err := req.Body.Read(buf); err == io.EOF {
+				break
+			}
+		}
+		req.Body.Read(buf)
+	})
+
+	rw, req := newTestRequest("POST", "/upload")
+	req.Body = ioutil.NopCloser(strings.NewReader("0123456789"))
+	req.ContentLength = 10
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, [][2]int64{{4, 10}, {8, 10}, {10, 10}, {10, 10}}, progress)
+
+	progress = nil
+	rw, req = newTestRequest("POST", "/upload")
+	req.Body = ioutil.NopCloser(strings.NewReader("0123"))
+	req.ContentLength = -1
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, [][2]int64{{4, -1}, {4, 4}}, progress)
+}
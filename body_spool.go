@@ -0,0 +1,130 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// SpoolMemoryLimit is how much of a spooled request body is kept in memory. The rest is written to a temporary
+// file, which is removed once the request is done. See Request.SpoolBody.
+var SpoolMemoryLimit int64 = 1 << 20
+
+// ErrBodyTooLarge is returned by Request.SpoolBody for bodies larger than the limit.
+var ErrBodyTooLarge = errors.New("web: request body is too large")
+
+// BodySpool is a request body that was read once and kept, so that it can be read again. See Request.SpoolBody.
+type BodySpool struct {
+	mem  []byte
+	file *os.File
+	size int64
+	err  error // why the body couldn't be spooled, eg ErrBodyTooLarge; only part of it was kept
+}
+
+// SpoolBody reads the request's body, up to limit bytes, and keeps it so that middleware and handlers can read it
+// as many times as they need, eg to replay a request, mirror it to a shadow backend, or hash it for an
+// idempotency key. The body is read from the client only once per request: later calls return the same
+// BodySpool. Every call rewinds the request's Body to the beginning.
+//
+// The first SpoolMemoryLimit bytes are kept in memory, and the rest in a temporary file that's removed once
+// ServeHTTP returns. SpoolBody returns ErrBodyTooLarge if the body is larger than limit; the request's Body then
+// still reads the whole body, but later calls return ErrBodyTooLarge whatever their limit.
+func (r *Request) SpoolBody(limit int64) (*BodySpool, error) {
+	if r.more != nil && r.more.bodySpool != nil {
+		s := r.more.bodySpool
+		if s.err != nil {
+			return nil, s.err
+		}
+		r.Body = s.Open()
+		if s.size > limit {
+			return nil, ErrBodyTooLarge
+		}
+		return s, nil
+	}
+
+	s := &BodySpool{}
+	r.extras().bodySpool = s
+	if r.Body == nil || r.Body == http.NoBody {
+		return s, nil
+	}
+	src := r.Body
+	if s.err = s.fill(src, limit); s.err != nil {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(s.Open(), src), src}
+		return nil, s.err
+	}
+	src.Close()
+	r.Body = s.Open()
+	return s, nil
+}
+
+// fill reads src into the spool until EOF, or until more than limit bytes were read.
+func (s *BodySpool) fill(src io.Reader, limit int64) error {
+	n, err := io.Copy(spoolWriter{s}, io.LimitReader(src, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return ErrBodyTooLarge
+	}
+	return nil
+}
+
+// Size returns the size of the body.
+func (s *BodySpool) Size() int64 {
+	return s.size
+}
+
+// Open returns a reader for the whole body, independent of any other.
+func (s *BodySpool) Open() io.ReadCloser {
+	var r io.Reader = bytes.NewReader(s.mem)
+	if s.file != nil {
+		r = io.MultiReader(r, io.NewSectionReader(s.file, 0, s.size-int64(len(s.mem))))
+	}
+	return io.NopCloser(r)
+}
+
+// close removes the spool's temporary file, if any.
+func (s *BodySpool) close() {
+	if s != nil && s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+}
+
+// spoolWriter appends to a BodySpool, in memory up to SpoolMemoryLimit and then to a temporary file.
+type spoolWriter struct {
+	s *BodySpool
+}
+
+func (w spoolWriter) Write(p []byte) (int, error) {
+	s := w.s
+	written := 0
+	if room := SpoolMemoryLimit - int64(len(s.mem)); s.file == nil && room > 0 {
+		n := len(p)
+		if int64(n) > room {
+			n = int(room)
+		}
+		s.mem = append(s.mem, p[:n]...)
+		s.size += int64(n)
+		written, p = n, p[n:]
+	}
+	if len(p) == 0 {
+		return written, nil
+	}
+	if s.file == nil {
+		f, err := os.CreateTemp("", "web-body-*")
+		if err != nil {
+			return written, err
+		}
+		s.file = f
+	}
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	return written + n, err
+}
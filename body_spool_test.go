@@ -0,0 +1,76 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolBody(t *testing.T) {
+	oldLimit := SpoolMemoryLimit
+	SpoolMemoryLimit = 4
+	defer func() {
+		SpoolMemoryLimit = oldLimit
+	}()
+
+	var tempFile string
+	router := New(Context{})
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		spool, err := req.SpoolBody(100)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(11), spool.Size())
+		if spool.file != nil {
+			tempFile = spool.file.Name()
+		}
+		shadow, _ := ioutil.ReadAll(spool.Open())
+		assert.Equal(t, "hello world", string(shadow))
+		next(rw, req)
+	})
+	router.Post("/echo", func(rw ResponseWriter, req *Request) {
+		first, _ := ioutil.ReadAll(req.Body)
+		spool, err := req.SpoolBody(20)
+		assert.NoError(t, err)
+		second, _ := ioutil.ReadAll(req.Body)
+		third, _ := ioutil.ReadAll(spool.Open())
+		rw.Write([]byte(string(first) + "|" + string(second) + "|" + string(third)))
+	})
+
+	rw, req := newTestRequest("POST", "/echo")
+	req.Body = ioutil.NopCloser(strings.NewReader("hello world"))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "hello world|hello world|hello world", http.StatusOK)
+
+	assert.NotEqual(t, "", tempFile)
+	_, err := os.Stat(tempFile)
+	assert.True(t, os.IsNotExist(err), "the temporary file is removed")
+}
+
+func TestSpoolBodyTooLarge(t *testing.T) {
+	router := New(Context{})
+	router.Post("/upload", func(rw ResponseWriter, req *Request) {
+		_, err := req.SpoolBody(5)
+		assert.Equal(t, ErrBodyTooLarge, err)
+		_, err = req.SpoolBody(100)
+		assert.Equal(t, ErrBodyTooLarge, err)
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.Write(body)
+	})
+	router.Post("/empty", func(rw ResponseWriter, req *Request) {
+		spool, err := req.SpoolBody(5)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), spool.Size())
+	})
+
+	rw, req := newTestRequest("POST", "/upload")
+	req.Body = ioutil.NopCloser(strings.NewReader("hello world"))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "hello world", http.StatusOK)
+
+	rw, req = newTestRequest("POST", "/empty")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CaseInsensitivePaths turns case-insensitive matching of the literal segments of route paths on or off (the
+// default) and returns the router. When it's on, /Users/42 is routed to /users/:id if no route matches it exactly;
+// path params keep the request's case. Note that only the root router can configure case-insensitive paths.
+func (r *Router) CaseInsensitivePaths(enabled bool) *Router {
+	if r.parent != nil {
+		panic("You can only configure case-insensitive paths on the root router.")
+	}
+	r.caseInsensitivePaths = enabled
+	if !enabled {
+		r.canonicalCaseRedirects = false
+	}
+	return r
+}
+
+// CanonicalCaseRedirects turns redirects to the case of route paths on or off (the default) and returns the
+// router. When it's on, paths match case-insensitively (see CaseInsensitivePaths), and requests whose path
+// doesn't have the case of its route's path are redirected to it, eg /Users/42 to /users/42: with a 301 for GET
+// and HEAD requests, and a 308 for others. Note that only the root router can configure canonical case redirects.
+func (r *Router) CanonicalCaseRedirects(enabled bool) *Router {
+	if r.parent != nil {
+		panic("You can only configure canonical case redirects on the root router.")
+	}
+	r.canonicalCaseRedirects = enabled
+	if enabled {
+		r.caseInsensitivePaths = true
+	}
+	return r
+}
+
+// matchPath matches segments in tree like pathNode.match, then ignoring case if the router has case-insensitive
+// paths.
+func (rootRouter *Router) matchPath(tree *pathNode, segments []string, host string) (*pathLeaf, map[string]string) {
+	leaf, wildcardMap := tree.match(segments, nil, host)
+	if leaf == nil && rootRouter.caseInsensitivePaths {
+		leaf, wildcardMap = tree.matchFold(segments, nil, host)
+	}
+	return leaf, wildcardMap
+}
+
+// matchedRouteSegments returns the segments of the path routePath stands for (see expandOptional) that matched
+// segments, without a trailing catch-all, which keeps the request's case. It returns false if there's none.
+func matchedRouteSegments(segments []string, routePath string) ([]string, bool) {
+	routeSegments, ok := matchedRoutePath(segments, routePath)
+	if n := len(routeSegments); n > 0 {
+		if ca, _ := isCatchAll(routeSegments[n-1]); ca {
+			routeSegments = routeSegments[:n-1]
+		}
+	}
+	return routeSegments, ok
+}
+
+// matchedRoutePath is like matchedRouteSegments, but keeps a trailing catch-all.
+func matchedRoutePath(segments []string, routePath string) ([]string, bool) {
+	paths, err := expandOptional(routePath)
+	if err != nil {
+		return nil, false
+	}
+	for _, p := range paths {
+		routeSegments := splitPath(p)
+		n := len(routeSegments)
+		if n > 0 {
+			if ca, _ := isCatchAll(routeSegments[n-1]); ca && len(segments) >= n-1 {
+				return routeSegments, true
+			}
+		}
+		if len(segments) == n {
+			return routeSegments, true
+		}
+	}
+	return nil, false
+}
+
+// fixCase returns path, an escaped request path matched by routePath, with the segments that only match literal
+// segments of routePath ignoring case replaced by those literals. It returns false if no segment was replaced.
+func fixCase(path string, routePath string) (string, bool) {
+	segments := splitPath(path)
+	routeSegments, ok := matchedRouteSegments(segments, routePath)
+	if !ok {
+		return path, false
+	}
+
+	changed := false
+	for i, routeSeg := range routeSegments {
+		if wc, _, _ := isWildcard(routeSeg); wc {
+			continue
+		}
+		if decoded, err := url.PathUnescape(segments[i]); err == nil && decoded != routeSeg {
+			segments[i] = url.PathEscape(routeSeg)
+			changed = true
+		}
+	}
+	if !changed {
+		return path, false
+	}
+
+	fixed := "/" + strings.Join(segments, "/")
+	if len(segments) > 0 && strings.HasSuffix(path, "/") {
+		fixed += "/"
+	}
+	return fixed, true
+}
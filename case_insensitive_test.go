@@ -0,0 +1,69 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	router := New(Context{})
+	router.Get("/users/:name", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "user %s", req.PathParams["name"])
+	})
+	router.Get("/Users/admin", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/USERS/bob")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	router.CaseInsensitivePaths(true)
+
+	rw, req = newTestRequest("GET", "/USERS/Bob")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "user Bob", http.StatusOK)
+
+	// Exact matches win.
+	rw, req = newTestRequest("GET", "/users/admin")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "user admin", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/Users/admin")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	rw, req = newTestRequest("POST", "/USERS/bob")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+	assert.Panics(t, func() {
+		router.Subrouter(Context{}, "/admin").CaseInsensitivePaths(true)
+	})
+}
+
+func TestCanonicalCaseRedirects(t *testing.T) {
+	router := New(Context{}).CanonicalCaseRedirects(true).TrailingSlash(TrailingSlashRedirect)
+	router.Get("/users/:name", (*Context).A)
+	router.Post("/Café/orders", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/Users/Bob?tab=posts")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusMovedPermanently)
+	assert.Equal(t, "/users/Bob?tab=posts", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("GET", "/USERS/bob/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusMovedPermanently)
+	assert.Equal(t, "/users/bob", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("POST", "/caf%C3%A9/Orders")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusPermanentRedirect)
+	assert.Equal(t, "/Caf%C3%A9/orders", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("GET", "/users/Bob")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+}
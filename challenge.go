@@ -0,0 +1,208 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Challenge is a test a client must pass (eg, a captcha or a proof of work) before a risky request is served.
+type Challenge interface {
+	// Verify reports whether req carries a solution to a challenge.
+	Verify(req *Request) bool
+	// Issue responds with a new challenge instead of invoking the handler.
+	Issue(rw ResponseWriter, req *Request)
+}
+
+// RiskSignal decides whether a request is risky enough to be challenged, eg based on its IP's reputation or rate.
+type RiskSignal func(req *Request) bool
+
+type routerChallenge struct {
+	challenge Challenge
+	risky     RiskSignal
+}
+
+// Challenge sets the Challenge for routes on this router and its subrouters that call RequireChallenge,
+// and returns the router. Requests to those routes for which risky returns true must solve the challenge.
+// If risky is nil, every request is challenged.
+func (r *Router) Challenge(c Challenge, risky RiskSignal) *Router {
+	r.challenge = &routerChallenge{challenge: c, risky: risky}
+	return r
+}
+
+// RequireChallenge marks the route as protected by the nearest Challenge set on its router or its parents.
+// Like access requirements, challenges are checked after all middleware, right before the handler, and a route
+// without a Challenge to check is reported by Router.Validate.
+func (r *Route) RequireChallenge() *Route {
+	r.checkConfigurable()
+	r.challenged = true
+	return r
+}
+
+// checkChallenge issues a challenge and returns false if the routed request must solve one but didn't.
+func (closure *middlewareClosure) checkChallenge(rw ResponseWriter, req *Request) bool {
+	for i := len(closure.Routers) - 1; i >= 0; i-- {
+		rc := closure.Routers[i].challenge
+		if rc == nil {
+			continue
+		}
+		if (rc.risky != nil && !rc.risky(req)) || rc.challenge.Verify(req) {
+			return true
+		}
+		rc.challenge.Issue(rw, req)
+		return false
+	}
+	// Like authorize, fail closed: Validate reports the route.
+	renderError(rw, req, http.StatusInternalServerError, DefaultPanicResponse)
+	return false
+}
+
+// ProofOfWorkChallenge makes clients spend CPU before their request is served. Issue responds with 403 and
+//
+//	{"challenge": "<challenge>", "difficulty": <bits>}
+//
+// The client must find a counter such that SHA-256("<challenge>:<counter>") starts with difficulty zero bits,
+// and retry with the header "X-Challenge-Solution: <challenge>:<counter>". Challenges are signed with Key, and
+// expire after TTL. Each can only be solved once: solved challenges are remembered in Used until they expire.
+type ProofOfWorkChallenge struct {
+	Key        []byte
+	Difficulty int           // Leading zero bits. Defaults to 20.
+	TTL        time.Duration // Defaults to 5 minutes.
+
+	// Used remembers the solved challenges. Defaults to a MemoryNonceStore; use a shared store if several servers
+	// verify the challenges.
+	Used NonceStore
+
+	defaultUsed sync.Once
+}
+
+// ChallengeSolutionHeader carries the solution to a ProofOfWorkChallenge.
+const ChallengeSolutionHeader = "X-Challenge-Solution"
+
+func (c *ProofOfWorkChallenge) difficulty() int {
+	if c.Difficulty == 0 {
+		return 20
+	}
+	return c.Difficulty
+}
+
+// Issue implements Challenge.
+func (c *ProofOfWorkChallenge) Issue(rw ResponseWriter, req *Request) {
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	payload := hex.EncodeToString(nonce) + "." + strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	challenge := payload + "." + c.sign(payload)
+
+	body, _ := json.Marshal(map[string]interface{}{"challenge": challenge, "difficulty": c.difficulty()})
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(http.StatusForbidden)
+	rw.Write(body)
+}
+
+// Verify implements Challenge.
+func (c *ProofOfWorkChallenge) Verify(req *Request) bool {
+	solution := req.Header.Get(ChallengeSolutionHeader)
+	sep := strings.LastIndex(solution, ":")
+	if sep < 0 {
+		return false
+	}
+	challenge := solution[:sep]
+
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[2]), []byte(c.sign(parts[0]+"."+parts[1]))) {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(solution))
+	if leadingZeroBits(sum[:]) < c.difficulty() {
+		return false
+	}
+	c.defaultUsed.Do(func() {
+		if c.Used == nil {
+			c.Used = NewMemoryNonceStore()
+		}
+	})
+	// The challenge is remembered a little past its expiry, since the expiry has a resolution of seconds.
+	fresh, err := c.Used.Use("challenge:"+parts[0], time.Until(time.Unix(expires, 0))+2*time.Second)
+	return err == nil && fresh
+}
+
+func (c *ProofOfWorkChallenge) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
+
+// CaptchaChallenge verifies captcha tokens with a provider's "siteverify" endpoint, which reCAPTCHA, hCaptcha and
+// Turnstile all implement: the secret and the token are POSTed as a form, and the reply is {"success": true|false}.
+type CaptchaChallenge struct {
+	VerifyURL string // Eg, "https://challenges.cloudflare.com/turnstile/v0/siteverify".
+	Secret    string
+
+	// TokenField is the form field holding the client's token, eg "cf-turnstile-response".
+	TokenField string
+
+	// Page is the HTML rendered (with a 403) when a challenge is issued. It should embed the provider's widget
+	// and resubmit the request with the token.
+	Page string
+
+	Client *http.Client // http.DefaultClient if nil.
+}
+
+// Issue implements Challenge.
+func (c *CaptchaChallenge) Issue(rw ResponseWriter, req *Request) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusForbidden)
+	rw.Write([]byte(c.Page))
+}
+
+// Verify implements Challenge. Errors talking to the provider fail the challenge.
+func (c *CaptchaChallenge) Verify(req *Request) bool {
+	token := req.FormValue(c.TokenField)
+	if token == "" {
+		return false
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.PostForm(c.VerifyURL, url.Values{"secret": {c.Secret}, "response": {token}})
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	return json.NewDecoder(resp.Body).Decode(&result) == nil && result.Success
+}
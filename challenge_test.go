@@ -0,0 +1,98 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func solveProofOfWork(challenge string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		solution := fmt.Sprintf("%s:%d", challenge, counter)
+		if sum := sha256Sum(solution); leadingZeroBits(sum) >= difficulty {
+			return solution
+		}
+	}
+}
+
+func TestProofOfWorkChallenge(t *testing.T) {
+	pow := &ProofOfWorkChallenge{Key: []byte("secret"), Difficulty: 8}
+	router := New(Context{})
+	router.Challenge(pow, func(req *Request) bool {
+		return req.Header.Get("X-Risky") != ""
+	})
+	router.Post("/signup", (*Context).A).RequireChallenge()
+	router.Post("/other", (*Context).A)
+
+	rw, req := newTestRequest("POST", "/signup")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	rw, req = newTestRequest("POST", "/other")
+	req.Header.Set("X-Risky", "1")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	rw, req = newTestRequest("POST", "/signup")
+	req.Header.Set("X-Risky", "1")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	var issued struct {
+		Challenge  string `json:"challenge"`
+		Difficulty int    `json:"difficulty"`
+	}
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &issued))
+	assert.Equal(t, 8, issued.Difficulty)
+
+	solution := solveProofOfWork(issued.Challenge, issued.Difficulty)
+	rw, req = newTestRequest("POST", "/signup")
+	req.Header.Set("X-Risky", "1")
+	req.Header.Set(ChallengeSolutionHeader, solution)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	// A solution only works once.
+	rw, req = newTestRequest("POST", "/signup")
+	req.Header.Set("X-Risky", "1")
+	req.Header.Set(ChallengeSolutionHeader, solution)
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	// Forged challenges are rejected.
+	forged := "00." + strings.SplitN(issued.Challenge, ".", 2)[1]
+	rw, req = newTestRequest("POST", "/signup")
+	req.Header.Set("X-Risky", "1")
+	req.Header.Set(ChallengeSolutionHeader, solveProofOfWork(forged, 8))
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+}
+
+func TestCaptchaChallenge(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		success := r.FormValue("secret") == "s3cret" && r.FormValue("response") == "good-token"
+		fmt.Fprintf(w, `{"success": %v}`, success)
+	}))
+	defer provider.Close()
+
+	router := New(Context{})
+	router.Challenge(&CaptchaChallenge{VerifyURL: provider.URL, Secret: "s3cret", TokenField: "captcha", Page: "<html>solve me</html>"}, nil)
+	router.Post("/signup", (*Context).A).RequireChallenge()
+
+	rw, req := newTestRequest("POST", "/signup?captcha=bad-token")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "<html>solve me</html>", http.StatusForbidden)
+
+	rw, req = newTestRequest("POST", "/signup?captcha=good-token")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+}
+
+func sha256Sum(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
@@ -0,0 +1,186 @@
+// Command webroutes generates route registration code from annotations next to handlers. Annotate a handler
+// function or method with one or more //web:route comments:
+//
+//	//web:route GET /users/:id name=user_show
+//	func (c *Context) ShowUser(rw web.ResponseWriter, req *web.Request) { ... }
+//
+// and add a go:generate directive to the package:
+//
+//	//go:generate webroutes
+//
+// webroutes writes web_routes_gen.go, which defines
+//
+//	func registerRoutes(router *web.Router)
+//
+// registering every annotated handler, sorted by file and line. Call it from where the router is built.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const annotation = "//web:route "
+
+var methods = map[string]string{
+	"GET":     "Get",
+	"POST":    "Post",
+	"PUT":     "Put",
+	"DELETE":  "Delete",
+	"PATCH":   "Patch",
+	"HEAD":    "Head",
+	"OPTIONS": "Options",
+}
+
+type route struct {
+	pos     token.Position
+	method  string
+	path    string
+	name    string
+	handler string
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package to scan")
+	out := flag.String("out", "web_routes_gen.go", "file to write, relative to -dir")
+	funcName := flag.String("func", "registerRoutes", "name of the generated function")
+	flag.Parse()
+
+	src, err := generate(*dir, *out, *funcName)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(*dir, *out), src, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webroutes:", err)
+		os.Exit(1)
+	}
+}
+
+// generate scans the package in dir, skipping tests and the output file, and returns the generated source.
+func generate(dir, out, funcName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != out
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkgName string
+	var routes []route
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, file := range pkg.Files {
+			fileRoutes, err := scanFile(fset, file)
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, fileRoutes...)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].pos.Filename != routes[j].pos.Filename {
+			return routes[i].pos.Filename < routes[j].pos.Filename
+		}
+		return routes[i].pos.Line < routes[j].pos.Line
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by webroutes. DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+	if pkgName != "web" {
+		b.WriteString("import \"github.com/gocraft/web\"\n\n")
+		fmt.Fprintf(&b, "func %s(router *web.Router) {\n", funcName)
+	} else {
+		fmt.Fprintf(&b, "func %s(router *Router) {\n", funcName)
+	}
+	for _, r := range routes {
+		fmt.Fprintf(&b, "\trouter.%s(%q, %s)", methods[r.method], r.path, r.handler)
+		if r.name != "" {
+			fmt.Fprintf(&b, ".Named(%q)", r.name)
+		}
+		fmt.Fprintf(&b, " // %s:%d\n", filepath.Base(r.pos.Filename), r.pos.Line)
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// scanFile returns the routes annotated on the functions and methods of file.
+func scanFile(fset *token.FileSet, file *ast.File) ([]route, error) {
+	var routes []route
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Doc == nil {
+			continue
+		}
+		for _, comment := range fn.Doc.List {
+			if !strings.HasPrefix(comment.Text, annotation) {
+				continue
+			}
+			pos := fset.Position(comment.Pos())
+			r, err := parseAnnotation(strings.TrimPrefix(comment.Text, annotation))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pos, err)
+			}
+			r.pos = pos
+			r.handler, err = handlerExpr(fn)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pos, err)
+			}
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+// parseAnnotation parses "GET /users/:id name=user_show".
+func parseAnnotation(text string) (route, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return route{}, fmt.Errorf("expected //web:route METHOD PATH [name=NAME], got %q", annotation+text)
+	}
+	r := route{method: strings.ToUpper(fields[0]), path: fields[1]}
+	if _, ok := methods[r.method]; !ok {
+		return route{}, fmt.Errorf("unsupported method %s", fields[0])
+	}
+	if !strings.HasPrefix(r.path, "/") {
+		return route{}, fmt.Errorf("path %s must start with '/'", r.path)
+	}
+	for _, option := range fields[2:] {
+		switch {
+		case strings.HasPrefix(option, "name="):
+			r.name = strings.TrimPrefix(option, "name=")
+		default:
+			return route{}, fmt.Errorf("unknown option %s", option)
+		}
+	}
+	return r, nil
+}
+
+// handlerExpr returns the expression referring to fn: Handler for functions, (*Context).Handler for methods.
+func handlerExpr(fn *ast.FuncDecl) (string, error) {
+	if fn.Recv == nil {
+		return fn.Name.Name, nil
+	}
+	star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return "", fmt.Errorf("%s must have a pointer receiver to be a handler", fn.Name.Name)
+	}
+	ident, ok := star.X.(*ast.Ident)
+	if !ok {
+		return "", fmt.Errorf("unsupported receiver type for %s", fn.Name.Name)
+	}
+	return fmt.Sprintf("(*%s).%s", ident.Name, fn.Name.Name), nil
+}
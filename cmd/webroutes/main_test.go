@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePackage(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "webroutes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := writePackage(t, map[string]string{
+		"users.go": `package app
+
+import "github.com/gocraft/web"
+
+type Context struct{}
+
+//web:route GET /users/:id name=user_show
+//web:route HEAD /users/:id
+func (c *Context) ShowUser(rw web.ResponseWriter, req *web.Request) {}
+
+// Health reports liveness.
+//web:route get /health
+func Health(rw web.ResponseWriter, req *web.Request) {}
+
+func helper() {}
+`,
+		"users_test.go": `package app
+
+//web:route GET /test
+func testHandler() {}
+`,
+		"web_routes_gen.go": `package app
+
+this file is stale and must be ignored
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	src, err := generate(dir, "web_routes_gen.go", "registerRoutes")
+	assert.NoError(t, err)
+	assert.Equal(t, `// Code generated by webroutes. DO NOT EDIT.
+
+package app
+
+import "github.com/gocraft/web"
+
+func registerRoutes(router *web.Router) {
+	router.Get("/users/:id", (*Context).ShowUser).Named("user_show") // users.go:7
+	router.Head("/users/:id", (*Context).ShowUser)                   // users.go:8
+	router.Get("/health", Health)                                    // users.go:12
+}
+`, string(src))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for src, expected := range map[string]string{
+		"//web:route GET\nfunc A() {}":                           "expected //web:route METHOD PATH",
+		"//web:route TRACE /a\nfunc A() {}":                      "unsupported method TRACE",
+		"//web:route GET a\nfunc A() {}":                         "must start with '/'",
+		"//web:route GET /a cache=1\nfunc A() {}":                "unknown option cache=1",
+		"type C struct{}\n//web:route GET /a\nfunc (c C) A() {}": "must have a pointer receiver",
+	} {
+		dir := writePackage(t, map[string]string{"a.go": "package app\n\n" + src + "\n"})
+		_, err := generate(dir, "web_routes_gen.go", "registerRoutes")
+		if assert.Error(t, err, src) {
+			assert.True(t, strings.Contains(err.Error(), expected), err.Error())
+			assert.True(t, strings.Contains(err.Error(), "a.go:"), err.Error())
+		}
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,252 @@
+package web
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Encoder is a content coding CompressMiddleware can compress responses with.
+type Encoder struct {
+	// Name is the coding's name in Accept-Encoding and Content-Encoding headers, eg "gzip" or "br".
+	Name string
+	// NewWriter returns a writer compressing to w. If it has a Flush() error method, streamed responses are
+	// flushed through it.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoder compresses with gzip at the default level.
+var GzipEncoder = Encoder{Name: "gzip", NewWriter: func(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}}
+
+// DeflateEncoder compresses with deflate (zlib) at the default level.
+var DeflateEncoder = Encoder{Name: "deflate", NewWriter: func(w io.Writer) io.WriteCloser {
+	return zlib.NewWriter(w)
+}}
+
+// DefaultIncompressibleTypes are the media types CompressMiddleware leaves alone by default, because they are
+// compressed already.
+var DefaultIncompressibleTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd", "application/x-7z-compressed",
+}
+
+// CompressOptions configures CompressMiddleware.
+type CompressOptions struct {
+	// Encoders are the codings to compress with, in order of preference when a client accepts several equally.
+	// Defaults to GzipEncoder and DeflateEncoder. The standard library has no brotli or zstd, but encoders from
+	// other packages can be added, eg Encoder{Name: "br", NewWriter: ...}.
+	Encoders []Encoder
+
+	// MinSize is the size under which responses aren't compressed, as it's not worth it. Defaults to 1024 bytes.
+	// Responses that are flushed before reaching it are compressed anyway.
+	MinSize int
+
+	// IncompressibleTypes are media types, or "type/*" ranges, that aren't compressed. Defaults to
+	// DefaultIncompressibleTypes.
+	IncompressibleTypes []string
+}
+
+// CompressMiddleware returns middleware that compresses response bodies with the coding the client prefers in its
+// Accept-Encoding header. Responses are left alone if they are small (see MinSize), have an incompressible
+// Content-Type, already have a Content-Encoding, or are partial (206) content. Responses that are flushed, eg
+// server-sent events, are compressed and flushed as they are written. Strong ETags are made weak on compressed
+// responses, since the bytes sent differ from those the ETag describes.
+func CompressMiddleware(opts CompressOptions) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if opts.Encoders == nil {
+		opts.Encoders = []Encoder{GzipEncoder, DeflateEncoder}
+	}
+	if opts.MinSize == 0 {
+		opts.MinSize = 1024
+	}
+	if opts.IncompressibleTypes == nil {
+		opts.IncompressibleTypes = DefaultIncompressibleTypes
+	}
+
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		rw.Header().Add("Vary", "Accept-Encoding")
+		encoder, ok := negotiateEncoding(req.Header.Get("Accept-Encoding"), opts.Encoders)
+		if !ok || req.Method == "HEAD" {
+			next(rw, req)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: rw, encoder: encoder, opts: &opts}
+		done := false
+		defer func() {
+			// On a panic, the buffered body is dropped so that the error response can be written instead.
+			if !done && cw.enc != nil {
+				cw.enc.Close()
+			}
+		}()
+		next(cw, req)
+		done = true
+		cw.finish()
+	}
+}
+
+// negotiateEncoding picks the encoder with the highest quality in accept, an Accept-Encoding header.
+func negotiateEncoding(accept string, encoders []Encoder) (Encoder, bool) {
+	var best Encoder
+	bestQ := 0.0
+	for _, encoder := range encoders {
+		q, wildcardQ := -1.0, -1.0
+		for _, part := range strings.Split(accept, ",") {
+			coding, quality := parseMediaRange(part)
+			switch coding {
+			case strings.ToLower(encoder.Name):
+				q = quality
+			case "*":
+				wildcardQ = quality
+			}
+		}
+		if q < 0 {
+			q = wildcardQ
+		}
+		if q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// compressWriter is the ResponseWriter of CompressMiddleware. It holds the header and body back until it knows
+// whether to compress: when the body reaches MinSize, when it's flushed, or when the handler is done.
+type compressWriter struct {
+	ResponseWriter
+	encoder Encoder
+	opts    *CompressOptions
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser // nil unless the body is compressed
+}
+
+// Unwrap returns the wrapped ResponseWriter. BeforeWrite callbacks registered through it run when the held back
+// header is finally written.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+	if !bodyAllowedForStatus(status) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.buf = append(w.buf, data...)
+		if len(w.buf) >= w.opts.MinSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(data), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(true)
+	}
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) StatusCode() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.StatusCode()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.StatusCode() != 0
+}
+
+// finish sends whatever is held back and ends the compressed stream, once the handler is done.
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if w.status == 0 {
+			return
+		}
+		w.decide(len(w.buf) >= w.opts.MinSize)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
+
+// decide sends the header, compressing the body if worth is true and the response allows it, and then the body
+// held back so far.
+func (w *compressWriter) decide(worth bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// net/http would otherwise sniff the compressed bytes.
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if worth && w.compressible(header) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoder.Name)
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.enc = w.encoder.NewWriter(writerOnly{w.ResponseWriter})
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) compressible(header http.Header) bool {
+	if w.status == http.StatusPartialContent || header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return true
+	}
+	for _, skipped := range w.opts.IncompressibleTypes {
+		if mediaType == skipped || (strings.HasSuffix(skipped, "/*") && strings.HasPrefix(mediaType, skipped[:len(skipped)-1])) {
+			return false
+		}
+	}
+	return true
+}
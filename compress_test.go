@@ -0,0 +1,141 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	large := strings.Repeat("hello compression ", 100)
+	router := New(Context{})
+	router.Middleware(CompressMiddleware(CompressOptions{}))
+	router.Get("/large", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rw.Header().Set("ETag", `"v1"`)
+		rw.Header().Set("Content-Length", "1800")
+		rw.Write([]byte(large[:900]))
+		rw.Write([]byte(large[900:]))
+	})
+	router.Get("/small", func(rw ResponseWriter, req *Request) {
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("tiny"))
+	})
+	router.Get("/image", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Type", "image/png")
+		rw.Write([]byte(large))
+	})
+	router.Get("/encoded", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Encoding", "br")
+		rw.Write([]byte(large))
+	})
+	router.Get("/sniffed", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("<html>" + large))
+	})
+	router.Get("/empty", func(rw ResponseWriter, req *Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		rw, req := newTestRequest("GET", path)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := get("/large", "gzip, deflate")
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "gzip", rw.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rw.Header().Get("Vary"))
+	assert.Equal(t, `W/"v1"`, rw.Header().Get("ETag"))
+	assert.Equal(t, "", rw.Header().Get("Content-Length"))
+	gz, err := gzip.NewReader(rw.Body)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(gz)
+	assert.Equal(t, large, string(body))
+
+	rw = get("/large", "gzip;q=0.5, deflate")
+	assert.Equal(t, "deflate", rw.Header().Get("Content-Encoding"))
+	zr, err := zlib.NewReader(rw.Body)
+	assert.NoError(t, err)
+	body, _ = ioutil.ReadAll(zr)
+	assert.Equal(t, large, string(body))
+
+	rw = get("/large", "*")
+	assert.Equal(t, "gzip", rw.Header().Get("Content-Encoding"))
+
+	for _, tt := range []struct {
+		path, acceptEncoding string
+		status               int
+	}{
+		{"/large", "", http.StatusOK},
+		{"/large", "identity", http.StatusOK},
+		{"/large", "gzip;q=0, deflate;q=0", http.StatusOK},
+		{"/small", "gzip", http.StatusCreated},
+		{"/image", "gzip", http.StatusOK},
+		{"/encoded", "gzip", http.StatusOK},
+		{"/empty", "gzip", http.StatusNoContent},
+	} {
+		rw = get(tt.path, tt.acceptEncoding)
+		assert.Equal(t, tt.status, rw.Code, tt.path)
+		assert.NotEqual(t, "gzip", rw.Header().Get("Content-Encoding"), tt.path)
+		assert.NotEqual(t, "deflate", rw.Header().Get("Content-Encoding"), tt.path)
+	}
+	assert.Equal(t, "tiny", get("/small", "gzip").Body.String())
+
+	rw = get("/sniffed", "gzip")
+	assert.Equal(t, "gzip", rw.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+}
+
+func TestCompressMiddlewareStreaming(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(CompressMiddleware(CompressOptions{}))
+	var flushed []byte
+	router.Get("/events", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("data: 1\n\n"))
+		rw.Flush()
+		flushed = append(flushed, rw.(*compressWriter).ResponseWriter.(*appResponseWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.Bytes()...)
+		rw.Write([]byte("data: 2\n\n"))
+	})
+
+	rw, req := newTestRequest("GET", "/events")
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "gzip", rw.Header().Get("Content-Encoding"))
+
+	// The first event can be decoded from what was flushed, before the handler is done.
+	gz, err := gzip.NewReader(bytes.NewReader(flushed))
+	assert.NoError(t, err)
+	first := make([]byte, 9)
+	_, err = gz.Read(first)
+	assert.NoError(t, err)
+	assert.Equal(t, "data: 1\n\n", string(first))
+
+	gz, err = gzip.NewReader(rw.Body)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(gz)
+	assert.Equal(t, "data: 1\n\ndata: 2\n\n", string(body))
+}
+
+func TestCompressMiddlewarePanic(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(CompressMiddleware(CompressOptions{}))
+	router.Get("/panic", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	rw, req := newTestRequest("GET", "/panic")
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", http.StatusInternalServerError)
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"regexp"
+	"sync"
+)
+
+// paramConstraints holds the named constraints of path params. See RegisterParamConstraint.
+var paramConstraints = struct {
+	sync.RWMutex
+	byName map[string]func(string) bool
+}{byName: map[string]func(string) bool{
+	"int":   isIntParam,
+	"alpha": isAlphaParam,
+	"alnum": isAlnumParam,
+	"uuid":  isUUIDParam,
+}}
+
+// RegisterParamConstraint makes name usable as the constraint of path params, like the built-in ones: int
+// (an optional minus sign and digits), alpha (ASCII letters), alnum (ASCII letters and digits) and uuid (a
+// UUID in its canonical, hyphenated form):
+//
+//	web.RegisterParamConstraint("sku", func(param string) bool { return skuFormat.Valid(param) })
+//	router.Get("/products/:sku:sku", (*Context).Product)
+//
+// A named constraint is checked with fn rather than a regexp, and names win over regexps that are spelled the
+// same. Register constraints at startup, before adding routes that use them. Registering a name twice panics.
+func RegisterParamConstraint(name string, fn func(param string) bool) {
+	paramConstraints.Lock()
+	defer paramConstraints.Unlock()
+
+	if _, ok := paramConstraints.byName[name]; ok {
+		panic("web: the param constraint " + name + " is already registered")
+	}
+	paramConstraints.byName[name] = fn
+}
+
+// paramConstraint constrains a path param with a named constraint or a regexp.
+type paramConstraint struct {
+	source string            // as written in the route's path, eg "int" or "\d+"
+	fn     func(string) bool // set for named constraints
+	re     *regexp.Regexp    // set otherwise
+}
+
+// parseParamConstraint returns the constraint source stands for, or nil if source is empty.
+func parseParamConstraint(source string) (*paramConstraint, error) {
+	if source == "" {
+		return nil, nil
+	}
+	paramConstraints.RLock()
+	fn := paramConstraints.byName[source]
+	paramConstraints.RUnlock()
+	if fn != nil {
+		return &paramConstraint{source: source, fn: fn}, nil
+	}
+
+	re, err := compileRegexpErr(source)
+	if err != nil {
+		return nil, err
+	}
+	return &paramConstraint{source: source, re: re}, nil
+}
+
+func (c *paramConstraint) match(param string) bool {
+	if c.fn != nil {
+		return c.fn(param)
+	}
+	return len(param) <= MaxRegexpParamLength && c.re.MatchString(param)
+}
+
+func isIntParam(param string) bool {
+	if len(param) > 0 && param[0] == '-' {
+		param = param[1:]
+	}
+	if param == "" {
+		return false
+	}
+	for i := 0; i < len(param); i++ {
+		if param[i] < '0' || param[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphaParam(param string) bool {
+	if param == "" {
+		return false
+	}
+	for i := 0; i < len(param); i++ {
+		if c := param[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnumParam(param string) bool {
+	if param == "" {
+		return false
+	}
+	for i := 0; i < len(param); i++ {
+		if c := param[i]; !(c >= '0' && c <= '9') && !isAlphaParam(param[i:i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUIDParam(param string) bool {
+	if len(param) != 36 {
+		return false
+	}
+	for i := 0; i < len(param); i++ {
+		c := param[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9') && !(c|0x20 >= 'a' && c|0x20 <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParamConstraints(t *testing.T) {
+	RegisterParamConstraint("lower", func(param string) bool { return param == strings.ToLower(param) })
+	defer func() {
+		paramConstraints.Lock()
+		delete(paramConstraints.byName, "lower")
+		paramConstraints.Unlock()
+	}()
+
+	router := New(Context{})
+	handler := func(kind string) func(ResponseWriter, *Request) {
+		return func(rw ResponseWriter, req *Request) {
+			fmt.Fprintf(rw, "%s %s", kind, req.PathParams["p"])
+		}
+	}
+	router.Get("/a/:p:int", handler("int")).Named("int")
+	router.Get("/a/:p:uuid", handler("uuid"))
+	router.Get("/a/:p:alpha", handler("alpha"))
+	router.Get("/a/:p:alnum", handler("alnum"))
+	router.Get("/b/:p:lower", handler("lower"))
+
+	cases := []struct{ path, body string }{
+		{"/a/42", "int 42"},
+		{"/a/-7", "int -7"},
+		{"/a/3f2504e0-4f89-11d3-9a0c-0305e82c3301", "uuid 3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		{"/a/Hello", "alpha Hello"},
+		{"/a/abc123", "alnum abc123"},
+		{"/b/quiet", "lower quiet"},
+		{"/a/-", "Not Found"},
+		{"/a/3f2504e0-4f89-11d3-9a0c-0305e82c330z", "Not Found"},
+		{"/a/a_b", "Not Found"},
+		{"/b/Loud", "Not Found"},
+	}
+	for _, c := range cases {
+		rw, req := newTestRequest("GET", c.path)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, c.body, strings.TrimSpace(rw.Body.String()), c.path)
+	}
+
+	_, err := router.MappedUrlFor("int", nil, "x")
+	assert.Error(t, err)
+	url, err := router.MappedUrlFor("int", nil, "5")
+	assert.NoError(t, err)
+	assert.Equal(t, "/a/5", url)
+
+	// A route with the same named constraint is unreachable, like one with the same regexp.
+	routeErr := routeErrorFrom(func() { router.Get("/a/:q:int", handler("again")) })
+	if assert.NotNil(t, routeErr) {
+		assert.Contains(t, routeErr.Reason, "unreachable")
+	}
+
+	assert.Panics(t, func() { RegisterParamConstraint("int", isIntParam) })
+}
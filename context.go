@@ -0,0 +1,24 @@
+package web
+
+import (
+	"context"
+)
+
+// SetContext replaces the request's context.Context with ctx, eg to attach values or a deadline for code further
+// down the stack. Unlike http.Request.WithContext it changes r in place, so every middleware and handler that
+// runs afterwards - and any library they pass req.Context() to - sees ctx:
+//
+//	func (c *Context) Deadline(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+//		ctx, cancel := context.WithTimeout(req.Context(), 2*time.Second)
+//		defer cancel()
+//		req.SetContext(ctx)
+//		next(rw, req)
+//	}
+//
+// The context is cancelled when the client goes away, so long-running handlers can watch req.Context().Done().
+func (r *Request) SetContext(ctx context.Context) {
+	if more := r.extras(); more.connCtx == nil {
+		more.connCtx = r.Request.Context()
+	}
+	r.Request = r.Request.WithContext(ctx)
+}
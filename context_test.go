@@ -0,0 +1,51 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type contextKey string
+
+func TestRequestSetContext(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		req.SetContext(context.WithValue(req.Context(), contextKey("user"), "alice"))
+		next(rw, req)
+	})
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		ctx, cancel := context.WithTimeout(req.Context(), time.Minute)
+		defer cancel()
+		req.SetContext(ctx)
+		next(rw, req)
+	})
+	admin.Get("/whoami", func(rw ResponseWriter, req *Request) {
+		_, hasDeadline := req.Context().Deadline()
+		fmt.Fprintf(rw, "%v %v", req.Context().Value(contextKey("user")), hasDeadline)
+	})
+	admin.Get("/slow", func(rw ResponseWriter, req *Request) {
+		select {
+		case <-req.Context().Done():
+			fmt.Fprint(rw, req.Context().Err())
+		case <-time.After(time.Second):
+			fmt.Fprint(rw, "finished")
+		}
+	})
+
+	rw, req := newTestRequest("GET", "/admin/whoami")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "alice true", 200)
+
+	rw, req = newTestRequest("GET", "/admin/slow")
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	router.ServeHTTP(rw, req.WithContext(ctx))
+	assertResponse(t, rw, "context canceled", 200)
+
+	assert.Nil(t, req.Context().Value(contextKey("user")))
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookie describes a cookie to set with SetCookie. Unlike http.Cookie, its security attributes
+// default to safe values: cookies are Secure and HttpOnly, and SameSite is Lax, unless you opt out.
+type Cookie struct {
+	Name   string
+	Value  string
+	Path   string // The router's CookieDefaults.Path if empty.
+	Domain string // The router's CookieDefaults.Domain if empty.
+
+	Expires time.Time
+	MaxAge  int // See http.Cookie.
+
+	// SameSite is the router's CookieDefaults.SameSite if 0.
+	SameSite http.SameSite
+
+	// Insecure omits the Secure attribute. Cookies are also insecure if the router's CookieDefaults say so.
+	Insecure bool
+
+	// AllowScripts omits the HttpOnly attribute, so JavaScript can read the cookie.
+	AllowScripts bool
+}
+
+// CookieDefaults configures cookies set by SetCookie for requests routed to a router.
+type CookieDefaults struct {
+	Path     string        // "/" if empty.
+	Domain   string        // Host-only cookies if empty.
+	SameSite http.SameSite // http.SameSiteLaxMode if 0.
+
+	// Insecure omits the Secure attribute from all cookies, eg for development over plain HTTP.
+	Insecure bool
+}
+
+// ErrHeadersWritten is returned when a cookie is set after the response headers were already written.
+var ErrHeadersWritten = errors.New("web: response headers were already written")
+
+var defaultCookieDefaults = CookieDefaults{}
+
+// CookieDefaults sets the cookie defaults for this router and its subrouters, and returns the router.
+// Middleware that runs before routing uses the root router's defaults.
+func (r *Router) CookieDefaults(defaults CookieDefaults) *Router {
+	r.cookieDefaults = &defaults
+	r.tables.settingsChanged()
+	return r
+}
+
+// cookieDefaultsFor returns the cookie defaults of the nearest router in routers, starting from the last one.
+func cookieDefaultsFor(routers []*Router) *CookieDefaults {
+	for i := len(routers) - 1; i >= 0; i-- {
+		if routers[i].cookieDefaults != nil {
+			return routers[i].cookieDefaults
+		}
+	}
+	return &defaultCookieDefaults
+}
+
+// CookieWriter is implemented by the ResponseWriter the router passes to middleware and handlers, which knows the
+// router's CookieDefaults and SecureCookies. Call the SetCookie, DeleteCookie and SetSecureCookie functions rather
+// than asserting it, as they also find it behind wrappers.
+type CookieWriter interface {
+	// SetCookie adds a Set-Cookie header for c, applying the router's CookieDefaults. It returns an error if the
+	// cookie is invalid (eg, a __Host- cookie with a Domain) or if the headers were already written.
+	SetCookie(c Cookie) error
+	// DeleteCookie tells the client to delete the cookie name, set with the router's default Path and Domain.
+	DeleteCookie(name string) error
+	// SetSecureCookie is like SetCookie, but signs (and encrypts) c's value with the router's SecureCookies, so
+	// that it can be read back with Request.SecureCookie.
+	SetSecureCookie(c Cookie) error
+}
+
+// SetCookie calls SetCookie on the CookieWriter rw wraps, unwrapping it like BeforeWrite does. Without one, c
+// gets the default CookieDefaults.
+func SetCookie(rw http.ResponseWriter, c Cookie) error {
+	if cw, ok := findWriter[CookieWriter](rw); ok {
+		return cw.SetCookie(c)
+	}
+	cookie, err := c.httpCookie(&defaultCookieDefaults)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(rw, cookie)
+	return nil
+}
+
+// DeleteCookie calls DeleteCookie on the CookieWriter rw wraps, like SetCookie.
+func DeleteCookie(rw http.ResponseWriter, name string) error {
+	if cw, ok := findWriter[CookieWriter](rw); ok {
+		return cw.DeleteCookie(name)
+	}
+	return SetCookie(rw, Cookie{Name: name, MaxAge: -1, Expires: time.Unix(0, 0)})
+}
+
+func (w *appResponseWriter) SetCookie(c Cookie) error {
+	if w.statusCode != 0 {
+		return ErrHeadersWritten
+	}
+
+	cookie, err := c.httpCookie(w.settings().cookieDefaults)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+func (w *appResponseWriter) DeleteCookie(name string) error {
+	return w.SetCookie(Cookie{Name: name, MaxAge: -1, Expires: time.Unix(0, 0)})
+}
+
+// httpCookie applies defaults to c and validates the result.
+func (c Cookie) httpCookie(defaults *CookieDefaults) (*http.Cookie, error) {
+	if defaults == nil {
+		defaults = &defaultCookieDefaults
+	}
+
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Expires:  c.Expires,
+		MaxAge:   c.MaxAge,
+		SameSite: c.SameSite,
+		Secure:   !c.Insecure && !defaults.Insecure,
+		HttpOnly: !c.AllowScripts,
+	}
+	if cookie.Path == "" {
+		cookie.Path = defaults.Path
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.Domain == "" {
+		cookie.Domain = defaults.Domain
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = defaults.SameSite
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+
+	if err := cookie.Valid(); err != nil {
+		return nil, fmt.Errorf("web: invalid cookie %q: %v", c.Name, err)
+	}
+	if cookie.SameSite == http.SameSiteNoneMode && !cookie.Secure {
+		return nil, fmt.Errorf("web: cookie %q has SameSite=None, which browsers only accept on Secure cookies", c.Name)
+	}
+	if strings.HasPrefix(c.Name, "__Secure-") && !cookie.Secure {
+		return nil, fmt.Errorf("web: cookie %q has the __Secure- prefix, so it must be Secure", c.Name)
+	}
+	if strings.HasPrefix(c.Name, "__Host-") && (!cookie.Secure || cookie.Path != "/" || cookie.Domain != "") {
+		return nil, fmt.Errorf("web: cookie %q has the __Host- prefix, so it must be Secure, have Path=/ and no Domain", c.Name)
+	}
+	return cookie, nil
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSetCookieDefaults(t *testing.T) {
+	router := New(Context{})
+	router.Get("/login", func(w ResponseWriter, r *Request) {
+		assert.NoError(t, SetCookie(w, Cookie{Name: "session", Value: "abc"}))
+		assert.NoError(t, SetCookie(w, Cookie{Name: "prefs", Value: "dark", AllowScripts: true, SameSite: http.SameSiteStrictMode}))
+		w.WriteHeader(http.StatusOK)
+		assert.Equal(t, ErrHeadersWritten, SetCookie(w, Cookie{Name: "late", Value: "1"}))
+	})
+
+	rw, req := newTestRequest("GET", "/login")
+	router.ServeHTTP(rw, req)
+	cookies := rw.Header()["Set-Cookie"]
+	assert.Equal(t, []string{
+		"session=abc; Path=/; HttpOnly; Secure; SameSite=Lax",
+		"prefs=dark; Path=/; Secure; SameSite=Strict",
+	}, cookies)
+}
+
+func TestSetCookieRouterDefaults(t *testing.T) {
+	router := New(Context{}).CookieDefaults(CookieDefaults{Insecure: true})
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.CookieDefaults(CookieDefaults{Path: "/admin", SameSite: http.SameSiteStrictMode})
+	router.Get("/a", func(w ResponseWriter, r *Request) {
+		SetCookie(w, Cookie{Name: "a", Value: "1"})
+	})
+	admin.Get("/b", func(w ResponseWriter, r *Request) {
+		DeleteCookie(w, "b")
+	})
+
+	rw, req := newTestRequest("GET", "/a")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "a=1; Path=/; HttpOnly; SameSite=Lax", rw.Header().Get("Set-Cookie"))
+
+	rw, req = newTestRequest("GET", "/admin/b")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "b=; Path=/admin; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Max-Age=0; HttpOnly; Secure; SameSite=Strict", rw.Header().Get("Set-Cookie"))
+}
+
+func TestSetCookieValidation(t *testing.T) {
+	table := []Cookie{
+		{Name: "__Host-id", Value: "1", Domain: "example.com"},
+		{Name: "__Host-id", Value: "1", Path: "/admin"},
+		{Name: "__Host-id", Value: "1", Insecure: true},
+		{Name: "__Secure-id", Value: "1", Insecure: true},
+		{Name: "id", Value: "1", Insecure: true, SameSite: http.SameSiteNoneMode},
+		{Name: "bad name", Value: "1"},
+	}
+	for _, c := range table {
+		_, err := c.httpCookie(nil)
+		assert.Error(t, err, c.Name)
+	}
+
+	_, err := Cookie{Name: "__Host-id", Value: "1"}.httpCookie(nil)
+	assert.NoError(t, err)
+}
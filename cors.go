@@ -0,0 +1,191 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures cross-origin requests for a router, see Router.CORS.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins that may make requests, eg "https://app.example.com". "*" allows any
+	// origin, and "https://*.example.com" any subdomain of example.com. Origins are compared case-insensitively.
+	AllowedOrigins []string
+
+	// AllowOrigin, if set, is called for origins AllowedOrigins doesn't allow, and allows them if it returns true.
+	AllowOrigin func(origin string) bool
+
+	// AllowedMethods lists the methods preflights allow. Defaults to the methods that have a route for the path.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers preflights allow, besides the CORS-safelisted ones. Defaults to
+	// whatever headers the preflight asks for.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers scripts may read, besides the CORS-safelisted ones.
+	ExposedHeaders []string
+
+	// AllowCredentials lets requests include cookies and authorization headers. The Access-Control-Allow-Origin
+	// header then names the request's origin, as browsers require. Together with "*" in AllowedOrigins, that would
+	// let any site act on behalf of its visitors, so "*" then doesn't allow any origin by itself: CORS panics unless
+	// AllowOrigin is set, and AllowOrigin decides for the origins no other entry of AllowedOrigins allows.
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache preflight responses. Zero leaves it up to them.
+	MaxAge time.Duration
+}
+
+// CORS lets the routes of this router and its subrouters be requested from other origins, and returns the router.
+// Only the nearest configuration applies: a subrouter's CORS replaces its parent's.
+//
+// Preflight requests (OPTIONS requests with an Origin and an Access-Control-Request-Method header) are answered by
+// the router, with a 204, if the path has a route for the requested method whose router has a CORS configuration.
+// They go through the root router's middleware, but not through any subrouter's or route's. Preflights from
+// origins that aren't allowed, or asking for methods or headers that aren't allowed, get a 204 without CORS
+// headers, which browsers treat as a refusal.
+func (r *Router) CORS(opts CORSOptions) *Router {
+	if opts.AllowCredentials && containsString(opts.AllowedOrigins, "*") && opts.AllowOrigin == nil {
+		panic("web: CORS can't allow credentials from any origin (\"*\"); list the origins, or use AllowOrigin")
+	}
+	r.cors = &opts
+	r.tables.settingsChanged()
+	return r
+}
+
+// corsFor returns the CORS configuration of the nearest of routers, or nil.
+func corsFor(routers []*Router) *CORSOptions {
+	for i := len(routers) - 1; i >= 0; i-- {
+		if routers[i].cors != nil {
+			return routers[i].cors
+		}
+	}
+	return nil
+}
+
+// isPreflight returns true if req is a CORS preflight request.
+func isPreflight(req *Request) bool {
+	return req.Method == string(httpMethodOptions) && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// handlePreflight answers req, a preflight, if the route for the requested method has a CORS configuration. It
+// returns false, and writes nothing, otherwise.
+func (rootRouter *Router) handlePreflight(rw ResponseWriter, req *Request) bool {
+	method := req.Header.Get("Access-Control-Request-Method")
+	probe := *req.Request
+	probe.Method = method
+	route, _ := calculateRoute(rootRouter, &Request{Request: &probe})
+	if route == nil {
+		return false
+	}
+	cors := route.settings().cors
+	if cors == nil {
+		return false
+	}
+
+	header := rw.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	origin := req.Header.Get("Origin")
+	if !cors.allowsOrigin(origin) {
+		rw.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	var methods []string
+	if cors.AllowedMethods != nil {
+		methods = cors.AllowedMethods
+	} else if advertiseMethods(rootRouter, rw, req) {
+		methods = strings.Split(header.Get("Allow"), ", ")
+	}
+	requested := splitHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+	if !containsFold(methods, method) || (cors.AllowedHeaders != nil && !allContainedFold(cors.AllowedHeaders, requested)) {
+		rw.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	cors.setAllowOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if cors.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge/time.Second)))
+	}
+	rw.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// applyCORS sets the CORS headers of an actual (not preflight) request, once it's routed.
+func applyCORS(rw ResponseWriter, req *Request) {
+	cors := req.settings.cors
+	if cors == nil {
+		return
+	}
+	header := rw.Header()
+	header.Add("Vary", "Origin")
+	origin := req.Header.Get("Origin")
+	if origin == "" || !cors.allowsOrigin(origin) {
+		return
+	}
+	cors.setAllowOrigin(header, origin)
+	if len(cors.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+	}
+}
+
+func (c *CORSOptions) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			if !c.AllowCredentials {
+				return true
+			}
+			continue // AllowOrigin decides, see AllowCredentials
+		}
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if i := strings.Index(allowed, "://*."); i >= 0 {
+			scheme, domain := allowed[:i+3], allowed[i+4:]
+			if len(origin) > len(scheme)+len(domain) && strings.EqualFold(origin[:len(scheme)], scheme) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(domain)) {
+				return true
+			}
+		}
+	}
+	return c.AllowOrigin != nil && c.AllowOrigin(origin)
+}
+
+func (c *CORSOptions) setAllowOrigin(header http.Header, origin string) {
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+	} else if containsString(c.AllowedOrigins, "*") {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+}
+
+// splitHeaderList splits a comma separated header value, dropping empty elements.
+func splitHeaderList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// allContainedFold returns true if every element of subset is in set, ignoring case.
+func allContainedFold(set, subset []string) bool {
+	for _, s := range subset {
+		if !containsFold(set, s) {
+			return false
+		}
+	}
+	return true
+}
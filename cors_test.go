@@ -0,0 +1,134 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	router := New(Context{})
+	router.Get("/public", func(rw ResponseWriter, req *Request) {})
+	api := router.Subrouter(Context{}, "/api").CORS(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.partner.com"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	api.Get("/items/:id", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("item"))
+	})
+	api.Put("/items/:id", func(rw ResponseWriter, req *Request) {})
+	open := api.Subrouter(Context{}, "/open").CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}})
+	open.Get("/feed", func(rw ResponseWriter, req *Request) {})
+	open.Post("/feed", func(rw ResponseWriter, req *Request) {})
+
+	request := func(method, path, origin string, headers ...string) *httptest.ResponseRecorder {
+		rw, req := newTestRequest(method, path)
+		req.Header.Set("Origin", origin)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	// Preflights.
+	rw := request("OPTIONS", "/api/items/1", "https://app.example.com",
+		"Access-Control-Request-Method", "PUT", "Access-Control-Request-Headers", "content-type, authorization")
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, "https://app.example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rw.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, PUT, HEAD, OPTIONS", rw.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, authorization", rw.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rw.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rw.Header()["Vary"])
+
+	rw = request("OPTIONS", "/api/items/1", "https://shop.partner.com", "Access-Control-Request-Method", "GET")
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, "https://shop.partner.com", rw.Header().Get("Access-Control-Allow-Origin"))
+
+	for _, tt := range []struct {
+		path, origin, method, headers string
+	}{
+		{"/api/items/1", "https://evil.com", "GET", ""},
+		{"/api/items/1", "https://partner.com.evil.com", "GET", ""},
+		{"/api/items/1", "https://app.example.com", "GET", "X-Secret"},
+		{"/api/open/feed", "https://any.com", "POST", ""},
+	} {
+		rw = request("OPTIONS", tt.path, tt.origin, "Access-Control-Request-Method", tt.method, "Access-Control-Request-Headers", tt.headers)
+		assert.Equal(t, http.StatusNoContent, rw.Code, tt.origin)
+		assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"), tt.origin)
+	}
+
+	// Preflights for methods without a route, or for routes without CORS, are handled like other OPTIONS requests.
+	rw = request("OPTIONS", "/api/items/1", "https://app.example.com", "Access-Control-Request-Method", "DELETE")
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT, HEAD, OPTIONS", rw.Header().Get("Allow"))
+	rw = request("OPTIONS", "/public", "https://app.example.com", "Access-Control-Request-Method", "GET")
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"))
+
+	// Actual requests.
+	rw = request("GET", "/api/items/1", "https://app.example.com")
+	assertResponse(t, rw, "item", http.StatusOK)
+	assert.Equal(t, "https://app.example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Request-Id", rw.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Origin", rw.Header().Get("Vary"))
+
+	rw = request("GET", "/api/items/1", "https://evil.com")
+	assertResponse(t, rw, "item", http.StatusOK)
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"))
+
+	rw = request("GET", "/api/open/feed", "https://any.com")
+	assert.Equal(t, "*", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Credentials"))
+
+	rw = request("GET", "/public", "https://app.example.com")
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "", rw.Header().Get("Vary"))
+}
+
+func TestCORSCredentialsFromAnyOrigin(t *testing.T) {
+	assert.Panics(t, func() {
+		New(Context{}).CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	})
+	assert.NotPanics(t, func() {
+		New(Context{}).CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true, AllowOrigin: func(string) bool { return true }})
+	})
+
+	// With credentials, "*" leaves it to AllowOrigin.
+	router := New(Context{}).CORS(CORSOptions{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+		AllowOrigin:      func(origin string) bool { return origin == "https://app.example.com" },
+	})
+	router.Get("/items", func(rw ResponseWriter, req *Request) {})
+
+	request := func(method, origin string, headers ...string) *httptest.ResponseRecorder {
+		rw, req := newTestRequest(method, "/items")
+		req.Header.Set("Origin", origin)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := request("GET", "https://app.example.com")
+	assert.Equal(t, "https://app.example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rw.Header().Get("Access-Control-Allow-Credentials"))
+
+	rw = request("GET", "https://evil.example")
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Credentials"))
+
+	rw = request("OPTIONS", "https://evil.example", "Access-Control-Request-Method", "GET")
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "", rw.Header().Get("Access-Control-Allow-Credentials"))
+}
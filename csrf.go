@@ -0,0 +1,137 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+)
+
+// CSRFOptions configures CSRFMiddleware.
+type CSRFOptions struct {
+	// CookieName is the cookie holding the client's CSRF secret. Defaults to "_csrf".
+	CookieName string
+
+	// HeaderName is the request header carrying the token, eg for requests made by scripts. Defaults to
+	// "X-CSRF-Token".
+	HeaderName string
+
+	// FieldName is the form field carrying the token. Defaults to "csrf_token".
+	FieldName string
+
+	// Exempt, if set, lets unsafe requests it returns true for through without a token, eg webhooks that
+	// authenticate otherwise.
+	Exempt func(*Request) bool
+}
+
+// DefaultInvalidCSRFTokenResponse is the default text rendered when CSRFMiddleware rejects a request.
+var DefaultInvalidCSRFTokenResponse = "Invalid CSRF Token"
+
+type csrfContextKey struct{}
+
+// csrfState is what CSRFMiddleware puts in the request's context.
+type csrfState struct {
+	secret []byte
+	field  string
+}
+
+const csrfSecretLen = 32
+
+// CSRFMiddleware returns middleware protecting against cross-site request forgery with double-submit cookies.
+// Each client gets a random secret in a cookie (set with SetCookie, so the router's CookieDefaults
+// apply), and requests with unsafe methods (anything but GET, HEAD, OPTIONS and TRACE) are rejected with a 403
+// unless they carry a token for that secret, in the HeaderName header or the FieldName form field. Pages get the
+// token with Request.CSRFToken, or a hidden form field with Request.CSRFField:
+//
+//	<form method="POST" action="/transfer">{{.CSRFField}} ...</form>
+//
+// Tokens are masked with a fresh random value every time they're asked for, so they never repeat in responses
+// (which defeats compression side-channel attacks like BREACH), and any token issued for the secret is valid.
+func CSRFMiddleware(opts CSRFOptions) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if opts.CookieName == "" {
+		opts.CookieName = "_csrf"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		var secret []byte
+		if cookie, err := req.Cookie(opts.CookieName); err == nil {
+			secret, _ = base64.RawURLEncoding.DecodeString(cookie.Value)
+		}
+		if len(secret) != csrfSecretLen {
+			secret = randomBytes(csrfSecretLen)
+			if err := SetCookie(rw, Cookie{Name: opts.CookieName, Value: base64.RawURLEncoding.EncodeToString(secret)}); err != nil {
+				panic(err)
+			}
+		}
+		rw.Header().Add("Vary", "Cookie")
+		req.SetContext(context.WithValue(req.Context(), csrfContextKey{}, &csrfState{secret: secret, field: opts.FieldName}))
+
+		switch req.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+		default:
+			if opts.Exempt != nil && opts.Exempt(req) {
+				break
+			}
+			token := req.Header.Get(opts.HeaderName)
+			if token == "" {
+				token = req.FormValue(opts.FieldName)
+			}
+			if !validCSRFToken(secret, token) {
+				renderError(rw, req, http.StatusForbidden, DefaultInvalidCSRFTokenResponse)
+				return
+			}
+		}
+		next(rw, req)
+	}
+}
+
+// CSRFToken returns a token for the request's CSRF secret, to send back with unsafe requests. It returns "" if
+// the request didn't go through a CSRFMiddleware.
+func (r *Request) CSRFToken() string {
+	state, _ := r.Context().Value(csrfContextKey{}).(*csrfState)
+	if state == nil {
+		return ""
+	}
+	mask := randomBytes(csrfSecretLen)
+	token := make([]byte, 2*csrfSecretLen)
+	copy(token, mask)
+	subtle.XORBytes(token[csrfSecretLen:], mask, state.secret)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// CSRFField returns a hidden form field with a CSRF token (see CSRFToken), for HTML templates. It returns "" if
+// the request didn't go through a CSRFMiddleware.
+func (r *Request) CSRFField() template.HTML {
+	state, _ := r.Context().Value(csrfContextKey{}).(*csrfState)
+	if state == nil {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(state.field) + `" value="` + r.CSRFToken() + `">`)
+}
+
+// validCSRFToken returns true if token is a masked secret.
+func validCSRFToken(secret []byte, token string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*csrfSecretLen {
+		return false
+	}
+	unmasked := make([]byte, csrfSecretLen)
+	subtle.XORBytes(unmasked, raw[:csrfSecretLen], raw[csrfSecretLen:])
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
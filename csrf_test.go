@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(CSRFMiddleware(CSRFOptions{
+		Exempt: func(req *Request) bool { return strings.HasPrefix(req.URL.Path, "/hooks/") },
+	}))
+	router.Get("/form", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("X-Token", req.CSRFToken())
+		rw.Write([]byte(req.CSRFField()))
+	})
+	router.Post("/transfer", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("transferred"))
+	})
+	router.Post("/hooks/payment", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("hooked"))
+	})
+
+	rw, req := newTestRequest("GET", "/form")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	cookies := rw.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	cookie := cookies[0]
+	assert.Equal(t, "_csrf", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	token := rw.Header().Get("X-Token")
+	assert.True(t, strings.HasPrefix(rw.Body.String(), `<input type="hidden" name="csrf_token" value="`), rw.Body.String())
+
+	// Returning clients keep their secret, but get fresh tokens.
+	rw, req = newTestRequest("GET", "/form")
+	req.AddCookie(cookie)
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 0, len(rw.Result().Cookies()))
+	assert.NotEqual(t, token, rw.Header().Get("X-Token"))
+	otherToken := rw.Header().Get("X-Token")
+
+	post := func(path string, cookie *http.Cookie, header, field string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		if field != "" {
+			form.Set("csrf_token", field)
+		}
+		req, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	assertResponse(t, post("/transfer", cookie, token, ""), "transferred", http.StatusOK)
+	assertResponse(t, post("/transfer", cookie, "", otherToken), "transferred", http.StatusOK)
+	assertResponse(t, post("/transfer", cookie, "", ""), "Invalid CSRF Token", http.StatusForbidden)
+	assertResponse(t, post("/transfer", cookie, "garbage", ""), "Invalid CSRF Token", http.StatusForbidden)
+	assertResponse(t, post("/transfer", nil, token, ""), "Invalid CSRF Token", http.StatusForbidden)
+	assertResponse(t, post("/transfer", &http.Cookie{Name: "_csrf", Value: "forged"}, token, ""), "Invalid CSRF Token", http.StatusForbidden)
+	assertResponse(t, post("/hooks/payment", nil, "", ""), "hooked", http.StatusOK)
+
+	// Tokens for one client's secret aren't valid for another's.
+	rw, req = newTestRequest("GET", "/form")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, post("/transfer", rw.Result().Cookies()[0], token, ""), "Invalid CSRF Token", http.StatusForbidden)
+}
+
+func TestCSRFTokenWithoutMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.CSRFToken() + string(req.CSRFField())))
+	})
+	rw, req := newTestRequest("GET", "/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusOK)
+}
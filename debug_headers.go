@@ -0,0 +1,85 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugTokenHeader is the request header that carries a debug token (see DebugToken).
+const DebugTokenHeader = "X-Debug-Token"
+
+// DebugToken returns a token, signed with key and valid for expiry, that developers send in the X-Debug-Token
+// header to get debug headers on production responses (see DebugHeadersMiddleware and DebugTokenSampler).
+func DebugToken(key []byte, expiry time.Duration) string {
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	return expires + "." + debugTokenSignature(key, expires)
+}
+
+// DebugTokenSampler returns a Sampler that samples exactly the requests carrying a valid, unexpired debug token
+// signed with key. It can also be passed to SampledLoggerMiddleware to log those requests.
+func DebugTokenSampler(key []byte) Sampler {
+	return SamplerFunc(func(req *Request) bool {
+		token := req.Header.Get(DebugTokenHeader)
+		i := strings.IndexByte(token, '.')
+		if i < 0 {
+			return false
+		}
+		expires, err := strconv.ParseInt(token[:i], 10, 64)
+		if err != nil || time.Now().Unix() > expires {
+			return false
+		}
+		return hmac.Equal([]byte(token[i+1:]), []byte(debugTokenSignature(key, token[:i])))
+	})
+}
+
+func debugTokenSignature(key []byte, expires string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("debug-token:" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DebugHeadersMiddleware returns middleware that adds debug headers to the responses of the requests sampler
+// samples: X-Route with the routed path, X-Request-Id with the request's ID (see RequestIDMiddleware), its
+// X-Request-Id header, or a generated one, and Server-Timing with the time spent until the response headers were
+// written. Other responses are unchanged.
+//
+// To keep internals away from ordinary clients, pass DebugTokenSampler rather than a random sampler.
+func DebugHeadersMiddleware(sampler Sampler) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if !sampler.Sample(req) {
+			next(rw, req)
+			return
+		}
+
+		start := time.Now()
+		requestID := req.RequestID()
+		if requestID == "" {
+			requestID = req.Header.Get("X-Request-Id")
+		}
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		BeforeWrite(rw, func(h http.Header) {
+			if path := req.RoutePath(); path != "" {
+				h.Set("X-Route", req.Method+" "+path)
+			}
+			h.Set("X-Request-Id", requestID)
+			h.Add("Server-Timing", fmt.Sprintf("app;dur=%.3f", float64(time.Since(start))/float64(time.Millisecond)))
+		})
+		next(rw, req)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
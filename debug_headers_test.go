@@ -0,0 +1,55 @@
+package web
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHeaders(t *testing.T) {
+	key := []byte("debug-secret")
+	router := New(Context{})
+	router.Middleware(DebugHeadersMiddleware(DebugTokenSampler(key)))
+	router.Get("/posts/:id", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/posts/3")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+	assert.Equal(t, "", rw.Header().Get("X-Route"))
+	assert.Equal(t, "", rw.Header().Get("X-Request-Id"))
+	assert.Equal(t, "", rw.Header().Get("Server-Timing"))
+
+	rw, req = newTestRequest("GET", "/posts/3")
+	req.Header.Set(DebugTokenHeader, DebugToken(key, time.Minute))
+	req.Header.Set("X-Request-Id", "abc123")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+	assert.Equal(t, "GET /posts/:id", rw.Header().Get("X-Route"))
+	assert.Equal(t, "abc123", rw.Header().Get("X-Request-Id"))
+	assert.True(t, strings.HasPrefix(rw.Header().Get("Server-Timing"), "app;dur="))
+
+	rw, req = newTestRequest("GET", "/posts/3")
+	req.Header.Set(DebugTokenHeader, DebugToken(key, time.Minute))
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 16, len(rw.Header().Get("X-Request-Id")))
+}
+
+func TestDebugTokenSampler(t *testing.T) {
+	key := []byte("debug-secret")
+	sampler := DebugTokenSampler(key)
+	sample := func(token string) bool {
+		_, req := newTestRequest("GET", "/")
+		req.Header.Set(DebugTokenHeader, token)
+		return sampler.Sample(&Request{Request: req})
+	}
+
+	assert.True(t, sample(DebugToken(key, time.Minute)))
+	assert.False(t, sample(DebugToken(key, -time.Minute)))
+	assert.False(t, sample(DebugToken([]byte("other"), time.Minute)))
+	assert.False(t, sample(""))
+	assert.False(t, sample("12345"))
+	token := DebugToken(key, time.Minute)
+	assert.False(t, sample("9"+token))
+}
@@ -0,0 +1,8 @@
+//go:build !webdebug
+// +build !webdebug
+
+package web
+
+// debugBuild is false: this binary was built without the webdebug tag, so debug mode and ShowErrorsMiddleware
+// are left out.
+const debugBuild = false
@@ -0,0 +1,27 @@
+//go:build !webdebug
+// +build !webdebug
+
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugModeNotCompiledIn(t *testing.T) {
+	router := New(Context{}).Debug(true)
+	assert.False(t, router.debug)
+	router.Middleware(ShowErrorsMiddleware)
+	router.Get("/action", (*Context).A)
+	router.Get("/boom", (*Context).ErrorAction)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+	assert.Equal(t, "", rw.Header().Get(MiddlewareTraceHeader))
+
+	rw, req = newTestRequest("GET", "/boom")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", 500)
+}
@@ -0,0 +1,8 @@
+//go:build webdebug
+// +build webdebug
+
+package web
+
+// debugBuild is true when the debug subsystems - debug mode and ShowErrorsMiddleware - are compiled in.
+// They are only compiled in with the webdebug build tag, so production binaries can't expose them by accident.
+const debugBuild = true
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// RouteDeprecation describes a deprecated route. See Route.Deprecated.
+type RouteDeprecation struct {
+	Sunset    time.Time `json:"sunset,omitempty"`    // when the route will be removed; zero if not yet known
+	Successor string    `json:"successor,omitempty"` // the name of the route that replaces it, if any
+}
+
+// Deprecated marks the route as deprecated, and returns the route. Its responses get a "Deprecation: true"
+// header, a Sunset header (RFC 8594) with sunset unless it's zero, and a Link header pointing at the route
+// named successorRouteName, with rel="successor-version", unless it's "". The successor's path params are
+// filled in from the request's, by name, and it's looked up on every request, so it can be named later:
+//
+//	router.Get("/v1/users/:id", (*Context).UserV1).Deprecated(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), "user")
+//	router.Get("/v2/users/:id", (*Context).User).Named("user")
+//
+// Deprecated routes are marked in Router.Snapshot, and DeprecatedRequests counts their use, to tell when
+// they can be removed.
+func (r *Route) Deprecated(sunset time.Time, successorRouteName string) *Route {
+	r.checkConfigurable()
+	r.deprecation = &RouteDeprecation{Sunset: sunset, Successor: successorRouteName}
+	return r
+}
+
+// Deprecation returns how the route was deprecated, or nil if it wasn't.
+func (r *Route) Deprecation() *RouteDeprecation {
+	return r.deprecation
+}
+
+// DeprecatedRequests returns how many requests were routed to r while it was deprecated, since the process
+// started.
+func (r *Route) DeprecatedRequests() int64 {
+	return r.deprecatedRequests.Load()
+}
+
+// applyDeprecation counts a request to a deprecated route, and adds the deprecation headers to its response.
+func applyDeprecation(rw ResponseWriter, req *Request, route *Route) {
+	route.deprecatedRequests.Add(1)
+	d := route.deprecation
+	header := rw.Header()
+	header.Set("Deprecation", "true")
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor == "" {
+		return
+	}
+	successor := findNamedRoute(route.router, d.Successor)
+	if successor == nil {
+		return
+	}
+	if path, err := fillPathParams(successor.path, req.PathParams); err == nil {
+		header.Add("Link", "<"+path+`>; rel="successor-version"`)
+	}
+}
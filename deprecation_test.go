@@ -0,0 +1,49 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecated(t *testing.T) {
+	sunset := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	router := New(Context{})
+	old := router.Get("/v1/users/:id", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("v1"))
+	}).Deprecated(sunset, "user")
+	router.Get("/v1/status", func(rw ResponseWriter, req *Request) {}).Deprecated(time.Time{}, "")
+	router.Get("/v2/users/:id", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("v2"))
+	}).Named("user")
+
+	rw, req := newTestRequest("GET", "/v1/users/5")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "v1", 200)
+	assert.Equal(t, "true", rw.Header().Get("Deprecation"))
+	assert.Equal(t, "Sun, 01 Jun 2025 00:00:00 GMT", rw.Header().Get("Sunset"))
+	assert.Equal(t, `</v2/users/5>; rel="successor-version"`, rw.Header().Get("Link"))
+
+	rw, req = newTestRequest("GET", "/v1/status")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "true", rw.Header().Get("Deprecation"))
+	assert.Equal(t, "", rw.Header().Get("Sunset"))
+	assert.Equal(t, "", rw.Header().Get("Link"))
+
+	rw, req = newTestRequest("GET", "/v2/users/5")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "v2", 200)
+	assert.Equal(t, "", rw.Header().Get("Deprecation"))
+
+	assert.Equal(t, int64(1), old.DeprecatedRequests())
+	assert.Equal(t, &RouteDeprecation{Sunset: sunset, Successor: "user"}, old.Deprecation())
+
+	var deprecated []string
+	for _, route := range router.Snapshot().Routes {
+		if route.Deprecated != nil {
+			deprecated = append(deprecated, route.Path)
+		}
+	}
+	assert.Equal(t, []string{"/v1/status", "/v1/users/:id"}, deprecated)
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"syscall"
+)
+
+// Disconnected returns true if the client went away before the response was finished, eg it closed the tab or
+// gave up waiting. Handlers doing long or expensive work can check it (or watch req.Context().Done()) and stop
+// early. Deadlines added with SetContext don't count as disconnects.
+//
+// Panics from requests whose client disconnected, and panics with the errors net/http returns for writes to a
+// closed connection ("broken pipe", "connection reset by peer"), are not reported to PanicHandler.
+func (r *Request) Disconnected() bool {
+	ctx := r.Context()
+	if r.more != nil && r.more.connCtx != nil {
+		ctx = r.more.connCtx
+	}
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// AbortedRequests returns how many requests routed to r had their client disconnect before the response
+// was finished, since the process started.
+func (r *Route) AbortedRequests() int64 {
+	return r.aborted.Load()
+}
+
+// isDisconnectError returns true if err (eg, a recovered panic) means that the client went away.
+func isDisconnectError(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	return errors.Is(err, http.ErrAbortHandler) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisconnected(t *testing.T) {
+	reporter := &stackCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{})
+	router.Middleware(LoggerMiddleware)
+	var cancel context.CancelFunc
+	var disconnected []bool
+	route := router.Get("/slow", func(rw ResponseWriter, req *Request) {
+		disconnected = append(disconnected, req.Disconnected())
+		cancel()
+		disconnected = append(disconnected, req.Disconnected())
+	})
+	router.Get("/pipe", func(rw ResponseWriter, req *Request) {
+		panic(fmt.Errorf("write: %w", syscall.EPIPE))
+	})
+	router.Get("/deadline", func(rw ResponseWriter, req *Request) {
+		ctx, cancelDeadline := context.WithCancel(req.Context())
+		req.SetContext(ctx)
+		cancelDeadline()
+		disconnected = append(disconnected, req.Disconnected())
+	})
+
+	rw, req := newTestRequest("GET", "/slow")
+	ctx, cancelFunc := context.WithCancel(req.Context())
+	cancel = cancelFunc
+	router.ServeHTTP(rw, req.WithContext(ctx))
+	assert.True(t, strings.Contains(buf.String(), "'/slow' (client disconnected)"))
+	assert.Equal(t, int64(1), route.AbortedRequests())
+
+	rw, req = newTestRequest("GET", "/deadline")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, []bool{false, true, false}, disconnected)
+	assert.Equal(t, int64(1), route.AbortedRequests())
+
+	rw, req = newTestRequest("GET", "/pipe")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "", reporter.stack)
+
+	assert.True(t, isDisconnectError(fmt.Errorf("write: %w", syscall.ECONNRESET)))
+	assert.False(t, isDisconnectError("broken pipe"))
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DispatchResponse is the response to a request run with Router.Dispatch.
+type DispatchResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Dispatch runs a request through the whole router (middleware, routing and handlers) in-process, and returns
+// its response. path is the request's path and query, eg "/users/1?fields=name", and must start with "/". body
+// may be nil. A Host header sets the request's host. Dispatch can be called on any router of the tree: the request
+// always starts at the root router.
+//
+// The request gets ctx as its context, so handlers that dispatch requests of their own (eg, to include fragments
+// rendered by other routes) should pass req.Context() along.
+func (r *Router) Dispatch(ctx context.Context, method, path string, body io.Reader, header http.Header) (*DispatchResponse, error) {
+	req, err := newDispatchRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+	return getRootRouter(r).dispatch(req), nil
+}
+
+// newDispatchRequest returns a request for Dispatch, checking that path is a path and not a URL.
+func newDispatchRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	u, err := url.ParseRequestURI(path)
+	if err != nil || u.IsAbs() || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(path, "//") {
+		return nil, fmt.Errorf("web: dispatched path %q must start with a single '/'", path)
+	}
+	if body == nil {
+		body = http.NoBody
+	}
+	return http.NewRequestWithContext(ctx, method, path, body)
+}
+
+// dispatch serves req and records its response.
+func (rootRouter *Router) dispatch(req *http.Request) *DispatchResponse {
+	recorder := &jobRecorder{header: make(http.Header)}
+	rootRouter.ServeHTTP(recorder, req)
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return &DispatchResponse{StatusCode: recorder.status, Header: recorder.header, Body: recorder.body.Bytes()}
+}
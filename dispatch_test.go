@@ -0,0 +1,55 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatch(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		rw.Header().Set("X-Middleware", "ran")
+		next(rw, req)
+	})
+	router.Post("/users/:id", func(rw ResponseWriter, req *Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(rw, "%s %s %s %s %s", req.PathParams["id"], req.URL.Query().Get("q"), req.Header.Get("X-Token"), req.Host, body)
+	})
+	api := router.Subrouter(Context{}, "/api")
+	api.Get("/value", func(rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, req.Context().Value(testContextKey{}))
+	})
+
+	resp, err := api.Dispatch(context.Background(), "POST", "/users/7?q=x", strings.NewReader("payload"), http.Header{
+		"X-Token": {"abc"},
+		"Host":    {"example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "ran", resp.Header.Get("X-Middleware"))
+	assert.Equal(t, "7 x abc example.com payload", string(resp.Body))
+
+	ctx := context.WithValue(context.Background(), testContextKey{}, "from ctx")
+	resp, err = router.Dispatch(ctx, "GET", "/api/value", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "from ctx", string(resp.Body))
+
+	resp, err = router.Dispatch(ctx, "GET", "/missing", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	for _, path := range []string{"http://example.com/users/7", "users/7", "//example.com/users/7", ""} {
+		_, err = router.Dispatch(ctx, "GET", path, nil, nil)
+		assert.Error(t, err, path)
+	}
+}
+
+type testContextKey struct{}
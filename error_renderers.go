@@ -0,0 +1,156 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrorRenderer renders one of the router's built-in error responses (eg, not found, or a panic with no
+// Error handler) in a particular format. message is DefaultNotFoundResponse, DefaultPanicResponse, etc.
+type ErrorRenderer func(rw ResponseWriter, req *Request, status int, message string)
+
+// defaultErrorRenderers are the renderers of a router that doesn't have any of its own. See Router.ErrorRenderer.
+var defaultErrorRenderers = map[string]ErrorRenderer{
+	"text/plain":       TextErrorRenderer,
+	"text/html":        HTMLErrorRenderer,
+	"application/json": JSONErrorRenderer,
+}
+
+// ErrorRenderer sets the renderer of built-in error responses for mediaType, replacing the default one if there is
+// one, and returns the router. A nil renderer removes it. The renderer is chosen by the request's Accept header,
+// among text/plain, text/html and application/json to begin with. text/plain is used when nothing acceptable is
+// registered. Note that only the root router can have error renderers. They can be changed while requests are
+// being served.
+func (r *Router) ErrorRenderer(mediaType string, renderer ErrorRenderer) *Router {
+	if r.parent != nil {
+		panic("You can only set error renderers on the root router.")
+	}
+	r.tables.mu.Lock()
+	defer r.tables.mu.Unlock()
+	current := defaultErrorRenderers
+	if renderers := r.errorRenderers.Load(); renderers != nil {
+		current = *renderers
+	}
+	renderers := make(map[string]ErrorRenderer, len(current)+1)
+	for k, v := range current {
+		renderers[k] = v
+	}
+	if renderer == nil {
+		delete(renderers, strings.ToLower(mediaType))
+	} else {
+		renderers[strings.ToLower(mediaType)] = renderer
+	}
+	r.errorRenderers.Store(&renderers)
+	r.tables.settingsChanged()
+	return r
+}
+
+// errorRenderersFor returns the error renderers of the root router.
+func errorRenderersFor(root *Router) map[string]ErrorRenderer {
+	if renderers := root.errorRenderers.Load(); renderers != nil {
+		return *renderers
+	}
+	return defaultErrorRenderers
+}
+
+// TextErrorRenderer renders message as plain text.
+func TextErrorRenderer(rw ResponseWriter, req *Request, status int, message string) {
+	http.Error(rw, message, status)
+}
+
+// JSONErrorRenderer renders message in the same shape as a CodedError, eg
+// {"error":{"code":"NOT_FOUND","message":"Not Found"}}.
+func JSONErrorRenderer(rw ResponseWriter, req *Request, status int, message string) {
+	code := strings.ToUpper(strings.Replace(http.StatusText(status), " ", "_", -1))
+	RenderCodedError(rw, &CodedError{&ErrorCode{Code: code, Status: status, Message: message}})
+}
+
+var htmlErrorTpl = template.Must(template.New("ErrorPage").Parse(
+	`<html><head><title>{{.Status}} {{.Message}}</title></head><body><h1>{{.Message}}</h1></body></html>`))
+
+// HTMLErrorRenderer renders message as a minimal HTML page.
+func HTMLErrorRenderer(rw ResponseWriter, req *Request, status int, message string) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	htmlErrorTpl.Execute(rw, map[string]interface{}{"Status": status, "Message": message})
+}
+
+func renderError(rw ResponseWriter, req *Request, status int, message string) {
+	renderers := defaultErrorRenderers
+	if req.settings != nil && req.settings.errorRenderers != nil {
+		renderers = req.settings.errorRenderers
+	}
+	negotiateErrorRenderer(renderers, req.Header.Get("Accept"))(rw, req, status, message)
+}
+
+// negotiateErrorRenderer picks the renderer in renderers with the highest quality in the accept header.
+// Exact media types win over "type/*" ranges at the same quality. "*/*" only selects text/plain, so
+// clients that accept anything keep getting the plain text responses.
+func negotiateErrorRenderer(renderers map[string]ErrorRenderer, accept string) ErrorRenderer {
+	mediaTypes := make([]string, 0, len(renderers))
+	for mediaType := range renderers {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	// Each media type gets the q of the most specific range matching it, so "application/json;q=0, */*" rules out
+	// JSON. Types whose q is 0 aren't acceptable at all.
+	best := ""
+	bestQ, bestSpecificity := 0.0, 0
+	for _, mediaType := range mediaTypes {
+		q, specificity := 0.0, 0
+		for _, part := range strings.Split(accept, ",") {
+			mediaRange, rangeQ := parseMediaRange(part)
+			if s := mediaRangeSpecificity(mediaRange, mediaType); s > specificity {
+				q, specificity = rangeQ, s
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = mediaType, q, specificity
+		}
+	}
+
+	if renderer := renderers[best]; renderer != nil {
+		return renderer
+	}
+	if renderer := renderers["text/plain"]; renderer != nil {
+		return renderer
+	}
+	return TextErrorRenderer
+}
+
+// parseMediaRange splits "text/html;q=0.8" into ("text/html", 0.8).
+func parseMediaRange(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return mediaRange, q
+}
+
+// mediaRangeSpecificity returns 3 if mediaRange is mediaType, 2 if it's the "type/*" range covering it,
+// 1 if it's "*/*" and mediaType is text/plain, and 0 if it doesn't match.
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 3
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]):
+		return 2
+	case mediaRange == "*/*" && mediaType == "text/plain":
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestErrorNegotiation(t *testing.T) {
+	router := New(Context{})
+	router.Get("/action", (*Context).ErrorAction)
+
+	table := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "text/plain; charset=utf-8"},
+		{"*/*", "text/plain; charset=utf-8"},
+		{"application/json", "application/json; charset=utf-8"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8"},
+		{"application/json;q=0.5, text/html", "text/html; charset=utf-8"},
+		{"application/json;q=0.1, */*", "text/plain; charset=utf-8"},
+		{"application/*", "application/json; charset=utf-8"},
+		{"image/png", "text/plain; charset=utf-8"},
+		{"application/json;q=0", "text/plain; charset=utf-8"},
+		{"application/json;q=0, application/*", "text/plain; charset=utf-8"},
+		{"text/html;q=0, */*;q=0.5", "text/plain; charset=utf-8"},
+	}
+
+	for _, test := range table {
+		rw, req := newTestRequest("GET", "/nope")
+		req.Header.Set("Accept", test.accept)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Equal(t, test.contentType, rw.Header().Get("Content-Type"), test.accept)
+
+		rw, req = newTestRequest("GET", "/action")
+		req.Header.Set("Accept", test.accept)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		assert.Equal(t, test.contentType, rw.Header().Get("Content-Type"), test.accept)
+	}
+
+	rw, req := newTestRequest("GET", "/nope")
+	req.Header.Set("Accept", "application/json")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, `{"error":{"code":"NOT_FOUND","message":"Not Found"}}`, http.StatusNotFound)
+
+	rw, req = newTestRequest("GET", "/nope")
+	req.Header.Set("Accept", "text/html")
+	router.ServeHTTP(rw, req)
+	assert.True(t, strings.Contains(rw.Body.String(), "<h1>Not Found</h1>"))
+}
+
+func TestCustomErrorRenderer(t *testing.T) {
+	router := New(Context{}).ErrorRenderer("application/xml", func(rw ResponseWriter, req *Request, status int, message string) {
+		rw.Header().Set("Content-Type", "application/xml")
+		rw.WriteHeader(status)
+		rw.Write([]byte("<error>" + message + "</error>"))
+	})
+	router.Get("/action", (*Context).ErrorAction)
+
+	rw, req := newTestRequest("GET", "/nope")
+	req.Header.Set("Accept", "application/xml")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "<error>Not Found</error>", http.StatusNotFound)
+
+	rw, req = newTestRequest("GET", "/action")
+	req.Header.Set("Accept", "application/xml")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "<error>Application Error</error>", http.StatusInternalServerError)
+
+	// Other routers keep the defaults.
+	other := New(Context{})
+	rw, req = newTestRequest("GET", "/nope")
+	req.Header.Set("Accept", "application/xml")
+	other.ServeHTTP(rw, req)
+	assert.Equal(t, "text/plain; charset=utf-8", rw.Header().Get("Content-Type"))
+
+	// Renderers can be removed, and changed while serving.
+	router.ErrorRenderer("application/json", nil)
+	rw, req = newTestRequest("GET", "/nope")
+	req.Header.Set("Accept", "application/json")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "text/plain; charset=utf-8", rw.Header().Get("Content-Type"))
+
+	assert.Panics(t, func() {
+		router.Subrouter(Context{}, "/admin").ErrorRenderer("text/csv", TextErrorRenderer)
+	})
+}
+
+func TestErrorRendererWhileServing(t *testing.T) {
+	router := New(Context{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rw, req := newTestRequest("GET", "/nope")
+				req.Header.Set("Accept", "application/xml")
+				router.ServeHTTP(rw, req)
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		router.ErrorRenderer("application/xml", TextErrorRenderer)
+	}
+	wg.Wait()
+}
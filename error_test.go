@@ -170,3 +170,85 @@ func TestConsistentContext(t *testing.T) {
 	router.ServeHTTP(rw, req)
 	assertResponse(t, rw, "My Secondary Error", 500)
 }
+
+func TestCodedError(t *testing.T) {
+	RegisterErrorCode("ORDER_NOT_FOUND", http.StatusNotFound, "Order not found")
+	RegisterErrorCode("ORDER_NOT_FOUND", http.StatusNotFound, "Order not found")
+	assert.Panics(t, func() {
+		RegisterErrorCode("ORDER_NOT_FOUND", http.StatusGone, "Order deleted")
+	})
+	count := ErrorCodeCount("ORDER_NOT_FOUND")
+
+	router := New(Context{})
+	router.Get("/orders/:id", func(w ResponseWriter, r *Request) {
+		panic(Coded("ORDER_NOT_FOUND"))
+	})
+	router.Get("/unknown", func(w ResponseWriter, r *Request) {
+		panic(Coded("NOT_REGISTERED"))
+	})
+
+	rw, req := newTestRequest("GET", "/orders/3")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, `{"error":{"code":"ORDER_NOT_FOUND","message":"Order not found"}}`, http.StatusNotFound)
+	assert.Equal(t, "application/json; charset=utf-8", rw.Header().Get("Content-Type"))
+	assert.Equal(t, count+1, ErrorCodeCount("ORDER_NOT_FOUND"))
+
+	rw, req = newTestRequest("GET", "/unknown")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, `{"error":{"code":"NOT_REGISTERED","message":"NOT_REGISTERED"}}`, http.StatusInternalServerError)
+	assert.Equal(t, int64(0), ErrorCodeCount("NOT_REGISTERED"))
+}
+
+func TestCodedErrorWithErrorHandler(t *testing.T) {
+	RegisterErrorCode("ACCOUNT_LOCKED", http.StatusForbidden, "Account locked")
+	count := ErrorCodeCount("ACCOUNT_LOCKED")
+
+	router := New(Context{})
+	router.Error(func(w ResponseWriter, r *Request, err interface{}) {
+		if coded, ok := err.(*CodedError); ok {
+			w.Header().Set("X-Error-Code", coded.Code)
+			RenderCodedError(w, coded)
+		}
+	})
+	router.Get("/account", func(w ResponseWriter, r *Request) {
+		panic(Coded("ACCOUNT_LOCKED"))
+	})
+
+	rw, req := newTestRequest("GET", "/account")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, `{"error":{"code":"ACCOUNT_LOCKED","message":"Account locked"}}`, http.StatusForbidden)
+	assert.Equal(t, "ACCOUNT_LOCKED", rw.Header().Get("X-Error-Code"))
+	assert.Equal(t, count+1, ErrorCodeCount("ACCOUNT_LOCKED"))
+}
+
+type stackCapturingReporter struct {
+	stack string
+}
+
+func (s *stackCapturingReporter) Panic(url string, err interface{}, stack string) {
+	s.stack = stack
+}
+
+func TestPanicStackAttribution(t *testing.T) {
+	reporter := &stackCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	router := New(Context{})
+	router.Get("/action", (*Context).ErrorAction)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", 500)
+
+	firstLine := strings.SplitN(reporter.stack, "\n", 2)[0]
+	if !strings.HasSuffix(firstLine, "(*Context).ErrorAction") {
+		t.Errorf("Expected the handler to be the top frame but got '%s'", firstLine)
+	}
+	if strings.Contains(reporter.stack, "reflect.") || strings.Contains(reporter.stack, "middlewareStack") {
+		t.Errorf("Expected framework frames to be trimmed but got:\n%s", reporter.stack)
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import "time"
+
+// EventType says what an Event is about.
+type EventType string
+
+// The events the framework emits.
+const (
+	EventRouteMatched    EventType = "route_matched"    // a request was routed; see Request.RoutePath
+	EventHandlerFinished EventType = "handler_finished" // a request was served, with Status and Duration
+	EventPanicRecovered  EventType = "panic_recovered"  // a request panicked with Panic
+	EventCacheHit        EventType = "cache_hit"        // a request was served by the ResponseCache
+	EventCacheMiss       EventType = "cache_miss"       // a request to a cached route wasn't
+	EventServerStarted   EventType = "server_started"   // a server started serving the router; see Router.Serve
+	EventServerStopped   EventType = "server_stopped"   // it stopped; see Router.Shutdown
+)
+
+// Event is something that happened while serving requests.
+type Event struct {
+	Type EventType
+
+	// Request is the request the event is about, or nil for events about the server. With PoolRequests, it's
+	// recycled once the request is done, so subscribers must not keep it.
+	Request *Request
+
+	Status   int           // set for EventHandlerFinished
+	Duration time.Duration // set for EventHandlerFinished
+	Panic    interface{}   // set for EventPanicRecovered
+}
+
+type eventSubscriber struct {
+	types []EventType // all events if empty
+	fn    func(Event)
+}
+
+// Subscribe calls fn with every event of types (or every event, if there are none), and returns the router.
+// Subscribers are kept on the root router, whichever router they subscribe through, and are called in the
+// order they subscribed, on the goroutine of the request the event is about. So they should be quick, and hand
+// slow work, like sending metrics over the network, to a goroutine of their own:
+//
+//	router.Subscribe(func(e web.Event) {
+//		requestDurations.WithLabelValues(e.Request.RoutePath()).Observe(e.Duration.Seconds())
+//	}, web.EventHandlerFinished)
+func (r *Router) Subscribe(fn func(Event), types ...EventType) *Router {
+	root := r.chain[0]
+	root.subscribers = append(root.subscribers, eventSubscriber{types: types, fn: fn})
+	return r
+}
+
+// Emit calls the subscribers of e.Type with e. Applications that run their servers without Router.Serve can emit
+// EventServerStarted and EventServerStopped themselves, eg around http.Server's ListenAndServe and Shutdown.
+func (r *Router) Emit(e Event) {
+	if subscribers := r.chain[0].subscribers; len(subscribers) > 0 {
+		emit(subscribers, e)
+	}
+}
+
+// emit is Emit's slow path, kept separate so that Emit is inlined and costs nothing without subscribers.
+func emit(subscribers []eventSubscriber, e Event) {
+	for _, s := range subscribers {
+		if len(s.types) == 0 {
+			s.fn(e)
+			continue
+		}
+		for _, t := range s.types {
+			if t == e.Type {
+				s.fn(e)
+				break
+			}
+		}
+	}
+}
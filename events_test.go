@@ -0,0 +1,61 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	reporter := &errCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	var events []string
+	var finished []Event
+	router := New(Context{}).ResponseCache(10)
+	admin := router.Subrouter(Context{}, "/admin")
+	admin.Subscribe(func(e Event) {
+		if e.Request == nil {
+			events = append(events, string(e.Type))
+			return
+		}
+		events = append(events, string(e.Type)+" "+e.Request.URL.Path)
+	})
+	router.Subscribe(func(e Event) {
+		finished = append(finished, e)
+	}, EventHandlerFinished)
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("home"))
+	}).Cache(true, time.Minute, 0)
+	router.Get("/panic", func(rw ResponseWriter, req *Request) {
+		panic("boom")
+	})
+
+	for _, path := range []string{"/", "/", "/panic", "/missing"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+	}
+	assert.Equal(t, []string{
+		"route_matched /", "cache_miss /", "handler_finished /",
+		"route_matched /", "cache_hit /", "handler_finished /",
+		"route_matched /panic", "panic_recovered /panic", "handler_finished /panic",
+		"handler_finished /missing",
+	}, events)
+
+	assert.Equal(t, 4, len(finished))
+	assert.Equal(t, http.StatusOK, finished[0].Status)
+	assert.True(t, finished[0].Duration > 0)
+	assert.Equal(t, http.StatusInternalServerError, finished[2].Status)
+	assert.Equal(t, http.StatusNotFound, finished[3].Status)
+
+	events = nil
+	router.Emit(Event{Type: EventServerStarted})
+	assert.Equal(t, []string{"server_started"}, events)
+	assert.Equal(t, 4, len(finished))
+}
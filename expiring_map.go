@@ -0,0 +1,47 @@
+package web
+
+import "time"
+
+// expiringMap holds the entries of the memory stores, each until it expires. Expired entries are dropped about
+// once a minute, when an entry is set, so keys that are never read again don't pile up. It isn't safe for
+// concurrent use; the stores guard it with their mutex.
+type expiringMap[V any] struct {
+	entries   map[string]expiringEntry[V]
+	lastSweep time.Time
+}
+
+type expiringEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newExpiringMap[V any]() *expiringMap[V] {
+	return &expiringMap[V]{entries: make(map[string]expiringEntry[V]), lastSweep: time.Now()}
+}
+
+// get returns the value of key, and false if it has none or it expired before now.
+func (m *expiringMap[V]) get(key string, now time.Time) (V, bool) {
+	entry, ok := m.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// set sets key to value until expires.
+func (m *expiringMap[V]) set(key string, value V, expires time.Time, now time.Time) {
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, entry := range m.entries {
+			if !now.Before(entry.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.entries[key] = expiringEntry[V]{value: value, expires: expires}
+}
+
+func (m *expiringMap[V]) delete(key string) {
+	delete(m.entries, key)
+}
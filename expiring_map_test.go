@@ -0,0 +1,35 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiringMap(t *testing.T) {
+	m := newExpiringMap[string]()
+	now := time.Now()
+	m.set("a", "1", now.Add(time.Second), now)
+	m.set("b", "2", now.Add(time.Hour), now)
+
+	v, ok := m.get("a", now)
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	_, ok = m.get("a", now.Add(time.Second))
+	assert.False(t, ok)
+
+	// Expired entries are kept until the next sweep, about a minute after the last one.
+	later := now.Add(30 * time.Second)
+	m.set("c", "3", later.Add(time.Hour), later)
+	assert.Equal(t, 3, len(m.entries))
+	later = now.Add(2 * time.Minute)
+	m.set("d", "4", later.Add(time.Hour), later)
+	assert.Equal(t, 3, len(m.entries))
+	_, ok = m.entries["a"]
+	assert.False(t, ok)
+
+	m.delete("b")
+	_, ok = m.get("b", now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fragments composes pages out of the responses of other routes, eg the widgets of a dashboard, each of which is
+// a named route that can be cached on its own. Templates include a fragment with the "fragment" function of
+// FuncMap, passing the route's name and its path params as name/value pairs:
+//
+//	fragments := router.Fragments().Cache("weather_widget", time.Minute)
+//	router.Get("/widgets/weather/:city", (*Context).Weather).Named("weather_widget")
+//
+//	func (c *Context) Dashboard(rw web.ResponseWriter, req *web.Request) {
+//		tmpl := template.Must(template.New("").Funcs(fragments.FuncMap(req)).Parse(
+//			`<aside>{{fragment "weather_widget" "city" .City}}</aside>`))
+//		tmpl.Execute(rw, c.user)
+//	}
+//
+// Fragments are GET requests dispatched in-process (see Router.Dispatch) with the page request's context and
+// SharedHeaders, so they run through the same middleware as the page. A fragment that doesn't respond with a 200
+// makes the template fail.
+type Fragments struct {
+	// SharedHeaders are copied from the page request to the fragment requests. Defaults to Authorization, Cookie
+	// and Accept-Language.
+	SharedHeaders []string
+
+	router *Router
+	mu     sync.Mutex
+	ttls   map[string]time.Duration
+	cache  map[string]cachedFragment
+}
+
+type cachedFragment struct {
+	body    template.HTML
+	expires time.Time
+}
+
+// Fragments returns a Fragments that includes the named routes of r's tree.
+func (r *Router) Fragments() *Fragments {
+	return &Fragments{
+		SharedHeaders: []string{"Authorization", "Cookie", "Accept-Language"},
+		router:        r,
+		ttls:          make(map[string]time.Duration),
+		cache:         make(map[string]cachedFragment),
+	}
+}
+
+// Cache keeps the responses of the route named routeName for ttl and returns f. Responses are cached by URL and
+// shared by every page request, so only cache fragments that don't depend on who is asking.
+func (f *Fragments) Cache(routeName string, ttl time.Duration) *Fragments {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ttls[routeName] = ttl
+	return f
+}
+
+// FuncMap returns the template functions including fragments for req: "fragment" takes a route name followed
+// by path param names and values, and returns the route's response.
+func (f *Fragments) FuncMap(req *Request) template.FuncMap {
+	return template.FuncMap{
+		"fragment": func(routeName string, params ...string) (template.HTML, error) {
+			if len(params)%2 != 0 {
+				return "", fmt.Errorf("web: fragment %s needs a value for every path param name", routeName)
+			}
+			query := make(Query, len(params)/2)
+			for i := 0; i < len(params); i += 2 {
+				query[params[i]] = params[i+1]
+			}
+			return f.Include(req, routeName, query)
+		},
+	}
+}
+
+// Include returns the response of the route named routeName, with pathParams filled in, for req.
+func (f *Fragments) Include(req *Request, routeName string, pathParams Query) (template.HTML, error) {
+	path, err := f.router.MappedUrlFor(routeName, pathParams)
+	if err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	ttl := f.ttls[routeName]
+	cached, ok := f.cache[path]
+	f.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.body, nil
+	}
+
+	header := make(http.Header)
+	for _, k := range f.SharedHeaders {
+		if v := req.Header.Values(k); len(v) > 0 {
+			header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	if req.Host != "" {
+		header.Set("Host", req.Host)
+	}
+	resp, err := f.router.Dispatch(req.Context(), "GET", path, nil, header)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("web: fragment %s responded with %d", path, resp.StatusCode)
+	}
+	body := template.HTML(resp.Body)
+
+	if ttl > 0 {
+		now := time.Now()
+		f.mu.Lock()
+		for k, c := range f.cache {
+			if !now.Before(c.expires) {
+				delete(f.cache, k)
+			}
+		}
+		f.cache[path] = cachedFragment{body: body, expires: now.Add(ttl)}
+		f.mu.Unlock()
+	}
+	return body, nil
+}
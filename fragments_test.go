@@ -0,0 +1,53 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFragments(t *testing.T) {
+	var weatherCalls, greetingCalls int
+	router := New(Context{})
+	fragments := router.Fragments().Cache("weather", time.Hour)
+	router.Get("/widgets/weather/:city", func(rw ResponseWriter, req *Request) {
+		weatherCalls++
+		fmt.Fprintf(rw, "<b>sunny in %s</b>", req.PathParams["city"])
+	}).Named("weather")
+	router.Get("/widgets/greeting", func(rw ResponseWriter, req *Request) {
+		greetingCalls++
+		fmt.Fprintf(rw, "hi %s", req.Header.Get("Cookie"))
+	}).Named("greeting")
+	router.Get("/widgets/broken", func(rw ResponseWriter, req *Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}).Named("broken")
+	router.Get("/dashboard/:city", func(rw ResponseWriter, req *Request) {
+		tmpl := template.Must(template.New("").Funcs(fragments.FuncMap(req)).Parse(req.URL.Query().Get("tmpl")))
+		if err := tmpl.Execute(rw, req.PathParams["city"]); err != nil {
+			fmt.Fprint(rw, "error: ", err)
+		}
+	})
+
+	dashboard := func(city, tmpl string) string {
+		rw, req := newTestRequest("GET", "/dashboard/"+city+"?tmpl="+tmpl)
+		req.Header.Set("Cookie", "user=ann")
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		return rw.Body.String()
+	}
+
+	page := `{{fragment "weather" "city" .}} {{fragment "greeting"}}`
+	assert.Equal(t, "<b>sunny in oslo</b> hi user=ann", dashboard("oslo", page))
+	assert.Equal(t, "<b>sunny in oslo</b> hi user=ann", dashboard("oslo", page))
+	assert.Equal(t, "<b>sunny in rome</b> hi user=ann", dashboard("rome", page))
+	assert.Equal(t, 2, weatherCalls)
+	assert.Equal(t, 3, greetingCalls)
+
+	assert.Contains(t, dashboard("oslo", `{{fragment "broken"}}`), "responded with 500")
+	assert.Contains(t, dashboard("oslo", `{{fragment "weather" "city"}}`), "needs a value")
+	assert.Contains(t, dashboard("oslo", `{{fragment "missing"}}`), "was not found")
+}
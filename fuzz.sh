@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+
+# Runs each fuzz target for FUZZTIME (default 1m). Crashers are saved under testdata/fuzz.
+for target in FuzzMatch FuzzFillPathParams; do
+	go test -run XXX -fuzz "^${target}\$" -fuzztime "${FUZZTIME:-1m}" . || exit 1
+done
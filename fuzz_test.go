@@ -0,0 +1,64 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fuzzRouter has static, wildcard, and regexp routes, nested and overlapping, so matching explores every
+// branch of the tree.
+func fuzzRouter() *Router {
+	handler := func(rw ResponseWriter, req *Request) {
+		for k, v := range req.PathParams {
+			rw.Write([]byte(k + "=" + v))
+		}
+	}
+	router := New(Context{})
+	router.Get("/", handler)
+	router.Get("/posts", handler)
+	router.Get("/posts/:id:\\d+", handler)
+	router.Get("/posts/:slug", handler)
+	router.Get("/posts/:id/comments/:comment_id:[a-f0-9]{4,}", handler)
+	router.Get("/files/:name:.*\\.(png|jpe?g)", handler)
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Get("/:section/:item", handler)
+	return router
+}
+
+// FuzzMatch routes arbitrary paths through a router, including malformed paths and odd percent-encodings.
+// It must never panic, and every matched path param must come from the request path.
+func FuzzMatch(f *testing.F) {
+	for _, seed := range []string{"/", "/posts/12", "/posts/hello/comments/beef", "//", "/posts//", "/%zz", "/files/a%2F.png", "/admin/%00/x", ""} {
+		f.Add(seed)
+	}
+	router := fuzzRouter()
+	f.Fuzz(func(t *testing.T, path string) {
+		for _, leaf := range router.tables.load().trees {
+			leaf.Match(path)
+		}
+
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.URL.Path = path
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	})
+}
+
+// FuzzFillPathParams builds URLs for arbitrary route patterns and param values. Patterns that can't be
+// registered are skipped; for the others, building a URL must return a URL or an error, never panic.
+func FuzzFillPathParams(f *testing.F) {
+	f.Add("/posts/:id", "12")
+	f.Add("/posts/:id:\\d+/comments/:c", "x")
+	f.Add("/a//:b", "")
+	f.Add("/:a:(", "1")
+	f.Fuzz(func(t *testing.T, pattern, param string) {
+		if _, err := New(Context{}).TryGet(pattern, func(rw ResponseWriter, req *Request) {}); err != nil {
+			return
+		}
+		fillPathParams(pattern, map[string]string{}, param, param)
+		fillPathParams(pattern, map[string]string{"id": param})
+	})
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// AutoHead turns automatic HEAD responses on (the default) or off and returns the router. When a HEAD request's
+// path has no HEAD route, the router serves it with the GET route: the handler runs as usual, but its body isn't
+// sent and the Content-Length header is set to the size it would have had. When it's off, such requests get a 405
+// (see MethodNotAllowed). Note that only the root router can configure automatic HEAD responses.
+func (r *Router) AutoHead(enabled bool) *Router {
+	if r.parent != nil {
+		panic("You can only configure automatic HEAD responses on the root router.")
+	}
+	r.autoHeadDisabled = !enabled
+	return r
+}
+
+// headWriter is the http.ResponseWriter of a HEAD request served by a GET route. It discards the body, and holds
+// the header back until the handler is done so that Content-Length can be the size of the discarded body.
+type headWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int64
+	flushed bool
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += int64(len(data))
+	return len(data), nil
+}
+
+// Flush sends the header right away, without a Content-Length unless the handler set one.
+func (w *headWriter) Flush() {
+	w.finish(false)
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *headWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// finish sends the header, once. If done, the handler has returned and the body's size is known.
+func (w *headWriter) finish(done bool) {
+	if w.flushed {
+		return
+	}
+	w.flushed = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.ResponseWriter.Header()
+	if done && header.Get("Content-Length") == "" && bodyAllowedForStatus(w.status) {
+		header.Set("Content-Length", strconv.FormatInt(w.size, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// bodyAllowedForStatus reports whether a response with status may have a body, and so a Content-Length.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
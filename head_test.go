@@ -0,0 +1,87 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoHead(t *testing.T) {
+	router := New(Context{})
+	router.Get("/large", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("X-Method", req.Method)
+		fmt.Fprint(rw, strings.Repeat("x", 10000))
+		fmt.Fprint(rw, "more")
+	})
+	router.Get("/sized", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Length", "3")
+		fmt.Fprint(rw, "abc")
+	})
+	router.Get("/empty", func(rw ResponseWriter, req *Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	router.Get("/streamed", func(rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, "abc")
+		rw.Flush()
+		fmt.Fprint(rw, "def")
+	})
+	router.Get("/panics", func(rw ResponseWriter, req *Request) {
+		panic("boom")
+	})
+	router.Head("/own", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("X-Own", "yes")
+	})
+	router.Get("/own", (*Context).A)
+
+	rw, req := newTestRequest("HEAD", "/large")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusOK)
+	assert.Equal(t, "HEAD", rw.Header().Get("X-Method"))
+	assert.Equal(t, "10004", rw.Header().Get("Content-Length"))
+
+	rw, req = newTestRequest("HEAD", "/sized")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusOK)
+	assert.Equal(t, "3", rw.Header().Get("Content-Length"))
+
+	rw, req = newTestRequest("HEAD", "/empty")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusNoContent)
+	assert.Equal(t, "", rw.Header().Get("Content-Length"))
+
+	rw, req = newTestRequest("HEAD", "/streamed")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusOK)
+	assert.True(t, rw.Flushed)
+	assert.Equal(t, "", rw.Header().Get("Content-Length"))
+
+	rw, req = newTestRequest("HEAD", "/panics")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusInternalServerError)
+
+	rw, req = newTestRequest("HEAD", "/own")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusOK)
+	assert.Equal(t, "yes", rw.Header().Get("X-Own"))
+
+	rw, req = newTestRequest("GET", "/large")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 10004, rw.Body.Len())
+}
+
+func TestAutoHeadOff(t *testing.T) {
+	router := New(Context{}).AutoHead(false)
+	router.Get("/a", (*Context).A)
+
+	rw, req := newTestRequest("HEAD", "/a")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", http.StatusMethodNotAllowed)
+	assert.Equal(t, "GET, OPTIONS", rw.Header().Get("Allow"))
+
+	assert.Panics(t, func() {
+		router.Subrouter(Context{}, "/sub").AutoHead(true)
+	})
+}
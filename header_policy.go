@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+)
+
+// HeaderPolicy declares the response headers of a router's or a route's responses, eg:
+//
+//	router.HeaderPolicy(web.HeaderPolicy{
+//		Set:      http.Header{"X-Content-Type-Options": {"nosniff"}},
+//		Defaults: http.Header{"Cache-Control": {"no-store"}},
+//		Strip:    []string{"Server", "X-Powered-By"},
+//	})
+//
+// The policy is applied right before the headers are written, after handlers and middleware set theirs.
+type HeaderPolicy struct {
+	// Set holds headers that responses always have, replacing any values handlers set.
+	Set http.Header
+	// Defaults holds headers that responses have unless handlers set them.
+	Defaults http.Header
+	// Strip lists headers that responses never have.
+	Strip []string
+}
+
+// HeaderPolicy sets the header policy of this router and its subrouters, and returns the router. Only the
+// nearest policy applies: a subrouter's or a route's policy replaces its parents'. Responses for requests that
+// aren't routed (eg, not found) get the root router's policy.
+func (r *Router) HeaderPolicy(policy HeaderPolicy) *Router {
+	r.headerPolicy = policy.clone()
+	r.tables.settingsChanged()
+	return r
+}
+
+// HeaderPolicy sets the header policy of the route, replacing its routers', and returns the route.
+func (r *Route) HeaderPolicy(policy HeaderPolicy) *Route {
+	r.checkConfigurable()
+	r.headerPolicy = policy.clone()
+	r.router.tables.settingsChanged()
+	return r
+}
+
+// headerPolicyFor returns the header policy of route or of its nearest router, or nil. If route is nil, it
+// returns the root router's policy.
+func headerPolicyFor(route *Route, rootRouter *Router) *HeaderPolicy {
+	routers := rootRouter.chain
+	if route != nil {
+		if route.headerPolicy != nil {
+			return route.headerPolicy
+		}
+		routers = route.router.chain
+	}
+	for i := len(routers) - 1; i >= 0; i-- {
+		if routers[i].headerPolicy != nil {
+			return routers[i].headerPolicy
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of p with canonical header keys, so callers can't change it after registering it.
+func (p HeaderPolicy) clone() *HeaderPolicy {
+	c := &HeaderPolicy{Set: make(http.Header, len(p.Set)), Defaults: make(http.Header, len(p.Defaults))}
+	for k, v := range p.Set {
+		c.Set[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	for k, v := range p.Defaults {
+		c.Defaults[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	for _, k := range p.Strip {
+		c.Strip = append(c.Strip, http.CanonicalHeaderKey(k))
+	}
+	return c
+}
+
+// apply enforces p on header. It's a BeforeWrite callback.
+func (p *HeaderPolicy) apply(header http.Header) {
+	for k, v := range p.Defaults {
+		if len(header[k]) == 0 {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+	for k, v := range p.Set {
+		header[k] = append([]string(nil), v...)
+	}
+	for _, k := range p.Strip {
+		delete(header, k)
+	}
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderPolicy(t *testing.T) {
+	router := New(Context{}).HeaderPolicy(HeaderPolicy{
+		Set:      http.Header{"x-content-type-options": {"nosniff"}},
+		Defaults: http.Header{"Cache-Control": {"no-store"}},
+		Strip:    []string{"x-powered-by"},
+	})
+	router.Get("/page", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("X-Powered-By", "web")
+		rw.Header().Set("X-Content-Type-Options", "none")
+		fmt.Fprint(rw, "page")
+	})
+	router.Get("/cached", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Cache-Control", "max-age=60")
+	})
+	router.Get("/own", (*Context).A).HeaderPolicy(HeaderPolicy{Set: http.Header{"X-Route": {"own"}}})
+	api := router.Subrouter(Context{}, "/api").HeaderPolicy(HeaderPolicy{Defaults: http.Header{"Content-Type": {"application/json"}}})
+	api.Get("/users", func(rw ResponseWriter, req *Request) {
+		panic("boom")
+	})
+
+	rw, req := newTestRequest("GET", "/page")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "page", http.StatusOK)
+	assert.Equal(t, "nosniff", rw.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.Equal(t, "", rw.Header().Get("X-Powered-By"))
+
+	rw, req = newTestRequest("GET", "/cached")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "max-age=60", rw.Header().Get("Cache-Control"))
+
+	rw, req = newTestRequest("GET", "/own")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "own", rw.Header().Get("X-Route"))
+	assert.Equal(t, "", rw.Header().Get("X-Content-Type-Options"))
+
+	rw, req = newTestRequest("GET", "/api/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", http.StatusInternalServerError)
+	assert.Equal(t, "", rw.Header().Get("Cache-Control"))
+
+	rw, req = newTestRequest("GET", "/missing")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+	assert.Equal(t, "nosniff", rw.Header().Get("X-Content-Type-Options"))
+}
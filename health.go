@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// HealthCheck reports whether something the application depends on, eg its database, works. It should give up
+// when ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// HealthCheck adds check under name to the checks run by HealthRoute, and returns the router. Checks are kept on
+// the root router, whichever router they're added through. Adding the same name twice panics.
+func (r *Router) HealthCheck(name string, check HealthCheck) *Router {
+	root := r.chain[0]
+	for _, c := range root.healthChecks {
+		if c.name == name {
+			panic("web: health check " + name + " is already registered")
+		}
+	}
+	root.healthChecks = append(root.healthChecks, namedHealthCheck{name: name, check: check})
+	return r
+}
+
+// HealthRoute adds a GET route at path running all health checks concurrently, and returns it. It responds with
+// 200 if they all pass, and 503 otherwise, with a JSON body like:
+//
+//	{"status": "fail", "checks": {"db": "ok", "search": "dial tcp 10.0.0.5:9200: connection refused"}}
+func (r *Router) HealthRoute(path string) *Route {
+	root := r.chain[0]
+	return r.Get(path, func(rw ResponseWriter, req *Request) {
+		results := make(map[string]string, len(root.healthChecks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		healthy := true
+		for _, c := range root.healthChecks {
+			wg.Add(1)
+			go func(c namedHealthCheck) {
+				defer wg.Done()
+				result := "ok"
+				if err := c.check(req.Context()); err != nil {
+					result = err.Error()
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				results[c.name] = result
+				healthy = healthy && result == "ok"
+			}(c)
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		if !healthy {
+			status, code = "fail", http.StatusServiceUnavailable
+		}
+		body, _ := json.Marshal(map[string]interface{}{"status": status, "checks": results})
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.WriteHeader(code)
+		rw.Write(body)
+	})
+}
+
+// HealthCheckNames returns the names of the router's health checks in sorted order.
+func (r *Router) HealthCheckNames() []string {
+	names := make([]string, 0, len(r.chain[0].healthChecks))
+	for _, c := range r.chain[0].healthChecks {
+		names = append(names, c.name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthRoute(t *testing.T) {
+	var searchErr error
+	router := New(Context{})
+	router.HealthCheck("db", func(ctx context.Context) error { return nil })
+	router.Subrouter(Context{}, "/search").HealthCheck("search", func(ctx context.Context) error { return searchErr })
+	router.HealthRoute("/healthz")
+	assert.Equal(t, []string{"db", "search"}, router.HealthCheckNames())
+	assert.Panics(t, func() {
+		router.HealthCheck("db", func(ctx context.Context) error { return nil })
+	})
+
+	rw, req := newTestRequest("GET", "/healthz")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, `{"checks":{"db":"ok","search":"ok"},"status":"ok"}`, http.StatusOK)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	searchErr = errors.New("connection refused")
+	rw, req = newTestRequest("GET", "/healthz")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, `{"checks":{"db":"ok","search":"connection refused"},"status":"fail"}`, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,81 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// HoneypotOptions configures Router.Honeypot.
+type HoneypotOptions struct {
+	// OnHit is called for every request to the honeypot, eg to log the client or add its address to a denylist.
+	OnHit func(req *Request)
+
+	// Tarpit, if non-zero, keeps the connection busy for this long by slowly dripping a response to the client.
+	// Without it, the honeypot responds like a missing page.
+	Tarpit time.Duration
+
+	// DripInterval is the pause between bytes while tarpitting. Defaults to one second.
+	DripInterval time.Duration
+
+	// MaxTarpitted is how many requests are tarpitted at once. Beyond that, the honeypot responds right away, so
+	// that a flood of requests can't tie up the server's goroutines and connections. Defaults to 100.
+	MaxTarpitted int
+}
+
+// Honeypot adds routes at path, for every method, that legitimate clients never visit (eg "/wp-login.php"), and
+// returns the router. Requests to them are reported to opts.OnHit and optionally tarpitted.
+func (r *Router) Honeypot(path string, opts HoneypotOptions) *Router {
+	if opts.DripInterval == 0 {
+		opts.DripInterval = time.Second
+	}
+	if opts.MaxTarpitted <= 0 {
+		opts.MaxTarpitted = 100
+	}
+	tarpitted := make(chan struct{}, opts.MaxTarpitted)
+
+	handler := func(rw ResponseWriter, req *Request) {
+		if opts.OnHit != nil {
+			opts.OnHit(req)
+		}
+		if opts.Tarpit != 0 {
+			select {
+			case tarpitted <- struct{}{}:
+				defer func() { <-tarpitted }()
+				tarpit(rw, req, opts.Tarpit, opts.DripInterval)
+				return
+			default:
+			}
+		}
+		renderError(rw, req, http.StatusNotFound, DefaultNotFoundResponse)
+	}
+
+	for _, method := range httpMethods {
+		r.addRoute(method, path, handler)
+	}
+	return r
+}
+
+// tarpit writes a byte every interval until duration has passed or the client goes away.
+func tarpit(rw ResponseWriter, req *Request, duration, interval time.Duration) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadline.C:
+			return
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := rw.Write([]byte(" ")); err != nil {
+				return
+			}
+			rw.Flush()
+		}
+	}
+}
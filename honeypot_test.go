@@ -0,0 +1,81 @@
+package web
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHoneypot(t *testing.T) {
+	var hits []string
+	router := New(Context{})
+	router.Honeypot("/wp-login.php", HoneypotOptions{OnHit: func(req *Request) {
+		hits = append(hits, req.Method)
+	}})
+
+	rw, req := newTestRequest("GET", "/wp-login.php")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	rw, req = newTestRequest("POST", "/wp-login.php")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, []string{"GET", "POST"}, hits)
+}
+
+func TestHoneypotTarpit(t *testing.T) {
+	router := New(Context{})
+	router.Honeypot("/admin.php", HoneypotOptions{Tarpit: 50 * time.Millisecond, DripInterval: 10 * time.Millisecond})
+
+	start := time.Now()
+	rw, req := newTestRequest("GET", "/admin.php")
+	router.ServeHTTP(rw, req)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.True(t, rw.Body.Len() >= 3)
+
+	// Clients that hang up are let go.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	rw, req = newTestRequest("GET", "/admin.php")
+	router.ServeHTTP(rw, req.WithContext(ctx))
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
+
+// headerSignal signals wrote when a response's header is written.
+type headerSignal struct {
+	*httptest.ResponseRecorder
+	wrote chan bool
+}
+
+func (w headerSignal) WriteHeader(code int) {
+	w.ResponseRecorder.WriteHeader(code)
+	w.wrote <- true
+}
+
+func TestHoneypotMaxTarpitted(t *testing.T) {
+	router := New(Context{})
+	router.Honeypot("/admin.php", HoneypotOptions{Tarpit: time.Minute, DripInterval: 10 * time.Millisecond, MaxTarpitted: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tarpitted := make(chan bool, 1)
+	done := make(chan bool)
+	go func() {
+		rw, req := newTestRequest("GET", "/admin.php")
+		router.ServeHTTP(headerSignal{rw, tarpitted}, req.WithContext(ctx))
+		done <- true
+	}()
+	<-tarpitted
+
+	// The only tarpit is taken, so further requests get a response right away.
+	start := time.Now()
+	rw, req := newTestRequest("GET", "/admin.php")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+	assert.True(t, time.Since(start) < time.Second)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Host constrains the routes added to the router and its subrouters from now on to requests for the host
+// pattern, and returns the router. A pattern is a host name whose labels can be wildcards, which match a single
+// label and are captured into PathParams like path wildcards:
+//
+//	api := rootRouter.Subrouter(Context{}, "/").Host("api.example.com")
+//	tenants := rootRouter.Subrouter(TenantContext{}, "/").Host(":tenant.example.com")
+//	tenants.Get("/dashboard", (*TenantContext).Dashboard) // req.PathParams["tenant"] is eg "acme"
+//
+// Hosts are compared case-insensitively, without the port. When routes for the same path have different hosts,
+// the most specific host wins, eg api.example.com before :tenant.example.com, and routes without a host match
+// every other host. The nearest router's host applies; Route.Host overrides it for a single route.
+func (r *Router) Host(pattern string) *Router {
+	hp, err := parseHostPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	r.host = hp
+	return r
+}
+
+// Host constrains the route to requests for the host pattern (see Router.Host) and returns the route. Call it
+// while registering the route, before registering another route for the same path.
+func (r *Route) Host(pattern string) *Route {
+	r.checkConfigurable()
+	hp, err := parseHostPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	err = r.router.tables.update(func(t *routeTable, cow bool) error {
+		tree := t.trees[r.method]
+		if cow {
+			tree = tree.clone()
+			t.trees[r.method] = tree
+		}
+		t.hosts = true
+		return tree.setHost(r, hp, cow)
+	})
+	if err != nil {
+		panic(newRouteError(r.method, r.path, err.Error()))
+	}
+	r.host = hp
+	return r
+}
+
+// HostPattern returns the host pattern the route is constrained to, or "" if it matches every host.
+func (r *Route) HostPattern() string {
+	if r.host == nil {
+		return ""
+	}
+	return r.host.pattern
+}
+
+// hostPattern is a parsed host pattern, eg ":tenant.example.com".
+type hostPattern struct {
+	pattern string
+	labels  []string // eg [":tenant", "example", "com"]
+}
+
+func parseHostPattern(pattern string) (*hostPattern, error) {
+	hp := &hostPattern{pattern: strings.ToLower(strings.TrimSuffix(pattern, "."))}
+	hp.labels = strings.Split(hp.pattern, ".")
+	var names []string
+	for _, label := range hp.labels {
+		if label == "" || label == ":" {
+			return nil, fmt.Errorf("web: invalid host pattern %q: it has an empty label", pattern)
+		}
+		if label[0] == ':' {
+			if containsString(names, label[1:]) {
+				return nil, fmt.Errorf("web: invalid host pattern %q: the wildcard %s is used more than once", pattern, label)
+			}
+			names = append(names, label[1:])
+		} else if strings.ContainsAny(label, ":/") {
+			return nil, fmt.Errorf("web: invalid host pattern %q: hosts can't have a port or a path", pattern)
+		}
+	}
+	return hp, nil
+}
+
+// specificity ranks host patterns: leaves with more specific hosts are tried first. Patterns with more literal
+// labels are more specific, and routes without a host (hp is nil) are the least specific.
+func (hp *hostPattern) specificity() int {
+	if hp == nil {
+		return -1
+	}
+	n := 0
+	for _, label := range hp.labels {
+		if label[0] != ':' {
+			n++
+		}
+	}
+	return n
+}
+
+// wildcards returns the names of hp's wildcards, in order. hp may be nil.
+func (hp *hostPattern) wildcards() []string {
+	if hp == nil {
+		return nil
+	}
+	var names []string
+	for _, label := range hp.labels {
+		if label[0] == ':' {
+			names = append(names, label[1:])
+		}
+	}
+	return names
+}
+
+// match returns the values of hp's wildcards in host, which must be normalized with requestHost, or false if
+// host doesn't match.
+func (hp *hostPattern) match(host string) ([]string, bool) {
+	var values []string
+	for i, label := range hp.labels {
+		part := host
+		if i < len(hp.labels)-1 {
+			dot := strings.IndexByte(host, '.')
+			if dot < 0 {
+				return nil, false
+			}
+			part, host = host[:dot], host[dot+1:]
+		}
+		if label[0] == ':' {
+			if part == "" {
+				return nil, false
+			}
+			values = append(values, part)
+		} else if part != label {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// requestHost returns the host of a request's Host header, in lower case and without the port.
+func requestHost(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
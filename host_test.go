@@ -0,0 +1,78 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostRouting(t *testing.T) {
+	router := New(Context{})
+	api := router.Subrouter(Context{}, "/").Host("API.example.com")
+	api.Get("/status", func(rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, "api")
+	})
+	tenants := router.Subrouter(AdminContext{}, "/").Host(":tenant.example.com")
+	tenants.Get("/status/:section", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "tenant %s %s", req.PathParams["tenant"], req.PathParams["section"])
+	})
+	router.Get("/status", func(rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, "any")
+	})
+	router.Get("/status/:section", func(rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, "any section")
+	}).Host("admin.example.org")
+
+	for _, tt := range []struct {
+		host, path, body string
+		status           int
+	}{
+		{"api.example.com", "/status", "api", 200},
+		{"Api.Example.com:8080", "/status", "api", 200},
+		{"acme.example.com", "/status", "any", 200},
+		{"acme.example.com", "/status/billing", "tenant acme billing", 200},
+		{"a.b.example.com", "/status/billing", "Not Found", 404},
+		{"admin.example.org", "/status/billing", "any section", 200},
+		{"", "/status/billing", "Not Found", 404},
+	} {
+		rw, req := newTestRequest("GET", tt.path)
+		req.Host = tt.host
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, tt.body, tt.status)
+	}
+
+	snapshot := router.Snapshot()
+	assert.Equal(t, "api.example.com", snapshot.Routes[1].Host)
+	matcher, err := NewRouteMatcher(snapshot)
+	assert.NoError(t, err)
+	_, params, ok := matcher.MatchHost("GET", "acme.example.com", "/status/billing")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"tenant": "acme", "section": "billing"}, params)
+	_, _, ok = matcher.Match("GET", "/status/billing")
+	assert.False(t, ok)
+}
+
+func TestInvalidHost(t *testing.T) {
+	router := New(Context{})
+	assert.Panics(t, func() { router.Host("example..com") })
+	assert.Panics(t, func() { router.Host(":a.:a.com") })
+	assert.Panics(t, func() { router.Host("example.com:8080") })
+
+	router.Get("/z", (*Context).A).Host("a.com")
+	route := router.Get("/z", (*Context).Z)
+	assert.Panics(t, func() { route.Host("a.com") })
+	assert.Equal(t, "", route.HostPattern())
+	route.Host("b.com")
+	assert.Equal(t, "b.com", route.HostPattern())
+	for host, body := range map[string]string{"a.com": "context-A", "b.com": "context-Z", "c.com": "Not Found"} {
+		rw, req := newTestRequest("GET", "/z")
+		req.Host = host
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, body, strings.TrimSpace(rw.Body.String()))
+	}
+
+	tenants := router.Subrouter(Context{}, "/t").Host(":id.example.com")
+	assert.Panics(t, func() { tenants.Get("/:id", (*Context).A) })
+}
@@ -0,0 +1,215 @@
+package web
+
+import (
+	"bytes"
+	"container/list"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ImageOptions configures Router.Images.
+type ImageOptions struct {
+	// MaxDim caps the width and height of served images. Defaults to 2048.
+	MaxDim int
+
+	// Formats lists the output formats clients may ask for with ?format=. Any of "jpeg", "png", and "gif".
+	// Defaults to all three. Images are served in their source format unless asked otherwise.
+	Formats []string
+
+	// CacheSize is how many rendered images are kept in memory. Defaults to 128. Set it to -1 to disable caching.
+	CacheSize int
+
+	// Key, if set, requires every image URL to be signed with it (see SignURL). Unsigned or expired URLs get a 403.
+	Key []byte
+}
+
+// Images adds a GET route at prefix + "/:name" serving images from source, resized to fit within the
+// ?w= and ?h= query params (keeping their aspect ratio, never enlarging them) and optionally converted to
+// another ?format=. name is a single path segment. Rendered images are cached in memory.
+func (r *Router) Images(prefix string, source http.FileSystem, opts ImageOptions) *Route {
+	if opts.MaxDim == 0 {
+		opts.MaxDim = 2048
+	}
+	if opts.Formats == nil {
+		opts.Formats = []string{"jpeg", "png", "gif"}
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = 128
+	}
+
+	server := &imageServer{source: source, opts: opts, cache: newImageCache(opts.CacheSize)}
+	return r.Get(prefix+"/:name", server.serve)
+}
+
+type imageServer struct {
+	source http.FileSystem
+	opts   ImageOptions
+	cache  *imageCache
+}
+
+type renderedImage struct {
+	contentType string
+	body        []byte
+}
+
+func (s *imageServer) serve(rw ResponseWriter, req *Request) {
+	if s.opts.Key != nil && !verifySignedURL(s.opts.Key, req.URL) {
+		renderError(rw, req, http.StatusForbidden, DefaultForbiddenResponse)
+		return
+	}
+
+	query := req.URL.Query()
+	width := s.dimension(query.Get("w"))
+	height := s.dimension(query.Get("h"))
+	format := query.Get("format")
+	if format != "" && !containsFold(s.opts.Formats, format) {
+		renderError(rw, req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+		return
+	}
+
+	name := req.PathParams["name"]
+	key := name + "|" + strconv.Itoa(width) + "|" + strconv.Itoa(height) + "|" + format
+	rendered, ok := s.cache.get(key)
+	if !ok {
+		var status int
+		rendered, status = s.render(name, width, height, format)
+		if rendered == nil {
+			renderError(rw, req, status, http.StatusText(status))
+			return
+		}
+		s.cache.add(key, rendered)
+	}
+
+	rw.Header().Set("Content-Type", rendered.contentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(rendered.body)))
+	rw.Write(rendered.body)
+}
+
+// dimension parses a ?w= or ?h= value. Missing, invalid, and too large values mean MaxDim.
+func (s *imageServer) dimension(value string) int {
+	d, err := strconv.Atoi(value)
+	if err != nil || d <= 0 || d > s.opts.MaxDim {
+		return s.opts.MaxDim
+	}
+	return d
+}
+
+// render loads, resizes and encodes the image. On failure it returns nil and the status to respond with.
+func (s *imageServer) render(name string, width, height int, format string) (*renderedImage, int) {
+	f, err := s.source.Open("/" + name)
+	if err != nil {
+		return nil, http.StatusNotFound
+	}
+	defer f.Close()
+
+	src, sourceFormat, err := image.Decode(f)
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType
+	}
+	if format == "" {
+		format = sourceFormat
+	}
+
+	var buf bytes.Buffer
+	dst := resizeToFit(src, width, height)
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, nil)
+	case "png":
+		err = png.Encode(&buf, dst)
+	case "gif":
+		err = gif.Encode(&buf, dst, nil)
+	default:
+		return nil, http.StatusUnsupportedMediaType
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError
+	}
+	return &renderedImage{contentType: "image/" + format, body: buf.Bytes()}, 0
+}
+
+// resizeToFit scales src down to fit within width x height, keeping its aspect ratio. Each destination pixel is
+// the average of the source pixels it covers. Images that already fit are returned as is.
+func resizeToFit(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= width && srcH <= height {
+		return src
+	}
+
+	dstW, dstH := width, srcH*width/srcW
+	if dstH > height {
+		dstW, dstH = srcW*height/srcH, height
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := bounds.Min.Y+y*srcH/dstH, bounds.Min.Y+(y+1)*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			x0, x1 := bounds.Min.X+x*srcW/dstW, bounds.Min.X+(x+1)*srcW/dstW
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// imageCache is a small LRU cache of rendered images.
+type imageCache struct {
+	sync.Mutex
+	size    int
+	order   *list.List // Most recently used at the front. Values are cache keys.
+	entries map[string]*list.Element
+	images  map[string]*renderedImage
+}
+
+func newImageCache(size int) *imageCache {
+	return &imageCache{size: size, order: list.New(), entries: make(map[string]*list.Element), images: make(map[string]*renderedImage)}
+}
+
+func (c *imageCache) get(key string) (*renderedImage, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return c.images[key], true
+}
+
+func (c *imageCache) add(key string, img *renderedImage) {
+	if c.size < 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = c.order.PushFront(key)
+	c.images[key] = img
+	for c.order.Len() > c.size {
+		oldest := c.order.Remove(c.order.Back()).(string)
+		delete(c.entries, oldest)
+		delete(c.images, oldest)
+	}
+}
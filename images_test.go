@@ -0,0 +1,93 @@
+package web
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestPNG(t *testing.T, dir string, name string, width, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{200, 100, 50, 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImages(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPNG(t, dir, "cat.png", 40, 20)
+
+	router := New(Context{})
+	router.Images("/img", http.Dir(dir), ImageOptions{MaxDim: 30})
+
+	rw, req := newTestRequest("GET", "/img/cat.png?w=10&h=10")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "image/png", rw.Header().Get("Content-Type"))
+	img, _, err := image.Decode(rw.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 10, 5), img.Bounds())
+	r, g, b, _ := img.At(3, 3).RGBA()
+	assert.Equal(t, []uint32{200, 100, 50}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	// MaxDim caps the size even without w/h.
+	rw, req = newTestRequest("GET", "/img/cat.png?format=jpeg")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "image/jpeg", rw.Header().Get("Content-Type"))
+	img, format, err := image.Decode(rw.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 30, 15), img.Bounds())
+
+	// Served from the cache once rendered.
+	os.Remove(filepath.Join(dir, "cat.png"))
+	rw, req = newTestRequest("GET", "/img/cat.png?w=10&h=10")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	rw, req = newTestRequest("GET", "/img/dog.png")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	rw, req = newTestRequest("GET", "/img/cat.png?format=bmp")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Bad Request", http.StatusBadRequest)
+}
+
+func TestImagesSigned(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPNG(t, dir, "cat.png", 4, 4)
+	key := []byte("secret")
+
+	router := New(Context{})
+	router.Images("/img", http.Dir(dir), ImageOptions{Key: key})
+
+	rw, req := newTestRequest("GET", "/img/cat.png?w=2")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	signed, err := signURL(key, "/img/cat.png?w=2", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	rw, req = newTestRequest("GET", signed)
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	expired, _ := signURL(key, "/img/cat.png?w=2", time.Now().Add(-time.Minute))
+	rw, req = newTestRequest("GET", expired)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+}
@@ -68,6 +68,12 @@ func TestInvalidMiddleware(t *testing.T) {
 	assert.Panics(t, func() {
 		router.Middleware((*Context).InvalidHandler)
 	})
+
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	route := admin.Get("/action", (*AdminContext).B)
+	assert.Panics(t, func() {
+		route.Use((*Context).mwAlpha)
+	})
 }
 
 func TestInvalidNotFound(t *testing.T) {
@@ -84,6 +90,19 @@ func TestInvalidNotFound(t *testing.T) {
 	})
 }
 
+func TestInvalidMethodNotAllowed(t *testing.T) {
+	router := New(Context{})
+
+	assert.Panics(t, func() {
+		router.MethodNotAllowed((*Context).InvalidHandler)
+	})
+
+	subrouter := router.Subrouter(Context{}, "")
+	assert.Panics(t, func() {
+		subrouter.MethodNotAllowed((*Context).A)
+	})
+}
+
 func TestInvalidError(t *testing.T) {
 	router := New(Context{})
 
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IsolationOptions configures Router.Isolate.
+type IsolationOptions struct {
+	// TimeBudget, if set, is the deadline of the request's context in the isolated router, and requests taking
+	// longer count as failures. Go can't stop a goroutine, so handlers must watch the context to give up.
+	TimeBudget time.Duration
+
+	// MaxFailures is how many failures in a row disable the router. Defaults to 5.
+	MaxFailures int
+
+	// Cooldown is how long a disabled router stays disabled. Defaults to 1 minute.
+	Cooldown time.Duration
+
+	// OnDisable, if set, is called when the router is disabled, eg to alert someone.
+	OnDisable func(name string)
+}
+
+// IsolatedPanic is what's reported to PanicHandler for panics in an isolated router, attributing them to it.
+type IsolatedPanic struct {
+	Name  string      // the name the router was isolated with
+	Value interface{} // the result of calling recover
+}
+
+func (p *IsolatedPanic) String() string {
+	return fmt.Sprintf("%s: %v", p.Name, p.Value)
+}
+
+// DefaultDisabledResponse is the default text rendered for requests to a disabled isolated router.
+var DefaultDisabledResponse = "Service Unavailable"
+
+// isolation is the state of an isolated router.
+type isolation struct {
+	name string
+	opts IsolationOptions
+
+	mu            sync.Mutex
+	failures      int // in a row
+	disabledUntil time.Time
+}
+
+// Isolate treats the router's routes, and those of its subrouters, as untrusted, eg when they're registered by
+// plugins, and returns the router:
+//
+//	plugin.Register(router.Subrouter(Context{}, "/plugins/reports").Isolate("reports", web.IsolationOptions{}))
+//
+// Panics in the router's middleware and handlers are reported to PanicHandler as an IsolatedPanic naming it, and
+// count as failures, as do requests over the TimeBudget (coded errors, see CodedError, don't). After MaxFailures
+// in a row, the router is disabled for the Cooldown: its requests get a 503 with a Retry-After header. Note that
+// Go can't limit the memory of a handler, so a plugin can still exhaust the process's memory.
+func (r *Router) Isolate(name string, opts IsolationOptions) *Router {
+	if r.isolation != nil {
+		panic("You can only isolate a router once.")
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = 5
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = time.Minute
+	}
+	iso := &isolation{name: name, opts: opts}
+	r.isolation = iso
+	// The isolation runs first, around all of the router's middleware.
+	r.middleware = append([]*middlewareHandler{newMiddlewareHandler(iso.middleware, r.contextType, "Isolate")}, r.middleware...)
+	return r
+}
+
+func (iso *isolation) middleware(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+	iso.mu.Lock()
+	wait := time.Until(iso.disabledUntil)
+	iso.mu.Unlock()
+	if wait > 0 {
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		renderError(rw, req, http.StatusServiceUnavailable, DefaultDisabledResponse)
+		return
+	}
+
+	start := time.Now()
+	if iso.opts.TimeBudget > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), iso.opts.TimeBudget)
+		defer cancel()
+		req.SetContext(ctx)
+	}
+	next(rw, req)
+
+	if iso.opts.TimeBudget > 0 && time.Since(start) > iso.opts.TimeBudget {
+		iso.fail()
+		return
+	}
+	iso.mu.Lock()
+	iso.failures = 0
+	iso.mu.Unlock()
+}
+
+// fail records a failure, disabling the router after MaxFailures in a row.
+func (iso *isolation) fail() {
+	iso.mu.Lock()
+	iso.failures++
+	disable := iso.failures >= iso.opts.MaxFailures
+	if disable {
+		iso.failures = 0
+		iso.disabledUntil = time.Now().Add(iso.opts.Cooldown)
+	}
+	iso.mu.Unlock()
+	if disable && iso.opts.OnDisable != nil {
+		iso.opts.OnDisable(iso.name)
+	}
+}
+
+// isolationFor returns the isolation of the nearest isolated router in chain, or nil if there's none.
+func isolationFor(chain []*Router) *isolation {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].isolation != nil {
+			return chain[i].isolation
+		}
+	}
+	return nil
+}
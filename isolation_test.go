@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type errCapturingReporter struct {
+	errs []interface{}
+}
+
+func (r *errCapturingReporter) Panic(url string, err interface{}, stack string) {
+	r.errs = append(r.errs, err)
+}
+
+func TestIsolatePanics(t *testing.T) {
+	reporter := &errCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	var disabled []string
+	router := New(Context{})
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("home"))
+	})
+	plugin := router.Subrouter(Context{}, "/plugins/reports").Isolate("reports", IsolationOptions{
+		MaxFailures: 2,
+		OnDisable:   func(name string) { disabled = append(disabled, name) },
+	})
+	fail := true
+	plugin.Get("/", func(rw ResponseWriter, req *Request) {
+		if fail {
+			panic("boom")
+		}
+		rw.Write([]byte("report"))
+	})
+	plugin.Get("/missing", func(rw ResponseWriter, req *Request) {
+		panic(Coded("REPORT_NOT_FOUND"))
+	})
+
+	serve := func(path string) *httpResponse {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		return &httpResponse{rw.Code, rw.Header(), rw.Body.String()}
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, serve("/plugins/reports").code)
+	assert.Equal(t, 1, len(reporter.errs))
+	assert.Equal(t, "reports: boom", reporter.errs[0].(*IsolatedPanic).String())
+
+	// Coded errors aren't failures, and successes reset the count.
+	fail = false
+	assert.Equal(t, http.StatusInternalServerError, serve("/plugins/reports/missing").code)
+	assert.Equal(t, http.StatusOK, serve("/plugins/reports").code)
+	fail = true
+	serve("/plugins/reports")
+	assert.Equal(t, 0, len(disabled))
+	serve("/plugins/reports")
+	assert.Equal(t, []string{"reports"}, disabled)
+
+	fail = false
+	resp := serve("/plugins/reports")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.code)
+	assert.Equal(t, "60", resp.header.Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, serve("/").code)
+	assert.Equal(t, 3, len(reporter.errs))
+
+	plugin.isolation.disabledUntil = time.Time{}
+	assert.Equal(t, http.StatusOK, serve("/plugins/reports").code)
+}
+
+func TestIsolateTimeBudget(t *testing.T) {
+	router := New(Context{})
+	plugin := router.Subrouter(Context{}, "/plugin").Isolate("slow", IsolationOptions{
+		TimeBudget:  10 * time.Millisecond,
+		MaxFailures: 1,
+	})
+	plugin.Get("/", func(rw ResponseWriter, req *Request) {
+		<-req.Context().Done()
+		rw.Write([]byte(req.Context().Err().Error()))
+	})
+
+	rw, req := newTestRequest("GET", "/plugin")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context deadline exceeded", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/plugin")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Service Unavailable", http.StatusServiceUnavailable)
+}
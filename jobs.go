@@ -0,0 +1,195 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// JobStatusRoute is the name of the route Router.Jobs registers.
+const JobStatusRoute = "job_status"
+
+// States of a JobStatus.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// JobStatus is what the job status route reports about a job.
+type JobStatus struct {
+	ID       string      `json:"id"`
+	State    string      `json:"state"`
+	Progress float64     `json:"progress"` // from 0 to 1
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// JobStore keeps the status of jobs, eg in a database shared by several processes. Its methods may be called
+// concurrently.
+type JobStore interface {
+	Save(status JobStatus) error
+	// Load returns false if there is no job with the id.
+	Load(id string) (JobStatus, bool, error)
+}
+
+// Task is the work of a job. It reports how far along it is (from 0 to 1) with progress, and returns the job's
+// result, which the job status route encodes as JSON. progress must not be called concurrently.
+type Task func(ctx context.Context, progress func(float64)) (interface{}, error)
+
+// TaskRunner starts tasks, eg on a bounded pool of workers or on a queue.
+type TaskRunner interface {
+	Run(fn func())
+}
+
+// TaskRunnerFunc is a function that implements TaskRunner.
+type TaskRunnerFunc func(fn func())
+
+// Run implements TaskRunner.
+func (f TaskRunnerFunc) Run(fn func()) {
+	f(fn)
+}
+
+// GoTaskRunner runs each task in its own goroutine.
+var GoTaskRunner = TaskRunnerFunc(func(fn func()) { go fn() })
+
+// Jobs runs tasks in the background for handlers that respond with a 202 right away. See Router.Jobs.
+type Jobs struct {
+	// Runner starts the tasks. Defaults to GoTaskRunner.
+	Runner TaskRunner
+
+	router *Router
+	store  JobStore
+}
+
+// Jobs registers a route named JobStatusRoute at path, which must have an :id param, and returns a Jobs whose
+// status that route reports from store:
+//
+//	jobs := router.Jobs("/jobs/:id", web.NewMemoryJobStore())
+//
+//	func (c *Context) Export(rw web.ResponseWriter, req *web.Request) {
+//		jobs.Enqueue(rw, req, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+//			return c.export(ctx, progress)
+//		})
+//	}
+//
+// The status route responds with the job's JobStatus as JSON.
+func (r *Router) Jobs(path string, store JobStore) *Jobs {
+	jobs := &Jobs{Runner: GoTaskRunner, router: r, store: store}
+	r.Get(path, jobs.statusHandler).Named(JobStatusRoute)
+	return jobs
+}
+
+// Enqueue starts task and responds with a 202 whose Location header points to the job's status (see Accepted).
+// It returns the job's ID.
+func (j *Jobs) Enqueue(rw ResponseWriter, req *Request, task Task) (string, error) {
+	status := JobStatus{ID: newRequestID(), State: JobPending}
+	if err := j.store.Save(status); err != nil {
+		return "", err
+	}
+
+	ctx := context.WithoutCancel(req.Context())
+	url := fmt.Sprint(req.URL)
+	j.Runner.Run(func() { j.run(ctx, url, status.ID, task) })
+
+	return status.ID, j.Accepted(rw, status.ID)
+}
+
+// StatusURL returns the path of the status of the job with id.
+func (j *Jobs) StatusURL(id string) (string, error) {
+	return j.router.MappedUrlFor(JobStatusRoute, Query{"id": id})
+}
+
+// Accepted responds with a 202 whose Location header points to the status of the job with id, and with the
+// job's status as JSON. Use it for jobs started some other way than Enqueue.
+func (j *Jobs) Accepted(rw ResponseWriter, id string) error {
+	location, err := j.StatusURL(id)
+	if err != nil {
+		return err
+	}
+	status, _, err := j.store.Load(id)
+	if err != nil {
+		return err
+	}
+	rw.Header().Set("Location", location)
+	return writeJobStatus(rw, http.StatusAccepted, status)
+}
+
+func (j *Jobs) run(ctx context.Context, url string, id string, task Task) {
+	status := JobStatus{ID: id, State: JobRunning}
+	j.store.Save(status)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			PanicHandler.Panic(url, recovered, formatFrames(panicFrames()))
+			status.State, status.Error = JobFailed, DefaultPanicResponse
+			j.store.Save(status)
+		}
+	}()
+
+	result, err := task(ctx, func(progress float64) {
+		status.Progress = progress
+		j.store.Save(status)
+	})
+	if err != nil {
+		status.State, status.Error = JobFailed, err.Error()
+	} else {
+		status.State, status.Progress, status.Result = JobDone, 1, result
+	}
+	j.store.Save(status)
+}
+
+func (j *Jobs) statusHandler(rw ResponseWriter, req *Request) {
+	status, ok, err := j.store.Load(req.PathParams["id"])
+	if err != nil {
+		panic(err)
+	}
+	if !ok {
+		renderError(rw, req, http.StatusNotFound, DefaultNotFoundResponse)
+		return
+	}
+	if err := writeJobStatus(rw, http.StatusOK, status); err != nil {
+		panic(err)
+	}
+}
+
+func writeJobStatus(rw ResponseWriter, code int, status JobStatus) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(code)
+	rw.Write(body)
+	return nil
+}
+
+// MemoryJobStore is a JobStore for a single process. It never forgets jobs.
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]JobStatus
+}
+
+// NewMemoryJobStore returns an empty MemoryJobStore.
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]JobStatus)}
+}
+
+// Save implements JobStore.
+func (s *MemoryJobStore) Save(status JobStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[status.ID] = status
+	return nil
+}
+
+// Load implements JobStore.
+func (s *MemoryJobStore) Load(id string) (JobStatus, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.jobs[id]
+	return status, ok, nil
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobs(t *testing.T) {
+	var tasks []func()
+	router := New(Context{})
+	jobs := router.Jobs("/jobs/:id", NewMemoryJobStore())
+	jobs.Runner = TaskRunnerFunc(func(fn func()) { tasks = append(tasks, fn) })
+
+	progressed := make(chan bool)
+	router.Post("/exports", func(rw ResponseWriter, req *Request) {
+		_, err := jobs.Enqueue(rw, req, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+			progress(0.5)
+			<-progressed
+			return map[string]int{"rows": 3}, nil
+		})
+		assert.NoError(t, err)
+	})
+	router.Post("/failing", func(rw ResponseWriter, req *Request) {
+		jobs.Enqueue(rw, req, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+			return nil, errors.New("disk full")
+		})
+	})
+
+	status := func(location string) JobStatus {
+		rw, req := newTestRequest("GET", location)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		var s JobStatus
+		assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &s))
+		return s
+	}
+
+	rw, req := newTestRequest("POST", "/exports")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	location := rw.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/jobs/"), location)
+	assert.Equal(t, JobPending, status(location).State)
+
+	done := make(chan bool)
+	go func() {
+		tasks[0]()
+		done <- true
+	}()
+	for status(location).Progress != 0.5 {
+	}
+	assert.Equal(t, JobRunning, status(location).State)
+	progressed <- true
+	<-done
+
+	s := status(location)
+	assert.Equal(t, JobDone, s.State)
+	assert.Equal(t, 1.0, s.Progress)
+	assert.Equal(t, map[string]interface{}{"rows": 3.0}, s.Result)
+
+	rw, req = newTestRequest("POST", "/failing")
+	router.ServeHTTP(rw, req)
+	tasks[1]()
+	s = status(rw.Header().Get("Location"))
+	assert.Equal(t, JobFailed, s.State)
+	assert.Equal(t, "disk full", s.Error)
+
+	rw, req = newTestRequest("GET", "/jobs/unknown")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+}
+
+func TestJobsPanic(t *testing.T) {
+	reporter := &stackCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	router := New(Context{})
+	jobs := router.Jobs("/jobs/:id", NewMemoryJobStore())
+	jobs.Runner = TaskRunnerFunc(func(fn func()) { fn() })
+	router.Post("/exports", func(rw ResponseWriter, req *Request) {
+		jobs.Enqueue(rw, req, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+			panic("boom")
+		})
+	})
+
+	rw, req := newTestRequest("POST", "/exports")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+
+	var s JobStatus
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &s))
+	assert.Equal(t, JobFailed, s.State)
+	assert.Equal(t, DefaultPanicResponse, s.Error)
+}
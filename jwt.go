@@ -0,0 +1,401 @@
+package web
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTOptions configures JWTMiddleware.
+type JWTOptions struct {
+	// Keys finds the key a token was signed with. See JWTKeyMap and NewJWKS.
+	Keys JWTKeys
+
+	// Issuer and Audience, if set, must match the token's "iss" claim and be one of its "aud".
+	Issuer   string
+	Audience string
+
+	// Leeway allows for clock skew between the token's issuer and this server when checking "exp" and "nbf".
+	Leeway time.Duration
+}
+
+// JWTKeys finds the key with ID kid (the token's "kid" header, which may be empty) to verify a token's signature
+// with: a []byte for HS256, HS384 and HS512, an *rsa.PublicKey for RS* and PS*, or an *ecdsa.PublicKey for ES*.
+// Tokens are only accepted with the algorithms that fit their key, so an RSA public key can't be used as an HMAC
+// secret.
+type JWTKeys interface {
+	JWTKey(ctx context.Context, kid string) (interface{}, error)
+}
+
+// JWTKeyMap is a fixed set of JWTKeys, by key ID. Tokens without a "kid" use the key under "".
+type JWTKeyMap map[string]interface{}
+
+// JWTKey implements JWTKeys.
+func (m JWTKeyMap) JWTKey(ctx context.Context, kid string) (interface{}, error) {
+	key, ok := m[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// JWTClaims are the claims of a verified token.
+type JWTClaims map[string]interface{}
+
+// Subject returns the "sub" claim, or "".
+func (c JWTClaims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+type jwtContextKey struct{}
+
+// JWTMiddleware returns middleware that requires an "Authorization: Bearer <token>" header with a JSON Web Token
+// signed with one of opts.Keys, that hasn't expired. Tokens must have an "exp" claim. Requests without a valid
+// token get a 401 with DefaultUnauthorizedResponse, and a WWW-Authenticate header saying what was wrong (RFC 6750).
+// The token's claims are available as Request.JWTClaims:
+//
+//	api.Middleware(web.JWTMiddleware(web.JWTOptions{Keys: web.NewJWKS("https://auth.example.com/.well-known/jwks.json"), Audience: "api"}))
+//	api.Get("/status", (*Context).Status).WithoutJWT()
+//
+// Add it to the router whose routes it protects, rather than to the root router, whose middleware runs before
+// requests are routed: that's what lets Route.WithoutJWT opt routes out.
+func JWTMiddleware(opts JWTOptions) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if req.route != nil && req.route.withoutJWT {
+			next(rw, req)
+			return
+		}
+		auth := req.Header.Get("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			renderError(rw, req, http.StatusUnauthorized, DefaultUnauthorizedResponse)
+			return
+		}
+		claims, err := verifyJWT(req.Context(), strings.TrimSpace(auth[7:]), opts, time.Now())
+		if err != nil {
+			rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, err.Error()))
+			renderError(rw, req, http.StatusUnauthorized, DefaultUnauthorizedResponse)
+			return
+		}
+		req.SetContext(context.WithValue(req.Context(), jwtContextKey{}, claims))
+		next(rw, req)
+	}
+}
+
+// WithoutJWT lets the route's requests through JWTMiddleware without a token, and returns the route.
+func (r *Route) WithoutJWT() *Route {
+	r.checkConfigurable()
+	r.withoutJWT = true
+	return r
+}
+
+// JWTClaims returns the claims of the request's token, or nil if it didn't go through JWTMiddleware.
+func (r *Request) JWTClaims() JWTClaims {
+	return JWTClaimsFromContext(r.Context())
+}
+
+// JWTClaimsFromContext is like Request.JWTClaims, for code that only has the request's context.
+func JWTClaimsFromContext(ctx context.Context) JWTClaims {
+	claims, _ := ctx.Value(jwtContextKey{}).(JWTClaims)
+	return claims
+}
+
+// verifyJWT checks token's signature and claims, and returns its claims.
+func verifyJWT(ctx context.Context, token string, opts JWTOptions, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	key, err := opts.Keys.JWTKey(ctx, header.Kid)
+	if err != nil {
+		// The error may be about fetching keys, which is none of the client's business.
+		return nil, errors.New("unknown signing key")
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(opts.Leeway)) {
+		return nil, errors.New("token has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(opts.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if opts.Issuer != "" && claims["iss"] != opts.Issuer {
+		return nil, errors.New("token has the wrong issuer")
+	}
+	if opts.Audience != "" && !jwtHasAudience(claims["aud"], opts.Audience) {
+		return nil, errors.New("token has the wrong audience")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil || json.Unmarshal(decoded, v) != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// jwtHasAudience returns true if aud, a string or an array of them, contains audience.
+func jwtHasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var jwtHashes = map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+// verifyJWTSignature checks signature of signed with key, which must fit alg.
+func verifyJWTSignature(alg string, key interface{}, signed string, signature []byte) error {
+	hash, ok := jwtHashes[strings.TrimLeft(alg, "HSRPE")]
+	if len(alg) != 5 || !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	invalid := errors.New("invalid signature")
+	switch key := key.(type) {
+	case []byte:
+		if alg[:2] != "HS" {
+			return fmt.Errorf("algorithm %q doesn't fit the key", alg)
+		}
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return invalid
+		}
+	case *rsa.PublicKey:
+		h := hash.New()
+		h.Write([]byte(signed))
+		var err error
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature)
+		case "PS":
+			err = rsa.VerifyPSS(key, hash, h.Sum(nil), signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		default:
+			return fmt.Errorf("algorithm %q doesn't fit the key", alg)
+		}
+		if err != nil {
+			return invalid
+		}
+	case *ecdsa.PublicKey:
+		bits := key.Curve.Params().BitSize
+		if bits != map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}[alg] {
+			return fmt.Errorf("algorithm %q doesn't fit the key", alg)
+		}
+		size := (bits + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		h := hash.New()
+		h.Write([]byte(signed))
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, h.Sum(nil), r, s) {
+			return invalid
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// JWKS is a set of JWTKeys published as a JSON Web Key Set, eg by an OAuth or OpenID Connect provider. Keys are
+// fetched on first use, and fetched again every RefreshInterval, or when a token names a key that isn't in the set
+// (at most once every MinRefreshInterval), so the provider can rotate its keys. Refreshes happen in the background,
+// one at a time, and the keys fetched before stay in use if one fails.
+type JWKS struct {
+	URL    string
+	Client *http.Client // a client with a 10 second timeout if nil
+
+	// RefreshInterval defaults to an hour, and MinRefreshInterval to a minute.
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]interface{}
+	fetched  time.Time  // when the last fetch started
+	fetching *jwksFetch // the fetch in progress, if any
+}
+
+// jwksFetch is a fetch of a JWKS's keys. err is set before done is closed.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// jwksClient is the client of JWKSs without one.
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewJWKS returns a JWKS fetched from url, with the default options.
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url}
+}
+
+// JWTKey implements JWTKeys. Tokens without a "kid" are accepted if the set has a single key. Requests for a key
+// that isn't in the set wait for the refresh it causes, until ctx is done; the others don't wait for refreshes.
+func (j *JWKS) JWTKey(ctx context.Context, kid string) (interface{}, error) {
+	refresh, minRefresh := j.RefreshInterval, j.MinRefreshInterval
+	if refresh == 0 {
+		refresh = time.Hour
+	}
+	if minRefresh == 0 {
+		minRefresh = time.Minute
+	}
+	j.mu.Lock()
+	key, ok := j.key(kid)
+	fetch := j.fetching
+	if since := time.Since(j.fetched); since >= refresh || (!ok && since >= minRefresh) {
+		fetch = j.startFetch()
+	}
+	j.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if fetch == nil {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	j.mu.Lock()
+	key, ok = j.key(kid)
+	j.mu.Unlock()
+	if !ok {
+		if fetch.err != nil {
+			return nil, fetch.err
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+func (j *JWKS) key(kid string) (interface{}, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// startFetch starts fetching the keys in the background, unless a fetch is in progress already, and returns the
+// fetch. The keys are only replaced if it succeeds. The caller must hold mu.
+func (j *JWKS) startFetch() *jwksFetch {
+	if j.fetching != nil {
+		return j.fetching
+	}
+	j.fetched = time.Now()
+	fetch := &jwksFetch{done: make(chan struct{})}
+	j.fetching = fetch
+	go func() {
+		keys, err := j.fetch()
+		j.mu.Lock()
+		if err == nil {
+			j.keys = keys
+		}
+		j.fetching = nil
+		j.mu.Unlock()
+		fetch.err = err
+		close(fetch.done)
+	}()
+	return fetch
+}
+
+// fetch returns the keys at URL. It doesn't use the context of the request that caused it, since the keys are
+// shared by all requests.
+func (j *JWKS) fetch() (map[string]interface{}, error) {
+	client := j.Client
+	if client == nil {
+		client = jwksClient
+	}
+	resp, err := client.Get(j.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", j.URL, resp.Status)
+	}
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", j.URL, err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN == nil && errE == nil {
+				keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+			}
+		case "EC":
+			curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if curve != nil && errX == nil && errY == nil {
+				keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			}
+		}
+	}
+	return keys, nil
+}
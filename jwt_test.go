@@ -0,0 +1,188 @@
+package web
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signTestJWT returns a token with header and claims, signed by sign.
+func signTestJWT(header, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(key []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	secret := []byte("secret")
+	router := New(Context{})
+	api := router.Subrouter(Context{}, "/api")
+	api.Middleware(JWTMiddleware(JWTOptions{Keys: JWTKeyMap{"": secret}, Issuer: "auth", Audience: "api"}))
+	api.Get("/me", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.JWTClaims().Subject()))
+	})
+	api.Get("/status", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("ok"))
+	}).WithoutJWT()
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		rw, req := newTestRequest("GET", path)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	hs := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+
+	rw := get("/api/me", signTestJWT(hs, map[string]interface{}{"sub": "bob", "iss": "auth", "aud": []string{"web", "api"}, "exp": exp}, hs256(secret)))
+	assertResponse(t, rw, "bob", 200)
+	assertResponse(t, get("/api/status", ""), "ok", 200)
+
+	rw = get("/api/me", "")
+	assertResponse(t, rw, "Unauthorized", 401)
+	assert.Equal(t, "Bearer", rw.Header().Get("WWW-Authenticate"))
+
+	for token, problem := range map[string]string{
+		"not-a-token": "malformed token",
+		signTestJWT(hs, map[string]interface{}{"sub": "bob", "iss": "auth", "aud": "api", "exp": exp}, hs256([]byte("wrong"))):                   "invalid signature",
+		signTestJWT(hs, map[string]interface{}{"sub": "bob", "iss": "auth", "aud": "api", "exp": exp - 7200}, hs256(secret)):                     "token has expired",
+		signTestJWT(hs, map[string]interface{}{"sub": "bob", "iss": "auth", "aud": "api"}, hs256(secret)):                                        "token has no expiry",
+		signTestJWT(hs, map[string]interface{}{"sub": "bob", "iss": "evil", "aud": "api", "exp": exp}, hs256(secret)):                            "token has the wrong issuer",
+		signTestJWT(hs, map[string]interface{}{"sub": "bob", "iss": "auth", "aud": "web", "exp": exp}, hs256(secret)):                            "token has the wrong audience",
+		signTestJWT(map[string]interface{}{"alg": "none"}, map[string]interface{}{"sub": "bob", "exp": exp}, func([]byte) []byte { return nil }): `unsupported algorithm "none"`,
+	} {
+		rw = get("/api/me", token)
+		assertResponse(t, rw, "Unauthorized", 401)
+		assert.Equal(t, fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, problem), rw.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	fetches := 0
+	var published []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fetches++
+		json.NewEncoder(rw).Encode(map[string]interface{}{"keys": published})
+	}))
+	defer server.Close()
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	published = []map[string]string{
+		{"kid": "ec", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}
+
+	router := New(Context{})
+	router.Middleware(JWTMiddleware(JWTOptions{Keys: NewJWKS(server.URL)}))
+	router.Get("/me", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.JWTClaims().Subject()))
+	})
+	get := func(token string) *httptest.ResponseRecorder {
+		rw, req := newTestRequest("GET", "/me")
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	claims := map[string]interface{}{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix()}
+
+	es256 := signTestJWT(map[string]interface{}{"alg": "ES256", "kid": "ec"}, claims, func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		assert.NoError(t, err)
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	})
+	assertResponse(t, get(es256), "bob", 200)
+	assertResponse(t, get(es256), "bob", 200)
+	assert.Equal(t, 1, fetches)
+
+	// A new key isn't fetched before MinRefreshInterval.
+	published = append(published, map[string]string{"kid": "rsa", "kty": "RSA", "n": b64(rsaKey.N.Bytes()), "e": b64([]byte{1, 0, 1})})
+	rs256 := signTestJWT(map[string]interface{}{"alg": "RS256", "kid": "rsa"}, claims, func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+		return signature
+	})
+	assertResponse(t, get(rs256), "Unauthorized", 401)
+	assert.Equal(t, 1, fetches)
+
+	router = New(Context{})
+	router.Middleware(JWTMiddleware(JWTOptions{Keys: &JWKS{URL: server.URL, MinRefreshInterval: time.Nanosecond}}))
+	router.Get("/me", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.JWTClaims().Subject()))
+	})
+	assertResponse(t, get(rs256), "bob", 200)
+
+	// The RSA public key must not be usable as an HMAC secret.
+	forged := signTestJWT(map[string]interface{}{"alg": "HS256", "kid": "rsa"}, claims, hs256(rsaKey.N.Bytes()))
+	rw := get(forged)
+	assertResponse(t, rw, "Unauthorized", 401)
+	assert.True(t, strings.Contains(rw.Header().Get("WWW-Authenticate"), "doesn't fit the key"))
+}
+
+func TestJWKSRefreshFailure(t *testing.T) {
+	var mu sync.Mutex
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(rw).Encode(map[string]interface{}{"keys": []map[string]string{{"kid": "ec", "kty": "EC", "crv": "P-256", "x": "AA", "y": "AA"}}})
+	}))
+	defer server.Close()
+
+	jwks := &JWKS{URL: server.URL, RefreshInterval: time.Nanosecond, MinRefreshInterval: time.Nanosecond}
+	key, err := jwks.JWTKey(context.Background(), "ec")
+	assert.NoError(t, err)
+	assert.NotNil(t, key)
+
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	for i := 0; i < 3; i++ {
+		// Refreshes fail in the background, and the keys fetched before stay in use.
+		key, err = jwks.JWTKey(context.Background(), "ec")
+		assert.NoError(t, err)
+		assert.NotNil(t, key)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A key that isn't in the set waits for the refresh, and gets its error.
+	_, err = jwks.JWTKey(context.Background(), "other")
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "500"), err.Error())
+	}
+}
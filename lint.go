@@ -0,0 +1,93 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Kinds of LintFinding.
+const (
+	// LintOverlap is reported for a route whose constraints may let an earlier route at the same path
+	// take some of its requests, eg /:id:\d+ registered before /:id:[0-9a-f]+.
+	LintOverlap = "overlap"
+	// LintUnnamed is reported for a route without a name, which can't be used with UrlFor.
+	LintUnnamed = "unnamed"
+	// LintMissingOptions is reported for a path with routes but no OPTIONS route, if AutoOptions is off. HEAD
+	// requests are routed to GET routes, so a missing HEAD route is never reported.
+	LintMissingOptions = "missing_options"
+)
+
+// LintFinding is a possible problem with a route table, found by Router.Lint.
+type LintFinding struct {
+	Kind    string `json:"kind"`
+	Method  string `json:"method,omitempty"` // empty for findings about a path, rather than a route
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Lint checks every route of the router tree r belongs to and returns its findings, sorted by path, method
+// and kind. Findings can be encoded as JSON, eg to fail a CI build on any overlap. Routes that could never
+// be matched at all are already rejected when they are registered. Lint can't see into handlers, so it
+// doesn't report path params that handlers ignore.
+func (r *Router) Lint() []LintFinding {
+	var findings []LintFinding
+	table := r.tables.current.Load()
+	for _, method := range table.methods() {
+		findings = lintNode(table.trees[method], findings)
+	}
+
+	methodsByPath := make(map[string][]string)
+	for _, route := range r.Snapshot().Routes {
+		methodsByPath[route.Path] = append(methodsByPath[route.Path], route.Method)
+		if route.Name == "" {
+			findings = append(findings, LintFinding{Kind: LintUnnamed, Method: route.Method, Path: route.Path, Message: "the route has no name"})
+		}
+	}
+	for path, methods := range methodsByPath {
+		if getRootRouter(r).autoOptionsDisabled && !containsString(methods, string(httpMethodOptions)) {
+			findings = append(findings, LintFinding{Kind: LintMissingOptions, Path: path, Message: "the path has no OPTIONS route"})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Kind < b.Kind
+	})
+	return findings
+}
+
+// lintNode appends overlap findings for pn and its descendants to findings.
+func lintNode(pn *pathNode, findings []LintFinding) []LintFinding {
+	for i, leaf := range pn.leaves {
+		for _, earlier := range pn.leaves[:i] {
+			if earlier.constraints != nil && leaf.constraints != nil {
+				findings = append(findings, LintFinding{
+					Kind:    LintOverlap,
+					Method:  string(leaf.route.method),
+					Path:    leaf.route.path,
+					Message: fmt.Sprintf("%s %s (registered earlier) may match some of its requests", earlier.route.method, earlier.route.path),
+				})
+				break
+			}
+		}
+	}
+	for _, edge := range pn.edges {
+		findings = lintNode(edge.node, findings)
+	}
+	for _, node := range pn.edgeMap {
+		findings = lintNode(node, findings)
+	}
+	if pn.wildcard != nil {
+		findings = lintNode(pn.wildcard, findings)
+	}
+	if pn.catchAll != nil {
+		findings = lintNode(pn.catchAll, findings)
+	}
+	return findings
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	router := New(Context{}).AutoOptions(false)
+	router.Get("/users/:id:\\d+", (*Context).A).Named("user")
+	router.Get("/users/:id:[0-9a-f]+", (*Context).A).Named("user_hex")
+	router.Get("/users/:id", (*Context).A).Named("user_any")
+	router.Options("/users/:id", (*Context).A).Named("user_options")
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Post("/reports", (*AdminContext).B)
+
+	findings := router.Lint()
+	assert.Equal(t, []LintFinding{
+		{Kind: LintMissingOptions, Path: "/admin/reports", Message: "the path has no OPTIONS route"},
+		{Kind: LintUnnamed, Method: "POST", Path: "/admin/reports", Message: "the route has no name"},
+		{Kind: LintMissingOptions, Path: "/users/:id:[0-9a-f]+", Message: "the path has no OPTIONS route"},
+		{Kind: LintOverlap, Method: "GET", Path: "/users/:id:[0-9a-f]+", Message: "GET /users/:id:\\d+ (registered earlier) may match some of its requests"},
+		{Kind: LintMissingOptions, Path: "/users/:id:\\d+", Message: "the path has no OPTIONS route"},
+	}, findings)
+
+	encoded, err := json.Marshal(findings[1])
+	assert.NoError(t, err)
+	assert.Equal(t, `{"kind":"unnamed","method":"POST","path":"/admin/reports","message":"the route has no name"}`, string(encoded))
+
+	assert.Equal(t, 0, len(New(Context{}).Lint()))
+
+	router = New(Context{})
+	router.Post("/reports", (*Context).A).Named("reports")
+	assert.Equal(t, 0, len(router.Lint()))
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -9,13 +10,31 @@ import (
 // Logger can be set to your own logger. Logger only applies to the LoggerMiddleware.
 var Logger = log.New(os.Stdout, "", 0)
 
-// LoggerMiddleware is generic middleware that will log requests to Logger (by default, Stdout).
+// LoggerMiddleware is generic middleware that will log requests to Logger (by default, Stdout). Requests whose
+// client went away are marked "(client disconnected)", and requests with an ID (see RequestIDMiddleware) end
+// with it, eg "request_id=5f2b9c1e0a7d4e83".
 func LoggerMiddleware(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+	logRequest(Logger, rw, req, next)
+}
+
+// NewLoggerMiddleware returns middleware like LoggerMiddleware, logging to l instead of Logger.
+func NewLoggerMiddleware(l *log.Logger) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		logRequest(l, rw, req, next)
+	}
+}
+
+func logRequest(logger *log.Logger, rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
 	startTime := time.Now()
 
 	next(rw, req)
 
-	duration := time.Since(startTime).Nanoseconds()
+	logger.Println(accessLogLine(time.Since(startTime), rw.StatusCode(), req.URL.Path, req.Disconnected(), req.RequestID()))
+}
+
+// accessLogLine formats a request like LoggerMiddleware, eg "[12 ms] 200 '/users' request_id=5f2b9c1e0a7d4e83".
+func accessLogLine(d time.Duration, status int, path string, disconnected bool, requestID string) string {
+	duration := d.Nanoseconds()
 	var durationUnits string
 	switch {
 	case duration > 2000000:
@@ -28,5 +47,12 @@ func LoggerMiddleware(rw ResponseWriter, req *Request, next NextMiddlewareFunc)
 		durationUnits = "ns"
 	}
 
-	Logger.Printf("[%d %s] %d '%s'\n", duration, durationUnits, rw.StatusCode(), req.URL.Path)
+	var suffix string
+	if disconnected {
+		suffix += " (client disconnected)"
+	}
+	if requestID != "" {
+		suffix += " request_id=" + requestID
+	}
+	return fmt.Sprintf("[%d %s] %d '%s'%s", duration, durationUnits, status, path, suffix)
 }
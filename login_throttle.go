@@ -0,0 +1,269 @@
+package web
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LoginThrottle slows down password guessing by counting failed logins per account and per client IP. After
+// the free attempts are used up, each further failure locks the account (or IP) out for twice as long as the
+// previous one, up to MaxLockout. A successful login resets the account's counter, and takes back its attempt
+// from the IP's.
+//
+// Handlers call Reserve before verifying credentials, which counts the attempt as a failure unless the account or
+// IP is locked out, and Succeeded if the credentials turn out to be right. As the attempt is counted before it's
+// made, concurrent logins can't all get through while the counter is still below the limit. Middleware rejects
+// locked-out requests before they reach the handler. The zero value of each field is its default.
+type LoginThrottle struct {
+	Store LoginAttemptStore
+
+	// AccountAttempts and IPAttempts are the failures allowed before a lockout. Default to 5 and 20.
+	AccountAttempts int
+	IPAttempts      int
+
+	// BaseLockout is the first lockout, and MaxLockout the longest. Default to 1 second and 1 hour.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+
+	// Window is how long failures are counted for after the last one. Defaults to 24 hours.
+	Window time.Duration
+}
+
+// LoginAttempts are the failures recorded for an account or IP.
+type LoginAttempts struct {
+	Failures int
+	Last     time.Time
+}
+
+// LoginLimit is how many failures a key is allowed, and how long it's locked out for after that. A LoginThrottle
+// passes its limits to its LoginAttemptStore, whose Reserve applies them.
+type LoginLimit struct {
+	Attempts    int           // the failures allowed before a lockout
+	BaseLockout time.Duration // the first lockout, doubled by each further failure
+	MaxLockout  time.Duration // the longest lockout
+	Window      time.Duration // how long failures are counted for after the last one
+}
+
+// Wait returns how much longer attempts lock a key out for at now, or 0 if another attempt may be made.
+func (l LoginLimit) Wait(attempts LoginAttempts, now time.Time) time.Duration {
+	if attempts.Failures < l.Attempts || now.Sub(attempts.Last) > l.Window {
+		return 0
+	}
+	lockout := l.MaxLockout
+	if shift := attempts.Failures - l.Attempts; shift < 32 {
+		if d := l.BaseLockout << uint(shift); d > 0 && d < lockout {
+			lockout = d
+		}
+	}
+	if wait := attempts.Last.Add(lockout).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// LoginAttemptStore persists LoginAttempts, eg in Redis so that all servers share the counters.
+type LoginAttemptStore interface {
+	// Get returns the attempts recorded for key, or zero LoginAttempts if there are none.
+	Get(key string) (LoginAttempts, error)
+	// Reserve returns how much longer key is locked out for at now under limit (see LoginLimit.Wait), or records an
+	// attempt for key at now, counted as a failure, and returns 0. Failures before now-limit.Window are forgotten,
+	// and the store may forget all of them after limit.Window. Reserve must be atomic, so that concurrent attempts
+	// can't all pass the check before any of them is counted; a Redis store can use a Lua script.
+	Reserve(key string, now time.Time, limit LoginLimit) (time.Duration, error)
+	// Release takes back an attempt Reserve recorded for key.
+	Release(key string, now time.Time, limit LoginLimit) error
+	// Delete forgets the attempts recorded for key.
+	Delete(key string) error
+}
+
+// DefaultLockedOutResponse is the default text rendered when LoginThrottle.Middleware rejects a request.
+var DefaultLockedOutResponse = "Too Many Login Attempts"
+
+// NewLoginThrottle returns a LoginThrottle with the default limits, recording attempts in store.
+func NewLoginThrottle(store LoginAttemptStore) *LoginThrottle {
+	return &LoginThrottle{
+		Store:           store,
+		AccountAttempts: 5,
+		IPAttempts:      20,
+		BaseLockout:     time.Second,
+		MaxLockout:      time.Hour,
+		Window:          24 * time.Hour,
+	}
+}
+
+// Check returns how much longer account or ip is locked out for, or 0 if a login may be attempted. It doesn't
+// record anything; call Reserve before verifying credentials.
+func (lt *LoginThrottle) Check(account, ip string) (time.Duration, error) {
+	now := time.Now()
+	accountWait, err := lt.wait("account:"+account, lt.accountLimit(), now)
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := lt.wait("ip:"+ip, lt.ipLimit(), now)
+	if err != nil {
+		return 0, err
+	}
+	if ipWait > accountWait {
+		return ipWait, nil
+	}
+	return accountWait, nil
+}
+
+// Reserve records a login attempt for account from ip, counted as a failure until Succeeded is called, and
+// returns 0. If account or ip is locked out, it records nothing and returns how much longer for.
+func (lt *LoginThrottle) Reserve(account, ip string) (time.Duration, error) {
+	now := time.Now()
+	wait, err := lt.Store.Reserve("account:"+account, now, lt.accountLimit())
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	wait, err = lt.Store.Reserve("ip:"+ip, now, lt.ipLimit())
+	if err != nil || wait > 0 {
+		if releaseErr := lt.Store.Release("account:"+account, now, lt.accountLimit()); err == nil {
+			err = releaseErr
+		}
+		return wait, err
+	}
+	return 0, nil
+}
+
+// Succeeded records that the login Reserve recorded for account from ip succeeded, resetting the account's
+// failures and taking the attempt back from the IP's.
+func (lt *LoginThrottle) Succeeded(account, ip string) error {
+	if err := lt.Store.Delete("account:" + account); err != nil {
+		return err
+	}
+	return lt.Store.Release("ip:"+ip, time.Now(), lt.ipLimit())
+}
+
+// Middleware returns middleware that rejects requests with 429 and a Retry-After header while the account
+// returned by account (eg req.PostFormValue("email")) or the client's IP is locked out. Handlers still need to
+// call Reserve, as requests that get through at the same time aren't counted yet.
+func (lt *LoginThrottle) Middleware(account func(req *Request) string) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		wait, err := lt.Check(account(req), ClientIP(req))
+		if err != nil {
+			panic(err)
+		}
+		if wait > 0 {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			renderError(rw, req, http.StatusTooManyRequests, DefaultLockedOutResponse)
+			return
+		}
+		next(rw, req)
+	}
+}
+
+func (lt *LoginThrottle) wait(key string, limit LoginLimit, now time.Time) (time.Duration, error) {
+	attempts, err := lt.Store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return limit.Wait(attempts, now), nil
+}
+
+func (lt *LoginThrottle) accountLimit() LoginLimit {
+	return lt.limit(orDefault(lt.AccountAttempts, 5))
+}
+
+func (lt *LoginThrottle) ipLimit() LoginLimit {
+	return lt.limit(orDefault(lt.IPAttempts, 20))
+}
+
+// limit returns the LoginLimit allowing attempts failures, with the throttle's lockouts and window.
+func (lt *LoginThrottle) limit(attempts int) LoginLimit {
+	limit := LoginLimit{Attempts: attempts, BaseLockout: lt.BaseLockout, MaxLockout: lt.MaxLockout, Window: lt.Window}
+	if limit.BaseLockout <= 0 {
+		limit.BaseLockout = time.Second
+	}
+	if limit.MaxLockout <= 0 {
+		limit.MaxLockout = time.Hour
+	}
+	if limit.Window <= 0 {
+		limit.Window = 24 * time.Hour
+	}
+	return limit
+}
+
+// orDefault returns n, or def if n isn't positive.
+func orDefault(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+// ClientIP returns the IP address of the client that sent req, without the port. It doesn't look at
+// X-Forwarded-For; run your proxy-aware middleware first if you are behind a load balancer.
+func ClientIP(req *Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// MemoryLoginAttemptStore is a LoginAttemptStore for a single process.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts *expiringMap[LoginAttempts]
+}
+
+// NewMemoryLoginAttemptStore returns an empty MemoryLoginAttemptStore.
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: newExpiringMap[LoginAttempts]()}
+}
+
+// Get implements LoginAttemptStore.
+func (s *MemoryLoginAttemptStore) Get(key string) (LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts, _ := s.attempts.get(key, time.Now())
+	return attempts, nil
+}
+
+// Reserve implements LoginAttemptStore.
+func (s *MemoryLoginAttemptStore) Reserve(key string, now time.Time, limit LoginLimit) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts, _ := s.attempts.get(key, now)
+	if now.Sub(attempts.Last) > limit.Window {
+		attempts.Failures = 0
+	}
+	if wait := limit.Wait(attempts, now); wait > 0 {
+		return wait, nil
+	}
+	attempts.Failures++
+	attempts.Last = now
+	s.attempts.set(key, attempts, now.Add(limit.Window), now)
+	return 0, nil
+}
+
+// Release implements LoginAttemptStore.
+func (s *MemoryLoginAttemptStore) Release(key string, now time.Time, limit LoginLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts, ok := s.attempts.get(key, now)
+	if !ok || attempts.Failures == 0 {
+		return nil
+	}
+	attempts.Failures--
+	if attempts.Failures == 0 {
+		s.attempts.delete(key)
+	} else {
+		s.attempts.set(key, attempts, attempts.Last.Add(limit.Window), now)
+	}
+	return nil
+}
+
+// Delete implements LoginAttemptStore.
+func (s *MemoryLoginAttemptStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts.delete(key)
+	return nil
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottleLockout(t *testing.T) {
+	lt := NewLoginThrottle(NewMemoryLoginAttemptStore())
+	lt.AccountAttempts = 2
+
+	for i := 0; i < 2; i++ {
+		wait, err := lt.Reserve("alice", "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), wait)
+	}
+
+	wait, _ := lt.Check("alice", "10.0.0.2")
+	assert.True(t, wait > 0 && wait <= time.Second, wait.String())
+	wait, _ = lt.Reserve("alice", "10.0.0.2")
+	assert.True(t, wait > 0 && wait <= time.Second, wait.String())
+
+	// The rejected attempt isn't counted for the IP.
+	attempts, _ := lt.Store.Get("ip:10.0.0.2")
+	assert.Equal(t, 0, attempts.Failures)
+
+	wait, _ = lt.Check("bob", "10.0.0.1")
+	assert.Equal(t, time.Duration(0), wait)
+
+	assert.NoError(t, lt.Succeeded("alice", "10.0.0.1"))
+	wait, _ = lt.Check("alice", "10.0.0.1")
+	assert.Equal(t, time.Duration(0), wait)
+	attempts, _ = lt.Store.Get("ip:10.0.0.1")
+	assert.Equal(t, 1, attempts.Failures)
+}
+
+func TestLoginLimitWait(t *testing.T) {
+	limit := LoginLimit{Attempts: 2, BaseLockout: time.Second, MaxLockout: time.Hour, Window: 24 * time.Hour}
+	now := time.Now()
+
+	assert.Equal(t, time.Duration(0), limit.Wait(LoginAttempts{Failures: 1, Last: now}, now))
+	assert.Equal(t, time.Second, limit.Wait(LoginAttempts{Failures: 2, Last: now}, now))
+	assert.Equal(t, 2*time.Second, limit.Wait(LoginAttempts{Failures: 3, Last: now}, now))
+	assert.Equal(t, time.Hour, limit.Wait(LoginAttempts{Failures: 40, Last: now}, now))
+	assert.Equal(t, time.Duration(0), limit.Wait(LoginAttempts{Failures: 3, Last: now.Add(-3 * time.Second)}, now))
+	assert.Equal(t, time.Duration(0), limit.Wait(LoginAttempts{Failures: 40, Last: now.Add(-25 * time.Hour)}, now))
+}
+
+func TestLoginThrottleIP(t *testing.T) {
+	lt := NewLoginThrottle(NewMemoryLoginAttemptStore())
+	lt.IPAttempts = 3
+	lt.MaxLockout = 500 * time.Millisecond
+
+	for _, account := range []string{"a", "b", "c"} {
+		wait, _ := lt.Reserve(account, "10.0.0.1")
+		assert.Equal(t, time.Duration(0), wait)
+	}
+	wait, _ := lt.Reserve("d", "10.0.0.1")
+	assert.True(t, wait > 0 && wait <= 500*time.Millisecond, wait.String())
+
+	// The account's attempt is taken back when the IP is locked out.
+	attempts, _ := lt.Store.Get("account:d")
+	assert.Equal(t, 0, attempts.Failures)
+
+	wait, _ = lt.Check("f", "10.0.0.1")
+	assert.True(t, wait > 0 && wait <= 500*time.Millisecond, wait.String())
+	wait, _ = lt.Check("f", "10.0.0.2")
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestLoginThrottleSuccessfulLogins(t *testing.T) {
+	lt := NewLoginThrottle(NewMemoryLoginAttemptStore())
+	lt.IPAttempts = 2
+
+	// Logins that succeed don't add up to a lockout.
+	for _, account := range []string{"a", "b", "c", "d"} {
+		wait, err := lt.Reserve(account, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), wait)
+		assert.NoError(t, lt.Succeeded(account, "10.0.0.1"))
+	}
+	wait, _ := lt.Check("e", "10.0.0.1")
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestLoginThrottleConcurrentAttempts(t *testing.T) {
+	lt := &LoginThrottle{Store: NewMemoryLoginAttemptStore()}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			wait, err := lt.Reserve("alice", fmt.Sprintf("10.0.0.%d", i))
+			assert.NoError(t, err)
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Only the default 5 free attempts get through, however many arrive at once.
+	assert.Equal(t, 5, allowed)
+	wait, err := lt.Check("alice", "10.0.0.100")
+	assert.NoError(t, err)
+	assert.True(t, wait > 0 && wait <= time.Second, wait.String())
+	wait, _ = lt.Check("bob", "10.0.0.1")
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestLoginThrottleMiddleware(t *testing.T) {
+	lt := NewLoginThrottle(NewMemoryLoginAttemptStore())
+	lt.AccountAttempts = 1
+	lt.BaseLockout = time.Minute
+
+	router := New(Context{})
+	router.Middleware(lt.Middleware(func(req *Request) string { return req.FormValue("user") }))
+	router.Post("/login", func(rw ResponseWriter, req *Request) {
+		if wait, _ := lt.Reserve(req.FormValue("user"), ClientIP(req)); wait > 0 {
+			rw.WriteHeader(429)
+			return
+		}
+		rw.WriteHeader(401)
+	})
+
+	rw, req := newTestRequest("POST", "/login?user=alice")
+	req.RemoteAddr = "10.0.0.1:5000"
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", 401)
+
+	rw, req = newTestRequest("POST", "/login?user=alice")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Too Many Login Attempts", 429)
+	assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+}
+
+func TestClientIP(t *testing.T) {
+	_, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "[::1]:8080"
+	assert.Equal(t, "::1", ClientIP(&Request{Request: req}))
+	req.RemoteAddr = "10.0.0.1"
+	assert.Equal(t, "10.0.0.1", ClientIP(&Request{Request: req}))
+}
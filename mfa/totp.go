@@ -0,0 +1,105 @@
+// Package mfa provides second-factor helpers for applications built on gocraft/web.
+//
+// TOTP implements time-based one-time passwords (RFC 6238) as used by authenticator apps:
+//
+//	secret := mfa.GenerateSecret()
+//	uri := mfa.ProvisioningURI("Example", "alice@example.com", secret) // render as a QR code
+//	...
+//	if counter, ok := mfa.Verify(secret, req.FormValue("code"), time.Now()); ok && counter > user.LastTOTPCounter {
+//		user.LastTOTPCounter = counter // reject the same code if it's submitted again
+//	}
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Period is the lifetime of a TOTP code, and Digits its length. These are the values authenticator apps assume.
+const (
+	Period = 30 * time.Second
+	Digits = 6
+)
+
+// Skew is how many periods before and after the current one Verify accepts, to allow for clock drift.
+var Skew = 1
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random 160-bit secret, base32 encoded, to store for the user and share with their
+// authenticator app.
+func GenerateSecret() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return encoding.EncodeToString(b)
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps scan (as a QR code) to enroll secret.
+func ProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code returns the TOTP code for secret at t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counterAt(t)), nil
+}
+
+// Verify checks code against secret at now, allowing for Skew. It returns the counter (time step) the code was
+// valid for, so callers can store it and refuse codes for the same or earlier counters.
+func Verify(secret, code string, now time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+	current := counterAt(now)
+	for i := -Skew; i <= Skew; i++ {
+		counter := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(strings.Replace(secret, " ", "", -1), "=")))
+	if err != nil {
+		return nil, fmt.Errorf("mfa: invalid TOTP secret: %v", err)
+	}
+	return key, nil
+}
+
+func counterAt(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// hotp implements HOTP (RFC 4226) with HMAC-SHA1.
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
@@ -0,0 +1,66 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The SHA1 vectors from RFC 6238, truncated to 6 digits.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	for unix, expected := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := Code(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	_, err := Code("not base32!", time.Now())
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	counter, ok := Verify(rfcSecret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111109/30), counter)
+
+	_, ok = Verify(rfcSecret, "081804", now.Add(Period))
+	assert.True(t, ok)
+	_, ok = Verify(rfcSecret, "081804", now.Add(2*Period))
+	assert.False(t, ok)
+	_, ok = Verify(rfcSecret, "000000", now)
+	assert.False(t, ok)
+	_, ok = Verify(rfcSecret, "81804", now)
+	assert.False(t, ok)
+
+	lower := strings.ToLower(rfcSecret)
+	_, ok = Verify(lower, "081804", now)
+	assert.True(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret := GenerateSecret()
+	assert.Equal(t, 32, len(secret))
+	assert.NotEqual(t, secret, GenerateSecret())
+
+	code, err := Code(secret, time.Now())
+	assert.NoError(t, err)
+	_, ok := Verify(secret, code, time.Now())
+	assert.True(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	assert.Equal(t, "otpauth://totp/Example:alice@example.com?issuer=Example&secret=JBSWY3DPEHPK3PXP",
+		ProvisioningURI("Example", "alice@example.com", "JBSWY3DPEHPK3PXP"))
+}
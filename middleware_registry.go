@@ -0,0 +1,88 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MiddlewareRegistry maps names to middleware, so that routers (or configuration files) can refer to middleware
+// by name and every router wires up the same implementation:
+//
+//	web.DefaultMiddlewareRegistry.Register("logging", web.LoggerMiddleware)
+//	web.DefaultMiddlewareRegistry.Register("auth", (*Context).Authenticate)
+//	...
+//	adminRouter.UseMiddleware("logging", "auth")
+//
+// Middleware taking a context can only be used by routers with that context type.
+type MiddlewareRegistry struct {
+	mu         sync.RWMutex
+	middleware map[string]interface{}
+}
+
+// DefaultMiddlewareRegistry is used by routers that don't have a registry of their own.
+var DefaultMiddlewareRegistry = NewMiddlewareRegistry()
+
+// NewMiddlewareRegistry returns an empty MiddlewareRegistry.
+func NewMiddlewareRegistry() *MiddlewareRegistry {
+	return &MiddlewareRegistry{middleware: make(map[string]interface{})}
+}
+
+// Register adds fn under name. Registering the same name twice panics.
+func (reg *MiddlewareRegistry) Register(name string, fn interface{}) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, ok := reg.middleware[name]; ok {
+		panic("web: middleware " + name + " is already registered")
+	}
+	reg.middleware[name] = fn
+}
+
+// Lookup returns the middleware registered under name.
+func (reg *MiddlewareRegistry) Lookup(name string) (interface{}, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	fn, ok := reg.middleware[name]
+	return fn, ok
+}
+
+// Names returns the registered names in sorted order.
+func (reg *MiddlewareRegistry) Names() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	names := make([]string, 0, len(reg.middleware))
+	for name := range reg.middleware {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MiddlewareRegistry sets the registry UseMiddleware looks names up in for this router and its subrouters,
+// and returns the router. Routers without one (and without a parent that has one) use DefaultMiddlewareRegistry.
+func (r *Router) MiddlewareRegistry(reg *MiddlewareRegistry) *Router {
+	r.middlewareRegistry = reg
+	return r
+}
+
+// UseMiddleware adds the middleware registered under each of names, in order, and returns the router. It panics
+// if a name isn't registered. The middleware shows up under its registered name in traces and snapshots.
+func (r *Router) UseMiddleware(names ...string) *Router {
+	reg := DefaultMiddlewareRegistry
+	for router := r; router != nil; router = router.parent {
+		if router.middlewareRegistry != nil {
+			reg = router.middlewareRegistry
+			break
+		}
+	}
+
+	for _, name := range names {
+		fn, ok := reg.Lookup(name)
+		if !ok {
+			panic(fmt.Sprintf("web: middleware %s is not registered", name))
+		}
+		r.addMiddleware(fn, name)
+	}
+	return r
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareRegistry(t *testing.T) {
+	reg := NewMiddlewareRegistry()
+	reg.Register("alpha", (*Context).mwAlpha)
+	reg.Register("epsilon", (*AdminContext).mwEpsilon)
+	reg.Register("generic", func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		rw.Header().Set("X-Generic", "yes")
+		next(rw, req)
+	})
+	assert.Equal(t, []string{"alpha", "epsilon", "generic"}, reg.Names())
+	assert.Panics(t, func() { reg.Register("alpha", (*Context).mwBeta) })
+
+	router := New(Context{}).MiddlewareRegistry(reg)
+	router.UseMiddleware("generic", "alpha")
+	admin := router.Subrouter(AdminContext{}, "/admin").UseMiddleware("epsilon")
+	admin.Get("/action", (*AdminContext).B)
+
+	rw, req := newTestRequest("GET", "/admin/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Alpha admin-mw-Epsilon admin-B", 200)
+	assert.Equal(t, "yes", rw.Header().Get("X-Generic"))
+
+	snapshot := router.Snapshot()
+	assert.Equal(t, []string{"generic", "alpha", "epsilon"}, snapshot.Routes[0].Middleware)
+
+	assert.Panics(t, func() { router.UseMiddleware("missing") })
+	assert.Panics(t, func() { router.UseMiddleware("epsilon") })
+}
+
+func TestDefaultMiddlewareRegistry(t *testing.T) {
+	DefaultMiddlewareRegistry.Register("test-beta", (*Context).mwBeta)
+	defer func() {
+		DefaultMiddlewareRegistry = NewMiddlewareRegistry()
+	}()
+
+	router := New(Context{}).UseMiddleware("test-beta")
+	router.Get("/action", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Beta context-A", 200)
+}
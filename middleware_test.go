@@ -268,3 +268,35 @@ func TestInterfaceMiddleware(t *testing.T) {
 	router.ServeHTTP(rw, req)
 	assertResponse(t, rw, "context-mw-Interface context-A", 200)
 }
+
+func TestRouteMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Middleware((*Context).mwAlpha)
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Middleware((*AdminContext).mwEpsilon)
+	admin.Get("/action", (*AdminContext).B).Use((*AdminContext).mwZeta).Use(mwGenricInterface)
+	admin.Get("/other", (*AdminContext).B)
+
+	rw, req := newTestRequest("GET", "/admin/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Alpha admin-mw-Epsilon admin-mw-Zeta context-mw-Interface admin-B", 200)
+
+	rw, req = newTestRequest("GET", "/admin/other")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Alpha admin-mw-Epsilon admin-B", 200)
+}
+
+func TestRouteMiddlewareNoNext(t *testing.T) {
+	router := New(Context{})
+	router.PoolRequests(true)
+	router.Get("/action", (*Context).A).Use((*Context).mwNoNext)
+	router.Get("/open", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-NoNext", 200)
+
+	rw, req = newTestRequest("GET", "/open")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+}
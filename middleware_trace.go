@@ -0,0 +1,67 @@
+package web
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// MiddlewareTraceHeader is the trailer a router in debug mode uses to report which middleware ran.
+const MiddlewareTraceHeader = "X-Middleware-Trace"
+
+type traceEntry struct {
+	name     string
+	duration time.Duration
+	finished bool
+}
+
+// traced invokes fn and records it in the request's trace. Durations include everything fn called downstream.
+// Strict mode learns from it which frame is running.
+func (closure *middlewareClosure) traced(name string, fn func()) {
+	more := closure.Request.extras()
+	i := len(more.trace)
+	more.trace = append(more.trace, traceEntry{name: name})
+	strict := more.strict
+	if strict != nil {
+		strict.enterFrame()
+	}
+	startTime := time.Now()
+	fn()
+	more.trace[i].duration = time.Since(startTime)
+	more.trace[i].finished = true
+	if strict != nil {
+		strict.exitFrame(closure.appResponseWriter.Written())
+	}
+}
+
+// reportTrace sets the trace trailer and logs it. Middleware that didn't return (because it or something
+// after it panicked) is reported as unfinished.
+func (closure *middlewareClosure) reportTrace() {
+	trace := closure.Request.extras().trace
+	parts := make([]string, len(trace))
+	for i, entry := range trace {
+		if entry.finished {
+			parts[i] = entry.name + "=" + entry.duration.String()
+		} else {
+			parts[i] = entry.name + "=unfinished"
+		}
+	}
+	joined := strings.Join(parts, ", ")
+
+	closure.appResponseWriter.Header().Set(MiddlewareTraceHeader, joined)
+	Logger.Printf("[trace] '%s' %s\n", closure.Request.URL.Path, joined)
+}
+
+// funcName returns a short name for the function in vfn, eg "web.(*Context).SetUser".
+func funcName(vfn reflect.Value) string {
+	fn := runtime.FuncForPC(vfn.Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
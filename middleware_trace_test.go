@@ -0,0 +1,64 @@
+//go:build webdebug
+// +build webdebug
+
+package web
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDebugMiddlewareTrace(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{}).Debug(true)
+	router.Middleware((*Context).mwAlpha)
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Middleware((*AdminContext).mwEpsilon)
+	admin.Get("/action", (*AdminContext).B)
+
+	rw, req := newTestRequest("GET", "/admin/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Alpha admin-mw-Epsilon admin-B", 200)
+
+	trace := rw.Result().Trailer.Get(MiddlewareTraceHeader)
+	traceRegexp := regexp.MustCompile(`^web\.\(\*Context\)\.mwAlpha=\S+, web\.\(\*AdminContext\)\.mwEpsilon=\S+, web\.\(\*AdminContext\)\.B=\S+$`)
+	if !traceRegexp.MatchString(trace) {
+		t.Errorf("Got invalid trace: %s", trace)
+	}
+	if !strings.Contains(buf.String(), "[trace] '/admin/action' "+trace) {
+		t.Errorf("Expected trace to be logged but got: %s", buf.String())
+	}
+}
+
+func TestDebugMiddlewareTraceUnfinished(t *testing.T) {
+	Logger = log.New(&bytes.Buffer{}, "", 0)
+
+	router := New(Context{}).Debug(true)
+	router.Middleware((*Context).mwNoNext)
+	router.Middleware((*Context).ErrorMiddleware)
+	router.Get("/action", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	trace := rw.Result().Trailer.Get(MiddlewareTraceHeader)
+	if !regexp.MustCompile(`^web\.\(\*Context\)\.mwNoNext=\S+$`).MatchString(trace) {
+		t.Errorf("Got invalid trace: %s", trace)
+	}
+
+	router = New(Context{}).Debug(true)
+	router.Middleware((*Context).ErrorMiddleware)
+	router.Get("/action", (*Context).A)
+
+	rw, req = newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", 500)
+	trace = rw.Result().Trailer.Get(MiddlewareTraceHeader)
+	if trace != "web.(*Context).ErrorMiddleware=unfinished" {
+		t.Errorf("Got invalid trace: %s", trace)
+	}
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers the nonces NonceMiddleware has accepted. Implementations backed by a shared store (eg
+// Redis SET NX with an expiry) make replay protection work across several servers.
+type NonceStore interface {
+	// Use marks nonce as used for ttl. It returns false if nonce was already used and hasn't expired yet.
+	Use(nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore for a single process.
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	used *expiringMap[struct{}]
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{used: newExpiringMap[struct{}]()}
+}
+
+// Use implements NonceStore.
+func (s *MemoryNonceStore) Use(nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if _, ok := s.used.get(nonce, now); ok {
+		return false, nil
+	}
+	s.used.set(nonce, struct{}{}, now.Add(ttl), now)
+	return true, nil
+}
+
+// NonceOptions configures NonceMiddleware.
+type NonceOptions struct {
+	// Header is the request header carrying a nonce chosen by the client, eg a webhook delivery ID.
+	// Defaults to "X-Nonce".
+	Header string
+
+	// Param, if set, is a query or form param carrying a nonce made with SignNonce, eg in a password reset link.
+	// Requests must then have a valid signed nonce in Param, and Header is ignored, so leaving out the param
+	// can't skip the signature check. Key is required with Param.
+	Param string
+	Key   []byte
+
+	// TTL is how long a nonce from Header is remembered; the client must not reuse one sooner. Signed nonces
+	// are remembered until they expire. Defaults to 24 hours.
+	TTL time.Duration
+}
+
+// DefaultMissingNonceResponse is the default text rendered when a request has no nonce.
+var DefaultMissingNonceResponse = "Missing Nonce"
+
+// DefaultReplayedRequestResponse is the default text rendered when a request's nonce was already used.
+var DefaultReplayedRequestResponse = "Request Already Processed"
+
+// SignNonce returns a random nonce signed with key that expires after expiry, for use with NonceOptions.Param.
+func SignNonce(key []byte, expiry time.Duration) string {
+	payload := newRequestID() + "." + strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	return payload + "." + nonceSignature(key, payload)
+}
+
+func nonceSignature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("nonce:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignedNonce returns how long the signed nonce is still valid for, or false if it's invalid or expired.
+func verifySignedNonce(key []byte, nonce string) (time.Duration, bool) {
+	i := strings.LastIndexByte(nonce, '.')
+	if i < 0 || !hmac.Equal([]byte(nonce[i+1:]), []byte(nonceSignature(key, nonce[:i]))) {
+		return 0, false
+	}
+	j := strings.LastIndexByte(nonce[:i], '.')
+	expires, err := strconv.ParseInt(nonce[j+1:i], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	ttl := time.Until(time.Unix(expires, 0))
+	return ttl, ttl > 0
+}
+
+// NonceMiddleware returns middleware that only lets each nonce through once. Requests without a nonce are
+// rejected with 400, requests with an invalid or expired signed nonce with 403, and replayed requests with 409.
+// Errors from the store are raised as panics.
+func NonceMiddleware(store NonceStore, opts NonceOptions) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if opts.Header == "" {
+		opts.Header = "X-Nonce"
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.Param != "" && len(opts.Key) == 0 {
+		panic("web: NonceOptions.Param requires a Key")
+	}
+
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		nonce, ttl := "", opts.TTL
+		if opts.Param != "" {
+			nonce = req.FormValue(opts.Param)
+		} else {
+			nonce = req.Header.Get(opts.Header)
+		}
+		if nonce == "" {
+			renderError(rw, req, http.StatusBadRequest, DefaultMissingNonceResponse)
+			return
+		}
+		if opts.Param != "" {
+			var ok bool
+			if ttl, ok = verifySignedNonce(opts.Key, nonce); !ok {
+				renderError(rw, req, http.StatusForbidden, DefaultForbiddenResponse)
+				return
+			}
+		}
+
+		fresh, err := store.Use(nonce, ttl)
+		if err != nil {
+			panic(err)
+		}
+		if !fresh {
+			renderError(rw, req, http.StatusConflict, DefaultReplayedRequestResponse)
+			return
+		}
+		next(rw, req)
+	}
+}
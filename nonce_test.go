@@ -0,0 +1,95 @@
+package web
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonceMiddlewareHeader(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(NonceMiddleware(NewMemoryNonceStore(), NonceOptions{}))
+	router.Post("/webhook", (*Context).A)
+
+	rw, req := newTestRequest("POST", "/webhook")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Missing Nonce", 400)
+
+	rw, req = newTestRequest("POST", "/webhook")
+	req.Header.Set("X-Nonce", "delivery-1")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+
+	rw, req = newTestRequest("POST", "/webhook")
+	req.Header.Set("X-Nonce", "delivery-1")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Request Already Processed", 409)
+
+	rw, req = newTestRequest("POST", "/webhook")
+	req.Header.Set("X-Nonce", "delivery-2")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+}
+
+func TestNonceMiddlewareSignedParam(t *testing.T) {
+	key := []byte("reset-secret")
+	router := New(Context{})
+	router.Middleware(NonceMiddleware(NewMemoryNonceStore(), NonceOptions{Param: "nonce", Key: key}))
+	router.Get("/reset", (*Context).A)
+
+	nonce := SignNonce(key, time.Hour)
+	rw, req := newTestRequest("GET", "/reset?nonce="+nonce)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+
+	rw, req = newTestRequest("GET", "/reset?nonce="+nonce)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Request Already Processed", 409)
+
+	for _, bad := range []string{SignNonce(key, -time.Hour), SignNonce([]byte("other"), time.Hour), "forged.9999999999.sig"} {
+		rw, req = newTestRequest("GET", "/reset?nonce="+bad)
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Forbidden", 403)
+	}
+
+	// Without the param, a client-chosen header nonce isn't enough.
+	rw, req = newTestRequest("GET", "/reset")
+	req.Header.Set("X-Nonce", "chosen-by-client")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Missing Nonce", 400)
+
+	assert.Panics(t, func() {
+		NonceMiddleware(NewMemoryNonceStore(), NonceOptions{Param: "nonce"})
+	})
+}
+
+type failingNonceStore struct{}
+
+func (failingNonceStore) Use(nonce string, ttl time.Duration) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func TestNonceMiddlewareStoreError(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(NonceMiddleware(failingNonceStore{}, NonceOptions{}))
+	router.Post("/webhook", (*Context).A)
+
+	rw, req := newTestRequest("POST", "/webhook")
+	req.Header.Set("X-Nonce", "delivery-1")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", 500)
+}
+
+func TestMemoryNonceStoreExpiry(t *testing.T) {
+	store := NewMemoryNonceStore()
+	ok, _ := store.Use("a", time.Millisecond)
+	assert.True(t, ok)
+	ok, _ = store.Use("a", time.Millisecond)
+	assert.False(t, ok)
+
+	time.Sleep(2 * time.Millisecond)
+	ok, _ = store.Use("a", time.Minute)
+	assert.True(t, ok)
+}
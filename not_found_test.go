@@ -49,3 +49,28 @@ func TestWithRootContext(t *testing.T) {
 	router.ServeHTTP(rw, req)
 	assertResponse(t, rw, "My Not Found With Context", http.StatusNotFound)
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	router := New(Context{})
+	router.Get("/action", (*Context).A)
+	router.Put("/action", (*Context).A)
+
+	rw, req := newTestRequest("POST", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", http.StatusMethodNotAllowed)
+	if allow := rw.Header().Get("Allow"); allow != "GET, PUT, HEAD, OPTIONS" {
+		t.Errorf("Expected an Allow header listing the path's methods but got '%s'", allow)
+	}
+
+	rw, req = newTestRequest("POST", "/this_path_doesnt_exist")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	router.MethodNotAllowed(func(c *Context, rw ResponseWriter, r *Request) {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		fmt.Fprintf(rw, "Use one of %s", rw.Header().Get("Allow"))
+	})
+	rw, req = newTestRequest("DELETE", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Use one of GET, PUT, HEAD, OPTIONS", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Message is a notification, eg an email for a password reset.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string // Plain text.
+}
+
+// Notifier sends messages, eg by email. Implementations for SMS or chat services can be plugged in the same way.
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(msg Message) error
+
+// Notify calls f(msg).
+func (f NotifierFunc) Notify(msg Message) error {
+	return f(msg)
+}
+
+// Notifier sets the Notifier for handlers on this router and its subrouters, and returns the router.
+// A subrouter can set its own Notifier to override its parent's.
+func (r *Router) Notifier(n Notifier) *Router {
+	r.notifier = n
+	return r
+}
+
+// Notifier returns the Notifier of the nearest router the request was routed through, or nil if there is none
+// or the request hasn't been routed yet.
+func (r *Request) Notifier() Notifier {
+	if r.route == nil {
+		return nil
+	}
+	for router := r.route.router; router != nil; router = router.parent {
+		if router.notifier != nil {
+			return router.notifier
+		}
+	}
+	return nil
+}
+
+// SMTPNotifier sends messages as plain text emails through an SMTP server.
+type SMTPNotifier struct {
+	Addr string    // Eg "smtp.example.com:587".
+	Auth smtp.Auth // May be nil.
+	From string
+
+	// sendMail is smtp.SendMail, replaced in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier returns an SMTPNotifier sending from from through the server at addr.
+func NewSMTPNotifier(addr string, auth smtp.Auth, from string) *SMTPNotifier {
+	return &SMTPNotifier{Addr: addr, Auth: auth, From: from}
+}
+
+// ErrInvalidMessage is returned when a message has no recipients, or a header containing a line break.
+var ErrInvalidMessage = errors.New("web: invalid message")
+
+// Notify implements Notifier.
+func (n *SMTPNotifier) Notify(msg Message) error {
+	if len(msg.To) == 0 || strings.ContainsAny(n.From+msg.Subject+strings.Join(msg.To, ""), "\r\n") {
+		return ErrInvalidMessage
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.Replace(strings.Replace(msg.Body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+
+	sendMail := n.sendMail
+	if sendMail == nil {
+		sendMail = smtp.SendMail
+	}
+	return sendMail(n.Addr, n.Auth, n.From, msg.To, b.Bytes())
+}
+
+// MessageTemplate renders Messages from text/template templates for the subject and the body.
+type MessageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewMessageTemplate parses subject and body as text/template templates, with funcs available to both.
+func NewMessageTemplate(subject, body string, funcs template.FuncMap) (*MessageTemplate, error) {
+	subjectTmpl, err := template.New("subject").Funcs(funcs).Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	bodyTmpl, err := template.New("body").Funcs(funcs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageTemplate{subject: subjectTmpl, body: bodyTmpl}, nil
+}
+
+// Render returns the Message for to, executing the templates with data.
+func (t *MessageTemplate) Render(to []string, data interface{}) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject.String(), Body: body.String()}, nil
+}
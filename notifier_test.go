@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTPNotifier(t *testing.T) {
+	var sent struct {
+		addr, from string
+		to         []string
+		msg        string
+	}
+	n := NewSMTPNotifier("smtp.example.com:587", nil, "noreply@example.com")
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent.addr, sent.from, sent.to, sent.msg = addr, from, to, string(msg)
+		return nil
+	}
+
+	err := n.Notify(Message{To: []string{"alice@example.com"}, Subject: "Reset your password", Body: "Hi\nClick here"})
+	assert.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", sent.addr)
+	assert.Equal(t, []string{"alice@example.com"}, sent.to)
+	assert.True(t, strings.HasPrefix(sent.msg, "From: noreply@example.com\r\nTo: alice@example.com\r\nSubject: Reset your password\r\n"))
+	assert.True(t, strings.HasSuffix(sent.msg, "\r\n\r\nHi\r\nClick here"))
+
+	assert.Equal(t, ErrInvalidMessage, n.Notify(Message{Subject: "No one"}))
+	assert.Equal(t, ErrInvalidMessage, n.Notify(Message{To: []string{"alice@example.com"}, Subject: "Hi\r\nBcc: eve@example.com"}))
+}
+
+func TestMessageTemplate(t *testing.T) {
+	tmpl, err := NewMessageTemplate("Welcome, {{.Name}}", "Hello {{.Name | upper}}!", map[string]interface{}{"upper": strings.ToUpper})
+	assert.NoError(t, err)
+
+	msg, err := tmpl.Render([]string{"alice@example.com"}, map[string]string{"Name": "Alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, Message{To: []string{"alice@example.com"}, Subject: "Welcome, Alice", Body: "Hello ALICE!"}, msg)
+
+	_, err = NewMessageTemplate("{{", "", nil)
+	assert.Error(t, err)
+}
+
+func TestRequestNotifier(t *testing.T) {
+	var rootMessages, adminMessages []Message
+	router := New(Context{})
+	router.Notifier(NotifierFunc(func(msg Message) error {
+		rootMessages = append(rootMessages, msg)
+		return nil
+	}))
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Notifier(NotifierFunc(func(msg Message) error {
+		adminMessages = append(adminMessages, msg)
+		return nil
+	}))
+	notify := func(rw ResponseWriter, req *Request) {
+		req.Notifier().Notify(Message{Subject: req.URL.Path})
+	}
+	router.Get("/reset", notify)
+	admin.Get("/alert", notify)
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		assert.Nil(t, req.Notifier())
+		next(rw, req)
+	})
+
+	rw, req := newTestRequest("GET", "/reset")
+	router.ServeHTTP(rw, req)
+	rw, req = newTestRequest("GET", "/admin/alert")
+	router.ServeHTTP(rw, req)
+
+	assert.Equal(t, []Message{{Subject: "/reset"}}, rootMessages)
+	assert.Equal(t, []Message{{Subject: "/admin/alert"}}, adminMessages)
+}
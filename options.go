@@ -0,0 +1,88 @@
+package web
+
+import (
+	"reflect"
+	"strings"
+)
+
+// AutoOptions turns automatic OPTIONS responses on (the default) or off and returns the router. When an OPTIONS
+// request's path has routes for other methods but no OPTIONS route, the router responds with an Allow header
+// listing those methods (HEAD is included with GET, see AutoHead) and a 204, or calls the OptionsHandler. When
+// it's off, such requests get a 405 like any other method the path has no route for (see MethodNotAllowed). Note
+// that only the root router can configure automatic OPTIONS responses.
+func (r *Router) AutoOptions(enabled bool) *Router {
+	if r.parent != nil {
+		panic("You can only configure automatic OPTIONS responses on the root router.")
+	}
+	r.autoOptionsDisabled = !enabled
+	return r
+}
+
+// OptionsHandler sets the function that writes automatic OPTIONS responses (see AutoOptions) and returns the
+// router. The Allow header is already set when fn is called. fn has the same signatures as a NotFound handler.
+// Note that only the root router can have an OptionsHandler.
+func (r *Router) OptionsHandler(fn interface{}) *Router {
+	if r.parent != nil {
+		panic("You can only set an OptionsHandler on the root router.")
+	}
+	vfn := reflect.ValueOf(fn)
+	validateOptionsHandler(vfn, r.contextType)
+	r.optionsHandler = vfn
+	return r
+}
+
+// Consumes declares the media types the route accepts in request bodies, eg "application/json" or
+// "application/merge-patch+json", and returns the route. POST and PATCH routes advertise them with Accept-Post and
+// Accept-Patch headers on automatic OPTIONS responses and on 405s for their path. Consumes doesn't check the
+// Content-Type of requests.
+func (r *Route) Consumes(mediaTypes ...string) *Route {
+	r.checkConfigurable()
+	r.consumes = append(r.consumes, mediaTypes...)
+	return r
+}
+
+// advertiseMethods sets the Allow header for req's path and host, with the methods that have a route for them in
+// the order of routeTable.methods, and the Accept-Post and Accept-Patch headers if those routes declare the media
+// types they consume. HEAD is allowed if GET is (unless AutoHead is off), and OPTIONS is allowed if any other
+// method is. It returns false, and sets no header, if no method has a route for the path.
+func advertiseMethods(rootRouter *Router, rw ResponseWriter, req *Request) bool {
+	segments, valid := requestSegments(req.URL)
+	if !valid {
+		return false
+	}
+	table := rootRouter.tables.load()
+	host := requestHost(req.Host)
+
+	methods := table.methods()
+	matched := make(map[httpMethod]*Route, len(methods))
+	for _, method := range methods {
+		leaf, _ := rootRouter.matchPath(table.trees[method], segments, host)
+		if leaf != nil && !(rootRouter.trailingSlash == TrailingSlashStrict && trailingSlashMismatch(leaf.route, req.URL.Path)) {
+			matched[method] = leaf.route
+		}
+	}
+	if len(matched) == 0 || (len(matched) == 1 && matched[httpMethodOptions] != nil) {
+		return false
+	}
+
+	var allowed []string
+	for _, method := range methods {
+		switch {
+		case matched[method] != nil:
+		case method == httpMethodHead && matched[httpMethodGet] != nil && !rootRouter.autoHeadDisabled:
+		case method == httpMethodOptions:
+		default:
+			continue
+		}
+		allowed = append(allowed, string(method))
+	}
+	header := rw.Header()
+	header.Set("Allow", strings.Join(allowed, ", "))
+	if route := matched[httpMethodPost]; route != nil && len(route.consumes) > 0 {
+		header.Set("Accept-Post", strings.Join(route.consumes, ", "))
+	}
+	if route := matched[httpMethodPatch]; route != nil && len(route.consumes) > 0 {
+		header.Set("Accept-Patch", strings.Join(route.consumes, ", "))
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoOptions(t *testing.T) {
+	router := New(Context{})
+	router.Get("/users/:id", (*Context).A)
+	router.Delete("/users/:id", (*Context).A)
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Post("/reports", (*AdminContext).B)
+	admin.Options("/custom", func(rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, "custom")
+	})
+
+	rw, req := newTestRequest("OPTIONS", "/users/3")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusNoContent)
+	assert.Equal(t, "GET, DELETE, HEAD, OPTIONS", rw.Header().Get("Allow"))
+
+	rw, req = newTestRequest("OPTIONS", "/admin/reports")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "POST, OPTIONS", rw.Header().Get("Allow"))
+
+	rw, req = newTestRequest("OPTIONS", "/admin/custom")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "custom", http.StatusOK)
+
+	rw, req = newTestRequest("OPTIONS", "/missing")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	router.OptionsHandler(func(ctx *Context, rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "allow: %s", rw.Header().Get("Allow"))
+	})
+	rw, req = newTestRequest("OPTIONS", "/admin/reports")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "allow: POST, OPTIONS", http.StatusOK)
+
+	router.AutoOptions(false)
+	rw, req = newTestRequest("OPTIONS", "/users/3")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+	assert.Panics(t, func() { admin.AutoOptions(true) })
+	assert.Panics(t, func() { admin.OptionsHandler(MyNotFoundHandler) })
+	assert.Panics(t, func() { router.OptionsHandler((*AdminContext).B) })
+}
+
+func TestAdvertiseAcceptHeaders(t *testing.T) {
+	router := New(Context{})
+	router.Get("/users/:id", (*Context).A)
+	router.Patch("/users/:id", (*Context).A).Consumes("application/merge-patch+json", "application/json-patch+json")
+	router.Post("/users", (*Context).A).Consumes("application/json")
+	router.Post("/uploads", (*Context).A)
+
+	rw, req := newTestRequest("OPTIONS", "/users/3")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusNoContent)
+	assert.Equal(t, "GET, PATCH, HEAD, OPTIONS", rw.Header().Get("Allow"))
+	assert.Equal(t, "application/merge-patch+json, application/json-patch+json", rw.Header().Get("Accept-Patch"))
+	assert.Equal(t, "", rw.Header().Get("Accept-Post"))
+
+	rw, req = newTestRequest("PUT", "/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", http.StatusMethodNotAllowed)
+	assert.Equal(t, "POST, OPTIONS", rw.Header().Get("Allow"))
+	assert.Equal(t, "application/json", rw.Header().Get("Accept-Post"))
+
+	rw, req = newTestRequest("OPTIONS", "/uploads")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "", rw.Header().Get("Accept-Post"))
+}
@@ -1,8 +1,12 @@
 package web
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 // PanicReporter can receive panics that happen when serving a request and report them to a log of some sort.
@@ -25,3 +29,52 @@ type logPanicReporter struct {
 func (l logPanicReporter) Panic(url string, err interface{}, stack string) {
 	l.log.Printf("PANIC\nURL: %v\nERROR: %v\nSTACK:\n%s\n", url, err, stack)
 }
+
+// The directory holding this package's sources. Frames from non-test files in it are framework internals.
+var frameworkDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// panicFrames returns the call stack of a panicking goroutine. It must be called from the deferred
+// function that recovered. Frames from the runtime, from reflect (which we use to call handlers), and from
+// this package's own plumbing are dropped, so the handler or middleware that panicked is the first frame.
+// If every frame is an internal one (eg, the panic came from the framework itself), nothing is dropped.
+func panicFrames() []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+
+	var all, user []runtime.Frame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		all = append(all, frame)
+		if !isInternalFrame(frame) {
+			user = append(user, frame)
+		}
+		if !more {
+			break
+		}
+	}
+
+	if len(user) == 0 {
+		return all
+	}
+	return user
+}
+
+func isInternalFrame(frame runtime.Frame) bool {
+	if strings.HasPrefix(frame.Function, "runtime.") || strings.HasPrefix(frame.Function, "reflect.") {
+		return true
+	}
+	return filepath.Dir(frame.File) == frameworkDir && !strings.HasSuffix(frame.File, "_test.go")
+}
+
+// formatFrames renders frames the same way runtime.Stack does: the function, then its file:line indented below it.
+func formatFrames(frames []runtime.Frame) string {
+	var b strings.Builder
+	for _, frame := range frames {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return b.String()
+}
@@ -0,0 +1,461 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the patch formats Request.ApplyPatch understands. Declare them on PATCH routes with
+// Route.Consumes to advertise them.
+const (
+	MergePatchMediaType = "application/merge-patch+json" // RFC 7386
+	JSONPatchMediaType  = "application/json-patch+json"  // RFC 6902
+)
+
+// MaxPatchSize caps the size of the patch documents ApplyPatch reads.
+var MaxPatchSize int64 = 1 << 20
+
+// PatchValidator is implemented by patch targets that check their patched value, eg that a patch didn't
+// empty a required field. See Request.ApplyPatch.
+type PatchValidator interface {
+	ValidatePatch() error
+}
+
+// PatchError is the error ApplyPatch returns when it can't apply a patch. Status is the status to respond
+// with: 415 for a Content-Type that isn't a patch format, 413 for a patch larger than MaxPatchSize, 400 for a
+// malformed patch, 409 for a JSON Patch whose test fails or whose paths don't exist, and 422 if the patched
+// document doesn't fit the target or fails validation.
+type PatchError struct {
+	Status  int
+	Message string
+}
+
+func (e *PatchError) Error() string {
+	return "web: can't apply the patch: " + e.Message
+}
+
+func patchErrorf(status int, format string, args ...interface{}) *PatchError {
+	return &PatchError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// ApplyPatch applies the request's body to target, a pointer to a value that is encoded as JSON, eg a struct
+// loaded from the database or a map. The body is a JSON Merge Patch or a JSON Patch, depending on the request's
+// Content-Type:
+//
+//	func (c *Context) UpdateUser(rw web.ResponseWriter, req *web.Request) {
+//		user := c.loadUser(req.PathParams["id"])
+//		if err := req.ApplyPatch(user); err != nil {
+//			var patchErr *web.PatchError
+//			if errors.As(err, &patchErr) {
+//				http.Error(rw, patchErr.Message, patchErr.Status)
+//				return
+//			}
+//			panic(err)
+//		}
+//		c.saveUser(user)
+//	}
+//
+// The patched document is decoded into a new value, which is validated if it's a PatchValidator, and only then
+// stored in target, so target is left as it was if ApplyPatch fails. The top-level fields of a struct that
+// aren't encoded as JSON (unexported or tagged json:"-") keep their values.
+func (r *Request) ApplyPatch(target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("web: ApplyPatch needs a non-nil pointer")
+	}
+
+	var apply func(doc interface{}, patch []byte) (interface{}, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case MergePatchMediaType:
+		apply = applyMergePatch
+	case JSONPatchMediaType:
+		apply = applyJSONPatch
+	default:
+		return patchErrorf(http.StatusUnsupportedMediaType, "the Content-Type must be %s or %s", MergePatchMediaType, JSONPatchMediaType)
+	}
+
+	patch, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxPatchSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(patch)) > MaxPatchSize {
+		return patchErrorf(http.StatusRequestEntityTooLarge, "the patch is larger than %d bytes", MaxPatchSize)
+	}
+
+	original, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeJSON(original)
+	if err != nil {
+		return err
+	}
+	patched, err := apply(doc, patch)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(patched)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.New(v.Elem().Type())
+	if v.Elem().Kind() == reflect.Struct {
+		result.Elem().Set(v.Elem())
+		clearJSONFields(result.Elem())
+	}
+	if err := json.Unmarshal(encoded, result.Interface()); err != nil {
+		return patchErrorf(http.StatusUnprocessableEntity, "the patched document is invalid: %v", err)
+	}
+	if validator, ok := result.Interface().(PatchValidator); ok {
+		if err := validator.ValidatePatch(); err != nil {
+			return patchErrorf(http.StatusUnprocessableEntity, "%v", err)
+		}
+	}
+	v.Elem().Set(result.Elem())
+	return nil
+}
+
+// clearJSONFields zeroes the fields of the struct v that are encoded as JSON, so that decoding the patched
+// document doesn't merge into their old values.
+func clearJSONFields(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name == "-" {
+			continue
+		}
+		if f := v.Field(i); f.CanSet() {
+			f.Set(reflect.Zero(field.Type))
+		}
+	}
+}
+
+// decodeJSON decodes data into generic values, keeping numbers as json.Number so they aren't rounded.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+func applyMergePatch(doc interface{}, patch []byte) (interface{}, error) {
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return nil, patchErrorf(http.StatusBadRequest, "the merge patch is malformed: %v", err)
+	}
+	return mergePatch(doc, p), nil
+}
+
+// mergePatch applies patch to target as RFC 7386 describes.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// jsonPatchOperation is an operation of a JSON Patch.
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+func applyJSONPatch(doc interface{}, patch []byte) (interface{}, error) {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, patchErrorf(http.StatusBadRequest, "the JSON patch is malformed: %v", err)
+	}
+	for i, op := range ops {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			if patchErr, ok := err.(*PatchError); ok {
+				patchErr.Message = fmt.Sprintf("operation %d (%s): %s", i, op.Op, patchErr.Message)
+			}
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func (op jsonPatchOperation) apply(doc interface{}) (interface{}, error) {
+	if op.Path == nil {
+		return nil, patchErrorf(http.StatusBadRequest, "it has no path")
+	}
+	path, err := parseJSONPointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var from []string
+	if op.Op == "move" || op.Op == "copy" {
+		if op.From == nil {
+			return nil, patchErrorf(http.StatusBadRequest, "it has no from")
+		}
+		if from, err = parseJSONPointer(*op.From); err != nil {
+			return nil, err
+		}
+	}
+	var value interface{}
+	if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+		if op.Value == nil {
+			return nil, patchErrorf(http.StatusBadRequest, "it has no value")
+		}
+		if value, err = decodeJSON(*op.Value); err != nil {
+			return nil, patchErrorf(http.StatusBadRequest, "its value is malformed: %v", err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return jsonPatchAdd(doc, path, value)
+	case "remove":
+		doc, _, err = jsonPatchRemove(doc, path)
+		return doc, err
+	case "replace":
+		if doc, _, err = jsonPatchRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(doc, path, value)
+	case "move":
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, patchErrorf(http.StatusBadRequest, "a value can't be moved into itself")
+		}
+		if doc, value, err = jsonPatchRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(doc, path, value)
+	case "copy":
+		if value, err = jsonPointerGet(doc, from); err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(doc, path, copyJSON(value))
+	case "test":
+		current, err := jsonPointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalJSON(current, value) {
+			return nil, patchErrorf(http.StatusConflict, "the value at %q is different", *op.Path)
+		}
+		return doc, nil
+	}
+	return nil, patchErrorf(http.StatusBadRequest, "unknown operation")
+}
+
+// parseJSONPointer returns the reference tokens of an RFC 6901 JSON pointer.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, patchErrorf(http.StatusBadRequest, "the path %q doesn't start with '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// jsonArrayIndex parses token as an index of array, which may be len(array) (or "-") if end is true.
+func jsonArrayIndex(array []interface{}, token string, end bool) (int, error) {
+	if token == "-" && end {
+		return len(array), nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, patchErrorf(http.StatusBadRequest, "%q is not an array index", token)
+	}
+	if i > len(array) || (i == len(array) && !end) {
+		return 0, patchErrorf(http.StatusConflict, "the index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func jsonPointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, patchErrorf(http.StatusConflict, "the member %q doesn't exist", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := jsonArrayIndex(container, token, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[i]
+		default:
+			return nil, patchErrorf(http.StatusConflict, "%q is not in an object or an array", token)
+		}
+	}
+	return doc, nil
+}
+
+// jsonPatchUpdate calls fn with the container path's last token is in, and returns doc with the container fn
+// returns in its place.
+func jsonPatchUpdate(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	child, err := jsonPointerGet(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = jsonPatchUpdate(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		container[path[0]] = child
+	case []interface{}:
+		i, _ := jsonArrayIndex(container, path[0], false)
+		container[i] = child
+	}
+	return doc, nil
+}
+
+func jsonPatchAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return jsonPatchUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch container := container.(type) {
+		case map[string]interface{}:
+			container[token] = value
+			return container, nil
+		case []interface{}:
+			i, err := jsonArrayIndex(container, token, true)
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[i+1:], container[i:])
+			container[i] = value
+			return container, nil
+		}
+		return nil, patchErrorf(http.StatusConflict, "%q is not in an object or an array", token)
+	})
+}
+
+// jsonPatchRemove returns doc without the value at path, and that value.
+func jsonPatchRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	var removed interface{}
+	doc, err := jsonPatchUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch container := container.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, patchErrorf(http.StatusConflict, "the member %q doesn't exist", token)
+			}
+			removed = value
+			delete(container, token)
+			return container, nil
+		case []interface{}:
+			i, err := jsonArrayIndex(container, token, false)
+			if err != nil {
+				return nil, err
+			}
+			removed = container[i]
+			return append(container[:i], container[i+1:]...), nil
+		}
+		return nil, patchErrorf(http.StatusConflict, "%q is not in an object or an array", token)
+	})
+	return doc, removed, err
+}
+
+// copyJSON returns a deep copy of a value decoded by decodeJSON.
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			c[k] = copyJSON(value)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = copyJSON(value)
+		}
+		return c
+	}
+	return v
+}
+
+// equalJSON reports whether a and b, decoded by decodeJSON, are equal as RFC 6902's test operation defines it:
+// numbers are compared by value.
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, value := range a {
+			other, ok := b[k]
+			if !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type patchedUser struct {
+	Name    string            `json:"name"`
+	Email   string            `json:"email,omitempty"`
+	Tags    []string          `json:"tags"`
+	Prefs   map[string]string `json:"prefs,omitempty"`
+	Version int               `json:"version"`
+	Secret  string            `json:"-"`
+	loaded  bool
+}
+
+func (u *patchedUser) ValidatePatch() error {
+	if u.Name == "" {
+		return errors.New("the name is required")
+	}
+	return nil
+}
+
+func newPatchRequest(contentType, body string) *Request {
+	_, req := newTestRequest("PATCH", "/users/1")
+	req.Header.Set("Content-Type", contentType)
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	return &Request{Request: req}
+}
+
+func testUser() *patchedUser {
+	return &patchedUser{Name: "Ann", Email: "ann@example.com", Tags: []string{"a", "b"}, Prefs: map[string]string{"theme": "dark", "lang": "en"}, Version: 3, Secret: "s3cret", loaded: true}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	user := testUser()
+	req := newPatchRequest("application/merge-patch+json; charset=utf-8", `{"email": null, "tags": ["c"], "prefs": {"lang": null, "tz": "UTC"}}`)
+	assert.NoError(t, req.ApplyPatch(user))
+	assert.Equal(t, &patchedUser{Name: "Ann", Tags: []string{"c"}, Prefs: map[string]string{"theme": "dark", "tz": "UTC"}, Version: 3, Secret: "s3cret", loaded: true}, user)
+
+	doc := map[string]interface{}{"a": 1.0, "b": map[string]interface{}{"c": true}}
+	req = newPatchRequest(MergePatchMediaType, `{"b": {"c": null, "d": 2}}`)
+	assert.NoError(t, req.ApplyPatch(&doc))
+	assert.Equal(t, map[string]interface{}{"a": 1.0, "b": map[string]interface{}{"d": 2.0}}, doc)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	user := testUser()
+	req := newPatchRequest(JSONPatchMediaType, `[
+		{"op": "test", "path": "/version", "value": 3.0},
+		{"op": "replace", "path": "/version", "value": 4},
+		{"op": "add", "path": "/tags/1", "value": "x"},
+		{"op": "add", "path": "/tags/-", "value": "z"},
+		{"op": "remove", "path": "/tags/0"},
+		{"op": "move", "from": "/prefs/theme", "path": "/prefs/color~1theme"},
+		{"op": "copy", "from": "/name", "path": "/prefs/owner"}
+	]`)
+	assert.NoError(t, req.ApplyPatch(user))
+	assert.Equal(t, &patchedUser{Name: "Ann", Email: "ann@example.com", Tags: []string{"x", "b", "z"}, Prefs: map[string]string{"color/theme": "dark", "lang": "en", "owner": "Ann"}, Version: 4, Secret: "s3cret", loaded: true}, user)
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	cases := []struct {
+		contentType, body string
+		status            int
+		message           string
+	}{
+		{"application/json", `{}`, http.StatusUnsupportedMediaType, "Content-Type"},
+		{MergePatchMediaType, `{`, http.StatusBadRequest, "malformed"},
+		{MergePatchMediaType, `{"name": ""}`, http.StatusUnprocessableEntity, "the name is required"},
+		{MergePatchMediaType, `{"version": "four"}`, http.StatusUnprocessableEntity, "invalid"},
+		{JSONPatchMediaType, `[{"op": "test", "path": "/version", "value": 2}]`, http.StatusConflict, `operation 0 (test): the value at "/version" is different`},
+		{JSONPatchMediaType, `[{"op": "remove", "path": "/missing"}]`, http.StatusConflict, `doesn't exist`},
+		{JSONPatchMediaType, `[{"op": "add", "path": "/tags/5", "value": "x"}]`, http.StatusConflict, "out of range"},
+		{JSONPatchMediaType, `[{"op": "add", "path": "/tags/01", "value": "x"}]`, http.StatusBadRequest, "not an array index"},
+		{JSONPatchMediaType, `[{"op": "move", "from": "/prefs", "path": "/prefs/x"}]`, http.StatusBadRequest, "into itself"},
+		{JSONPatchMediaType, `[{"op": "add", "path": "/name"}]`, http.StatusBadRequest, "no value"},
+		{JSONPatchMediaType, `[{"op": "frob", "path": "/name"}]`, http.StatusBadRequest, "unknown operation"},
+	}
+	for _, c := range cases {
+		user := testUser()
+		err := newPatchRequest(c.contentType, c.body).ApplyPatch(user)
+		patchErr, ok := err.(*PatchError)
+		if assert.True(t, ok, c.body) {
+			assert.Equal(t, c.status, patchErr.Status, c.body)
+			assert.Contains(t, patchErr.Message, c.message, c.body)
+		}
+		assert.Equal(t, testUser(), user, c.body)
+	}
+}
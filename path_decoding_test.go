@@ -0,0 +1,88 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathParamDecoding(t *testing.T) {
+	router := New(Context{})
+	router.Get("/files/:name", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "%s|%s", req.PathParams["name"], req.RawPathParam("name"))
+	})
+	router.Get("/files/:name/meta", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "meta:%s", req.PathParams["name"])
+	})
+	router.Get("/café", (*Context).A)
+
+	for target, expected := range map[string]string{
+		"/files/a+b":           "a+b|a+b",
+		"/files/a%20b":         "a b|a%20b",
+		"/files/a%2Fb":         "a/b|a%2Fb",
+		"/files/a%2fb":         "a/b|a%2fb",
+		"/files/a%252Fb":       "a%2Fb|a%252Fb",
+		"/files/%E2%9C%93":     "✓|%E2%9C%93",
+		"/files/a%2Fb/meta":    "meta:a/b",
+		"/files/a%2F%20b/meta": "meta:a/ b",
+		"/caf%C3%A9":           "context-A",
+		"/café":                "context-A",
+	} {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com"+target, nil)
+		if !assert.NoError(t, err, target) {
+			continue
+		}
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, expected, 200)
+	}
+}
+
+func TestRawPathParamOptionalAndCatchAll(t *testing.T) {
+	router := New(Context{})
+	router.Get("/articles/:year(/:month)", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "%s|%s", req.RawPathParam("year"), req.RawPathParam("month"))
+	})
+	router.Get("/src/*path", func(rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "%s|%s", req.PathParams["path"], req.RawPathParam("path"))
+	})
+
+	for target, expected := range map[string]string{
+		"/articles/2024":          "2024|",
+		"/articles/2024/0%31":     "2024|0%31",
+		"/articles/2%30/a%2Fb":    "2%30|a%2Fb",
+		"/src/a%2Fb/c":            "a/b/c|a%2Fb/c",
+		"/src/dir/file%20name.go": "dir/file name.go|dir/file%20name.go",
+	} {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com"+target, nil)
+		if !assert.NoError(t, err, target) {
+			continue
+		}
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, expected, 200)
+	}
+}
+
+func TestPathParamInvalidEncoding(t *testing.T) {
+	router := New(Context{})
+	router.Get("/files/:name", (*Context).A)
+
+	_, err := http.NewRequest("GET", "http://example.com/files/%zz", nil)
+	assert.Error(t, err)
+
+	// A RawPath with invalid escapes (only possible if set by hand) doesn't route.
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/files/x", nil)
+	req.URL = &url.URL{Path: "/files/a/b", RawPath: "/files/a%2Fb%zz"}
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 404, rw.Code)
+
+	rw, req = newTestRequest("GET", "/files/a")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+}
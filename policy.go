@@ -0,0 +1,132 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PolicyInput describes a routed request to a PolicyEngine. It serializes to JSON so it can be handed to
+// external engines as is.
+type PolicyInput struct {
+	Method       string             `json:"method"`
+	Path         string             `json:"path"`
+	Route        string             `json:"route"`
+	RouteName    string             `json:"route_name,omitempty"`
+	Params       map[string]string  `json:"params,omitempty"`
+	Principal    interface{}        `json:"principal,omitempty"`
+	Metadata     map[string]string  `json:"metadata,omitempty"`
+	Requirements AccessRequirements `json:"requirements"`
+}
+
+// PolicyEngine decides whether a request may proceed. An error means no decision could be made; the request
+// is then treated like a panic, so Error handlers can report it.
+type PolicyEngine interface {
+	Evaluate(input *PolicyInput) (allow bool, err error)
+}
+
+// PrincipalFunc extracts the authenticated principal from a request for a PolicyInput. ctx is the context of
+// the router the policy was set on. Return nil if the request is anonymous.
+type PrincipalFunc func(ctx interface{}, req *Request) interface{}
+
+type routerPolicy struct {
+	engine    PolicyEngine
+	principal PrincipalFunc
+}
+
+// Policy sets a PolicyEngine that must allow every request routed to this router or its subrouters, and returns
+// the router. Like an Authorizer, the engine runs after all middleware, right before the handler. principal may be nil.
+func (r *Router) Policy(engine PolicyEngine, principal PrincipalFunc) *Router {
+	r.policy = &routerPolicy{engine: engine, principal: principal}
+	r.tables.settingsChanged()
+	return r
+}
+
+// WithMetadata attaches a key/value pair to the route, and returns the route. Metadata is passed to
+// PolicyEngines and included in route snapshots.
+func (r *Route) WithMetadata(key, value string) *Route {
+	r.checkConfigurable()
+	if r.metadata == nil {
+		r.metadata = make(map[string]string)
+	}
+	r.metadata[key] = value
+	return r
+}
+
+// Metadata returns the value attached to the route for key, or "" if there is none.
+func (r *Route) Metadata(key string) string {
+	return r.metadata[key]
+}
+
+// checkPolicy runs the nearest PolicyEngine for the routed request, if there is one. If the request is denied,
+// the error response is rendered and false is returned.
+func (closure *middlewareClosure) checkPolicy(rw ResponseWriter, req *Request) bool {
+	for i := len(closure.Routers) - 1; i >= 0; i-- {
+		policy := closure.Routers[i].policy
+		if policy == nil {
+			continue
+		}
+
+		input := &PolicyInput{
+			Method:       req.Method,
+			Path:         req.URL.Path,
+			Route:        req.route.path,
+			RouteName:    req.route.Name,
+			Params:       req.PathParams,
+			Metadata:     req.route.metadata,
+			Requirements: req.route.Requirements(),
+		}
+		if policy.principal != nil {
+			input.Principal = policy.principal(closure.Contexts[i].Interface(), req)
+		}
+
+		allow, err := policy.engine.Evaluate(input)
+		if err != nil {
+			panic(err)
+		}
+		if !allow {
+			renderError(rw, req, http.StatusForbidden, DefaultForbiddenResponse)
+		}
+		return allow
+	}
+	return true
+}
+
+// OPAPolicy is a PolicyEngine that queries an Open Policy Agent server through its data API.
+// URL is the full URL of a boolean rule, eg "http://localhost:8181/v1/data/httpapi/authz/allow".
+// The PolicyInput is sent as the query's input. An undefined result denies the request.
+type OPAPolicy struct {
+	URL    string
+	Client *http.Client // http.DefaultClient if nil.
+}
+
+// Evaluate implements PolicyEngine.
+func (p *OPAPolicy) Evaluate(input *PolicyInput) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, err
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(p.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("web: OPA query to %s returned status %d", p.URL, resp.StatusCode)
+	}
+
+	var decision struct {
+		Result *bool `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, err
+	}
+	return decision.Result != nil && *decision.Result, nil
+}
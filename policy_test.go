@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type policyFunc func(input *PolicyInput) (bool, error)
+
+func (f policyFunc) Evaluate(input *PolicyInput) (bool, error) {
+	return f(input)
+}
+
+func TestPolicy(t *testing.T) {
+	var got *PolicyInput
+	engine := policyFunc(func(input *PolicyInput) (bool, error) {
+		got = input
+		return input.Principal == "alice" && input.Params["id"] == "3", nil
+	})
+
+	router := New(Context{})
+	router.Policy(engine, func(ctx interface{}, req *Request) interface{} {
+		return req.Header.Get("X-User")
+	})
+	router.Get("/orders/:id", (*Context).A).Named("order").WithMetadata("owner", "billing").RequireScopes("orders:read")
+	router.Authorizer(AuthorizerFunc(func(ctx interface{}, req *Request, required AccessRequirements) error { return nil }))
+
+	rw, req := newTestRequest("GET", "/orders/3")
+	req.Header.Set("X-User", "alice")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+	assert.Equal(t, &PolicyInput{
+		Method:       "GET",
+		Path:         "/orders/3",
+		Route:        "/orders/:id",
+		RouteName:    "order",
+		Params:       map[string]string{"id": "3"},
+		Principal:    "alice",
+		Metadata:     map[string]string{"owner": "billing"},
+		Requirements: AccessRequirements{Scopes: []string{"orders:read"}},
+	}, got)
+
+	rw, req = newTestRequest("GET", "/orders/3")
+	req.Header.Set("X-User", "mallory")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+}
+
+func TestOPAPolicy(t *testing.T) {
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query struct {
+			Input PolicyInput `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&query)
+		switch query.Input.Path {
+		case "/allowed":
+			w.Write([]byte(`{"result": true}`))
+		case "/denied":
+			w.Write([]byte(`{"result": false}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer opa.Close()
+
+	router := New(Context{})
+	router.Policy(&OPAPolicy{URL: opa.URL + "/v1/data/httpapi/authz/allow"}, nil)
+	router.Get("/allowed", (*Context).A)
+	router.Get("/denied", (*Context).A)
+	router.Get("/undefined", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/allowed")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/denied")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	rw, req = newTestRequest("GET", "/undefined")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits how often each client may make requests, with a token bucket per key: a bucket holds up to
+// Burst tokens, every request takes one, and it refills at Requests tokens Per interval. Requests finding their
+// bucket empty are rejected with 429 Too Many Requests.
+//
+// A RateLimiter's buckets are shared by every router and route its Middleware is added to, so add one to a router
+// to limit all of its routes together, or to a route with Route.Use to limit just that route:
+//
+//	router.Middleware(web.NewRateLimiter(100, time.Minute).Middleware())
+//	router.Post("/search", (*Context).Search).Use(web.NewRateLimiter(5, time.Second).Middleware())
+type RateLimiter struct {
+	Store RateLimitStore
+
+	// Requests Per interval is the rate the buckets refill at.
+	Requests int
+	Per      time.Duration
+
+	// Burst is the most requests made at once. Defaults to Requests.
+	Burst int
+
+	// Key returns the key of the request's bucket. Defaults to ClientIP; see also RateLimitByHeader.
+	Key func(*Request) string
+
+	// Name prefixes the keys (as "name:key"), so that limiters sharing a Store, eg in Redis, have their own buckets.
+	Name string
+}
+
+// RateLimitStore keeps token buckets, eg in Redis so that all servers share them.
+type RateLimitStore interface {
+	// Take takes a token from key's bucket, which holds up to burst tokens and gains one every interval. It
+	// returns the tokens left in the bucket, and if it was empty, how long until it has a token again.
+	// Take must be atomic; a Redis store can use a Lua script.
+	Take(key string, burst int, interval time.Duration) (remaining int, wait time.Duration, err error)
+}
+
+// DefaultTooManyRequestsResponse is the default text rendered when RateLimiter.Middleware rejects a request.
+var DefaultTooManyRequestsResponse = "Too Many Requests"
+
+// NewRateLimiter returns a RateLimiter allowing requests Per interval for each client IP, with buckets in a new
+// MemoryRateLimitStore.
+func NewRateLimiter(requests int, per time.Duration) *RateLimiter {
+	return &RateLimiter{
+		Store:    NewMemoryRateLimitStore(),
+		Requests: requests,
+		Per:      per,
+		Key:      ClientIP,
+	}
+}
+
+// RateLimitByHeader returns a RateLimiter.Key using the request's header, eg an API key. Requests without the
+// header are limited by their ClientIP.
+//
+// Clients choose the header's value, so one sending a new value with every request gets a new bucket every time.
+// Only use it after middleware rejecting unknown values, eg an API key check, or limit by ClientIP as well.
+func RateLimitByHeader(header string) func(*Request) string {
+	return func(req *Request) string {
+		if v := req.Header.Get(header); v != "" {
+			return header + ":" + v
+		}
+		return "ip:" + ClientIP(req)
+	}
+}
+
+// Middleware returns middleware enforcing the limit. Responses get X-RateLimit-Limit (the burst),
+// X-RateLimit-Remaining and X-RateLimit-Reset (seconds until the bucket is full again) headers, and rejected
+// ones a Retry-After header too. It panics if the limiter's rate isn't positive.
+func (rl *RateLimiter) Middleware() func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if rl.Requests <= 0 || rl.Per/time.Duration(rl.Requests) <= 0 {
+		panic("web: a RateLimiter needs positive Requests, and a Per of at least Requests nanoseconds")
+	}
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		burst := rl.Burst
+		if burst <= 0 {
+			burst = rl.Requests
+		}
+		key := ClientIP
+		if rl.Key != nil {
+			key = rl.Key
+		}
+		interval := rl.Per / time.Duration(rl.Requests)
+		remaining, wait, err := rl.Store.Take(rl.Name+":"+key(req), burst, interval)
+		if err != nil {
+			panic(err)
+		}
+
+		header := rw.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(burst))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((time.Duration(burst-remaining) * interval).Seconds()))))
+		if wait > 0 {
+			header.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			renderError(rw, req, http.StatusTooManyRequests, DefaultTooManyRequestsResponse)
+			return
+		}
+		next(rw, req)
+	}
+}
+
+// MemoryRateLimitStore is a RateLimitStore for a single process.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets *expiringMap[*tokenBucket] // each until it's full, and can be forgotten
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: newExpiringMap[*tokenBucket]()}
+}
+
+// Take implements RateLimitStore. Full buckets are dropped about once a minute.
+func (s *MemoryRateLimitStore) Take(key string, burst int, interval time.Duration) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	b, ok := s.buckets.get(key, now)
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+float64(now.Sub(b.last))/float64(interval))
+	b.last = now
+	if b.tokens < 1 {
+		return 0, time.Duration((1 - b.tokens) * float64(interval)), nil
+	}
+	b.tokens--
+	s.buckets.set(key, b, now.Add(time.Duration((float64(burst)-b.tokens)*float64(interval))), now)
+	return int(b.tokens), 0, nil
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	for i := 2; i >= 0; i-- {
+		remaining, wait, err := store.Take("a", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, remaining)
+		assert.Equal(t, time.Duration(0), wait)
+	}
+	remaining, wait, _ := store.Take("a", 3, time.Minute)
+	assert.Equal(t, 0, remaining)
+	assert.True(t, wait > 59*time.Second && wait <= time.Minute, wait.String())
+
+	remaining, _, _ = store.Take("b", 3, time.Minute)
+	assert.Equal(t, 2, remaining)
+
+	remaining, wait, _ = store.Take("c", 1, 10*time.Millisecond)
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, time.Duration(0), wait)
+	time.Sleep(20 * time.Millisecond)
+	_, wait, _ = store.Take("c", 1, 10*time.Millisecond)
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(NewRateLimiter(2, time.Minute).Middleware())
+	search := NewRateLimiter(1, time.Second)
+	search.Key = RateLimitByHeader("X-API-Key")
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("home"))
+	})
+	router.Get("/search", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("results"))
+	}).Use(search.Middleware())
+
+	get := func(path, ip, apiKey string) *httpResponse {
+		rw, req := newTestRequest("GET", path)
+		req.RemoteAddr = ip + ":5000"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		router.ServeHTTP(rw, req)
+		return &httpResponse{rw.Code, rw.Header(), strings.TrimSpace(rw.Body.String())}
+	}
+
+	resp := get("/search", "10.0.0.1", "k1")
+	assert.Equal(t, http.StatusOK, resp.code)
+	assert.Equal(t, "1", resp.header.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", resp.header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", resp.header.Get("X-RateLimit-Reset"))
+
+	resp = get("/search", "10.0.0.1", "k1")
+	assert.Equal(t, http.StatusTooManyRequests, resp.code)
+	assert.Equal(t, "Too Many Requests", resp.body)
+	assert.Equal(t, "1", resp.header.Get("Retry-After"))
+
+	// The router's limit has no requests left for 10.0.0.1 either.
+	resp = get("/", "10.0.0.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, resp.code)
+	assert.Equal(t, "30", resp.header.Get("Retry-After"))
+	assert.Equal(t, "2", resp.header.Get("X-RateLimit-Limit"))
+
+	resp = get("/search", "10.0.0.2", "k2")
+	assert.Equal(t, http.StatusOK, resp.code)
+}
+
+type httpResponse struct {
+	code   int
+	header http.Header
+	body   string
+}
+
+func TestRateLimiterInvalidRate(t *testing.T) {
+	assert.Panics(t, func() { NewRateLimiter(0, time.Minute).Middleware() })
+	assert.Panics(t, func() { NewRateLimiter(10, 0).Middleware() })
+	assert.Panics(t, func() { NewRateLimiter(10, 5*time.Nanosecond).Middleware() })
+}
@@ -0,0 +1,226 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RememberMe issues and verifies persistent-login tokens using the selector/verifier pattern. The cookie holds
+// a selector, which identifies the login on this device, and a verifier, of which the store only keeps a hash.
+// The verifier is rotated every time the token is used. If a token shows up with the right selector but an old
+// verifier, it was copied and used elsewhere: every token of the user is revoked and ErrRememberMeTheft returned.
+//
+// With Sessions, add its Middleware after the Sessions middleware: requests whose session has no user are logged
+// in from their token, which keeps the user in the session from then on. Otherwise, call Authenticate from your
+// authentication middleware when there is no other credential, and log the user in if it returns a user ID.
+// Because the verifier rotates, concurrent requests with the same old cookie look like theft; call Authenticate
+// only when starting a new login session, not on every request.
+type RememberMe struct {
+	// Name of the cookie holding the token.
+	Name string
+
+	// MaxAge is how long a token is valid after it was last used. Defaults to 30 days.
+	MaxAge time.Duration
+
+	// SessionKey is the session value Authenticate keeps the user's ID in, for requests that have a session.
+	// Defaults to "user_id".
+	SessionKey string
+
+	Store RememberMeStore
+}
+
+// RememberMeToken is what a RememberMeStore persists for each device a user is remembered on.
+type RememberMeToken struct {
+	Selector     string
+	VerifierHash []byte
+	UserID       string
+	Expires      time.Time
+}
+
+// RememberMeStore persists remember-me tokens, eg in a database table indexed by selector and by user ID.
+type RememberMeStore interface {
+	// Save creates or replaces the token with token.Selector.
+	Save(token RememberMeToken) error
+	// Find returns the token with selector, or nil if there is none.
+	Find(selector string) (*RememberMeToken, error)
+	// Delete removes the token with selector, if any.
+	Delete(selector string) error
+	// DeleteUser removes every token of userID.
+	DeleteUser(userID string) error
+}
+
+// ErrRememberMeTheft is returned by RememberMe.Authenticate when a token was reused after it had been rotated.
+var ErrRememberMeTheft = errors.New("web: remember-me token was reused, all of the user's tokens were revoked")
+
+// NewRememberMe returns a RememberMe storing its tokens in store and its cookie under name.
+func NewRememberMe(name string, store RememberMeStore) *RememberMe {
+	return &RememberMe{Name: name, MaxAge: 30 * 24 * time.Hour, SessionKey: "user_id", Store: store}
+}
+
+// Middleware returns middleware that logs in requests whose session has no user, if their cookie remembers one
+// (see Authenticate). It must run after the Sessions middleware. Requests with a stolen token go on without a
+// user.
+func (rm *RememberMe) Middleware() func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if session := req.Session(); session != nil && session.Get(rm.sessionKey()) == "" {
+			if _, err := rm.Authenticate(rw, req); err != nil && err != ErrRememberMeTheft {
+				panic(err)
+			}
+		}
+		next(rw, req)
+	}
+}
+
+func (rm *RememberMe) sessionKey() string {
+	if rm.SessionKey == "" {
+		return "user_id"
+	}
+	return rm.SessionKey
+}
+
+// Issue remembers userID on this device, eg after a login with "remember me" checked.
+func (rm *RememberMe) Issue(rw ResponseWriter, userID string) error {
+	return rm.issue(rw, randomToken(), userID)
+}
+
+func (rm *RememberMe) issue(rw ResponseWriter, selector, userID string) error {
+	verifier := randomToken()
+	token := RememberMeToken{
+		Selector:     selector,
+		VerifierHash: hashVerifier(verifier),
+		UserID:       userID,
+		Expires:      time.Now().Add(rm.MaxAge),
+	}
+	if err := rm.Store.Save(token); err != nil {
+		return err
+	}
+	return SetCookie(rw, Cookie{Name: rm.Name, Value: selector + ":" + verifier, Expires: token.Expires})
+}
+
+// Authenticate returns the ID of the user remembered by the request's cookie and rotates the token. If the request
+// has a session, it also renews the session's ID and sets SessionKey to the user's ID in it, like a login. It
+// returns an empty ID if there is no valid token, clearing the cookie if there was one.
+func (rm *RememberMe) Authenticate(rw ResponseWriter, req *Request) (string, error) {
+	cookie, err := req.Cookie(rm.Name)
+	if err != nil {
+		return "", nil
+	}
+	selector, verifier, ok := splitRememberMeCookie(cookie.Value)
+	if !ok {
+		return "", DeleteCookie(rw, rm.Name)
+	}
+
+	token, err := rm.Store.Find(selector)
+	if err != nil {
+		return "", err
+	}
+	if token == nil || time.Now().After(token.Expires) {
+		return "", DeleteCookie(rw, rm.Name)
+	}
+	if subtle.ConstantTimeCompare(token.VerifierHash, hashVerifier(verifier)) != 1 {
+		if err := rm.Store.DeleteUser(token.UserID); err != nil {
+			return "", err
+		}
+		DeleteCookie(rw, rm.Name)
+		return "", ErrRememberMeTheft
+	}
+
+	if session := req.Session(); session != nil {
+		session.RenewID()
+		session.Set(rm.sessionKey(), token.UserID)
+	}
+	return token.UserID, rm.issue(rw, selector, token.UserID)
+}
+
+// Forget stops remembering the user on this device, eg on logout, and removes SessionKey from the request's
+// session, if it has one.
+func (rm *RememberMe) Forget(rw ResponseWriter, req *Request) error {
+	if session := req.Session(); session != nil {
+		session.Delete(rm.sessionKey())
+	}
+	if cookie, err := req.Cookie(rm.Name); err == nil {
+		if selector, _, ok := splitRememberMeCookie(cookie.Value); ok {
+			if err := rm.Store.Delete(selector); err != nil {
+				return err
+			}
+		}
+	}
+	return DeleteCookie(rw, rm.Name)
+}
+
+func splitRememberMeCookie(value string) (selector, verifier string, ok bool) {
+	i := strings.IndexByte(value, ':')
+	if i <= 0 || i == len(value)-1 {
+		return "", "", false
+	}
+	return value[:i], value[i+1:], true
+}
+
+func randomToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashVerifier(verifier string) []byte {
+	sum := sha256.Sum256([]byte(verifier))
+	return sum[:]
+}
+
+// MemoryRememberMeStore is a RememberMeStore for a single process, eg for tests.
+type MemoryRememberMeStore struct {
+	mu     sync.Mutex
+	tokens map[string]RememberMeToken
+}
+
+// NewMemoryRememberMeStore returns an empty MemoryRememberMeStore.
+func NewMemoryRememberMeStore() *MemoryRememberMeStore {
+	return &MemoryRememberMeStore{tokens: make(map[string]RememberMeToken)}
+}
+
+// Save implements RememberMeStore.
+func (s *MemoryRememberMeStore) Save(token RememberMeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token.Selector] = token
+	return nil
+}
+
+// Find implements RememberMeStore.
+func (s *MemoryRememberMeStore) Find(selector string) (*RememberMeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[selector]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Delete implements RememberMeStore.
+func (s *MemoryRememberMeStore) Delete(selector string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, selector)
+	return nil
+}
+
+// DeleteUser implements RememberMeStore.
+func (s *MemoryRememberMeStore) DeleteUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for selector, token := range s.tokens {
+		if token.UserID == userID {
+			delete(s.tokens, selector)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rememberMeRouter(rm *RememberMe) *Router {
+	router := New(Context{})
+	router.Post("/login", func(rw ResponseWriter, req *Request) {
+		if err := rm.Issue(rw, req.FormValue("user")); err != nil {
+			panic(err)
+		}
+	})
+	router.Get("/whoami", func(rw ResponseWriter, req *Request) {
+		userID, err := rm.Authenticate(rw, req)
+		if err == ErrRememberMeTheft {
+			rw.WriteHeader(http.StatusUnauthorized)
+		}
+		rw.Write([]byte(userID))
+	})
+	router.Post("/logout", func(rw ResponseWriter, req *Request) {
+		if err := rm.Forget(rw, req); err != nil {
+			panic(err)
+		}
+	})
+	return router
+}
+
+func rememberMeRequest(router *Router, method, path, cookie string) (*httptest.ResponseRecorder, string) {
+	rw, req := newTestRequest(method, path)
+	if cookie != "" {
+		req.Header.Set("Cookie", "remember="+cookie)
+	}
+	router.ServeHTTP(rw, req)
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == "remember" {
+			return rw, c.Value
+		}
+	}
+	return rw, cookie
+}
+
+func TestRememberMe(t *testing.T) {
+	store := NewMemoryRememberMeStore()
+	router := rememberMeRouter(NewRememberMe("remember", store))
+
+	_, cookie := rememberMeRequest(router, "POST", "/login?user=alice", "")
+	assert.True(t, strings.Contains(cookie, ":"))
+	assert.Equal(t, 1, len(store.tokens))
+
+	rw, rotated := rememberMeRequest(router, "GET", "/whoami", cookie)
+	assertResponse(t, rw, "alice", 200)
+	assert.NotEqual(t, cookie, rotated)
+	assert.Equal(t, strings.Split(cookie, ":")[0], strings.Split(rotated, ":")[0])
+
+	rw, _ = rememberMeRequest(router, "GET", "/whoami", rotated)
+	assertResponse(t, rw, "alice", 200)
+
+	rw, cleared := rememberMeRequest(router, "GET", "/whoami", "unknown:token")
+	assertResponse(t, rw, "", 200)
+	assert.Equal(t, "", cleared)
+
+	_, other := rememberMeRequest(router, "POST", "/login?user=bob", "")
+	rememberMeRequest(router, "POST", "/logout", other)
+	rw, _ = rememberMeRequest(router, "GET", "/whoami", other)
+	assertResponse(t, rw, "", 200)
+}
+
+func TestRememberMeTheft(t *testing.T) {
+	store := NewMemoryRememberMeStore()
+	router := rememberMeRouter(NewRememberMe("remember", store))
+
+	_, stolen := rememberMeRequest(router, "POST", "/login?user=alice", "")
+	_, laptop := rememberMeRequest(router, "POST", "/login?user=alice", "")
+	_, phone := rememberMeRequest(router, "POST", "/login?user=bob", "")
+
+	_, rotated := rememberMeRequest(router, "GET", "/whoami", stolen)
+	assert.NotEqual(t, stolen, rotated)
+
+	rw, _ := rememberMeRequest(router, "GET", "/whoami", stolen)
+	assertResponse(t, rw, "", 401)
+
+	for _, cookie := range []string{rotated, laptop} {
+		rw, _ = rememberMeRequest(router, "GET", "/whoami", cookie)
+		assertResponse(t, rw, "", 200)
+	}
+	rw, _ = rememberMeRequest(router, "GET", "/whoami", phone)
+	assertResponse(t, rw, "bob", 200)
+}
+
+func TestRememberMeSessions(t *testing.T) {
+	rm := NewRememberMe("remember", NewMemoryRememberMeStore())
+	router := New(Context{})
+	router.Middleware(NewSessions(NewMemorySessionStore()).Middleware())
+	router.Middleware(rm.Middleware())
+	router.Post("/login", func(rw ResponseWriter, req *Request) {
+		if err := rm.Issue(rw, req.FormValue("user")); err != nil {
+			panic(err)
+		}
+	})
+	router.Post("/visit", func(rw ResponseWriter, req *Request) {
+		req.Session().Set("visited", "yes")
+	})
+	router.Get("/whoami", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.Session().Get("user_id")))
+	})
+
+	serve := func(method, path string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, map[string]*http.Cookie) {
+		rw, req := newTestRequest(method, path)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		router.ServeHTTP(rw, req)
+		set := make(map[string]*http.Cookie)
+		for _, c := range rw.Result().Cookies() {
+			set[c.Name] = c
+		}
+		return rw, set
+	}
+
+	_, set := serve("POST", "/login?user=alice")
+	remember := set["remember"]
+	_, set = serve("POST", "/visit")
+	anonymous := set["_session"]
+
+	// The remembered user is logged in, in a session with a new ID.
+	rw, set := serve("GET", "/whoami", anonymous, remember)
+	assertResponse(t, rw, "alice", 200)
+	session := set["_session"]
+	if assert.NotNil(t, session) {
+		assert.NotEqual(t, anonymous.Value, session.Value)
+	}
+	rotated := set["remember"]
+	if assert.NotNil(t, rotated) {
+		assert.NotEqual(t, remember.Value, rotated.Value)
+	}
+
+	// From then on the session has the user, and the token isn't used again.
+	rw, set = serve("GET", "/whoami", session, rotated)
+	assertResponse(t, rw, "alice", 200)
+	assert.Nil(t, set["remember"])
+
+	// A stolen token logs nobody in.
+	rw, _ = serve("GET", "/whoami", remember)
+	assertResponse(t, rw, "", 200)
+}
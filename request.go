@@ -2,10 +2,13 @@ package web
 
 import (
 	"bytes"
-	"container/list"
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
+	"time"
 )
 
 // Request wraps net/http's Request and gocraf/web specific fields. In particular, PathParams is used to access
@@ -15,15 +18,47 @@ type Request struct {
 
 	// PathParams exists if you have wildcards in your URL that you need to capture.
 	// Eg, /users/:id/tickets/:ticket_id and /users/1/tickets/33 would yield the map {id: "3", ticket_id: "33"}
+	// Params are percent-decoded exactly once: /files/a%2Fb.txt yields {name: "a/b.txt"}. See RawPathParam.
 	PathParams map[string]string
 
 	// The actual route that got invoked
 	route *Route
 
+	// The route's settings, or the root router's until the request is routed.
+	settings *routeSettings
+
+	// State that only some requests need, allocated by the first use. See extras.
+	more *requestExtras
+
 	rootContext   reflect.Value // Root context. Set immediately.
 	targetContext reflect.Value // The target context corresponding to the route. Not set until root middleware is done.
 }
 
+// requestExtras is the state of a request that most requests don't need, so that they don't pay for it. It's
+// shared by the Request, its ResponseWriter and the middleware closure serving it.
+type requestExtras struct {
+	connCtx      context.Context // the context as the request arrived, if SetContext replaced it; see Disconnected
+	bodySpool    *BodySpool      // see SpoolBody
+	afterSuccess []func()        // see AfterSuccess
+	pooled       bool            // the request is recycled once ServeHTTP returns; see PoolRequests
+
+	writingStatus int                 // the status being written, while BeforeWrite callbacks run
+	beforeWrite   []func(http.Header) // see BeforeWrite
+	strict        *strictState        // only set when the router is in debug mode
+
+	trace     []traceEntry // only recorded when the router is in debug mode
+	abandoned bool         // see serveWithTimeout
+	start     time.Time    // only set if there are subscribers for EventHandlerFinished
+}
+
+// extras returns the request's extras, allocating them if needed. Code that only reads them checks r.more.
+func (r *Request) extras() *requestExtras {
+	if r.more == nil {
+		r.more = &requestExtras{}
+	}
+	return r.more
+}
+
 // IsRouted can be called from middleware to determine if the request has been routed yet.
 func (r *Request) IsRouted() bool {
 	return r.route != nil
@@ -38,6 +73,30 @@ func (r *Request) RoutePath() string {
 	return ""
 }
 
+// RawPathParam returns the path param name as it appeared in the request, before percent-decoding. Eg, for
+// /files/:name and /files/a%2Fb.txt it returns "a%2Fb.txt", and for /src/*path and /src/a%2Fb/c it returns
+// "a%2Fb/c". It returns an empty string if there is no such param, or if it's in an optional group the request
+// left out.
+func (r *Request) RawPathParam(name string) string {
+	if r.route == nil {
+		return ""
+	}
+	rawSegments := splitPath(r.URL.EscapedPath())
+	routeSegments, ok := matchedRoutePath(rawSegments, r.route.path)
+	if !ok {
+		return ""
+	}
+	for i, seg := range routeSegments {
+		if isWld, wldName, _ := isWildcard(seg); isWld && wldName == name {
+			return rawSegments[i]
+		}
+		if ca, caName := isCatchAll(seg); ca && caName == name {
+			return strings.Join(rawSegments[i:], "/")
+		}
+	}
+	return ""
+}
+
 func (r *Request) MustUrlFor(routeName string, pathParams ...string) string {
 	url, err := r.MappedUrlFor(routeName, nil, pathParams...)
 	if err != nil {
@@ -50,62 +109,48 @@ func (r *Request) UrlFor(routeName string, pathParams ...string) (string, error)
 	return r.MappedUrlFor(routeName, nil, pathParams...)
 }
 
+// Query holds named params for MappedUrlFor, eg r.MappedUrlFor("search", web.Query{"q": "foo", "page": "2"}).
+type Query map[string]string
+
+// MappedUrlFor returns the path of the route named routeName. Wildcards are filled in from namedParams by name,
+// and then from pathParams in order. Named params the route's path doesn't use are added as a query string,
+// sorted by name.
 func (r *Request) MappedUrlFor(routeName string, namedParams map[string]string, pathParams ...string) (string, error) {
 	if r.route == nil {
 		return "", fmt.Errorf("Request to %s is not associated with any route.", r.RequestURI)
 	}
-	if namedParams == nil {
-		namedParams = make(map[string]string)
-	}
-
-	discovered := list.New()
-	routers := list.New()
-
-	// Start searching from the current router,
-	// but go back to the root if needed.
-	routers.PushBack(r.route.router)
-	didPushRootRouter := false
-
-	for routers.Len() > 0 {
-		var router *Router = routers.Remove(routers.Front()).(*Router)
-		if contains(discovered, router) {
-			continue
-		}
-
-		discovered.PushBack(router)
-
-		for _, route := range router.routes {
-			if route.Name == routeName {
-				return fillPathParams(route.path, namedParams, pathParams...)
-			}
-		}
-
-		for _, r := range router.children {
-			routers.PushBack(r)
-		}
-
-		if !didPushRootRouter && routers.Len() == 0 {
-			didPushRootRouter = true
-			routers.PushBack(getRootRouter(r.route.router))
-		}
-	}
-
-	return "", fmt.Errorf("Route with name %s was not found.", routeName)
+	return r.route.router.MappedUrlFor(routeName, namedParams, pathParams...)
 }
 
 func fillPathParams(path string, namedParams map[string]string, otherParams ...string) (string, error) {
+	paths, err := expandOptional(path)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) > 1 {
+		path = longestFillablePath(paths, namedParams, len(otherParams))
+	}
+
 	buf := new(bytes.Buffer)
 	segments := splitPath(path)
 	currentParam := 0
 	otherParamIndex := 0
 	otherParamsLength := len(otherParams)
+	var usedNames []string
 
 	for _, seg := range segments {
 		buf.WriteString("/")
 		isWld, wldName, wldRegexpStr := isWildcard(seg)
+		ca, caName := isCatchAll(seg)
+		if ca {
+			isWld, wldName = true, caName
+		}
 
 		if isWld {
 			paramVal, ok := namedParams[wldName]
+			if ok {
+				usedNames = append(usedNames, wldName)
+			}
 
 			if !ok { // Try to get the param from otherParams
 				if otherParamIndex >= otherParamsLength {
@@ -115,11 +160,15 @@ func fillPathParams(path string, namedParams map[string]string, otherParams ...s
 				otherParamIndex += 1
 			}
 
-			if wldRegexpStr != "" && !compileRegexp(wldRegexpStr).MatchString(paramVal) {
+			if constraint, err := parseParamConstraint(wldRegexpStr); err != nil || (constraint != nil && !constraint.match(paramVal)) {
 				return "", fmt.Errorf("Could not match the parameter #%d from path '%s'. Tried to match with '%s'.", currentParam+1, path, paramVal)
 			}
 
 			currentParam += 1
+			if ca {
+				// A catch-all's value may be given with the slash before it, eg "/a/b" for /files/*path.
+				paramVal = strings.TrimPrefix(paramVal, "/")
+			}
 			buf.WriteString(paramVal)
 		} else {
 			buf.WriteString(seg)
@@ -130,12 +179,48 @@ func fillPathParams(path string, namedParams map[string]string, otherParams ...s
 		return "", fmt.Errorf("Path '%s' takes %d parameters, while %d was given.", path, otherParamIndex+1, otherParamsLength)
 	}
 
+	if len(namedParams) > len(usedNames) {
+		query := make(url.Values, len(namedParams)-len(usedNames))
+		for name, value := range namedParams {
+			if !containsString(usedNames, name) {
+				query.Set(name, value)
+			}
+		}
+		buf.WriteString("?")
+		buf.WriteString(query.Encode())
+	}
+
 	return buf.String(), nil
 }
 
-func contains(l *list.List, value interface{}) bool {
-	for e := l.Front(); e != nil; e = e.Next() {
-		if e.Value == value {
+// longestFillablePath returns the first of paths, the expansions of a path with optional groups, whose params
+// are all given, by name or as one of otherParams more params, or else the shortest.
+func longestFillablePath(paths []string, namedParams map[string]string, otherParams int) string {
+	for _, path := range paths {
+		unnamed := 0
+		for _, seg := range splitPath(path) {
+			name := ""
+			if wc, wcName, _ := isWildcard(seg); wc {
+				name = wcName
+			} else if ca, caName := isCatchAll(seg); ca {
+				name = caName
+			} else {
+				continue
+			}
+			if _, ok := namedParams[name]; !ok {
+				unnamed++
+			}
+		}
+		if unnamed <= otherParams {
+			return path
+		}
+	}
+	return paths[len(paths)-1]
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
 			return true
 		}
 	}
@@ -152,3 +237,8 @@ func getRootRouter(router *Router) *Router {
 	}
 	return router
 }
+
+// findNamedRoute returns the route named routeName in the whole tree of routers that router belongs to.
+func findNamedRoute(router *Router, routeName string) *Route {
+	return router.tables.current.Load().named[routeName]
+}
@@ -0,0 +1,70 @@
+package web
+
+import "context"
+
+// RequestIDOptions configures RequestIDMiddleware.
+type RequestIDOptions struct {
+	// Header is the request and response header carrying the ID. Defaults to "X-Request-Id".
+	Header string
+
+	// Trusted, if set, returns true for requests whose incoming ID is kept, eg those from your own load balancer
+	// or services. Other requests get a new ID, so that clients can't forge entries in your logs.
+	Trusted func(*Request) bool
+
+	// Generate returns new IDs. Defaults to 16 random hex digits.
+	Generate func() string
+}
+
+type requestIDContextKey struct{}
+
+// maxRequestIDLen is the length of the longest incoming ID that's kept.
+const maxRequestIDLen = 128
+
+// RequestIDMiddleware returns middleware giving each request an ID, to correlate log lines across services. The
+// ID is available as Request.RequestID (or RequestIDFromContext, for code that only has the request's context),
+// is sent back in the Header response header, and is logged by LoggerMiddleware. Pass it on in the Header of
+// requests to other services so that they log it too.
+//
+// Add it as the first root middleware, so that all other middleware sees the ID.
+func RequestIDMiddleware(opts RequestIDOptions) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if opts.Header == "" {
+		opts.Header = "X-Request-Id"
+	}
+	if opts.Generate == nil {
+		opts.Generate = newRequestID
+	}
+
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		id := req.Header.Get(opts.Header)
+		if id == "" || opts.Trusted == nil || !opts.Trusted(req) || !validRequestID(id) {
+			id = opts.Generate()
+		}
+		rw.Header().Set(opts.Header, id)
+		req.SetContext(context.WithValue(req.Context(), requestIDContextKey{}, id))
+		next(rw, req)
+	}
+}
+
+// RequestID returns the request's ID, or "" if the request didn't go through a RequestIDMiddleware.
+func (r *Request) RequestID() string {
+	return RequestIDFromContext(r.Context())
+}
+
+// RequestIDFromContext returns the ID of the request ctx belongs to, or "" if it has none. See RequestIDMiddleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// validRequestID returns true if id is short, and printable ASCII without spaces, so it's safe to log.
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{})
+	router.Middleware(LoggerMiddleware)
+	router.Middleware(RequestIDMiddleware(RequestIDOptions{
+		Trusted: func(req *Request) bool { return ClientIP(req) == "10.0.0.1" },
+	}))
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		assert.Equal(t, req.RequestID(), RequestIDFromContext(req.Context()))
+		rw.Write([]byte(req.RequestID()))
+	})
+
+	// Untrusted clients get a new ID.
+	rw, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "192.168.0.9:5000"
+	req.Header.Set("X-Request-Id", "forged")
+	router.ServeHTTP(rw, req)
+	id := rw.Body.String()
+	assert.Equal(t, 16, len(id))
+	assert.Equal(t, id, rw.Header().Get("X-Request-Id"))
+	assert.True(t, strings.HasSuffix(buf.String(), "'/' request_id="+id+"\n"), buf.String())
+
+	// Trusted ones keep theirs, unless it isn't safe to log.
+	rw, req = newTestRequest("GET", "/")
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Request-Id", "upstream-42")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "upstream-42", 200)
+
+	rw, req = newTestRequest("GET", "/")
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Request-Id", "bad id\n")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 16, len(rw.Body.String()))
+}
+
+func TestRequestIDWithoutMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.RequestID()))
+	})
+	rw, req := newTestRequest("GET", "/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", 200)
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"reflect"
+	"sync"
+)
+
+// PoolRequests turns request pooling on or off and returns the router. EXPERIMENTAL.
+//
+// With pooling on, the framework's per-request data (the Request and ResponseWriter wrappers, the slice of
+// contexts, the middleware trace, and the closure that advances the middleware stack) is recycled once
+// ServeHTTP returns instead of being left to the garbage collector. This cuts allocations for high-throughput
+// services, but handlers and middleware must not retain the *Request or ResponseWriter (eg, in a goroutine)
+// after they return. Your contexts are never pooled.
+//
+// Note that only the root router can pool requests. Toggling pooling only affects requests that start afterwards.
+func (r *Router) PoolRequests(enabled bool) *Router {
+	if r.parent != nil {
+		panic("You can only pool requests on the root router.")
+	}
+	if !enabled {
+		r.closurePool.Store(nil)
+		return r
+	}
+	r.closurePool.Store(&sync.Pool{
+		New: func() interface{} { return &middlewareClosure{} },
+	})
+	return r
+}
+
+// recycle resets closure and puts it back in pool, the pool it came from, keeping its slices' memory, its request
+// extras and its Next function. Closures still in use by a request that timed out aren't recycled.
+func recycle(pool *sync.Pool, closure *middlewareClosure) {
+	more := closure.Request.more
+	if more != nil && more.abandoned {
+		return
+	}
+	for i := range closure.Contexts {
+		closure.Contexts[i] = reflect.Value{}
+	}
+	contexts, next := closure.Contexts[:0], closure.Next
+	if more != nil {
+		*more = requestExtras{trace: more.trace[:0]}
+	}
+
+	*closure = middlewareClosure{}
+	closure.Contexts, closure.Next, closure.Request.more = contexts, next, more
+	pool.Put(closure)
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPoolRequests(t *testing.T) {
+	router := New(Context{}).PoolRequests(true)
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Middleware((*AdminContext).mwEpsilon)
+	admin.Get("/users/:id", func(c *AdminContext, w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "user-%s", r.PathParams["id"])
+	})
+	router.Get("/action", (*Context).A)
+	router.Get("/boom", (*Context).ErrorAction)
+
+	for i := 0; i < 3; i++ {
+		rw, req := newTestRequest("GET", fmt.Sprintf("/admin/users/%d", i))
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, fmt.Sprintf("admin-mw-Epsilon user-%d", i), 200)
+
+		rw, req = newTestRequest("GET", "/action")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "context-A", 200)
+
+		rw, req = newTestRequest("GET", "/boom")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Application Error", 500)
+	}
+}
+
+func TestPoolRequestsToggledInFlight(t *testing.T) {
+	router := New(Context{}).PoolRequests(true)
+	router.Get("/off", func(w ResponseWriter, r *Request) {
+		router.PoolRequests(false)
+		fmt.Fprint(w, "off")
+	})
+	router.Get("/on", func(w ResponseWriter, r *Request) {
+		router.PoolRequests(true)
+		fmt.Fprint(w, "on")
+	})
+
+	rw, req := newTestRequest("GET", "/off")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "off", 200)
+
+	rw, req = newTestRequest("GET", "/on")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "on", 200)
+}
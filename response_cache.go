@@ -0,0 +1,258 @@
+package web
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxCachedResponseSize is the size of the largest response body a ResponseCache keeps.
+var MaxCachedResponseSize = 1 << 20
+
+// cacheDirective is a route's caching policy. See Route.Cache.
+type cacheDirective struct {
+	public               bool
+	maxAge               time.Duration
+	staleWhileRevalidate time.Duration
+	header               string // the Cache-Control header
+}
+
+// Cache declares how long the route's responses may be cached, and returns the route. Successful responses (2xx,
+// and 301 and 308 redirects) get a Cache-Control header unless the handler sets one, eg
+// "public, max-age=60, stale-while-revalidate=30" for:
+//
+//	router.Get("/products/:id", (*Context).Product).Cache(true, time.Minute, 30*time.Second)
+//
+// Public responses may be kept by shared caches, like CDNs, and private ones only by the client's browser. If the
+// root router has a ResponseCache, GET responses of public routes are also cached by the router.
+func (r *Route) Cache(public bool, maxAge, staleWhileRevalidate time.Duration) *Route {
+	r.checkConfigurable()
+	header := "private"
+	if public {
+		header = "public"
+	}
+	header += ", max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	if staleWhileRevalidate > 0 {
+		header += ", stale-while-revalidate=" + strconv.Itoa(int(staleWhileRevalidate/time.Second))
+	}
+	r.cache = &cacheDirective{public: public, maxAge: maxAge, staleWhileRevalidate: staleWhileRevalidate, header: header}
+	return r
+}
+
+// applyCacheDirective sets the Cache-Control header of route's successful responses written to rw.
+func applyCacheDirective(rw *appResponseWriter, route *Route) {
+	rw.BeforeWrite(func(header http.Header) {
+		status := rw.extras().writingStatus
+		cacheable := (status >= 200 && status < 300) || status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+		if cacheable && header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", route.cache.header)
+		}
+	})
+}
+
+// ResponseCache turns on the router's in-memory cache of responses, which keeps up to maxEntries responses of
+// routes with a public Cache directive, and returns the router. Only 200 responses to GET requests are cached,
+// unless they set a cookie, say "Vary: *", or their handler overrode the Cache-Control header with no-store or
+// private. Cached responses vary by the request headers their Vary header names.
+//
+// A cached response is served while it's fresh (for the route's maxAge) instead of running the route's handler.
+// All middleware, and the route's access requirements, policy and challenge, still run first, so a response
+// is never served to a request they would have rejected. What's cached is what the handler wrote, so middleware
+// that transforms responses, like compression, still applies to cached ones. For staleWhileRevalidate longer,
+// it's still served, and the route is run again in the background (see Router.Dispatch) to refresh it.
+//
+// Requests with an Authorization or Cookie header are neither cached nor served from the cache, unless the
+// response's Vary header names it, as their responses are likely to be about the user.
+// Note that only the root router can have a ResponseCache.
+func (r *Router) ResponseCache(maxEntries int) *Router {
+	if r.parent != nil {
+		panic("You can only set a ResponseCache on the root router.")
+	}
+	r.responseCache = &responseCache{size: maxEntries, order: list.New(), entries: make(map[string]*list.Element)}
+	return r
+}
+
+// responseCache is a small LRU cache of responses.
+type responseCache struct {
+	sync.Mutex
+	size    int
+	order   *list.List // Most recently used at the front. Values are *cachedResponse.
+	entries map[string]*list.Element
+}
+
+type cachedResponse struct {
+	key          string
+	status       int
+	header       http.Header
+	body         []byte
+	stored       time.Time
+	vary         http.Header // the request headers the response varies by, and their values
+	revalidating bool
+}
+
+// cacheRevalidationKey marks the context of the requests that refresh stale responses, so they aren't served from
+// the cache themselves.
+type cacheRevalidationKey struct{}
+
+func responseCacheKey(req *Request) string {
+	return req.Host + " " + req.URL.RequestURI()
+}
+
+// serve writes the cached response for req, if there is a usable one, and refreshes it in the background if it's
+// stale. It returns false if there is none.
+func (c *responseCache) serve(rootRouter *Router, rw ResponseWriter, req *Request, route *Route) bool {
+	if req.Context().Value(cacheRevalidationKey{}) != nil {
+		return false
+	}
+	key := responseCacheKey(req)
+	c.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.Unlock()
+		return false
+	}
+	entry := e.Value.(*cachedResponse)
+	age := time.Since(entry.stored)
+	if age >= route.cache.maxAge+route.cache.staleWhileRevalidate || !entry.matches(req) || hasCredentials(req, entry.vary) {
+		c.Unlock()
+		return false
+	}
+	c.order.MoveToFront(e)
+	if age >= route.cache.maxAge && !entry.revalidating {
+		entry.revalidating = true
+		go c.revalidate(rootRouter, entry, req.Host, req.URL.RequestURI())
+	}
+	c.Unlock()
+
+	header := rw.Header()
+	for k, v := range entry.header {
+		header[k] = v
+	}
+	header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	rw.WriteHeader(entry.status)
+	rw.Write(entry.body)
+	return true
+}
+
+// revalidate runs the request of entry again, which replaces entry if it's still cacheable.
+func (c *responseCache) revalidate(rootRouter *Router, entry *cachedResponse, host, uri string) {
+	header := entry.vary.Clone()
+	header.Set("Host", host)
+	ctx := context.WithValue(context.Background(), cacheRevalidationKey{}, true)
+	rootRouter.Dispatch(ctx, "GET", uri, nil, header)
+
+	c.Lock()
+	entry.revalidating = false
+	c.Unlock()
+}
+
+// matches returns true if req has the header values the response varies by.
+func (entry *cachedResponse) matches(req *Request) bool {
+	for k := range entry.vary {
+		if strings.Join(req.Header.Values(k), ",") != strings.Join(entry.vary.Values(k), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// credentialHeaders are the request headers that make a response likely to be about the user.
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// hasCredentials returns true if req has credentials that vary doesn't name, so the response for it mustn't be
+// shared.
+func hasCredentials(req *Request, vary http.Header) bool {
+	for _, k := range credentialHeaders {
+		if _, varies := vary[k]; req.Header.Get(k) != "" && !varies {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *responseCache) add(entry *cachedResponse) {
+	if c.size <= 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[entry.key]; ok {
+		c.order.Remove(e)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Remove(c.order.Back()).(*cachedResponse)
+		delete(c.entries, oldest.key)
+	}
+}
+
+// cacheRecorder is the ResponseWriter of a request whose response may be cached. It records the response as it's
+// written.
+type cacheRecorder struct {
+	ResponseWriter
+	status   int
+	header   http.Header
+	body     []byte
+	tooLarge bool
+	failed   bool // a write failed, eg after a Timeout
+}
+
+// WriteHeader records the headers once they're written, so they include the ones BeforeWrite callbacks set, like
+// Set-Cookie.
+// Unwrap returns the wrapped ResponseWriter, for BeforeWrite and the cookie functions.
+func (w *cacheRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *cacheRecorder) WriteHeader(status int) {
+	w.ResponseWriter.WriteHeader(status)
+	if w.status == 0 {
+		w.status = status
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+}
+
+func (w *cacheRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	if err != nil {
+		w.failed = true
+	}
+	if !w.tooLarge {
+		if len(w.body)+n > MaxCachedResponseSize {
+			w.tooLarge, w.body = true, nil
+		} else {
+			w.body = append(w.body, data[:n]...)
+		}
+	}
+	return n, err
+}
+
+// store adds the recorded response for req to c if it's cacheable.
+func (w *cacheRecorder) store(c *responseCache, req *Request) {
+	if w.status != http.StatusOK || w.tooLarge || w.failed || w.header.Get("Set-Cookie") != "" {
+		return
+	}
+	cacheControl := strings.ToLower(w.header.Get("Cache-Control"))
+	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return
+	}
+	vary := make(http.Header)
+	for _, k := range splitHeaderList(strings.Join(w.header.Values("Vary"), ",")) {
+		if k == "*" {
+			return
+		}
+		vary[http.CanonicalHeaderKey(k)] = req.Header.Values(k)
+	}
+	if hasCredentials(req, vary) {
+		return
+	}
+	w.header.Del("Age")
+	c.add(&cachedResponse{key: responseCacheKey(req), status: w.status, header: w.header, body: w.body, stored: time.Now(), vary: vary})
+}
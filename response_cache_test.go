@@ -0,0 +1,195 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteCacheHeader(t *testing.T) {
+	router := New(Context{})
+	router.Get("/public", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("public"))
+	}).Cache(true, time.Minute, 30*time.Second)
+	router.Get("/private", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("private"))
+	}).Cache(false, 10*time.Second, 0)
+	router.Get("/override", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.Write([]byte("override"))
+	}).Cache(true, time.Minute, 0)
+	router.Get("/missing", func(rw ResponseWriter, req *Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}).Cache(true, time.Minute, 0)
+
+	for path, header := range map[string]string{
+		"/public":   "public, max-age=60, stale-while-revalidate=30",
+		"/private":  "private, max-age=10",
+		"/override": "no-store",
+		"/missing":  "",
+	} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, header, rw.Header().Get("Cache-Control"), path)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	calls := 0
+	router := New(Context{}).ResponseCache(10)
+	router.Get("/products/:id", func(rw ResponseWriter, req *Request) {
+		calls++
+		rw.Header().Set("Vary", "Accept-Language")
+		rw.Write([]byte(req.PathParams["id"] + " " + req.Header.Get("Accept-Language") + " " + strconv.Itoa(calls)))
+	}).Cache(true, time.Minute, time.Minute)
+	router.Get("/cart", func(rw ResponseWriter, req *Request) {
+		calls++
+		rw.Write([]byte(strconv.Itoa(calls)))
+	}).Cache(false, time.Minute, 0)
+
+	get := func(path, language string) *http.Response {
+		rw, req := newTestRequest("GET", path)
+		if language != "" {
+			req.Header.Set("Accept-Language", language)
+		}
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		return rw.Result()
+	}
+	body := func(resp *http.Response) string {
+		b := make([]byte, 100)
+		n, _ := resp.Body.Read(b)
+		return string(b[:n])
+	}
+
+	assert.Equal(t, "1 en 1", body(get("/products/1", "en")))
+	resp := get("/products/1", "en")
+	assert.Equal(t, "1 en 1", body(resp))
+	assert.Equal(t, "0", resp.Header.Get("Age"))
+	assert.Equal(t, "public, max-age=60, stale-while-revalidate=60", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "1 de 2", body(get("/products/1", "de")))
+	assert.Equal(t, "2 en 3", body(get("/products/2", "en")))
+	assert.Equal(t, 3, calls)
+
+	// Private responses are never cached by the router.
+	assert.Equal(t, "4", body(get("/cart", "")))
+	assert.Equal(t, "5", body(get("/cart", "")))
+
+	// Stale responses are served while they're refreshed in the background.
+	cache := router.responseCache
+	cache.Lock()
+	entry := cache.entries[responseCacheKey(&Request{Request: mustNewRequest("GET", "/products/2")})].Value.(*cachedResponse)
+	entry.stored = entry.stored.Add(-90 * time.Second)
+	cache.Unlock()
+	assert.Equal(t, "2 en 3", body(get("/products/2", "en")))
+	for i := 0; i < 100; i++ {
+		cache.Lock()
+		revalidating := entry.revalidating
+		cache.Unlock()
+		if !revalidating {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "2 en 6", body(get("/products/2", "en")))
+
+	// Expired responses are not served.
+	cache.Lock()
+	for _, e := range cache.entries {
+		e.Value.(*cachedResponse).stored = time.Now().Add(-3 * time.Minute)
+	}
+	cache.Unlock()
+	assert.Equal(t, "1 en 7", body(get("/products/1", "en")))
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	calls := 0
+	router := New(Context{}).ResponseCache(1)
+	router.Get("/:id", func(rw ResponseWriter, req *Request) {
+		calls++
+		rw.Write([]byte(strconv.Itoa(calls)))
+	}).Cache(true, time.Minute, 0)
+
+	for _, path := range []string{"/a", "/a", "/b", "/a"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestResponseCacheOnlyOnRoot(t *testing.T) {
+	router := New(Context{})
+	assert.Panics(t, func() {
+		router.Subrouter(Context{}, "/admin").ResponseCache(10)
+	})
+}
+
+func mustNewRequest(method, path string) *http.Request {
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+func TestResponseCacheAccessChecks(t *testing.T) {
+	router := New(Context{}).ResponseCache(10)
+	router.Authorizer(AuthorizerFunc(func(ctx interface{}, req *Request, required AccessRequirements) error {
+		if req.Header.Get("X-Scopes") != "admin" {
+			return ErrUnauthenticated
+		}
+		return nil
+	}))
+	internal := router.Subrouter(Context{}, "/internal")
+	internal.Middleware(BasicAuthMiddleware("internal", BasicAuthUsers(map[string]string{"ops": "s3cret"})))
+	internal.Get("/secret", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("secret"))
+	}).Cache(true, time.Minute, 0)
+	router.Get("/admin", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("admin"))
+	}).Cache(true, time.Minute, 0).RequireScopes("admin")
+
+	// Authenticated requests fill the cache.
+	rw, req := newTestRequest("GET", "/internal/secret")
+	req.SetBasicAuth("ops", "s3cret")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "secret", 200)
+	rw, req = newTestRequest("GET", "/admin")
+	req.Header.Set("X-Scopes", "admin")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "admin", 200)
+
+	// Anonymous requests must not get the cached responses.
+	rw, req = newTestRequest("GET", "/internal/secret")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unauthorized", 401)
+	rw, req = newTestRequest("GET", "/admin")
+	router.ServeHTTP(rw, req)
+	assert.NotEqual(t, 200, rw.Code)
+	assert.False(t, rw.Body.String() == "admin")
+}
+
+func TestResponseCacheCredentials(t *testing.T) {
+	calls := 0
+	router := New(Context{}).ResponseCache(10)
+	router.Get("/page", func(rw ResponseWriter, req *Request) {
+		calls++
+		rw.Write([]byte(strconv.Itoa(calls)))
+	}).Cache(true, time.Minute, 0)
+
+	get := func(cookie string) *httpResponse {
+		rw, req := newTestRequest("GET", "/page")
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		router.ServeHTTP(rw, req)
+		return &httpResponse{rw.Code, rw.Header(), rw.Body.String()}
+	}
+	assert.Equal(t, "1", get("session=alice").body) // not cached
+	assert.Equal(t, "2", get("").body)
+	assert.Equal(t, "2", get("").body)            // cached
+	assert.Equal(t, "3", get("session=bob").body) // not served from the cache
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 )
@@ -23,15 +24,81 @@ type ResponseWriter interface {
 	Size() int
 }
 
+// BeforeWriter is implemented by the ResponseWriter the router passes to middleware and handlers. Call the
+// BeforeWrite function rather than asserting it, as that also finds it behind wrappers.
+type BeforeWriter interface {
+	// BeforeWrite registers fn to be called with the response headers right before they are written, whether
+	// that's triggered by WriteHeader, Write, or Flush. Callbacks run in the order they were registered.
+	// Registering a callback after the headers were written has no effect.
+	BeforeWrite(fn func(http.Header))
+}
+
+// BeforeWrite registers fn with the BeforeWriter rw wraps (see BeforeWriter). Wrappers are unwrapped with their
+// Unwrap() http.ResponseWriter method, like http.ResponseController does. If there's no BeforeWriter, fn is
+// called right away, unless rw is a ResponseWriter that has already written its headers.
+func BeforeWrite(rw http.ResponseWriter, fn func(http.Header)) {
+	if bw, ok := findWriter[BeforeWriter](rw); ok {
+		bw.BeforeWrite(fn)
+	} else if w, ok := rw.(ResponseWriter); !ok || !w.Written() {
+		fn(rw.Header())
+	}
+}
+
+// findWriter returns the first writer in rw's chain of Unwrap methods that implements T.
+func findWriter[T any](rw http.ResponseWriter) (T, bool) {
+	for {
+		if t, ok := rw.(T); ok {
+			return t, true
+		}
+		unwrapper, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		rw = unwrapper.Unwrap()
+	}
+}
+
 type appResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	size       int
+	req        *Request // the request being responded to, for its settings and extras
+}
+
+// settings returns the settings of the request's route or router.
+func (w *appResponseWriter) settings() *routeSettings {
+	if w.req == nil || w.req.settings == nil {
+		return &noSettings
+	}
+	return w.req.settings
+}
+
+// extras returns the request's extras, allocating them if needed.
+func (w *appResponseWriter) extras() *requestExtras {
+	if w.req == nil {
+		w.req = &Request{}
+	}
+	return w.req.extras()
+}
+
+// strict returns the response's strict mode state, or nil unless the router is in debug mode.
+func (w *appResponseWriter) strict() *strictState {
+	if w.req == nil || w.req.more == nil {
+		return nil
+	}
+	return w.req.more.strict
 }
 
 // Don't need this yet because we get it for free:
 func (w *appResponseWriter) Write(data []byte) (n int, err error) {
+	if debugBuild && w.strict() != nil {
+		if err := w.checkWrite(); err != nil {
+			return 0, err
+		}
+	}
 	if w.statusCode == 0 {
+		w.runBeforeWrite(http.StatusOK)
 		w.statusCode = http.StatusOK
 	}
 	size, err := w.ResponseWriter.Write(data)
@@ -39,11 +106,63 @@ func (w *appResponseWriter) Write(data []byte) (n int, err error) {
 	return size, err
 }
 
+// ReadFrom implements io.ReaderFrom so that io.Copy (and so http.ServeContent) hands the body straight to the
+// underlying ResponseWriter. net/http's own ResponseWriter can then use sendfile or splice for files and sockets.
+func (w *appResponseWriter) ReadFrom(src io.Reader) (n int64, err error) {
+	if debugBuild && w.strict() != nil {
+		if err := w.checkWrite(); err != nil {
+			return 0, err
+		}
+	}
+	if w.statusCode == 0 {
+		w.runBeforeWrite(http.StatusOK)
+		w.statusCode = http.StatusOK
+	}
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, src)
+	}
+	w.size += int(n)
+	return n, err
+}
+
+// writerOnly hides every method but Write, so io.Copy doesn't loop back into ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
+
 func (w *appResponseWriter) WriteHeader(statusCode int) {
+	if debugBuild && w.strict() != nil && !w.checkWriteHeader(statusCode) {
+		return
+	}
+	if w.statusCode == 0 {
+		w.runBeforeWrite(statusCode)
+	}
 	w.statusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (w *appResponseWriter) BeforeWrite(fn func(http.Header)) {
+	if w.statusCode == 0 {
+		more := w.extras()
+		more.beforeWrite = append(more.beforeWrite, fn)
+	}
+}
+
+func (w *appResponseWriter) runBeforeWrite(status int) {
+	if w.req == nil || w.req.more == nil || len(w.req.more.beforeWrite) == 0 {
+		return
+	}
+	more := w.req.more
+	more.writingStatus = status
+	callbacks := more.beforeWrite
+	more.beforeWrite = nil
+	for _, fn := range callbacks {
+		fn(w.Header())
+	}
+}
+
 func (w *appResponseWriter) StatusCode() int {
 	return w.statusCode
 }
@@ -61,6 +180,9 @@ func (w *appResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if !ok {
 		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
 	}
+	if debugBuild && w.strict() != nil {
+		w.strict().hijacked = true
+	}
 	return hijacker.Hijack()
 }
 
@@ -71,6 +193,10 @@ func (w *appResponseWriter) CloseNotify() <-chan bool {
 func (w *appResponseWriter) Flush() {
 	flusher, ok := w.ResponseWriter.(http.Flusher)
 	if ok {
+		if w.statusCode == 0 {
+			w.runBeforeWrite(http.StatusOK)
+			w.statusCode = http.StatusOK
+		}
 		flusher.Flush()
 	}
 }
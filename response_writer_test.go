@@ -3,9 +3,11 @@ package web
 import (
 	"bufio"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -111,3 +113,109 @@ func TestResponseWriterCloseNotify(t *testing.T) {
 	}
 	assert.True(t, closed)
 }
+
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFromCalled bool
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFromCalled = true
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rw := ResponseWriter(&appResponseWriter{ResponseWriter: rec})
+
+	// Hide strings.Reader's WriteTo, like os.File does when it can't sendfile by itself.
+	n, err := io.Copy(rw, struct{ io.Reader }{strings.NewReader("Hello world")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	assert.True(t, rec.readFromCalled)
+	assert.Equal(t, "Hello world", rec.Body.String())
+	assert.Equal(t, http.StatusOK, rw.StatusCode())
+	assert.Equal(t, 11, rw.Size())
+
+	// Without a ReaderFrom underneath, we fall back to plain writes.
+	plain := httptest.NewRecorder()
+	rw = ResponseWriter(&appResponseWriter{ResponseWriter: plain})
+	n, err = io.Copy(rw, strings.NewReader("Hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	assert.Equal(t, "Hello world", plain.Body.String())
+	assert.Equal(t, 11, rw.Size())
+}
+
+func TestResponseWriterBeforeWrite(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(func(w ResponseWriter, r *Request, next NextMiddlewareFunc) {
+		BeforeWrite(w, func(h http.Header) {
+			h.Set("X-Frame-Options", "DENY")
+		})
+		next(w, r)
+		// Too late: the handler already wrote.
+		BeforeWrite(w, func(h http.Header) {
+			h.Set("X-Late", "1")
+		})
+	})
+	router.Middleware(func(w ResponseWriter, r *Request, next NextMiddlewareFunc) {
+		BeforeWrite(w, func(h http.Header) {
+			h.Set("X-Order", h.Get("X-Frame-Options")+",second")
+		})
+		next(w, r)
+	})
+	router.Get("/write", (*Context).A)
+	router.Get("/header", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	router.Get("/flush", func(w ResponseWriter, r *Request) {
+		w.Flush()
+	})
+
+	for _, path := range []string{"/write", "/header", "/flush"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		result := rw.Result()
+		assert.Equal(t, "DENY", result.Header.Get("X-Frame-Options"), path)
+		assert.Equal(t, "DENY,second", result.Header.Get("X-Order"), path)
+		assert.Equal(t, "", result.Header.Get("X-Late"), path)
+	}
+}
+
+// A wrapper from outside the package, which only knows about the ResponseWriter interface.
+type wrappedWriter struct {
+	ResponseWriter
+}
+
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestResponseWriterHelpersUnwrap(t *testing.T) {
+	router := New(Context{}).CookieDefaults(CookieDefaults{Domain: "example.com"})
+	router.Middleware(func(w ResponseWriter, r *Request, next NextMiddlewareFunc) {
+		next(&wrappedWriter{w}, r)
+	})
+	router.Get("/action", func(w ResponseWriter, r *Request) {
+		BeforeWrite(w, func(h http.Header) {
+			h.Set("X-Status", "set before write")
+		})
+		assert.NoError(t, SetCookie(w, Cookie{Name: "a", Value: "1"}))
+		w.Write([]byte("ok"))
+		assert.Equal(t, ErrHeadersWritten, SetCookie(w, Cookie{Name: "late", Value: "1"}))
+	})
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "set before write", rw.Header().Get("X-Status"))
+	assert.Equal(t, []string{"a=1; Path=/; Domain=example.com; HttpOnly; Secure; SameSite=Lax"}, rw.Header()["Set-Cookie"])
+
+	// Plain net/http writers get the callback right away, and the default cookie attributes.
+	plain := httptest.NewRecorder()
+	BeforeWrite(plain, func(h http.Header) { h.Set("X-Now", "1") })
+	assert.Equal(t, "1", plain.Header().Get("X-Now"))
+	assert.NoError(t, DeleteCookie(plain, "a"))
+	assert.Equal(t, []string{"a=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Max-Age=0; HttpOnly; Secure; SameSite=Lax"}, plain.Header()["Set-Cookie"])
+	assert.Error(t, SetSecureCookie(plain, Cookie{Name: "b", Value: "2"}))
+}
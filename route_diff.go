@@ -0,0 +1,173 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteDiff lists the differences between two route tables. Routes are identified by method, path and host; a route
+// whose name, metadata, handler, middleware, access requirements or deprecation differ between the two tables is
+// reported as changed.
+type RouteDiff struct {
+	Added   []RouteSnapshot
+	Removed []RouteSnapshot
+	Changed []RouteChange
+}
+
+// RouteChange is a route that exists in both tables but differs between them.
+type RouteChange struct {
+	Old RouteSnapshot
+	New RouteSnapshot
+}
+
+// DiffRoutes compares two route tables, eg the snapshots of the previous and the current release. Each list in the
+// result is sorted by path, then method.
+func DiffRoutes(old, new RouterSnapshot) RouteDiff {
+	oldRoutes := indexSnapshot(old)
+	newRoutes := indexSnapshot(new)
+
+	var diff RouteDiff
+	for key, route := range newRoutes {
+		oldRoute, ok := oldRoutes[key]
+		if !ok {
+			diff.Added = append(diff.Added, route)
+		} else if !sameRoute(oldRoute, route) {
+			diff.Changed = append(diff.Changed, RouteChange{Old: oldRoute, New: route})
+		}
+	}
+	for key, route := range oldRoutes {
+		if _, ok := newRoutes[key]; !ok {
+			diff.Removed = append(diff.Removed, route)
+		}
+	}
+
+	sortSnapshots(diff.Added)
+	sortSnapshots(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return snapshotLess(diff.Changed[i].New, diff.Changed[j].New)
+	})
+	return diff
+}
+
+// Empty returns true if the two route tables were the same.
+func (d RouteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String renders the diff one route per line, prefixed with +, - or ~, eg for CI output.
+func (d RouteDiff) String() string {
+	var b strings.Builder
+	for _, route := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", describeSnapshot(route))
+	}
+	for _, route := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", describeSnapshot(route))
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&b, "~ %s (was %s)\n", describeSnapshot(change.New), describeSnapshot(change.Old))
+	}
+	return b.String()
+}
+
+func indexSnapshot(snapshot RouterSnapshot) map[string]RouteSnapshot {
+	routes := make(map[string]RouteSnapshot, len(snapshot.Routes))
+	for _, route := range snapshot.Routes {
+		routes[route.Method+" "+route.Path+" "+route.Host] = route
+	}
+	return routes
+}
+
+func sameRoute(a, b RouteSnapshot) bool {
+	if a.Name != b.Name || a.Handler != b.Handler || !sameStrings(a.Middleware, b.Middleware) ||
+		len(a.Metadata) != len(b.Metadata) {
+		return false
+	}
+	for k, v := range a.Metadata {
+		if bv, ok := b.Metadata[k]; !ok || bv != v {
+			return false
+		}
+	}
+	if (a.Access == nil) != (b.Access == nil) || (a.Access != nil &&
+		(!sameStrings(a.Access.Scopes, b.Access.Scopes) || !sameStrings(a.Access.Roles, b.Access.Roles))) {
+		return false
+	}
+	if (a.Deprecated == nil) != (b.Deprecated == nil) || (a.Deprecated != nil &&
+		(!a.Deprecated.Sunset.Equal(b.Deprecated.Sunset) || a.Deprecated.Successor != b.Deprecated.Successor)) {
+		return false
+	}
+	return true
+}
+
+// sameStrings returns true if a and b have the same elements in the same order. nil and empty are the same, as
+// snapshots read back from JSON leave empty lists out.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortSnapshots(routes []RouteSnapshot) {
+	sort.Slice(routes, func(i, j int) bool {
+		return snapshotLess(routes[i], routes[j])
+	})
+}
+
+func snapshotLess(a, b RouteSnapshot) bool {
+	if a.Path != b.Path {
+		return a.Path < b.Path
+	}
+	if a.Method != b.Method {
+		return a.Method < b.Method
+	}
+	return a.Host < b.Host
+}
+
+func describeSnapshot(route RouteSnapshot) string {
+	s := route.Method + " " + route.Path
+	if route.Host != "" {
+		s += " host=" + route.Host
+	}
+	if route.Name != "" {
+		s += " name=" + route.Name
+	}
+	keys := make([]string, 0, len(route.Metadata))
+	for k := range route.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += " " + k + "=" + route.Metadata[k]
+	}
+	if route.Handler != "" {
+		s += " handler=" + route.Handler
+	}
+	if len(route.Middleware) > 0 {
+		s += " middleware=" + strings.Join(route.Middleware, ",")
+	}
+	if route.Access != nil {
+		if len(route.Access.Scopes) > 0 {
+			s += " scopes=" + strings.Join(route.Access.Scopes, ",")
+		}
+		if len(route.Access.Roles) > 0 {
+			s += " roles=" + strings.Join(route.Access.Roles, ",")
+		}
+	}
+	if d := route.Deprecated; d != nil {
+		s += " deprecated"
+		if !d.Sunset.IsZero() {
+			s += " sunset=" + d.Sunset.UTC().Format(time.RFC3339)
+		}
+		if d.Successor != "" {
+			s += " successor=" + d.Successor
+		}
+	}
+	return s
+}
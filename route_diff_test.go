@@ -0,0 +1,78 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	old := RouterSnapshot{Routes: []RouteSnapshot{
+		{Method: "GET", Path: "/posts", Name: "posts"},
+		{Method: "GET", Path: "/posts/:id", Metadata: map[string]string{"owner": "blog"}},
+		{Method: "DELETE", Path: "/posts/:id"},
+		{Method: "GET", Path: "/legacy"},
+	}}
+	new := RouterSnapshot{Routes: []RouteSnapshot{
+		{Method: "GET", Path: "/posts", Name: "posts"},
+		{Method: "GET", Path: "/posts/:id", Metadata: map[string]string{"owner": "content"}},
+		{Method: "DELETE", Path: "/posts/:id", Name: "deletePost"},
+		{Method: "POST", Path: "/posts"},
+		{Method: "GET", Path: "/comments"},
+	}}
+
+	diff := DiffRoutes(old, new)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []RouteSnapshot{{Method: "GET", Path: "/comments"}, {Method: "POST", Path: "/posts"}}, diff.Added)
+	assert.Equal(t, []RouteSnapshot{{Method: "GET", Path: "/legacy"}}, diff.Removed)
+	if assert.Equal(t, 2, len(diff.Changed)) {
+		assert.Equal(t, "DELETE", diff.Changed[0].New.Method)
+		assert.Equal(t, "deletePost", diff.Changed[0].New.Name)
+		assert.Equal(t, "blog", diff.Changed[1].Old.Metadata["owner"])
+	}
+
+	assert.Equal(t, "+ GET /comments\n"+
+		"+ POST /posts\n"+
+		"- GET /legacy\n"+
+		"~ DELETE /posts/:id name=deletePost (was DELETE /posts/:id)\n"+
+		"~ GET /posts/:id owner=content (was GET /posts/:id owner=blog)\n", diff.String())
+
+	assert.True(t, DiffRoutes(new, new).Empty())
+	assert.Equal(t, "", DiffRoutes(new, new).String())
+}
+
+func TestDiffRoutesDetails(t *testing.T) {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := RouteSnapshot{
+		Method:     "GET",
+		Path:       "/orders",
+		Handler:    "main.(*Context).Orders",
+		Middleware: []string{"auth"},
+		Access:     &AccessRequirements{Scopes: []string{"orders:read"}},
+		Deprecated: &RouteDeprecation{Sunset: sunset, Successor: "orders_v2"},
+	}
+	changes := map[string]func(r *RouteSnapshot){
+		"handler":    func(r *RouteSnapshot) { r.Handler = "main.(*Context).OrdersV2" },
+		"middleware": func(r *RouteSnapshot) { r.Middleware = []string{"auth", "audit"} },
+		"access":     func(r *RouteSnapshot) { r.Access = &AccessRequirements{Scopes: []string{"orders:write"}} },
+		"no access":  func(r *RouteSnapshot) { r.Access = nil },
+		"sunset":     func(r *RouteSnapshot) { r.Deprecated = &RouteDeprecation{Sunset: sunset.AddDate(0, 1, 0), Successor: "orders_v2"} },
+		"successor":  func(r *RouteSnapshot) { r.Deprecated = &RouteDeprecation{Sunset: sunset, Successor: "orders_v3"} },
+	}
+	for name, change := range changes {
+		changed := base
+		change(&changed)
+		diff := DiffRoutes(RouterSnapshot{Routes: []RouteSnapshot{base}}, RouterSnapshot{Routes: []RouteSnapshot{changed}})
+		assert.Equal(t, 1, len(diff.Changed), name)
+	}
+
+	// Empty and missing lists are the same, as are equal times in other locations.
+	same := base
+	same.Access = &AccessRequirements{Scopes: []string{"orders:read"}, Roles: []string{}}
+	same.Deprecated = &RouteDeprecation{Sunset: sunset.In(time.FixedZone("CET", 3600)), Successor: "orders_v2"}
+	assert.True(t, DiffRoutes(RouterSnapshot{Routes: []RouteSnapshot{base}}, RouterSnapshot{Routes: []RouteSnapshot{same}}).Empty())
+
+	assert.Equal(t, "GET /orders handler=main.(*Context).Orders middleware=auth scopes=orders:read deprecated sunset=2027-01-01T00:00:00Z successor=orders_v2",
+		describeSnapshot(base))
+}
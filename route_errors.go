@@ -0,0 +1,47 @@
+package web
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// RouteError describes an invalid route registration, such as a malformed path, a duplicate route name, or a
+// route that can never be reached. Registration methods panic with a *RouteError.
+type RouteError struct {
+	Method string
+	Path   string
+	Source string // file:line of the call that registered the route
+	Reason string
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("web: invalid route %s %s registered at %s: %s", e.Method, e.Path, e.Source, e.Reason)
+}
+
+func newRouteError(method httpMethod, path string, reason string) *RouteError {
+	return &RouteError{Method: string(method), Path: path, Source: registrationCaller(), Reason: reason}
+}
+
+// validatePath returns a reason if path can't be routed, or an empty string.
+func validatePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "the path must start with '/'"
+	}
+	return ""
+}
+
+// registrationCaller returns the file:line of the first caller outside of the framework.
+func registrationCaller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func routeErrorFrom(fn func()) (routeErr *RouteError) {
+	defer func() {
+		routeErr, _ = recover().(*RouteError)
+	}()
+	fn()
+	return nil
+}
+
+func TestRouteErrors(t *testing.T) {
+	handler := func(w ResponseWriter, r *Request) {}
+
+	cases := []struct {
+		name   string
+		path   string
+		reason string
+	}{
+		{"no leading slash", "action", "must start with '/'"},
+		{"unnamed wildcard", "/posts/:", "has no name"},
+		{"duplicate wildcard", "/posts/:id/comments/:id", ":id is used more than once"},
+		{"bad regexp", "/posts/:id:[0-9", ":id has an invalid regexp"},
+		{"unnamed catch-all", "/files/*", "has no name"},
+		{"catch-all in the middle", "/files/*path/raw", "*path must be the last segment"},
+		{"catch-all reusing a wildcard", "/files/:path/*path", ":path is used more than once"},
+		{"optional group in the middle", "/articles(/:year)/feed", "must be at the end"},
+		{"unclosed optional group", "/articles(/:year", "missing its ')'"},
+		{"optional group reusing a wildcard", "/articles/:id(/:id)", ":id is used more than once"},
+	}
+	for _, c := range cases {
+		router := New(Context{})
+		err := routeErrorFrom(func() { router.Get(c.path, handler) })
+		if assert.NotNil(t, err, c.name) {
+			assert.Equal(t, "GET", err.Method, c.name)
+			assert.Contains(t, err.Reason, c.reason, c.name)
+			assert.True(t, strings.HasPrefix(filepath.Base(err.Source), "route_errors_test.go:"), c.name+": "+err.Source)
+		}
+		assert.Equal(t, 0, len(router.routes), c.name)
+	}
+}
+
+func TestRouteErrorUnreachable(t *testing.T) {
+	handler := func(w ResponseWriter, r *Request) {}
+	router := New(Context{})
+	router.Get("/posts/:id:\\d+", handler)
+	router.Get("/posts/:id", handler)
+	router.Post("/posts/:id", handler)
+
+	err := routeErrorFrom(func() { router.Get("/posts/:slug", handler) })
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "/posts/:slug", err.Path)
+		assert.Contains(t, err.Reason, "GET /posts/:id (registered earlier)")
+	}
+
+	err = routeErrorFrom(func() { router.Get("/posts/:id:[a-z]+", handler) })
+	assert.Contains(t, err.Error(), "unreachable")
+
+	admin := router.Subrouter(Context{}, "/admin")
+	admin.Get("/", handler)
+	err = routeErrorFrom(func() { admin.Get("/", handler) })
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "/admin/", err.Path)
+	}
+}
+
+func TestRouteErrorDuplicateName(t *testing.T) {
+	handler := func(w ResponseWriter, r *Request) {}
+	router := New(Context{})
+	router.Get("/posts", handler).Named("posts")
+
+	admin := router.Subrouter(Context{}, "/admin")
+	route := admin.Get("/posts", handler)
+
+	err, ok := route.TryNamed("posts").(*RouteError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "/admin/posts", err.Path)
+		assert.Contains(t, err.Reason, `"posts" is already used by GET /posts`)
+		assert.True(t, strings.HasPrefix(err.Error(), "web: invalid route GET /admin/posts registered at "))
+	}
+	assert.Equal(t, "", route.Name)
+
+	// Named doesn't check: the route gets the name, but lookups keep finding the first route.
+	assert.NotPanics(t, func() { route.Named("posts") })
+	assert.Equal(t, "posts", route.Name)
+	assert.True(t, findNamedRoute(router, "posts") == router.routes[0])
+
+	assert.NotPanics(t, func() { router.routes[0].Named("posts") })
+}
+
+func TestTryRegistration(t *testing.T) {
+	handler := func(w ResponseWriter, r *Request) {}
+	router := New(Context{})
+
+	route, err := router.TryGet("/posts/:id", handler)
+	assert.NoError(t, err)
+	assert.NoError(t, route.TryNamed("post"))
+
+	_, err = router.TryGet("/posts/:slug", handler)
+	if assert.Error(t, err) {
+		routeErr := err.(*RouteError)
+		assert.Contains(t, routeErr.Reason, "unreachable")
+		assert.True(t, strings.HasPrefix(filepath.Base(routeErr.Source), "route_errors_test.go:"), routeErr.Source)
+	}
+
+	_, err = router.TryPost("/posts", 1)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.(*RouteError).Reason, "int is not a valid handler")
+	}
+
+	_, err = router.TryPut("/posts/:id:[", handler)
+	assert.Error(t, err)
+
+	route, err = router.TryDelete("/posts/:id", handler)
+	assert.NoError(t, err)
+	err = route.TryNamed("post")
+	assert.Error(t, err)
+	assert.Equal(t, "", route.Name)
+
+	_, err = router.TryPatch("/posts/:id", (*Context).A)
+	assert.NoError(t, err)
+	_, err = router.TryHead("/posts/:id", handler)
+	assert.NoError(t, err)
+	_, err = router.TryOptions("/posts/:id", handler)
+	assert.NoError(t, err)
+	_, err = router.TryHandle("PURGE", "/posts/:id", handler)
+	assert.NoError(t, err)
+	_, err = router.TryHandle("NOT A METHOD", "/posts/:id", handler)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.(*RouteError).Reason, `"NOT A METHOD" is not a valid HTTP method`)
+	}
+
+	rw, req := newTestRequest("PATCH", "/posts/4")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+}
+
+func TestRouteRegexpBounds(t *testing.T) {
+	handler := func(w ResponseWriter, r *Request) {}
+	router := New(Context{})
+
+	err := routeErrorFrom(func() { router.Get("/a/:id:x{1,1000}", handler) })
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Reason, "too complex")
+	}
+	err = routeErrorFrom(func() { router.Get("/a/:id:(a)\\1", handler) })
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Reason, "invalid regexp")
+	}
+
+	router.Get("/posts/:action:new|edit", (*Context).A)
+	router.Get("/long/:id:\\d+", (*Context).A)
+
+	for path, status := range map[string]int{
+		"/posts/new":                         200,
+		"/posts/edit":                        200,
+		"/posts/newer":                       404,
+		"/posts/reedit":                      404,
+		"/long/" + strings.Repeat("1", 1024): 200,
+		"/long/" + strings.Repeat("1", 1025): 404,
+	} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, status, rw.Code, path)
+	}
+}
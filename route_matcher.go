@@ -0,0 +1,88 @@
+package web
+
+import (
+	"fmt"
+)
+
+// RouteMatcher matches paths against a route table without a Router or a server. It is meant for client-side
+// code compiled with GOOS=js GOARCH=wasm: load the server's RouterSnapshot (eg served as JSON), and check
+// navigation targets or build URLs with the same matching rules the server uses.
+type RouteMatcher struct {
+	root      map[httpMethod]*pathNode
+	snapshots map[*Route]RouteSnapshot
+	named     map[string]*Route
+}
+
+// NewRouteMatcher builds a RouteMatcher for the routes in snapshot. It returns an error if a route is invalid.
+func NewRouteMatcher(snapshot RouterSnapshot) (*RouteMatcher, error) {
+	m := &RouteMatcher{
+		root:      make(map[httpMethod]*pathNode),
+		snapshots: make(map[*Route]RouteSnapshot),
+		named:     make(map[string]*Route),
+	}
+	for _, s := range snapshot.Routes {
+		route := &Route{method: httpMethod(s.Method), path: s.Path, Name: s.Name}
+		if s.Host != "" {
+			host, err := parseHostPattern(s.Host)
+			if err != nil {
+				return nil, err
+			}
+			route.host = host
+		}
+		tree, ok := m.root[route.method]
+		if !ok {
+			tree = newPathNode()
+			m.root[route.method] = tree
+		}
+		if err := tree.add(s.Path, route, false); err != nil {
+			return nil, fmt.Errorf("web: invalid route %s %s: %v", s.Method, s.Path, err)
+		}
+		m.snapshots[route] = s
+		if s.Name != "" {
+			m.named[s.Name] = route
+		}
+	}
+	return m, nil
+}
+
+// Match returns the route a request for method and path would be routed to, and its path params. Like the
+// router, HEAD requests fall back to GET routes. Routes constrained to a host never match; see MatchHost.
+func (m *RouteMatcher) Match(method, path string) (RouteSnapshot, map[string]string, bool) {
+	return m.MatchHost(method, "", path)
+}
+
+// MatchHost is like Match, for a request to host.
+func (m *RouteMatcher) MatchHost(method, host, path string) (RouteSnapshot, map[string]string, bool) {
+	if len(path) == 0 || path[0] != '/' {
+		return RouteSnapshot{}, nil, false
+	}
+	segments := splitPath(path)
+	host = requestHost(host)
+
+	var leaf *pathLeaf
+	var wildcardMap map[string]string
+	if tree, ok := m.root[httpMethod(method)]; ok {
+		leaf, wildcardMap = tree.match(segments, nil, host)
+	}
+	if leaf == nil && httpMethod(method) == httpMethodHead {
+		if tree, ok := m.root[httpMethodGet]; ok {
+			leaf, wildcardMap = tree.match(segments, nil, host)
+		}
+	}
+	if leaf == nil {
+		return RouteSnapshot{}, nil, false
+	}
+	return m.snapshots[leaf.route], wildcardMap, true
+}
+
+// MappedUrlFor builds the path of the route named routeName like Request.MappedUrlFor.
+func (m *RouteMatcher) MappedUrlFor(routeName string, namedParams map[string]string, pathParams ...string) (string, error) {
+	route, ok := m.named[routeName]
+	if !ok {
+		return "", fmt.Errorf("Route with name %s was not found.", routeName)
+	}
+	if namedParams == nil {
+		namedParams = make(map[string]string)
+	}
+	return fillPathParams(route.path, namedParams, pathParams...)
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatcher(t *testing.T) {
+	router := New(Context{})
+	router.Get("/posts/:id:\\d+", (*Context).A).Named("post")
+	router.Get("/posts/:slug", (*Context).A).Named("post_by_slug")
+	router.Post("/posts", (*Context).A)
+
+	m, err := NewRouteMatcher(router.Snapshot())
+	assert.NoError(t, err)
+
+	route, params, ok := m.Match("GET", "/posts/12")
+	assert.True(t, ok)
+	assert.Equal(t, "post", route.Name)
+	assert.Equal(t, map[string]string{"id": "12"}, params)
+
+	route, params, ok = m.Match("HEAD", "/posts/hello")
+	assert.True(t, ok)
+	assert.Equal(t, "post_by_slug", route.Name)
+	assert.Equal(t, "hello", params["slug"])
+
+	_, _, ok = m.Match("DELETE", "/posts/12")
+	assert.False(t, ok)
+	_, _, ok = m.Match("GET", "/comments")
+	assert.False(t, ok)
+
+	url, err := m.MappedUrlFor("post", nil, "7")
+	assert.NoError(t, err)
+	assert.Equal(t, "/posts/7", url)
+	_, err = m.MappedUrlFor("post", nil, "x")
+	assert.Error(t, err)
+	_, err = m.MappedUrlFor("missing", nil)
+	assert.Error(t, err)
+
+	_, err = NewRouteMatcher(RouterSnapshot{Routes: []RouteSnapshot{{Method: "GET", Path: "/a/:id:["}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// RouteProvider contributes routes, middleware and health checks to a host router, so that an application can be
+// assembled from modules that each own their part of the URL space:
+//
+//	type billing struct{}
+//
+//	func (billing) Name() string { return "billing" }
+//
+//	func (billing) Provide(router *web.Router) error {
+//		router.Middleware(requireAccount)
+//		router.Get("/invoices", listInvoices)
+//		router.HealthCheck("billing/ledger", pingLedger)
+//		return nil
+//	}
+type RouteProvider interface {
+	// Name identifies the provider, eg in errors.
+	Name() string
+
+	// Provide adds the provider's routes to router. router is a subrouter of the host with no path prefix of its
+	// own, so middleware the provider adds only applies to its routes.
+	Provide(router *Router) error
+}
+
+var routeProviders = struct {
+	sync.Mutex
+	providers map[string]RouteProvider
+}{providers: make(map[string]RouteProvider)}
+
+// RegisterRouteProvider registers p, eg from the init function of its package, for Router.ProvideRegistered.
+// Packages built as Go plugins can register their providers this way too, so that plugin.Open is enough to load
+// them. Registering the same name twice panics.
+func RegisterRouteProvider(p RouteProvider) {
+	routeProviders.Lock()
+	defer routeProviders.Unlock()
+
+	if _, ok := routeProviders.providers[p.Name()]; ok {
+		panic("web: route provider " + p.Name() + " is already registered")
+	}
+	routeProviders.providers[p.Name()] = p
+}
+
+// RegisteredRouteProviders returns the registered providers, sorted by name.
+func RegisteredRouteProviders() []RouteProvider {
+	routeProviders.Lock()
+	defer routeProviders.Unlock()
+
+	providers := make([]RouteProvider, 0, len(routeProviders.providers))
+	for _, p := range routeProviders.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name() < providers[j].Name() })
+	return providers
+}
+
+// Provide lets each of providers add its routes to a subrouter of r, in order, and returns r. It panics if a
+// provider returns an error. Providers' handlers and middleware can take r's context type, or none.
+func (r *Router) Provide(providers ...RouteProvider) *Router {
+	for _, p := range providers {
+		sub := r.Subrouter(reflect.New(r.contextType).Elem().Interface(), "")
+		if err := p.Provide(sub); err != nil {
+			panic(fmt.Sprintf("web: route provider %s: %v", p.Name(), err))
+		}
+	}
+	return r
+}
+
+// ProvideRegistered calls Provide with RegisteredRouteProviders, and returns r.
+func (r *Router) ProvideRegistered() *Router {
+	return r.Provide(RegisteredRouteProviders()...)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testProvider struct {
+	name string
+	err  error
+}
+
+func (p testProvider) Name() string {
+	return p.name
+}
+
+func (p testProvider) Provide(router *Router) error {
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		rw.Header().Set("X-Provider", p.name)
+		next(rw, req)
+	})
+	router.Get("/"+p.name, func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(p.name))
+	})
+	router.Get("/"+p.name+"/ctx", (*Context).A)
+	return p.err
+}
+
+func TestProvide(t *testing.T) {
+	router := New(Context{})
+	router.Get("/", (*Context).A)
+	router.Provide(testProvider{name: "billing"}, testProvider{name: "search"})
+
+	rw, req := newTestRequest("GET", "/billing")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "billing", http.StatusOK)
+	assert.Equal(t, "billing", rw.Header().Get("X-Provider"))
+
+	rw, req = newTestRequest("GET", "/search/ctx")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+	assert.Equal(t, "search", rw.Header().Get("X-Provider"))
+
+	// Providers' middleware doesn't leak into the host's routes.
+	rw, req = newTestRequest("GET", "/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+	assert.Equal(t, "", rw.Header().Get("X-Provider"))
+
+	defer func() {
+		assert.Equal(t, "web: route provider broken: no config", recover())
+	}()
+	router.Provide(testProvider{name: "broken", err: errors.New("no config")})
+}
+
+func TestRegisterRouteProvider(t *testing.T) {
+	defer func() {
+		routeProviders.Lock()
+		delete(routeProviders.providers, "zeta")
+		delete(routeProviders.providers, "alpha")
+		routeProviders.Unlock()
+	}()
+	RegisterRouteProvider(testProvider{name: "zeta"})
+	RegisterRouteProvider(testProvider{name: "alpha"})
+	assert.Panics(t, func() {
+		RegisterRouteProvider(testProvider{name: "alpha"})
+	})
+
+	providers := RegisteredRouteProviders()
+	assert.Equal(t, 2, len(providers))
+	assert.Equal(t, "alpha", providers[0].Name())
+
+	router := New(Context{}).ProvideRegistered()
+	rw, req := newTestRequest("GET", "/zeta")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "zeta", http.StatusOK)
+}
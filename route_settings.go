@@ -0,0 +1,61 @@
+package web
+
+import "time"
+
+// routeSettings are the settings a route's requests take from the nearest router that has them. They're resolved
+// when the route is first served rather than on every request, and again after any of them changes.
+type routeSettings struct {
+	version        int64 // the routeTables' settingsVersion they were resolved at
+	cookieDefaults *CookieDefaults
+	secureCookies  *SecureCookies
+	timeout        time.Duration
+	maxBodySize    int64
+	headerPolicy   *HeaderPolicy
+	cors           *CORSOptions
+	policies       bool // whether any of the routers has a Policy
+	errorRenderers map[string]ErrorRenderer
+}
+
+// noSettings are the settings of a request that isn't served by a router.
+var noSettings routeSettings
+
+// settings returns the settings of requests served by r before they're routed, which only have its cookie
+// settings, header policy and error renderers.
+func (r *Router) settings() *routeSettings {
+	version := r.tables.settingsVersion.Load()
+	if s := r.resolved.Load(); s != nil && s.version == version {
+		return s
+	}
+	s := &routeSettings{
+		version:        version,
+		cookieDefaults: cookieDefaultsFor(r.chain),
+		secureCookies:  secureCookiesFor(r.chain),
+		headerPolicy:   headerPolicyFor(nil, r),
+		errorRenderers: errorRenderersFor(r.chain[0]),
+	}
+	r.resolved.Store(s)
+	return s
+}
+
+// settings returns the route's resolved settings.
+func (r *Route) settings() *routeSettings {
+	version := r.router.tables.settingsVersion.Load()
+	if s := r.resolved.Load(); s != nil && s.version == version {
+		return s
+	}
+	s := &routeSettings{
+		version:        version,
+		cookieDefaults: cookieDefaultsFor(r.router.chain),
+		secureCookies:  secureCookiesFor(r.router.chain),
+		timeout:        timeoutFor(r),
+		maxBodySize:    maxBodySizeFor(r),
+		headerPolicy:   headerPolicyFor(r, r.router.chain[0]),
+		cors:           corsFor(r.router.chain),
+		errorRenderers: errorRenderersFor(r.router.chain[0]),
+	}
+	for _, router := range r.router.chain {
+		s.policies = s.policies || router.policy != nil
+	}
+	r.resolved.Store(s)
+	return s
+}
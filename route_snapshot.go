@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// RouterSnapshot is a serializable description of a router's route table.
+type RouterSnapshot struct {
+	Routes []RouteSnapshot `json:"routes"`
+}
+
+// RouteSnapshot describes a single route in a RouterSnapshot. Middleware lists the names of the middleware that
+// run before the handler, outermost first.
+type RouteSnapshot struct {
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Host       string              `json:"host,omitempty"`
+	Name       string              `json:"name,omitempty"`
+	Metadata   map[string]string   `json:"metadata,omitempty"`
+	Handler    string              `json:"handler,omitempty"`
+	Middleware []string            `json:"middleware,omitempty"`
+	Access     *AccessRequirements `json:"access,omitempty"`
+	Deprecated *RouteDeprecation   `json:"deprecated,omitempty"`
+}
+
+// Snapshot describes every route of the router tree r belongs to, sorted by path, then method. It can be
+// encoded as JSON and later read back with LoadSnapshot, eg to compare releases with DiffRoutes.
+func (r *Router) Snapshot() RouterSnapshot {
+	var snapshot RouterSnapshot
+	routers := []*Router{getRootRouter(r)}
+	for len(routers) > 0 {
+		var router *Router
+		router, routers = routers[0], routers[1:]
+
+		var middleware []string
+		for _, chained := range router.chain {
+			for _, mw := range chained.middleware {
+				middleware = append(middleware, mw.name)
+			}
+		}
+		for _, route := range router.routes {
+			snapshot.Routes = append(snapshot.Routes, snapshotRoute(route, middleware))
+		}
+		routers = append(routers, router.children...)
+	}
+	sortSnapshots(snapshot.Routes)
+	return snapshot
+}
+
+func snapshotRoute(route *Route, middleware []string) RouteSnapshot {
+	s := RouteSnapshot{
+		Method:     string(route.method),
+		Path:       route.path,
+		Host:       route.HostPattern(),
+		Name:       route.Name,
+		Handler:    route.handler.name,
+		Middleware: middleware,
+	}
+	if len(route.middleware) > 0 {
+		s.Middleware = make([]string, 0, len(middleware)+len(route.middleware))
+		s.Middleware = append(s.Middleware, middleware...)
+		for _, mw := range route.middleware {
+			s.Middleware = append(s.Middleware, mw.name)
+		}
+	}
+	if len(route.metadata) > 0 {
+		s.Metadata = make(map[string]string, len(route.metadata))
+		for k, v := range route.metadata {
+			s.Metadata[k] = v
+		}
+	}
+	if route.access != nil {
+		access := *route.access
+		s.Access = &access
+	}
+	if route.deprecation != nil {
+		deprecation := *route.deprecation
+		s.Deprecated = &deprecation
+	}
+	return s
+}
+
+// LoadSnapshot reads a RouterSnapshot encoded as JSON, eg one written by an earlier release.
+func LoadSnapshot(r io.Reader) (RouterSnapshot, error) {
+	var snapshot RouterSnapshot
+	err := json.NewDecoder(r).Decode(&snapshot)
+	return snapshot, err
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterSnapshot(t *testing.T) {
+	router := New(Context{})
+	router.Middleware((*Context).mwAlpha)
+	router.Get("/posts", (*Context).Z).Named("posts")
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	admin.Middleware((*AdminContext).mwEpsilon)
+	admin.Delete("/posts/:id", (*AdminContext).B).RequireRole("admin").WithMetadata("owner", "blog").Use((*AdminContext).mwZeta)
+
+	snapshot := admin.Snapshot()
+	if assert.Equal(t, 2, len(snapshot.Routes)) {
+		route := snapshot.Routes[0]
+		assert.Equal(t, "DELETE", route.Method)
+		assert.Equal(t, "/admin/posts/:id", route.Path)
+		assert.Equal(t, "blog", route.Metadata["owner"])
+		assert.Equal(t, []string{"admin"}, route.Access.Roles)
+		assert.Equal(t, []string{"web.(*Context).mwAlpha", "web.(*AdminContext).mwEpsilon", "web.(*AdminContext).mwZeta"}, route.Middleware)
+		assert.Equal(t, "web.(*AdminContext).B", route.Handler)
+
+		route = snapshot.Routes[1]
+		assert.Equal(t, "posts", route.Name)
+		assert.Nil(t, route.Access)
+		assert.Equal(t, []string{"web.(*Context).mwAlpha"}, route.Middleware)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, json.NewEncoder(&buf).Encode(snapshot))
+	loaded, err := LoadSnapshot(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, loaded)
+
+	router.Post("/posts", (*Context).A)
+	diff := DiffRoutes(loaded, router.Snapshot())
+	assert.Equal(t, "+ POST /posts handler=web.(*Context).A middleware=web.(*Context).mwAlpha\n", diff.String())
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// routeTable is everything the request hot path reads about routes: the path tree for each method, and the
+// routes by name. Once a router has served a request, its published table is never modified. Registering
+// another route copies the nodes it changes and publishes a new table, so lookups never lock.
+type routeTable struct {
+	trees map[httpMethod]*pathNode
+	named map[string]*Route
+	hosts bool // whether any route is constrained to a host, so requests need theirs
+}
+
+// routeTables is shared by a root router and all of its subrouters.
+type routeTables struct {
+	mu      sync.Mutex // held while registering
+	current atomic.Pointer[routeTable]
+	serving atomic.Bool
+
+	// Called once, with mu held, when the first request is served.
+	firstServe func()
+
+	// While a Router.Batch is running, registrations modify staged instead, and the routes they add are kept in
+	// stagedRoutes, until the outermost batch publishes them. Guarded by mu.
+	batches      int
+	staged       *routeTable
+	stagedRoutes []*Route
+
+	// Changed whenever a setting that routes inherit from their routers changes. See Route.settings.
+	settingsVersion atomic.Int64
+}
+
+func newRouteTables() *routeTables {
+	t := &routeTable{trees: make(map[httpMethod]*pathNode), named: make(map[string]*Route)}
+	for _, method := range httpMethods {
+		t.trees[method] = newPathNode()
+	}
+	tables := &routeTables{}
+	tables.current.Store(t)
+	return tables
+}
+
+// load returns the current table. Requests call it, so that from then on a table is copied before it is changed.
+// Only the first requests take the lock, to wait out a registration that is modifying the table in place.
+func (rt *routeTables) load() *routeTable {
+	if !rt.serving.Load() {
+		rt.mu.Lock()
+		if !rt.serving.Load() && rt.firstServe != nil {
+			rt.firstServe()
+		}
+		rt.serving.Store(true)
+		rt.mu.Unlock()
+	}
+	return rt.current.Load()
+}
+
+// settingsChanged makes routes resolve their settings again the next time they're served.
+func (rt *routeTables) settingsChanged() {
+	rt.settingsVersion.Add(1)
+}
+
+// update calls fn with a table to modify and publishes it if fn succeeds, or stages it during a batch. Before any
+// request has been served the table is modified in place; after that fn gets a copy, and cow tells it to copy
+// nodes too.
+func (rt *routeTables) update(fn func(t *routeTable, cow bool) error) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	t := rt.current.Load()
+	if rt.staged != nil {
+		t = rt.staged
+	}
+	cow := rt.serving.Load()
+	if cow {
+		t = t.clone()
+	}
+	if err := fn(t, cow); err != nil {
+		return err
+	}
+	if rt.staged != nil {
+		rt.staged = t
+	} else {
+		rt.current.Store(t)
+	}
+	return nil
+}
+
+// added records that route was added to the table by the current update: it's live at once, unless a batch is
+// staging it. The caller holds mu.
+func (rt *routeTables) added(route *Route) {
+	if rt.staged != nil {
+		rt.stagedRoutes = append(rt.stagedRoutes, route)
+	} else {
+		route.live.Store(true)
+	}
+}
+
+// beginBatch starts staging registrations. See Router.Batch.
+func (rt *routeTables) beginBatch() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.batches == 0 {
+		rt.staged = rt.current.Load()
+	}
+	rt.batches++
+}
+
+// endBatch publishes the staged table and the routes added to it, once the outermost batch ends.
+func (rt *routeTables) endBatch() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.batches--; rt.batches > 0 {
+		return
+	}
+	rt.current.Store(rt.staged)
+	for _, route := range rt.stagedRoutes {
+		route.live.Store(true)
+	}
+	rt.staged, rt.stagedRoutes = nil, nil
+}
+
+// methods returns the methods t has trees for: httpMethods, then those added with Router.Handle in sorted order.
+func (t *routeTable) methods() []httpMethod {
+	if len(t.trees) == len(httpMethods) {
+		return httpMethods
+	}
+	var extra []httpMethod
+	for method := range t.trees {
+		if !containsMethod(httpMethods, method) {
+			extra = append(extra, method)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+	return append(append([]httpMethod(nil), httpMethods...), extra...)
+}
+
+func containsMethod(methods []httpMethod, method httpMethod) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of t that shares its path nodes.
+func (t *routeTable) clone() *routeTable {
+	c := &routeTable{trees: make(map[httpMethod]*pathNode, len(t.trees)), named: make(map[string]*Route, len(t.named)), hosts: t.hosts}
+	for method, tree := range t.trees {
+		c.trees[method] = tree
+	}
+	for name, route := range t.named {
+		c.named[name] = route
+	}
+	return c
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterWhileServing(t *testing.T) {
+	router := New(Context{})
+	router.Get("/action", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+
+	before := router.tables.load()
+	admin := router.Subrouter(AdminContext{}, "/admin")
+	router.Batch(func() {
+		admin.Get("/action", (*AdminContext).B).Named("admin_action")
+	})
+	assert.True(t, before != router.tables.load())
+	assert.Nil(t, before.named["admin_action"])
+	leaf, _ := before.trees[httpMethodGet].Match("/admin/action")
+	assert.Nil(t, leaf)
+
+	rw, req = newTestRequest("GET", "/admin/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "admin-B", 200)
+
+	assert.Equal(t, "admin_action", findNamedRoute(router, "admin_action").Name)
+
+	// A rejected route leaves the published table alone.
+	published := router.tables.load()
+	_, err := router.TryGet("/action", (*Context).Z)
+	assert.Error(t, err)
+	assert.True(t, published == router.tables.load())
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	router := New(Context{})
+	router.Get("/action", (*Context).A)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			router.Get(fmt.Sprintf("/dynamic/%d", i), (*Context).Z)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		rw, req := newTestRequest("GET", "/action")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "context-A", 200)
+	}
+	wg.Wait()
+
+	rw, req := newTestRequest("GET", "/dynamic/99")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-Z", 200)
+}
+
+func TestBatchWhileServing(t *testing.T) {
+	router := New(Context{}).Authorizer(AuthorizerFunc(func(ctx interface{}, req *Request, required AccessRequirements) error {
+		return ErrUnauthenticated
+	}))
+	route := router.Get("/action", (*Context).A).Named("action")
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			rw, req := newTestRequest("GET", "/action")
+			router.ServeHTTP(rw, req)
+			assertResponse(t, rw, "context-A", 200)
+		}
+	}()
+	err := router.Batch(func() {
+		admin := router.Get("/admin", (*Context).A).RequireRole("admin").WithMetadata("owner", "ops")
+
+		// Staged routes aren't served until the batch ends.
+		rw, req := newTestRequest("GET", "/admin")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Not Found", http.StatusNotFound)
+		admin.Named("admin")
+	})
+	assert.NoError(t, err)
+	wg.Wait()
+
+	rw, req = newTestRequest("GET", "/admin")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unauthorized", http.StatusUnauthorized)
+	assert.NotNil(t, findNamedRoute(router, "admin"))
+
+	// Routes that requests may be reading can't be changed.
+	assert.Panics(t, func() { route.RequireRole("admin") })
+	assert.Panics(t, func() { route.Named("renamed") })
+	assert.Panics(t, func() { router.Get("/late", (*Context).A).Use((*Context).mwAlpha) })
+	assert.Equal(t, "action", route.Name)
+}
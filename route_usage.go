@@ -0,0 +1,66 @@
+package web
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// RouteUsage is how much a named route was used, since the process started or usage collection was turned on.
+type RouteUsage struct {
+	Name     string    `json:"name"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Hits     int64     `json:"hits"`
+	LastSeen time.Time `json:"last_seen"` // zero if the route was never hit
+}
+
+// CollectUsage turns on counting the requests to each named route, and when it was last hit, and returns the
+// router. Unnamed routes aren't counted. Usage is reported by RouteUsage and UsageRoute, to find endpoints that
+// are no longer called before deleting them. Note that only the root router can collect usage.
+func (r *Router) CollectUsage() *Router {
+	if r.parent != nil {
+		panic("You can only collect usage on the root router.")
+	}
+	r.collectUsage = true
+	return r
+}
+
+// recordUsage counts a request to route.
+func recordUsage(route *Route) {
+	if route.Name == "" {
+		return
+	}
+	route.hits.Add(1)
+	route.lastHit.Store(time.Now().UnixNano())
+}
+
+// RouteUsage returns the usage of every named route of the router tree r belongs to, sorted by name. Routes that
+// were never hit are included, with 0 Hits.
+func (r *Router) RouteUsage() []RouteUsage {
+	named := r.tables.current.Load().named
+	usage := make([]RouteUsage, 0, len(named))
+	for name, route := range named {
+		u := RouteUsage{Name: name, Method: string(route.method), Path: route.path, Hits: route.hits.Load()}
+		if lastHit := route.lastHit.Load(); lastHit != 0 {
+			u.LastSeen = time.Unix(0, lastHit).UTC()
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+// UsageRoute adds a GET route at path responding with RouteUsage as JSON, and returns it. It's meant for an admin
+// router; protect it like the rest of it:
+//
+//	admin.UsageRoute("/usage")
+//	// [{"name": "old_report", "method": "GET", "path": "/reports/old", "hits": 0, "last_seen": "0001-01-01T00:00:00Z"}, ...]
+func (r *Router) UsageRoute(path string) *Route {
+	return r.Get(path, func(rw ResponseWriter, req *Request) {
+		body, _ := json.Marshal(r.RouteUsage())
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.Write(body)
+	})
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteUsage(t *testing.T) {
+	router := New(Context{}).CollectUsage()
+	router.Get("/reports", func(rw ResponseWriter, req *Request) {}).Named("reports")
+	router.Get("/reports/old", func(rw ResponseWriter, req *Request) {}).Named("old_reports")
+	router.Get("/unnamed", func(rw ResponseWriter, req *Request) {})
+	admin := router.Subrouter(Context{}, "/admin")
+	admin.UsageRoute("/usage").Named("usage")
+
+	for _, path := range []string{"/reports", "/reports", "/unnamed"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+	}
+
+	rw, req := newTestRequest("GET", "/admin/usage")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 200, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	var usage []RouteUsage
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &usage))
+	if assert.Equal(t, 3, len(usage)) {
+		assert.Equal(t, "old_reports", usage[0].Name)
+		assert.Equal(t, int64(0), usage[0].Hits)
+		assert.True(t, usage[0].LastSeen.IsZero())
+		assert.Equal(t, "reports", usage[1].Name)
+		assert.Equal(t, "/reports", usage[1].Path)
+		assert.Equal(t, int64(2), usage[1].Hits)
+		assert.True(t, time.Since(usage[1].LastSeen) < time.Minute)
+		assert.Equal(t, "usage", usage[2].Name)
+		assert.Equal(t, int64(1), usage[2].Hits)
+	}
+
+	assert.Panics(t, func() { admin.CollectUsage() })
+}
+
+func TestRouteUsageOff(t *testing.T) {
+	router := New(Context{})
+	router.Get("/reports", func(rw ResponseWriter, req *Request) {}).Named("reports")
+	rw, req := newTestRequest("GET", "/reports")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, int64(0), router.RouteUsage()[0].Hits)
+}
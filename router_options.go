@@ -0,0 +1,108 @@
+package web
+
+import (
+	"log"
+	"time"
+)
+
+// RouterOption configures a router made by New. Each option does what the router method it's named after does,
+// so these are the same:
+//
+//	router := web.New(Context{}, web.WithNotFound((*Context).NotFound), web.WithStrictSlash(), web.WithLogger(l))
+//
+//	router := web.New(Context{})
+//	router.NotFound((*Context).NotFound).TrailingSlash(web.TrailingSlashStrict)
+//	router.Middleware(web.NewLoggerMiddleware(l))
+//
+// Options are applied in order, so options adding middleware add it in the order they're passed.
+type RouterOption func(*Router)
+
+// WithPrefix gives every route of the router an implicit prefix, like NewWithPrefix.
+func WithPrefix(pathPrefix string) RouterOption {
+	return func(r *Router) { r.pathPrefix = pathPrefix }
+}
+
+// WithNotFound sets the router's NotFound handler.
+func WithNotFound(fn interface{}) RouterOption {
+	return func(r *Router) { r.NotFound(fn) }
+}
+
+// WithMethodNotAllowed sets the router's MethodNotAllowed handler.
+func WithMethodNotAllowed(fn interface{}) RouterOption {
+	return func(r *Router) { r.MethodNotAllowed(fn) }
+}
+
+// WithError sets the router's Error handler.
+func WithError(fn interface{}) RouterOption {
+	return func(r *Router) { r.Error(fn) }
+}
+
+// WithTrailingSlash sets how the router treats trailing slashes. See Router.TrailingSlash.
+func WithTrailingSlash(mode TrailingSlashMode) RouterOption {
+	return func(r *Router) { r.TrailingSlash(mode) }
+}
+
+// WithStrictSlash is WithTrailingSlash(TrailingSlashStrict).
+func WithStrictSlash() RouterOption {
+	return WithTrailingSlash(TrailingSlashStrict)
+}
+
+// WithCaseInsensitivePaths makes the router's paths case-insensitive. See Router.CaseInsensitivePaths.
+func WithCaseInsensitivePaths() RouterOption {
+	return func(r *Router) { r.CaseInsensitivePaths(true) }
+}
+
+// WithLogger logs every request to l. See NewLoggerMiddleware.
+func WithLogger(l *log.Logger) RouterOption {
+	return func(r *Router) { r.Middleware(NewLoggerMiddleware(l)) }
+}
+
+// WithAccessLog logs every request to each of sinks. See Router.AccessLog.
+func WithAccessLog(sinks ...AccessLogSink) RouterOption {
+	return func(r *Router) { r.AccessLog(sinks...) }
+}
+
+// WithMiddleware adds fn as middleware. See Router.Middleware.
+func WithMiddleware(fn interface{}) RouterOption {
+	return func(r *Router) { r.Middleware(fn) }
+}
+
+// WithCookieDefaults sets the router's CookieDefaults.
+func WithCookieDefaults(defaults CookieDefaults) RouterOption {
+	return func(r *Router) { r.CookieDefaults(defaults) }
+}
+
+// WithCORS sets the router's CORS options.
+func WithCORS(opts CORSOptions) RouterOption {
+	return func(r *Router) { r.CORS(opts) }
+}
+
+// WithHeaderPolicy sets the router's HeaderPolicy.
+func WithHeaderPolicy(policy HeaderPolicy) RouterOption {
+	return func(r *Router) { r.HeaderPolicy(policy) }
+}
+
+// WithTimeout sets the router's Timeout.
+func WithTimeout(d time.Duration) RouterOption {
+	return func(r *Router) { r.Timeout(d) }
+}
+
+// WithMaxBodySize sets the router's MaxBodySize.
+func WithMaxBodySize(n int64) RouterOption {
+	return func(r *Router) { r.MaxBodySize(n) }
+}
+
+// WithResponseCache turns on the router's ResponseCache.
+func WithResponseCache(maxEntries int) RouterOption {
+	return func(r *Router) { r.ResponseCache(maxEntries) }
+}
+
+// WithPoolRequests turns on request pooling. See Router.PoolRequests.
+func WithPoolRequests() RouterOption {
+	return func(r *Router) { r.PoolRequests(true) }
+}
+
+// WithDebug puts the router in debug mode. See Router.Debug.
+func WithDebug() RouterOption {
+	return func(r *Router) { r.Debug(true) }
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterOptions(t *testing.T) {
+	var buf, accessLog bytes.Buffer
+	var order []string
+	router := New(Context{},
+		WithPrefix("/api"),
+		WithNotFound(func(rw ResponseWriter, req *Request) {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte("custom not found"))
+		}),
+		WithStrictSlash(),
+		WithLogger(log.New(&buf, "", 0)),
+		WithAccessLog(NewAccessLogWriter(&accessLog, AccessLogText)),
+		WithMiddleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+			order = append(order, "first")
+			next(rw, req)
+		}),
+		WithMiddleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+			order = append(order, "second")
+			next(rw, req)
+		}),
+	)
+	router.Get("/users", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/api/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+	assert.Equal(t, []string{"first", "second"}, order)
+	assert.True(t, strings.Contains(buf.String(), "200 '/api/users'"), buf.String())
+	assert.True(t, strings.Contains(accessLog.String(), "200 '/api/users'"), accessLog.String())
+
+	rw, req = newTestRequest("GET", "/api/users/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "custom not found", http.StatusNotFound)
+}
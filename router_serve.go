@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"reflect"
 	"runtime"
+	"time"
 )
 
 type middlewareClosure struct {
@@ -12,9 +13,10 @@ type middlewareClosure struct {
 	Request
 	Routers                []*Router
 	Contexts               []reflect.Value
-	currentMiddlewareIndex int
-	currentRouterIndex     int
-	currentMiddlewareLen   int
+	currentMiddlewareIndex int32
+	currentRouterIndex     int32
+	currentMiddlewareLen   int32
+	routeMiddlewareIndex   int32
 	RootRouter             *Router
 	Next                   NextMiddlewareFunc
 }
@@ -26,25 +28,70 @@ func (rootRouter *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// The reason we put these here instead of in the middleware stack, is Go (as of 1.2)
 	// creates a heap variable for each varaiable in the closure. To minimize that, we'll
 	// just have one (closure *middlewareClosure).
-	var closure middlewareClosure
+	var closure *middlewareClosure
+	if pool := rootRouter.closurePool.Load(); pool != nil {
+		closure = pool.Get().(*middlewareClosure)
+		closure.Request.extras().pooled = true
+		defer recycle(pool, closure)
+	} else {
+		closure = &middlewareClosure{}
+	}
 	closure.Request.Request = r
+	closure.Request.settings = rootRouter.settings()
 	closure.appResponseWriter.ResponseWriter = rw
-	closure.Routers = make([]*Router, 1, rootRouter.maxChildrenDepth)
-	closure.Routers[0] = rootRouter
-	closure.Contexts = make([]reflect.Value, 1, rootRouter.maxChildrenDepth)
-	closure.Contexts[0] = reflect.New(rootRouter.contextType)
-	closure.currentMiddlewareLen = len(rootRouter.middleware)
+	closure.appResponseWriter.req = &closure.Request
+	closure.Routers = rootRouter.chain
+	if closure.Contexts == nil {
+		closure.Contexts = make([]reflect.Value, 0, rootRouter.maxChildrenDepth)
+	}
+	closure.Contexts = append(closure.Contexts, reflect.New(rootRouter.contextType))
+	closure.currentMiddlewareLen = int32(len(rootRouter.middleware))
 	closure.RootRouter = rootRouter
 	closure.Request.rootContext = closure.Contexts[0]
 
 	// Handle errors
 	defer func() {
-		if recovered := recover(); recovered != nil {
-			rootRouter.handlePanic(&closure.appResponseWriter, &closure.Request, recovered)
+		recovered := recover()
+		if recovered != nil {
+			rootRouter.handlePanic(&closure.appResponseWriter, &closure.Request, recovered, panicFrames())
+		} else if closure.Request.bodyTooLarge() && !closure.appResponseWriter.Written() {
+			renderError(&closure.appResponseWriter, &closure.Request, http.StatusRequestEntityTooLarge, DefaultBodyTooLargeResponse)
+		}
+		if head, ok := closure.appResponseWriter.ResponseWriter.(*headWriter); ok {
+			head.finish(true)
+		}
+		if closure.Request.route != nil && closure.Request.Disconnected() {
+			closure.Request.route.aborted.Add(1)
+		}
+		more := closure.Request.more
+		if more == nil {
+			return
+		}
+		if more.strict != nil {
+			more.strict.finished = true
+		}
+		more.bodySpool.close()
+		if recovered == nil && successStatus(closure.appResponseWriter.statusCode) {
+			closure.Request.runAfterSuccess()
+		}
+		if !more.start.IsZero() {
+			rootRouter.Emit(Event{Type: EventHandlerFinished, Request: &closure.Request, Status: closure.appResponseWriter.statusCode, Duration: time.Since(more.start)})
 		}
 	}()
+	if len(rootRouter.subscribers) > 0 {
+		closure.Request.extras().start = time.Now()
+	}
 
-	next := middlewareStack(&closure)
+	if debugBuild && rootRouter.debug {
+		closure.Request.extras().strict = &strictState{path: r.URL.Path}
+		rw.Header().Add("Trailer", MiddlewareTraceHeader)
+		defer closure.reportTrace()
+	}
+
+	next := closure.Next
+	if next == nil {
+		next = middlewareStack(closure)
+	}
 	next(&closure.appResponseWriter, &closure.Request)
 }
 
@@ -56,7 +103,11 @@ func (rootRouter *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 // The action invoking middleware is executed after all middleware. It executes the final handler.
 func middlewareStack(closure *middlewareClosure) NextMiddlewareFunc {
 	closure.Next = func(rw ResponseWriter, req *Request) {
-		if closure.currentRouterIndex >= len(closure.Routers) {
+		if int(closure.currentRouterIndex) >= len(closure.Routers) {
+			// All router middleware has run. Continue with the route's own middleware, if any is left.
+			if req.route != nil && closure.routeMiddlewareIndex > 0 {
+				closure.invokeRoute(rw, req)
+			}
 			return
 		}
 
@@ -65,6 +116,7 @@ func middlewareStack(closure *middlewareClosure) NextMiddlewareFunc {
 		//  - set currentMiddlewareIndex, currentRouterIndex, currentMiddlewareLen
 		//  - calculate route, setting routers/contexts, and fields in req.
 		var middleware *middlewareHandler
+		var timeout time.Duration
 		if closure.currentMiddlewareIndex < closure.currentMiddlewareLen {
 			middleware = closure.Routers[closure.currentRouterIndex].middleware[closure.currentMiddlewareIndex]
 		} else {
@@ -73,30 +125,59 @@ func middlewareStack(closure *middlewareClosure) NextMiddlewareFunc {
 				// If we're still on the root router, it's time to actually figure out what the route is.
 				// Do so, and update the various variables.
 				// We could also 404 at this point: if so, run NotFound handlers and return.
+				if isPreflight(req) && closure.RootRouter.handlePreflight(rw, req) {
+					return
+				}
 				route, wildcardMap := calculateRoute(closure.RootRouter, req)
+				if route != nil {
+					req.settings = route.settings()
+				}
+				if policy := req.settings.headerPolicy; policy != nil {
+					closure.appResponseWriter.BeforeWrite(policy.apply)
+				}
 				if route == nil {
-					if closure.RootRouter.notFoundHandler.IsValid() {
-						invoke(closure.RootRouter.notFoundHandler, closure.Contexts[0], []reflect.Value{reflect.ValueOf(rw), reflect.ValueOf(req)})
-					} else {
-						rw.WriteHeader(http.StatusNotFound)
-						fmt.Fprintf(rw, DefaultNotFoundResponse)
-					}
+					closure.RootRouter.handleUnrouted(rw, req, closure.Contexts[0])
 					return
 				}
+				if closure.RootRouter.trailingSlash == TrailingSlashRedirect || closure.RootRouter.canonicalCaseRedirects {
+					if location, ok := closure.RootRouter.canonicalLocation(req, route); ok {
+						redirectCanonical(rw, req, location)
+						return
+					}
+				}
 
-				closure.Routers = routersFor(route, closure.Routers)
+				settings := req.settings
+				closure.Routers = route.router.chain
 				closure.Contexts = contextsFor(closure.Contexts, closure.Routers)
 
 				req.targetContext = closure.Contexts[len(closure.Contexts)-1]
 				req.route = route
 				req.PathParams = wildcardMap
+				applyCORS(rw, req)
+				closure.RootRouter.Emit(Event{Type: EventRouteMatched, Request: req})
+				if closure.RootRouter.collectUsage {
+					recordUsage(route)
+				}
+				if route.deprecation != nil {
+					applyDeprecation(rw, req, route)
+				}
+				if limit := settings.maxBodySize; limit > 0 && !limitBody(rw, req, limit) {
+					return
+				}
+				if route.cache != nil {
+					applyCacheDirective(&closure.appResponseWriter, route)
+				}
+				if route.method == httpMethodGet && req.Method == string(httpMethodHead) {
+					closure.appResponseWriter.ResponseWriter = &headWriter{ResponseWriter: closure.appResponseWriter.ResponseWriter}
+				}
+				timeout = settings.timeout
 			}
 
 			closure.currentMiddlewareIndex = 0
 			closure.currentRouterIndex++
-			routersLen := len(closure.Routers)
+			routersLen := int32(len(closure.Routers))
 			for closure.currentRouterIndex < routersLen {
-				closure.currentMiddlewareLen = len(closure.Routers[closure.currentRouterIndex].middleware)
+				closure.currentMiddlewareLen = int32(len(closure.Routers[closure.currentRouterIndex].middleware))
 				if closure.currentMiddlewareLen > 0 {
 					break
 				}
@@ -104,89 +185,186 @@ func middlewareStack(closure *middlewareClosure) NextMiddlewareFunc {
 			}
 			if closure.currentRouterIndex < routersLen {
 				middleware = closure.Routers[closure.currentRouterIndex].middleware[closure.currentMiddlewareIndex]
-			} else {
-				// We're done! invoke the action
-				handler := req.route.handler
-				if handler.Generic {
-					handler.GenericHandler(rw, req)
-				} else {
-					handler.DynamicHandler.Call([]reflect.Value{closure.Contexts[len(closure.Contexts)-1], reflect.ValueOf(rw), reflect.ValueOf(req)})
-				}
 			}
+			// Otherwise we're done! middleware stays nil, and the route's middleware and the action are invoked.
 		}
 
 		closure.currentMiddlewareIndex++
 
-		// Invoke middleware.
-		if middleware != nil {
-			middleware.invoke(closure.Contexts[closure.currentRouterIndex], rw, req, closure.Next)
+		if timeout > 0 {
+			closure.serveWithTimeout(timeout, rw, req, func(rw ResponseWriter, req *Request) { closure.invokeNext(middleware, rw, req) })
+		} else {
+			closure.invokeNext(middleware, rw, req)
 		}
 	}
 
 	return closure.Next
 }
 
+// invokeNext invokes middleware, or if it's nil, continues with the route's own middleware and the action.
+func (closure *middlewareClosure) invokeNext(middleware *middlewareHandler, rw ResponseWriter, req *Request) {
+	if middleware == nil {
+		closure.invokeRoute(rw, req)
+		return
+	}
+	ctx := closure.Contexts[closure.currentRouterIndex]
+	if debugBuild && closure.RootRouter.debug {
+		closure.traced(middleware.name, func() { middleware.invoke(ctx, rw, req, closure.Next) })
+	} else {
+		middleware.invoke(ctx, rw, req, closure.Next)
+	}
+}
+
+// invokeRoute advances through the route's own middleware, then checks access and invokes the action.
+// Each call runs one step; route middleware calls closure.Next to get here again.
+func (closure *middlewareClosure) invokeRoute(rw ResponseWriter, req *Request) {
+	route := req.route
+	i := int(closure.routeMiddlewareIndex)
+	closure.routeMiddlewareIndex++
+	ctx := closure.Contexts[len(closure.Contexts)-1]
+
+	if i < len(route.middleware) {
+		middleware := route.middleware[i]
+		if debugBuild && closure.RootRouter.debug {
+			closure.traced(middleware.name, func() { middleware.invoke(ctx, rw, req, closure.Next) })
+		} else {
+			middleware.invoke(ctx, rw, req, closure.Next)
+		}
+		return
+	}
+	if i > len(route.middleware) {
+		return // the action already ran
+	}
+
+	if route.hasAccessRequirements() && !closure.authorize(rw, req) {
+		return
+	}
+	if req.settings.policies && !closure.checkPolicy(rw, req) {
+		return
+	}
+	if route.challenged && !closure.checkChallenge(rw, req) {
+		return
+	}
+
+	// The cache is only consulted now, once every middleware and access check has let the request through.
+	var recorder *cacheRecorder
+	if cache := closure.RootRouter.responseCache; cache != nil && route.cache != nil && route.cache.public && req.Method == string(httpMethodGet) {
+		if cache.serve(closure.RootRouter, rw, req, route) {
+			closure.RootRouter.Emit(Event{Type: EventCacheHit, Request: req})
+			return
+		}
+		closure.RootRouter.Emit(Event{Type: EventCacheMiss, Request: req})
+		recorder = &cacheRecorder{ResponseWriter: rw}
+		rw = recorder
+	}
+
+	handler := route.handler
+	if debugBuild && closure.RootRouter.debug {
+		closure.traced(handler.name, func() { handler.invoke(ctx, rw, req) })
+	} else if handler.Generic {
+		handler.GenericHandler(rw, req)
+	} else if handler.TypedHandler != nil {
+		handler.TypedHandler(ctx, rw, req)
+	} else {
+		handler.DynamicHandler.Call([]reflect.Value{ctx, rwValue(rw), reflect.ValueOf(req)})
+	}
+	if recorder != nil {
+		recorder.store(closure.RootRouter.responseCache, req)
+	}
+}
+
 func (mw *middlewareHandler) invoke(ctx reflect.Value, rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
 	if mw.Generic {
 		mw.GenericMiddleware(rw, req, next)
+	} else if mw.TypedMiddleware != nil {
+		mw.TypedMiddleware(ctx, rw, req, next)
 	} else {
-		mw.DynamicMiddleware.Call([]reflect.Value{ctx, reflect.ValueOf(rw), reflect.ValueOf(req), reflect.ValueOf(next)})
+		mw.DynamicMiddleware.Call([]reflect.Value{ctx, rwValue(rw), reflect.ValueOf(req), reflect.ValueOf(next)})
 	}
 }
 
-// Strange performance characteristics: this hurts benchmark scores.
-// func (ah *actionHandler) invoke(ctx reflect.Value, rw ResponseWriter, req *Request) {
-// 	if ah.Generic {
-// 		ah.GenericHandler(rw, req)
-// 	} else {
-// 		ah.DynamicHandler.Call([]reflect.Value{ctx, reflect.ValueOf(rw), reflect.ValueOf(req)})
-// 	}
-// }
+// Strange performance characteristics: calling this instead of inlining it hurts benchmark scores,
+// so the hot path inlines it and only debug mode uses it.
+func (ah *actionHandler) invoke(ctx reflect.Value, rw ResponseWriter, req *Request) {
+	if ah.Generic {
+		ah.GenericHandler(rw, req)
+	} else if ah.TypedHandler != nil {
+		ah.TypedHandler(ctx, rw, req)
+	} else {
+		ah.DynamicHandler.Call([]reflect.Value{ctx, rwValue(rw), reflect.ValueOf(req)})
+	}
+}
+
+// rwValue returns rw as a reflect.Value of type ResponseWriter rather than of rw's own type, so that calling a
+// handler with it doesn't check again that it implements ResponseWriter. That check is slower than the call.
+func rwValue(rw ResponseWriter) reflect.Value {
+	return reflect.ValueOf(&rw).Elem()
+}
+
+// handleUnrouted responds to a request no route matched. If the path has routes for other methods, it gets an
+// automatic OPTIONS response or a 405, with an Allow header (see advertiseMethods); otherwise it's not found. ctx
+// is the root context.
+func (rootRouter *Router) handleUnrouted(rw ResponseWriter, req *Request, ctx reflect.Value) {
+	if advertiseMethods(rootRouter, rw, req) {
+		if req.Method == string(httpMethodOptions) && !rootRouter.autoOptionsDisabled {
+			if rootRouter.optionsHandler.IsValid() {
+				invoke(rootRouter.optionsHandler, ctx, []reflect.Value{rwValue(rw), reflect.ValueOf(req)})
+			} else {
+				rw.WriteHeader(http.StatusNoContent)
+			}
+		} else if rootRouter.methodNotAllowedHandler.IsValid() {
+			invoke(rootRouter.methodNotAllowedHandler, ctx, []reflect.Value{rwValue(rw), reflect.ValueOf(req)})
+		} else {
+			renderError(rw, req, http.StatusMethodNotAllowed, DefaultMethodNotAllowedResponse)
+		}
+		return
+	}
+
+	if rootRouter.serveSPAFallback(rw, req) {
+		return
+	}
+
+	if rootRouter.notFoundHandler.IsValid() {
+		invoke(rootRouter.notFoundHandler, ctx, []reflect.Value{rwValue(rw), reflect.ValueOf(req)})
+	} else {
+		renderError(rw, req, http.StatusNotFound, DefaultNotFoundResponse)
+	}
+}
 
 func calculateRoute(rootRouter *Router, req *Request) (*Route, map[string]string) {
 	var leaf *pathLeaf
 	var wildcardMap map[string]string
+	segments, valid := requestSegments(req.URL)
+	if !valid {
+		return nil, nil
+	}
+	table := rootRouter.tables.load()
+	var host string
+	if table.hosts {
+		host = requestHost(req.Host)
+	}
 	method := httpMethod(req.Method)
-	tree, ok := rootRouter.root[method]
+	tree, ok := table.trees[method]
 	if ok {
-		leaf, wildcardMap = tree.Match(req.URL.Path)
+		leaf, wildcardMap = rootRouter.matchPath(tree, segments, host)
 	}
 
 	// If no match and this is a HEAD, route on GET.
-	if leaf == nil && method == httpMethodHead {
-		tree, ok := rootRouter.root[httpMethodGet]
+	if leaf == nil && method == httpMethodHead && !rootRouter.autoHeadDisabled {
+		tree, ok := table.trees[httpMethodGet]
 		if ok {
-			leaf, wildcardMap = tree.Match(req.URL.Path)
+			leaf, wildcardMap = rootRouter.matchPath(tree, segments, host)
 		}
 	}
 
 	if leaf == nil {
 		return nil, nil
 	}
-
-	return leaf.route, wildcardMap
-}
-
-// given the route (and target router), return [root router, child router, ..., leaf route's router]
-// Use the memory in routers to store this information
-func routersFor(route *Route, routers []*Router) []*Router {
-	routers = routers[:0]
-	curRouter := route.router
-	for curRouter != nil {
-		routers = append(routers, curRouter)
-		curRouter = curRouter.parent
-	}
-
-	// Reverse the slice
-	s := 0
-	e := len(routers) - 1
-	for s < e {
-		routers[s], routers[e] = routers[e], routers[s]
-		s++
-		e--
+	if rootRouter.trailingSlash == TrailingSlashStrict && trailingSlashMismatch(leaf.route, req.URL.Path) {
+		return nil, nil
 	}
 
-	return routers
+	return leaf.route, wildcardMap
 }
 
 // contexts is initially filled with a single context for the root
@@ -197,14 +375,12 @@ func contextsFor(contexts []reflect.Value, routers []*Router) []reflect.Value {
 	routersLen := len(routers)
 
 	for i := 1; i < routersLen; i++ {
-		var ctx reflect.Value
-		if routers[i].contextType == routers[i-1].contextType {
-			ctx = contexts[i-1]
-		} else {
+		ctx := contexts[i-1]
+		if routers[i].ownsContext {
+			parent := ctx
 			ctx = reflect.New(routers[i].contextType)
 			// set the first field to the parent
-			f := reflect.Indirect(ctx).Field(0)
-			f.Set(contexts[i-1])
+			ctx.Elem().Field(0).Set(parent)
 		}
 		contexts = append(contexts, ctx)
 	}
@@ -215,10 +391,12 @@ func contextsFor(contexts []reflect.Value, routers []*Router) []reflect.Value {
 // If there's a panic in the root middleware (so that we don't have a route/target), then invoke the root handler or default.
 // If there's a panic in other middleware, then invoke the target action's function.
 // If there's a panic in the action handler, then invoke the target action's function.
-func (rootRouter *Router) handlePanic(rw *appResponseWriter, req *Request, err interface{}) {
+func (rootRouter *Router) handlePanic(rw *appResponseWriter, req *Request, err interface{}, stack []runtime.Frame) {
 	var targetRouter *Router  // This will be set to the router we want to use the errorHandler on.
 	var context reflect.Value // this is the context of the target router
 
+	rootRouter.Emit(Event{Type: EventPanicRecovered, Request: req, Panic: err})
+
 	if req.route == nil {
 		targetRouter = rootRouter
 		context = req.rootContext
@@ -240,17 +418,34 @@ func (rootRouter *Router) handlePanic(rw *appResponseWriter, req *Request, err i
 		}
 	}
 
+	coded, isCoded := err.(*CodedError)
+	if isCoded {
+		countCodedError(coded)
+	}
+
 	if targetRouter.errorHandler.IsValid() {
-		invoke(targetRouter.errorHandler, context, []reflect.Value{reflect.ValueOf(rw), reflect.ValueOf(req), reflect.ValueOf(err)})
+		invoke(targetRouter.errorHandler, context, []reflect.Value{rwValue(rw), reflect.ValueOf(req), reflect.ValueOf(err)})
+	} else if isCoded {
+		RenderCodedError(rw, coded)
+	} else if isBodyTooLarge(err) {
+		renderError(rw, req, http.StatusRequestEntityTooLarge, DefaultBodyTooLargeResponse)
 	} else {
-		http.Error(rw, DefaultPanicResponse, http.StatusInternalServerError)
+		renderError(rw, req, http.StatusInternalServerError, DefaultPanicResponse)
 	}
 
-	const size = 4096
-	stack := make([]byte, size)
-	stack = stack[:runtime.Stack(stack, false)]
+	// Coded errors are part of the application's normal flow, so they're not reported as panics. Neither are
+	// errors from writing to a client that went away, or from reading a body over its MaxBodySize.
+	if isCoded || req.Disconnected() || isDisconnectError(err) || isBodyTooLarge(err) {
+		return
+	}
 
-	PanicHandler.Panic(fmt.Sprint(req.URL), err, string(stack))
+	if req.route != nil {
+		if iso := isolationFor(req.route.router.chain); iso != nil {
+			iso.fail()
+			err = &IsolatedPanic{Name: iso.name, Value: err}
+		}
+	}
+	PanicHandler.Panic(fmt.Sprint(req.URL), err, formatFrames(stack))
 }
 
 func invoke(handler reflect.Value, ctx reflect.Value, values []reflect.Value) {
@@ -267,5 +462,9 @@ func invoke(handler reflect.Value, ctx reflect.Value, values []reflect.Value) {
 // DefaultNotFoundResponse is the default text rendered when no route is found and no NotFound handlers are present.
 var DefaultNotFoundResponse = "Not Found"
 
+// DefaultMethodNotAllowedResponse is the default text rendered when a path has routes, but none for the request's
+// method, and no MethodNotAllowed handler is present.
+var DefaultMethodNotAllowedResponse = "Method Not Allowed"
+
 // DefaultPanicResponse is the default text rendered when a panic occurs and no Error handlers are present.
 var DefaultPanicResponse = "Application Error"
@@ -1,8 +1,13 @@
 package web
 
 import (
+	"fmt"
+	"net/url"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type httpMethod string
@@ -20,6 +25,10 @@ const (
 var httpMethods = []httpMethod{httpMethodGet, httpMethodPost, httpMethodPut, httpMethodDelete, httpMethodPatch, httpMethodHead, httpMethodOptions}
 
 // Router implements net/http's Handler interface and is what you attach middleware, routes/handlers, and subrouters to.
+// Routes can be added while the router is serving requests; requests see either the old or the new set of routes,
+// and route lookup never takes a lock. Such routes are live as soon as they're added, so routes that need
+// configuring (eg with RequireRole or Use) must be added in a Batch. Middleware and subrouters must still be set
+// up beforehand.
 type Router struct {
 	// Hierarchy:
 	parent           *Router // nil if root router.
@@ -29,6 +38,13 @@ type Router struct {
 	// For each request we'll create one of these objects
 	contextType reflect.Type
 
+	// [root router, child router, ..., this router]. Computed once when the router is created, so
+	// serving a request doesn't need to walk the hierarchy.
+	chain []*Router
+
+	// True if this router's context type differs from its parent's, so a new context is allocated for it.
+	ownsContext bool
+
 	// Eg, "/" or "/admin". Any routes added to this router will be prefixed with this.
 	pathPrefix string
 
@@ -36,8 +52,8 @@ type Router struct {
 	middleware []*middlewareHandler
 	routes     []*Route
 
-	// The root pathnode is the same for a tree of Routers
-	root map[httpMethod]*pathNode
+	// The route tables are the same for a tree of Routers
+	tables *routeTables
 
 	// This can can be set on any router. The target's ErrorHandler will be invoked if it exists
 	errorHandler reflect.Value
@@ -45,6 +61,93 @@ type Router struct {
 	// This can only be set on the root handler, since by virtue of not finding a route, we don't have a target.
 	// (That being said, in the future we could investigate namespace matches)
 	notFoundHandler reflect.Value
+
+	// These can only be set on the root router. See AutoOptions and OptionsHandler.
+	autoOptionsDisabled bool
+	optionsHandler      reflect.Value
+
+	// This can only be set on the root router. See AutoHead.
+	autoHeadDisabled bool
+
+	// This can only be set on the root router. See TrailingSlash.
+	trailingSlash TrailingSlashMode
+
+	// These can only be set on the root router. See CaseInsensitivePaths and CanonicalCaseRedirects.
+	caseInsensitivePaths   bool
+	canonicalCaseRedirects bool
+
+	// Set through any router, but kept on the root router, longest prefix first. See SPAFallback.
+	spaFallbacks []spaFallback
+
+	// This can only be set on the root router. See MethodNotAllowed.
+	methodNotAllowedHandler reflect.Value
+
+	// This can be set on any router. The nearest HeaderPolicy applies to responses.
+	headerPolicy *HeaderPolicy
+
+	// This can be set on any router. The nearest CORS configuration applies to a route's requests.
+	cors *CORSOptions
+
+	// This can only be set on the root router. See ResponseCache.
+	responseCache *responseCache
+
+	// This can only be set on the root router. See CollectUsage.
+	collectUsage bool
+
+	// This can be set on any router. See Isolate.
+	isolation *isolation
+
+	// This can be set on any router. The nearest Timeout applies to a route's requests, unless it has its own.
+	timeout time.Duration
+
+	// This can be set on any router. The nearest MaxBodySize applies to a route's requests, unless it has its own.
+	maxBodySize int64
+
+	// The settings of requests before they're routed, on the root router. See Router.settings.
+	resolved atomic.Pointer[routeSettings]
+
+	// This can only be set on the root router. nil for the defaults. See ErrorRenderer.
+	errorRenderers atomic.Pointer[map[string]ErrorRenderer]
+
+	// Added through any router, but kept on the root router. See HealthCheck.
+	healthChecks []namedHealthCheck
+
+	// Added through any router, but kept on the root router. See Subscribe.
+	subscribers []eventSubscriber
+
+	// This can be set on any router. The nearest Authorizer enforces a route's access requirements.
+	authorizer Authorizer
+
+	// This can be set on any router. The nearest policy must allow every request routed to a router.
+	policy *routerPolicy
+
+	// This can be set on any router. The nearest Challenge protects routes that require one.
+	challenge *routerChallenge
+
+	// This can be set on any router. The nearest router's defaults apply to cookies set with SetCookie.
+	cookieDefaults *CookieDefaults
+
+	// This can be set on any router. The nearest router's SecureCookies sign cookies set with SetSecureCookie.
+	secureCookies *SecureCookies
+
+	// This can be set on any router. Handlers reach the nearest Notifier with Request.Notifier.
+	notifier Notifier
+
+	// This can be set on any router. Routes take the nearest router's host when they are added.
+	host *hostPattern
+
+	// This can be set on any router. UseMiddleware looks names up in the nearest registry.
+	middlewareRegistry *MiddlewareRegistry
+
+	// This can only be set on the root router. See Debug.
+	debug bool
+
+	// This can only be set on the root router. See BaseURL.
+	baseURL *url.URL
+
+	// This can only be set on the root router. See PoolRequests. It's atomic, since pooling can be toggled while
+	// requests are served.
+	closurePool atomic.Pointer[sync.Pool]
 }
 
 // NextMiddlewareFunc are functions passed into your middleware. To advance the middleware, call the function.
@@ -62,15 +165,92 @@ type GenericMiddleware func(ResponseWriter, *Request, NextMiddlewareFunc)
 type GenericHandler func(ResponseWriter, *Request)
 
 type Route struct {
-	router  *Router
-	method  httpMethod
-	path    string
-	handler *actionHandler
-	Name    string
+	router             *Router
+	method             httpMethod
+	path               string
+	handler            *actionHandler
+	Name               string
+	middleware         []*middlewareHandler
+	access             *AccessRequirements // nil unless the route has requirements
+	metadata           map[string]string
+	challenged         bool
+	host               *hostPattern                  // nil if the route matches every host
+	headerPolicy       *HeaderPolicy                 // nil unless set with Route.HeaderPolicy
+	consumes           []string                      // see Route.Consumes
+	withoutTransaction bool                          // see Route.WithoutTransaction
+	withoutJWT         bool                          // see Route.WithoutJWT
+	cache              *cacheDirective               // see Route.Cache
+	timeout            time.Duration                 // see Route.Timeout
+	maxBodySize        int64                         // see Route.MaxBodySize
+	deprecation        *RouteDeprecation             // nil unless set with Route.Deprecated
+	aborted            atomic.Int64                  // requests whose client went away; see AbortedRequests
+	deprecatedRequests atomic.Int64                  // see DeprecatedRequests
+	hits               atomic.Int64                  // see CollectUsage
+	lastHit            atomic.Int64                  // Unix nanoseconds; see CollectUsage
+	resolved           atomic.Pointer[routeSettings] // see Route.settings
+	live               atomic.Bool                   // requests may see the route; see Router.Batch
 }
 
+// Named sets the route's Name and returns the route. If another route already has the name, UrlFor and other
+// lookups by name keep finding that route; use TryNamed to catch that.
 func (r *Route) Named(n string) *Route {
-	r.Name = n
+	r.setName(n, false)
+	return r
+}
+
+// TryNamed is like Named, but returns a *RouteError, and leaves the route's name alone, if another route already
+// has the name n.
+func (r *Route) TryNamed(n string) error {
+	return r.setName(n, true)
+}
+
+func (r *Route) setName(n string, exclusive bool) error {
+	r.checkConfigurable()
+	return r.router.tables.update(func(t *routeTable, cow bool) error {
+		other := t.named[n]
+		if other != nil && other != r && exclusive {
+			return newRouteError(r.method, r.path, fmt.Sprintf("the name %q is already used by %s %s", n, other.method, other.path))
+		}
+		if t.named[r.Name] == r {
+			delete(t.named, r.Name)
+		}
+		if other == nil {
+			t.named[n] = r
+		}
+		r.Name = n
+		return nil
+	})
+}
+
+// checkConfigurable panics if requests may already be reading the route's configuration.
+func (r *Route) checkConfigurable() {
+	if r.live.Load() && r.router.tables.serving.Load() {
+		panic(fmt.Sprintf("web: route %s %s is already being served; add routes that need configuring in Router.Batch", r.method, r.path))
+	}
+}
+
+// Batch calls fn, and publishes the routes fn adds to r's router tree all at once when it returns. Requests
+// don't see them until then, so they can be configured first, which a route added while the router is serving
+// otherwise can't be:
+//
+//	router.Batch(func() {
+//		router.Get("/reports", (*Context).Reports).RequireRole("admin").Use((*Context).Audit)
+//	})
+//
+// Batches can be nested; the outermost one publishes the routes. Batch returns the error from Validate.
+func (r *Router) Batch(fn func()) error {
+	r.tables.beginBatch()
+	defer r.tables.endBatch()
+	fn()
+	return r.Validate()
+}
+
+// Use adds fn as middleware for this route only and returns the route. Route middleware runs after all of the
+// router's middleware, in the order it was added, with the route's router's context. fn has the same signatures
+// as Router.Middleware.
+func (r *Route) Use(fn interface{}) *Route {
+	r.checkConfigurable()
+	r.middleware = append(r.middleware, newMiddlewareHandler(fn, r.router.contextType, ""))
 	return r
 }
 
@@ -78,29 +258,36 @@ type middlewareHandler struct {
 	Generic           bool
 	DynamicMiddleware reflect.Value
 	GenericMiddleware GenericMiddleware
+	TypedMiddleware   func(ctx reflect.Value, rw ResponseWriter, req *Request, next NextMiddlewareFunc) // See TypedRouter.
+	name              string
 }
 
 type actionHandler struct {
 	Generic        bool
 	DynamicHandler reflect.Value
 	GenericHandler GenericHandler
+	TypedHandler   func(ctx reflect.Value, rw ResponseWriter, req *Request) // See TypedRouter.
+	name           string
 }
 
 var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
 
 // New returns a new router with context type ctx. ctx should be a struct instance,
 // whose purpose is to communicate type information. On each request, an instance of this
-// context type will be automatically allocated and sent to handlers.
-func New(ctx interface{}) *Router {
+// context type will be automatically allocated and sent to handlers. opts configure the router; see
+// RouterOption.
+func New(ctx interface{}, opts ...RouterOption) *Router {
 	validateContext(ctx, nil)
 
 	r := &Router{}
 	r.contextType = reflect.TypeOf(ctx)
+	r.chain = []*Router{r}
 	r.pathPrefix = "/"
 	r.maxChildrenDepth = 1
-	r.root = make(map[httpMethod]*pathNode)
-	for _, method := range httpMethods {
-		r.root[method] = newPathNode()
+	r.tables = newRouteTables()
+	r.tables.firstServe = r.logInvalid
+	for _, opt := range opts {
+		opt(r)
 	}
 	return r
 }
@@ -108,10 +295,7 @@ func New(ctx interface{}) *Router {
 // NewWithPrefix returns a new router (see New) but each route will have an implicit prefix.
 // For instance, with pathPrefix = "/api/v2", all routes under this router will begin with "/api/v2".
 func NewWithPrefix(ctx interface{}, pathPrefix string) *Router {
-	r := New(ctx)
-	r.pathPrefix = pathPrefix
-
-	return r
+	return New(ctx, WithPrefix(pathPrefix))
 }
 
 // Subrouter attaches a new subrouter to the specified router and returns it.
@@ -135,23 +319,35 @@ func (r *Router) Subrouter(ctx interface{}, pathPrefix string) *Router {
 	}
 
 	newRouter.contextType = reflect.TypeOf(ctx)
+	newRouter.ownsContext = newRouter.contextType != r.contextType
+	newRouter.chain = append(append(make([]*Router, 0, len(r.chain)+1), r.chain...), newRouter)
 	newRouter.pathPrefix = appendPath(r.pathPrefix, pathPrefix)
-	newRouter.root = r.root
+	newRouter.tables = r.tables
 
 	return newRouter
 }
 
 // Middleware adds the specified middleware tot he router and returns the router.
 func (r *Router) Middleware(fn interface{}) *Router {
+	return r.addMiddleware(fn, "")
+}
+
+// addMiddleware adds fn under name, which defaults to the function's name.
+func (r *Router) addMiddleware(fn interface{}, name string) *Router {
+	r.middleware = append(r.middleware, newMiddlewareHandler(fn, r.contextType, name))
+	return r
+}
+
+func newMiddlewareHandler(fn interface{}, ctxType reflect.Type, name string) *middlewareHandler {
 	vfn := reflect.ValueOf(fn)
-	validateMiddleware(vfn, r.contextType)
+	validateMiddleware(vfn, ctxType)
+	if name == "" {
+		name = funcName(vfn)
+	}
 	if vfn.Type().NumIn() == 3 {
-		r.middleware = append(r.middleware, &middlewareHandler{Generic: true, GenericMiddleware: fn.(func(ResponseWriter, *Request, NextMiddlewareFunc))})
-	} else {
-		r.middleware = append(r.middleware, &middlewareHandler{Generic: false, DynamicMiddleware: vfn})
+		return &middlewareHandler{Generic: true, GenericMiddleware: fn.(func(ResponseWriter, *Request, NextMiddlewareFunc)), name: name}
 	}
-
-	return r
+	return &middlewareHandler{Generic: false, DynamicMiddleware: vfn, name: name}
 }
 
 // Error sets the specified function as the error handler (when panics happen) and returns the router.
@@ -174,6 +370,42 @@ func (r *Router) NotFound(fn interface{}) *Router {
 	return r
 }
 
+// MethodNotAllowed sets the specified function as the handler for requests whose path has routes, but none for
+// the request's method, and returns the router. Without one, such requests get a 405. Either way, the Allow header
+// lists the path's methods before the handler runs. MethodNotAllowed handlers have the same signatures as
+// NotFound handlers. Note that only the root router can have a MethodNotAllowed handler.
+func (r *Router) MethodNotAllowed(fn interface{}) *Router {
+	if r.parent != nil {
+		panic("You can only set a MethodNotAllowed handler on the root router.")
+	}
+	vfn := reflect.ValueOf(fn)
+	validateMethodNotAllowedHandler(vfn, r.contextType)
+	r.methodNotAllowedHandler = vfn
+	return r
+}
+
+// Debug turns debug mode on or off and returns the router. In debug mode, every response carries an
+// X-Middleware-Trace trailer listing the middleware and handler that ran, in order, with their durations.
+// The same trace is logged to Logger.
+//
+// Debug mode is also strict about ResponseWriter use: calling WriteHeader twice, writing after Hijack, or
+// writing after the request finished (eg, from a goroutine) is logged to Logger with the offending file:line.
+// Note that only the root router can be put in debug mode.
+//
+// Debug mode is only compiled into binaries built with the webdebug tag. In other binaries, enabling it logs
+// a warning and has no effect.
+func (r *Router) Debug(enabled bool) *Router {
+	if r.parent != nil {
+		panic("You can only enable debug mode on the root router.")
+	}
+	if enabled && !debugBuild {
+		Logger.Println("web: debug mode is not available in this binary; build with -tags webdebug to enable it")
+		return r
+	}
+	r.debug = enabled
+	return r
+}
+
 // Get will add a route to the router that matches on GET requests and the specified path.
 func (r *Router) Get(path string, fn interface{}) *Route {
 	return r.addRoute(httpMethodGet, path, fn)
@@ -209,19 +441,143 @@ func (r *Router) Options(path string, fn interface{}) *Route {
 	return r.addRoute(httpMethodOptions, path, fn)
 }
 
+// Handle will add a route to the router that matches on requests with the specified method and path. Use it for
+// methods without a method of their own, eg Handle("PURGE", "/cache/:key", fn) or WebDAV's PROPFIND. Methods are
+// case-sensitive; an invalid method panics with a *RouteError.
+func (r *Router) Handle(method string, path string, fn interface{}) *Route {
+	return r.addRoute(httpMethod(method), path, fn)
+}
+
+// Any will add a route for each of GET, POST, PUT, DELETE, PATCH, HEAD and OPTIONS requests and the specified
+// path, and returns them in that order.
+func (r *Router) Any(path string, fn interface{}) []*Route {
+	routes := make([]*Route, 0, len(httpMethods))
+	for _, method := range httpMethods {
+		routes = append(routes, r.addRoute(method, path, fn))
+	}
+	return routes
+}
+
+// TryGet is like Get, but returns a *RouteError instead of panicking if the route is invalid. It's meant for
+// programs that build routes from configuration and need to report problems rather than crash.
+func (r *Router) TryGet(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodGet, path, fn)
+}
+
+// TryPost is like Post, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryPost(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodPost, path, fn)
+}
+
+// TryPut is like Put, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryPut(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodPut, path, fn)
+}
+
+// TryDelete is like Delete, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryDelete(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodDelete, path, fn)
+}
+
+// TryPatch is like Patch, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryPatch(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodPatch, path, fn)
+}
+
+// TryHead is like Head, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryHead(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodHead, path, fn)
+}
+
+// TryOptions is like Options, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryOptions(path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethodOptions, path, fn)
+}
+
+// TryHandle is like Handle, but returns a *RouteError instead of panicking if the route is invalid.
+func (r *Router) TryHandle(method string, path string, fn interface{}) (*Route, error) {
+	return r.tryAddRoute(httpMethod(method), path, fn)
+}
+
 func (r *Router) addRoute(method httpMethod, path string, fn interface{}) *Route {
-	vfn := reflect.ValueOf(fn)
-	validateHandler(vfn, r.contextType)
+	validateHandler(reflect.ValueOf(fn), r.contextType)
+	route, err := r.tryAddRoute(method, path, fn)
+	if err != nil {
+		panic(err)
+	}
+	return route
+}
+
+func (r *Router) tryAddRoute(method httpMethod, path string, fn interface{}) (*Route, error) {
 	fullPath := appendPath(r.pathPrefix, path)
-	route := &Route{method: method, path: fullPath, router: r}
+	vfn := reflect.ValueOf(fn)
+	var req *Request
+	var resp func() ResponseWriter
+	if !vfn.IsValid() || !isValidHandler(vfn, r.contextType, reflect.TypeOf(resp).Out(0), reflect.TypeOf(req)) {
+		return nil, newRouteError(method, fullPath, fmt.Sprintf("%T is not a valid handler for a router with context %v", fn, r.contextType))
+	}
+	var handler *actionHandler
 	if vfn.Type().NumIn() == 2 {
-		route.handler = &actionHandler{Generic: true, GenericHandler: fn.(func(ResponseWriter, *Request))}
+		handler = &actionHandler{Generic: true, GenericHandler: fn.(func(ResponseWriter, *Request)), name: funcName(vfn)}
 	} else {
-		route.handler = &actionHandler{Generic: false, DynamicHandler: vfn}
+		handler = &actionHandler{Generic: false, DynamicHandler: vfn, name: funcName(vfn)}
 	}
-	r.routes = append(r.routes, route)
-	r.root[method].add(fullPath, route)
-	return route
+	return r.tryAddHandler(method, fullPath, handler)
+}
+
+// tryAddHandler adds a route for handler at fullPath, which already includes the router's prefix.
+func (r *Router) tryAddHandler(method httpMethod, fullPath string, handler *actionHandler) (*Route, error) {
+	route := &Route{method: method, path: fullPath, router: r, handler: handler, host: r.nearestHost()}
+	if !validMethod(method) {
+		return nil, newRouteError(method, fullPath, fmt.Sprintf("%q is not a valid HTTP method", method))
+	}
+	if reason := validatePath(fullPath); reason != "" {
+		return nil, newRouteError(method, fullPath, reason)
+	}
+	err := r.tables.update(func(t *routeTable, cow bool) error {
+		tree, ok := t.trees[method]
+		if !ok {
+			tree = newPathNode()
+			t.trees[method] = tree
+		} else if cow {
+			tree = tree.clone()
+			t.trees[method] = tree
+		}
+		if err := tree.add(fullPath, route, cow); err != nil {
+			return err
+		}
+		t.hosts = t.hosts || route.host != nil
+		r.routes = append(r.routes, route)
+		r.tables.added(route)
+		return nil
+	})
+	if err != nil {
+		return nil, newRouteError(method, fullPath, err.Error())
+	}
+	return route, nil
+}
+
+// validMethod reports whether method is a token, as HTTP requires of methods.
+func validMethod(method httpMethod) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range []byte(method) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// nearestHost returns the host pattern of r or its nearest parent that has one.
+func (r *Router) nearestHost() *hostPattern {
+	for i := len(r.chain) - 1; i >= 0; i-- {
+		if r.chain[i].host != nil {
+			return r.chain[i].host
+		}
+	}
+	return nil
 }
 
 // Calculates the max child depth of the node. Leaves return 1. For Parent->Child, Parent is 2.
@@ -281,10 +637,23 @@ func validateErrorHandler(vfn reflect.Value, ctxType reflect.Type) {
 }
 
 func validateNotFoundHandler(vfn reflect.Value, ctxType reflect.Type) {
+	validateFallbackHandler(vfn, ctxType, "a 'not found' handler", "not found handler")
+}
+
+func validateMethodNotAllowedHandler(vfn reflect.Value, ctxType reflect.Type) {
+	validateFallbackHandler(vfn, ctxType, "a 'method not allowed' handler", "method not allowed handler")
+}
+
+func validateOptionsHandler(vfn reflect.Value, ctxType reflect.Type) {
+	validateFallbackHandler(vfn, ctxType, "an OPTIONS handler", "OPTIONS handler")
+}
+
+// validateFallbackHandler validates handlers that run without a route, like NotFound handlers.
+func validateFallbackHandler(vfn reflect.Value, ctxType reflect.Type, article, name string) {
 	var req *Request
 	var resp func() ResponseWriter
 	if !isValidHandler(vfn, ctxType, reflect.TypeOf(resp).Out(0), reflect.TypeOf(req)) {
-		panic(instructiveMessage(vfn, "a 'not found' handler", "not found handler", "rw web.ResponseWriter, req *web.Request", ctxType))
+		panic(instructiveMessage(vfn, article, name, "rw web.ResponseWriter, req *web.Request", ctxType))
 	}
 }
 
@@ -332,6 +332,89 @@ func TestRouteVerbs(t *testing.T) {
 	}
 }
 
+func TestRouteHandle(t *testing.T) {
+	router := New(Context{})
+	router.Handle("PURGE", "/cache/:key", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "purged %s", r.PathParams["key"])
+	})
+	router.Get("/cache/:key", (*Context).A)
+	routes := router.Any("/any", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "any %s", r.Method)
+	})
+	if len(routes) != len(httpMethods) {
+		t.Error("Expected a route per method, got", len(routes))
+	}
+
+	rw, req := newTestRequest("PURGE", "/cache/home")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "purged home", 200)
+
+	rw, req = newTestRequest("purge", "/cache/home")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", 405)
+	if allow := rw.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, PURGE" {
+		t.Error("Expected Allow to list PURGE, got", allow)
+	}
+
+	for _, method := range []string{"GET", "DELETE", "OPTIONS"} {
+		rw, req = newTestRequest(method, "/any")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "any "+method, 200)
+	}
+
+	rw, req = newTestRequest("PROPFIND", "/any")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Method Not Allowed", 405)
+}
+
+func TestCatchAll(t *testing.T) {
+	router := New(Context{})
+	files := func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "files %q", r.PathParams["path"])
+	}
+	router.Get("/files/*path", files).Named("file")
+	router.Get("/files/readme", (*Context).A)
+	router.Get("/files/:name/raw", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "raw %s", r.PathParams["name"])
+	})
+	router.Get("/users/:id/*rest", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s %s", r.PathParams["id"], r.PathParams["rest"])
+	})
+	router.Get("/tags/:tag", (*Context).A).Named("tag")
+
+	cases := []struct{ path, body string }{
+		{"/files/a/b/c.txt", `files "a/b/c.txt"`},
+		{"/files/a", `files "a"`},
+		{"/files", `files ""`},
+		{"/files/", `files ""`},
+		{"/files/a%2Fb/c", `files "a/b/c"`},
+		{"/files/readme", "context-A"},
+		{"/files/x/raw", "raw x"},
+		{"/files/x/raw/more", `files "x/raw/more"`},
+		{"/users/3/posts/4", "3 posts/4"},
+	}
+	for _, c := range cases {
+		rw, req := newTestRequest("GET", c.path)
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, c.body, 200)
+	}
+
+	url, err := router.MappedUrlFor("file", map[string]string{"path": "a/b/c.txt"})
+	if err != nil || url != "/files/a/b/c.txt" {
+		t.Error("Expected /files/a/b/c.txt, got", url, err)
+	}
+
+	// A catch-all's leading slash is dropped, but other params' values are used as they are.
+	url, err = router.MappedUrlFor("file", map[string]string{"path": "/a/b"})
+	if err != nil || url != "/files/a/b" {
+		t.Error("Expected /files/a/b, got", url, err)
+	}
+	url, err = router.MappedUrlFor("tag", map[string]string{"tag": "/x"})
+	if err != nil || url != "/tags//x" {
+		t.Error("Expected /tags//x, got", url, err)
+	}
+}
+
 func TestRouteHead(t *testing.T) {
 	router := New(Context{})
 	router.Get("/a", (*Context).A)
@@ -342,7 +425,10 @@ func TestRouteHead(t *testing.T) {
 
 	rw, req = newTestRequest("HEAD", "/a")
 	router.ServeHTTP(rw, req)
-	assertResponse(t, rw, "context-A", 200)
+	assertResponse(t, rw, "", 200)
+	if rw.Header().Get("Content-Length") != "9" {
+		t.Error("Expected Content-Length 9, got", rw.Header().Get("Content-Length"))
+	}
 }
 
 func TestIsRouted(t *testing.T) {
@@ -377,3 +463,58 @@ func TestIsRouted(t *testing.T) {
 	router.ServeHTTP(rw, req)
 	assertResponse(t, rw, "/a", 200)
 }
+
+func TestOptionalSegments(t *testing.T) {
+	router := New(Context{})
+	router.Get("/articles/:year:\\d{4}(/:month:(0[1-9]|1[0-2])(/:day))", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s %s %s", r.PathParams["year"], r.PathParams["month"], r.PathParams["day"])
+	}).Named("archive").Host("example.com")
+	router.Get("/docs(/*page)", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "docs %q", r.PathParams["page"])
+	})
+
+	cases := []struct{ path, body string }{
+		{"/articles/2024", "2024"},
+		{"/articles/2024/05", "2024 05"},
+		{"/articles/2024/05/17", "2024 05 17"},
+		{"/docs", `docs ""`},
+		{"/docs/a/b", `docs "a/b"`},
+	}
+	for _, c := range cases {
+		rw, req := newTestRequest("GET", c.path)
+		req.Host = "example.com"
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, c.body, 200)
+	}
+
+	for _, path := range []string{"/articles/2024/13", "/articles/2024/05/17/x"} {
+		rw, req := newTestRequest("GET", path)
+		req.Host = "example.com"
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Not Found", 404)
+	}
+
+	rw, req := newTestRequest("GET", "/articles/2024")
+	req.Host = "other.com"
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", 404)
+
+	urls := []struct {
+		params map[string]string
+		url    string
+	}{
+		{map[string]string{"year": "2024"}, "/articles/2024"},
+		{map[string]string{"year": "2024", "month": "05"}, "/articles/2024/05"},
+		{map[string]string{"year": "2024", "month": "05", "day": "17"}, "/articles/2024/05/17"},
+		{map[string]string{"year": "2024", "day": "17"}, "/articles/2024?day=17"},
+	}
+	for _, u := range urls {
+		url, err := router.MappedUrlFor("archive", u.params)
+		if err != nil || url != u.url {
+			t.Error("Expected", u.url, "got", url, err)
+		}
+	}
+	if url, err := router.MappedUrlFor("archive", nil, "2024", "05"); err != nil || url != "/articles/2024/05" {
+		t.Error("Expected /articles/2024/05, got", url, err)
+	}
+}
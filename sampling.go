@@ -0,0 +1,70 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"sync/atomic"
+)
+
+// FingerprintHeaders are the request headers that Request.Fingerprint takes into account.
+var FingerprintHeaders = []string{"Accept", "Content-Type"}
+
+// Fingerprint returns a stable identifier for the kind of request this is: its method, its routed path
+// (eg "/users/:id", or the raw path if it isn't routed yet), and the values of FingerprintHeaders.
+// Two requests to /users/1 and /users/2 have the same fingerprint.
+func (r *Request) Fingerprint() string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	if r.route != nil {
+		h.Write([]byte(r.route.path))
+	} else {
+		h.Write([]byte(r.URL.Path))
+	}
+	for _, header := range FingerprintHeaders {
+		h.Write([]byte{0})
+		h.Write([]byte(r.Header.Get(header)))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Sampler decides which requests are sampled by middleware that only needs some of them, such as
+// SampledLoggerMiddleware.
+type Sampler interface {
+	Sample(req *Request) bool
+}
+
+// SamplerFunc adapts a function to the Sampler interface.
+type SamplerFunc func(req *Request) bool
+
+// Sample calls f(req).
+func (f SamplerFunc) Sample(req *Request) bool {
+	return f(req)
+}
+
+// SampleEvery returns a Sampler that samples the first request and then every nth one.
+func SampleEvery(n int) Sampler {
+	var count uint64
+	return SamplerFunc(func(req *Request) bool {
+		return (atomic.AddUint64(&count, 1)-1)%uint64(n) == 0
+	})
+}
+
+// SamplePercent returns a Sampler that samples each request with probability percent/100.
+func SamplePercent(percent float64) Sampler {
+	return SamplerFunc(func(req *Request) bool {
+		return rand.Float64()*100 < percent
+	})
+}
+
+// SampledLoggerMiddleware returns middleware that logs like LoggerMiddleware, but only the requests sampler samples.
+func SampledLoggerMiddleware(sampler Sampler) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if sampler.Sample(req) {
+			LoggerMiddleware(rw, req, next)
+		} else {
+			next(rw, req)
+		}
+	}
+}
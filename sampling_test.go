@@ -0,0 +1,63 @@
+package web
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	var fingerprints []string
+	router := New(Context{})
+	router.Get("/users/:id", func(w ResponseWriter, r *Request) {
+		fingerprints = append(fingerprints, r.Fingerprint())
+	})
+	router.Post("/users/:id", func(w ResponseWriter, r *Request) {
+		fingerprints = append(fingerprints, r.Fingerprint())
+	})
+
+	for _, path := range []string{"/users/1", "/users/2"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+	}
+	rw, req := newTestRequest("POST", "/users/1")
+	router.ServeHTTP(rw, req)
+	rw, req = newTestRequest("GET", "/users/1")
+	req.Header.Set("Accept", "application/json")
+	router.ServeHTTP(rw, req)
+
+	assert.Equal(t, 16, len(fingerprints[0]))
+	assert.Equal(t, fingerprints[0], fingerprints[1])
+	assert.NotEqual(t, fingerprints[0], fingerprints[2])
+	assert.NotEqual(t, fingerprints[0], fingerprints[3])
+}
+
+func TestSamplers(t *testing.T) {
+	every := SampleEvery(3)
+	var sampled []bool
+	for i := 0; i < 6; i++ {
+		sampled = append(sampled, every.Sample(nil))
+	}
+	assert.Equal(t, []bool{true, false, false, true, false, false}, sampled)
+
+	assert.False(t, SamplePercent(0).Sample(nil))
+	assert.True(t, SamplePercent(100).Sample(nil))
+}
+
+func TestSampledLoggerMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{})
+	router.Middleware(SampledLoggerMiddleware(SampleEvery(2)))
+	router.Get("/action", (*Context).A)
+
+	for i := 0; i < 4; i++ {
+		rw, req := newTestRequest("GET", "/action")
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "context-A", 200)
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), "'/action'"))
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCookie is returned for a secure cookie that was tampered with, was signed with a key that's no longer
+// in use, or has expired.
+var ErrInvalidCookie = errors.New("web: invalid or expired secure cookie")
+
+var errNoSecureCookies = errors.New("web: no SecureCookies were set on the router")
+
+// SecureCookies signs cookies with HMAC-SHA256, so clients can't forge or change them, and optionally encrypts them
+// with AES-GCM, so they can't read them either. The signature covers the cookie's name and expiry, so a value
+// can't be moved to another cookie or used after it expired.
+//
+// Set it on a router, and use SetSecureCookie and Request.SecureCookie:
+//
+//	router.SecureCookies(web.NewSecureCookies(currentKey, previousKey))
+//	...
+//	web.SetSecureCookie(rw, web.Cookie{Name: "cart", Value: cartID, MaxAge: 86400})
+//	...
+//	cartID, err := req.SecureCookie("cart")
+type SecureCookies struct {
+	keys []secureCookieKey
+
+	// Encrypt encrypts cookies as well as signing them.
+	Encrypt bool
+}
+
+type secureCookieKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// NewSecureCookies returns SecureCookies that sign (and encrypt) with the first of keys, and accept cookies
+// signed with any of them. To rotate keys, put the new one first, and drop the old one once the cookies it
+// signed have expired. Keys must be random, and at least 32 bytes long.
+func NewSecureCookies(keys ...[]byte) *SecureCookies {
+	if len(keys) == 0 {
+		panic("web: SecureCookies needs at least one key")
+	}
+	s := &SecureCookies{}
+	for _, key := range keys {
+		if len(key) < 32 {
+			panic("web: SecureCookies keys must be at least 32 bytes long")
+		}
+		block, err := aes.NewCipher(deriveKey(key, "web secure cookie encryption"))
+		if err != nil {
+			panic(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		s.keys = append(s.keys, secureCookieKey{sign: deriveKey(key, "web secure cookie signing"), aead: aead})
+	}
+	return s
+}
+
+// SecureCookies sets the SecureCookies used by SetSecureCookie and Request.SecureCookie for requests
+// routed to this router and its subrouters, and returns the router. Middleware that runs before routing uses
+// the root router's.
+func (r *Router) SecureCookies(s *SecureCookies) *Router {
+	r.secureCookies = s
+	r.tables.settingsChanged()
+	return r
+}
+
+// secureCookiesFor returns the SecureCookies of the nearest router in routers, or nil if none has any.
+func secureCookiesFor(routers []*Router) *SecureCookies {
+	for i := len(routers) - 1; i >= 0; i-- {
+		if routers[i].secureCookies != nil {
+			return routers[i].secureCookies
+		}
+	}
+	return nil
+}
+
+// Encode returns the signed (and encrypted) cookie value for value, in the cookie name, valid until expires, or
+// forever if it's zero.
+func (s *SecureCookies) Encode(name, value string, expires time.Time) string {
+	var expiresUnix int64
+	if !expires.IsZero() {
+		expiresUnix = expires.Unix()
+	}
+	payload := strconv.FormatInt(expiresUnix, 10) + "|" + value
+	key := s.keys[0]
+	if s.Encrypt {
+		nonce := randomBytes(key.aead.NonceSize())
+		return base64.RawURLEncoding.EncodeToString(key.aead.Seal(nonce, nonce, []byte(payload), []byte(name)))
+	}
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + secureCookieSignature(key.sign, name, encoded)
+}
+
+// Decode returns the value encoded in the cookie name, or ErrInvalidCookie.
+func (s *SecureCookies) Decode(name, encoded string) (string, error) {
+	for _, key := range s.keys {
+		if payload, ok := s.open(key, name, encoded); ok {
+			i := strings.IndexByte(payload, '|')
+			if i < 0 {
+				return "", ErrInvalidCookie
+			}
+			expires, err := strconv.ParseInt(payload[:i], 10, 64)
+			if err != nil || (expires != 0 && time.Now().Unix() >= expires) {
+				return "", ErrInvalidCookie
+			}
+			return payload[i+1:], nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// open verifies (and decrypts) encoded with key, and returns its payload.
+func (s *SecureCookies) open(key secureCookieKey, name, encoded string) (string, bool) {
+	if s.Encrypt {
+		sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(sealed) < key.aead.NonceSize() {
+			return "", false
+		}
+		nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+		payload, err := key.aead.Open(nil, nonce, ciphertext, []byte(name))
+		return string(payload), err == nil
+	}
+	i := strings.LastIndexByte(encoded, '.')
+	if i < 0 || !hmac.Equal([]byte(encoded[i+1:]), []byte(secureCookieSignature(key.sign, name, encoded[:i]))) {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded[:i])
+	return string(payload), err == nil
+}
+
+func secureCookieSignature(key []byte, name, encoded string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name + "|" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// deriveKey derives a key for purpose from key, so that one key can be used for several purposes.
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// encrypting returns s, or a copy of it that encrypts, for values clients mustn't read whatever Encrypt is.
+func (s *SecureCookies) encrypting() *SecureCookies {
+	if s.Encrypt {
+		return s
+	}
+	return &SecureCookies{keys: s.keys, Encrypt: true}
+}
+
+// secureCookieExpiry returns when c expires, or the zero time if it's a session cookie.
+func secureCookieExpiry(c Cookie) time.Time {
+	if c.MaxAge > 0 {
+		return time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+	}
+	return c.Expires
+}
+
+// SetSecureCookie calls SetSecureCookie on the CookieWriter rw wraps, like SetCookie. Without one, there are no
+// SecureCookies to sign c with, and it returns an error.
+func SetSecureCookie(rw http.ResponseWriter, c Cookie) error {
+	if cw, ok := findWriter[CookieWriter](rw); ok {
+		return cw.SetSecureCookie(c)
+	}
+	return errNoSecureCookies
+}
+
+func (w *appResponseWriter) SetSecureCookie(c Cookie) error {
+	secureCookies := w.settings().secureCookies
+	if secureCookies == nil {
+		return errNoSecureCookies
+	}
+	c.Value = secureCookies.Encode(c.Name, c.Value, secureCookieExpiry(c))
+	return w.SetCookie(c)
+}
+
+// SecureCookie returns the value of the secure cookie name (see SetSecureCookie). It returns
+// http.ErrNoCookie if there's no such cookie, and ErrInvalidCookie if it's invalid.
+func (r *Request) SecureCookie(name string) (string, error) {
+	if r.settings == nil || r.settings.secureCookies == nil {
+		return "", errNoSecureCookies
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return r.settings.secureCookies.Decode(name, cookie.Value)
+}
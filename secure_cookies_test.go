@@ -0,0 +1,84 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureCookies(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte("o"), 32), bytes.Repeat([]byte("n"), 32)
+	for _, encrypt := range []bool{false, true} {
+		old := NewSecureCookies(oldKey)
+		old.Encrypt = encrypt
+		rotated := NewSecureCookies(newKey, oldKey)
+		rotated.Encrypt = encrypt
+
+		encoded := old.Encode("cart", "cart-42", time.Time{})
+		if encrypt {
+			assert.False(t, strings.Contains(encoded, "cart-42"))
+		}
+		value, err := rotated.Decode("cart", encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, "cart-42", value)
+
+		_, err = old.Decode("cart", rotated.Encode("cart", "cart-42", time.Time{}))
+		assert.Equal(t, ErrInvalidCookie, err)
+		_, err = rotated.Decode("other", encoded)
+		assert.Equal(t, ErrInvalidCookie, err)
+		_, err = rotated.Decode("cart", rotated.Encode("cart", "cart-42", time.Now().Add(-time.Second)))
+		assert.Equal(t, ErrInvalidCookie, err)
+
+		tampered := []byte(encoded)
+		tampered[2] ^= 1
+		_, err = rotated.Decode("cart", string(tampered))
+		assert.Equal(t, ErrInvalidCookie, err)
+	}
+}
+
+func TestSetSecureCookie(t *testing.T) {
+	router := New(Context{}).SecureCookies(NewSecureCookies(bytes.Repeat([]byte("k"), 32)))
+	router.Get("/set", func(rw ResponseWriter, req *Request) {
+		assert.NoError(t, SetSecureCookie(rw, Cookie{Name: "cart", Value: "42", MaxAge: 60}))
+	})
+	router.Get("/get", func(rw ResponseWriter, req *Request) {
+		value, err := req.SecureCookie("cart")
+		if err != nil {
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		rw.Write([]byte(value))
+	})
+
+	rw, req := newTestRequest("GET", "/set")
+	router.ServeHTTP(rw, req)
+	cookie := rw.Header().Get("Set-Cookie")
+	assert.True(t, strings.HasPrefix(cookie, "cart="))
+	value := strings.SplitN(strings.TrimPrefix(cookie, "cart="), ";", 2)[0]
+
+	rw, req = newTestRequest("GET", "/get")
+	req.AddCookie(&http.Cookie{Name: "cart", Value: value})
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "42", 200)
+
+	rw, req = newTestRequest("GET", "/get")
+	req.AddCookie(&http.Cookie{Name: "cart", Value: "42"})
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, ErrInvalidCookie.Error(), 200)
+
+	rw, req = newTestRequest("GET", "/get")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, http.ErrNoCookie.Error(), 200)
+
+	bare := New(Context{})
+	bare.Get("/set", func(rw ResponseWriter, req *Request) {
+		assert.Equal(t, errNoSecureCookies, SetSecureCookie(rw, Cookie{Name: "cart", Value: "42"}))
+	})
+	rw, req = newTestRequest("GET", "/set")
+	bare.ServeHTTP(rw, req)
+	assert.Equal(t, "", rw.Header().Get("Set-Cookie"))
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// ListenAndServe listens on srv.Addr (":http" if it's empty) and serves the root router with srv, setting its
+// Handler. See Serve.
+func (r *Router) ListenAndServe(srv *http.Server) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(srv, l)
+}
+
+// Serve serves the root router with srv on l, setting srv's Handler, like srv.Serve. It emits EventServerStarted
+// before accepting connections. Stop the server with Router.Shutdown, which emits EventServerStopped once the
+// requests in flight are done; if srv fails instead, Serve emits it before returning the error.
+func (r *Router) Serve(srv *http.Server, l net.Listener) error {
+	root := r.chain[0]
+	srv.Handler = root
+	root.Emit(Event{Type: EventServerStarted})
+	err := srv.Serve(l)
+	if err != http.ErrServerClosed {
+		root.Emit(Event{Type: EventServerStopped})
+	}
+	return err
+}
+
+// Shutdown shuts srv down gracefully with srv.Shutdown, and emits EventServerStopped once it's done, or ctx is.
+func (r *Router) Shutdown(ctx context.Context, srv *http.Server) error {
+	err := srv.Shutdown(ctx)
+	r.Emit(Event{Type: EventServerStopped})
+	return err
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeEmitsServerEvents(t *testing.T) {
+	var mu sync.Mutex
+	var events []EventType
+	router := New(Context{})
+	router.Subscribe(func(e Event) {
+		mu.Lock()
+		events = append(events, e.Type)
+		mu.Unlock()
+	}, EventServerStarted, EventServerStopped, EventHandlerFinished)
+	api := router.Subrouter(Context{}, "/api")
+	api.Get("/ping", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("pong"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	srv := &http.Server{}
+	served := make(chan error, 1)
+	go func() {
+		served <- api.Serve(srv, l)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/api/ping")
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "pong", string(body))
+	}
+
+	assert.NoError(t, router.Shutdown(context.Background(), srv))
+	assert.Equal(t, http.ErrServerClosed, <-served)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []EventType{EventServerStarted, EventHandlerFinished, EventServerStopped}, events)
+}
+
+func TestServeFailure(t *testing.T) {
+	var events []EventType
+	router := New(Context{})
+	router.Subscribe(func(e Event) {
+		events = append(events, e.Type)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	l.Close()
+
+	assert.Error(t, router.Serve(&http.Server{}, l))
+	assert.Equal(t, []EventType{EventServerStarted, EventServerStopped}, events)
+}
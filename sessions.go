@@ -0,0 +1,327 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sessions keeps data about each client between its requests, eg who is logged in, in a SessionStore. The
+// client's session cookie holds the session's ID, or with a cookie store, the whole session, encrypted.
+//
+// Add its Middleware to the routers that need sessions, and use Request.Session in handlers:
+//
+//	sessions := web.NewSessions(web.NewMemorySessionStore())
+//	router.Middleware(sessions.Middleware())
+//	...
+//	func (c *Context) Login(rw web.ResponseWriter, req *web.Request) {
+//		...
+//		session := req.Session()
+//		session.RenewID() // so that an ID planted before the login is worthless
+//		session.Set("user_id", user.ID)
+//	}
+type Sessions struct {
+	Store SessionStore
+
+	// CookieName is the session cookie's name. Defaults to "_session".
+	CookieName string
+
+	// IdleTimeout ends sessions that weren't used for that long. Defaults to 30 minutes.
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout ends sessions that long after they started, however much they're used. Defaults to 24
+	// hours.
+	AbsoluteTimeout time.Duration
+}
+
+// SessionData is what a SessionStore keeps for a session.
+type SessionData struct {
+	ID       string
+	Values   map[string]string
+	Created  time.Time
+	LastSeen time.Time
+}
+
+// SessionStore keeps sessions. See NewMemorySessionStore, NewKVSessionStore and NewCookieSessionStore.
+type SessionStore interface {
+	// Load returns the session the session cookie's value refers to, or false if there is none.
+	Load(value string) (SessionData, bool, error)
+	// Save keeps data for ttl, and returns the value for the session cookie.
+	Save(data SessionData, ttl time.Duration) (string, error)
+	// Delete forgets the session with id.
+	Delete(id string) error
+}
+
+// Session is the session of a request. See Sessions.
+type Session struct {
+	data      SessionData
+	oldID     string // the ID to delete, after RenewID or Destroy
+	changed   bool
+	loaded    bool // whether the session was loaded from the store, rather than just started
+	destroyed bool // whether the cookie should be cleared, unless the session is saved
+	finished  bool // whether the session was saved, or deleted
+}
+
+type sessionContextKey struct{}
+
+// NewSessions returns Sessions with the default options, keeping sessions in store.
+func NewSessions(store SessionStore) *Sessions {
+	return &Sessions{Store: store, CookieName: "_session", IdleTimeout: 30 * time.Minute, AbsoluteTimeout: 24 * time.Hour}
+}
+
+// Middleware returns middleware that loads the request's session, or starts one, and saves it before the
+// response's headers are written if it changed, so changes made after that are lost. Expired sessions are
+// deleted, and replaced with new ones.
+func (s *Sessions) Middleware() func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	s = s.withDefaults()
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		session := s.load(req)
+		req.SetContext(context.WithValue(req.Context(), sessionContextKey{}, session))
+		BeforeWrite(rw, func(http.Header) {
+			s.finish(rw, session)
+		})
+		next(rw, req)
+		if !rw.Written() {
+			s.finish(rw, session)
+		}
+	}
+}
+
+// withDefaults returns a copy of s, with the defaults of the options left zero.
+func (s *Sessions) withDefaults() *Sessions {
+	opts := *s
+	if opts.CookieName == "" {
+		opts.CookieName = "_session"
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 30 * time.Minute
+	}
+	if opts.AbsoluteTimeout <= 0 {
+		opts.AbsoluteTimeout = 24 * time.Hour
+	}
+	return &opts
+}
+
+func (s *Sessions) load(req *Request) *Session {
+	now := time.Now()
+	if cookie, err := req.Cookie(s.CookieName); err == nil {
+		data, ok, err := s.Store.Load(cookie.Value)
+		if err != nil {
+			panic(err)
+		}
+		if ok {
+			if now.Sub(data.LastSeen) < s.IdleTimeout && now.Sub(data.Created) < s.AbsoluteTimeout {
+				return &Session{data: data, loaded: true}
+			}
+			if err := s.Store.Delete(data.ID); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return &Session{data: newSessionData(now)}
+}
+
+func newSessionData(now time.Time) SessionData {
+	return SessionData{ID: randomToken(), Values: make(map[string]string), Created: now, LastSeen: now}
+}
+
+// finish saves or deletes session, once.
+func (s *Sessions) finish(rw ResponseWriter, session *Session) {
+	if session.finished {
+		return
+	}
+	session.finished = true
+	if session.oldID != "" {
+		if err := s.Store.Delete(session.oldID); err != nil {
+			panic(err)
+		}
+	}
+
+	// Unchanged sessions are saved at most once a minute, to push back their idle timeout.
+	now := time.Now()
+	if !session.changed && (!session.loaded || now.Sub(session.data.LastSeen) < time.Minute) {
+		if session.destroyed {
+			if err := DeleteCookie(rw, s.CookieName); err != nil {
+				panic(err)
+			}
+		}
+		return
+	}
+	session.data.LastSeen = now
+	ttl := s.IdleTimeout
+	if left := session.data.Created.Add(s.AbsoluteTimeout).Sub(now); left < ttl {
+		ttl = left
+	}
+	value, err := s.Store.Save(session.data, ttl)
+	if err != nil {
+		panic(err)
+	}
+	if err := SetCookie(rw, Cookie{Name: s.CookieName, Value: value}); err != nil {
+		panic(err)
+	}
+}
+
+// Session returns the request's session, or nil if the request didn't go through a Sessions middleware.
+func (r *Request) Session() *Session {
+	session, _ := r.Context().Value(sessionContextKey{}).(*Session)
+	return session
+}
+
+// ID returns the session's ID.
+func (s *Session) ID() string {
+	return s.data.ID
+}
+
+// Get returns the value of key, or "" if it has none.
+func (s *Session) Get(key string) string {
+	return s.data.Values[key]
+}
+
+// Set sets key to value.
+func (s *Session) Set(key, value string) {
+	s.data.Values[key] = value
+	s.changed = true
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	delete(s.data.Values, key)
+	s.changed = true
+}
+
+// RenewID gives the session a new ID, keeping its values. Call it when the user's privileges change, eg on
+// login, so that a session ID an attacker got hold of before doesn't carry them.
+func (s *Session) RenewID() {
+	if s.oldID == "" && s.loaded {
+		s.oldID = s.data.ID
+	}
+	s.data.ID = randomToken()
+	s.changed = true
+}
+
+// Destroy ends the session, eg on logout, and clears its cookie. The request then has a new, empty session,
+// which is only kept if values are set in it.
+func (s *Session) Destroy() {
+	if s.oldID == "" && s.loaded {
+		s.oldID = s.data.ID
+	}
+	s.data = newSessionData(time.Now())
+	s.loaded, s.changed, s.destroyed = false, false, true
+}
+
+// SessionKV is a key-value store with expiry, eg Redis, to keep sessions in. See NewKVSessionStore.
+type SessionKV interface {
+	// Get returns the value of key, or nil if there is none.
+	Get(key string) ([]byte, error)
+	// Set sets key to value, which may be forgotten after ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes key.
+	Delete(key string) error
+}
+
+type kvSessionStore struct {
+	kv SessionKV
+}
+
+// NewKVSessionStore returns a SessionStore keeping sessions in kv as JSON, under "session:" and their ID. The
+// session cookie only holds the ID.
+func NewKVSessionStore(kv SessionKV) SessionStore {
+	return kvSessionStore{kv}
+}
+
+// NewMemorySessionStore returns a SessionStore for a single process.
+func NewMemorySessionStore() SessionStore {
+	return kvSessionStore{&memorySessionKV{values: newExpiringMap[[]byte]()}}
+}
+
+func (s kvSessionStore) Load(id string) (SessionData, bool, error) {
+	encoded, err := s.kv.Get("session:" + id)
+	if err != nil || encoded == nil {
+		return SessionData{}, false, err
+	}
+	var data SessionData
+	if err := json.Unmarshal(encoded, &data); err != nil || data.ID != id {
+		return SessionData{}, false, nil
+	}
+	return data, true, nil
+}
+
+func (s kvSessionStore) Save(data SessionData, ttl time.Duration) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return data.ID, s.kv.Set("session:"+data.ID, encoded, ttl)
+}
+
+func (s kvSessionStore) Delete(id string) error {
+	return s.kv.Delete("session:" + id)
+}
+
+type memorySessionKV struct {
+	mu     sync.Mutex
+	values *expiringMap[[]byte]
+}
+
+func (kv *memorySessionKV) Get(key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	value, _ := kv.values.get(key, time.Now())
+	return value, nil
+}
+
+func (kv *memorySessionKV) Set(key string, value []byte, ttl time.Duration) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	now := time.Now()
+	kv.values.set(key, value, now.Add(ttl), now)
+	return nil
+}
+
+func (kv *memorySessionKV) Delete(key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.values.delete(key)
+	return nil
+}
+
+type cookieSessionStore struct {
+	cookies *SecureCookies
+}
+
+// NewCookieSessionStore returns a SessionStore keeping sessions in their cookie, encrypted with cookies (whether or
+// not its Encrypt is set), so their keys can be rotated like those of other secure cookies. Nothing is kept on
+// the server, so sessions must stay small (browsers limit cookies to about 4KB), and Destroy can't revoke copies
+// of the cookie before they expire.
+func NewCookieSessionStore(cookies *SecureCookies) SessionStore {
+	return cookieSessionStore{cookies.encrypting()}
+}
+
+// cookieSessionName binds session cookies' values to sessions, so that other secure cookies can't pass for them.
+const cookieSessionName = "web session"
+
+func (s cookieSessionStore) Load(value string) (SessionData, bool, error) {
+	encoded, err := s.cookies.Decode(cookieSessionName, value)
+	if err != nil {
+		return SessionData{}, false, nil
+	}
+	var data SessionData
+	if err := json.Unmarshal([]byte(encoded), &data); err != nil {
+		return SessionData{}, false, nil
+	}
+	return data, true, nil
+}
+
+func (s cookieSessionStore) Save(data SessionData, ttl time.Duration) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return s.cookies.Encode(cookieSessionName, string(encoded), time.Now().Add(ttl)), nil
+}
+
+func (s cookieSessionStore) Delete(id string) error {
+	return nil
+}
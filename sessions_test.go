@@ -0,0 +1,137 @@
+package web
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSessionsRouter(sessions *Sessions) *Router {
+	router := New(Context{})
+	router.Middleware(sessions.Middleware())
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte(req.Session().Get("user")))
+	})
+	router.Post("/login", func(rw ResponseWriter, req *Request) {
+		session := req.Session()
+		session.RenewID()
+		session.Set("user", req.URL.Query().Get("user"))
+	})
+	router.Post("/logout", func(rw ResponseWriter, req *Request) {
+		req.Session().Destroy()
+		rw.Write([]byte("bye"))
+	})
+	return router
+}
+
+func serveWithCookie(router *Router, method, path string, cookie *http.Cookie) (string, *http.Cookie) {
+	rw, req := newTestRequest(method, path)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	router.ServeHTTP(rw, req)
+	cookies := rw.Result().Cookies()
+	if len(cookies) == 0 {
+		return rw.Body.String(), nil
+	}
+	return rw.Body.String(), cookies[0]
+}
+
+func TestSessions(t *testing.T) {
+	for name, store := range map[string]SessionStore{
+		"memory": NewMemorySessionStore(),
+		"cookie": NewCookieSessionStore(NewSecureCookies([]byte("0123456789abcdef0123456789abcdef"))),
+	} {
+		router := testSessionsRouter(NewSessions(store))
+
+		// Sessions that were never used aren't kept.
+		body, cookie := serveWithCookie(router, "GET", "/", nil)
+		assert.Equal(t, "", body, name)
+		assert.Nil(t, cookie, name)
+
+		_, cookie = serveWithCookie(router, "POST", "/login?user=alice", nil)
+		assert.Equal(t, "_session", cookie.Name, name)
+		assert.True(t, cookie.HttpOnly, name)
+		body, unchanged := serveWithCookie(router, "GET", "/", cookie)
+		assert.Equal(t, "alice", body, name)
+		assert.Nil(t, unchanged, name)
+
+		// Logging in again rotates the session.
+		_, rotated := serveWithCookie(router, "POST", "/login?user=bob", cookie)
+		assert.NotEqual(t, cookie.Value, rotated.Value, name)
+		body, _ = serveWithCookie(router, "GET", "/", rotated)
+		assert.Equal(t, "bob", body, name)
+
+		body, cleared := serveWithCookie(router, "POST", "/logout", rotated)
+		assert.Equal(t, "bye", body, name)
+		assert.Equal(t, "", cleared.Value, name)
+		assert.True(t, cleared.MaxAge < 0, name)
+	}
+}
+
+func TestSessionsServerSideRevocation(t *testing.T) {
+	router := testSessionsRouter(NewSessions(NewMemorySessionStore()))
+	_, first := serveWithCookie(router, "POST", "/login?user=alice", nil)
+	_, second := serveWithCookie(router, "POST", "/login?user=mallory", first)
+
+	// The old ID is worthless after a rotation, and the new one after a logout.
+	body, _ := serveWithCookie(router, "GET", "/", first)
+	assert.Equal(t, "", body)
+	serveWithCookie(router, "POST", "/logout", second)
+	body, _ = serveWithCookie(router, "GET", "/", second)
+	assert.Equal(t, "", body)
+}
+
+func TestSessionsExpiry(t *testing.T) {
+	sessions := NewSessions(NewMemorySessionStore())
+	sessions.IdleTimeout = 20 * time.Millisecond
+	router := testSessionsRouter(sessions)
+	_, cookie := serveWithCookie(router, "POST", "/login?user=alice", nil)
+	time.Sleep(30 * time.Millisecond)
+	body, _ := serveWithCookie(router, "GET", "/", cookie)
+	assert.Equal(t, "", body)
+
+	sessions = NewSessions(NewCookieSessionStore(NewSecureCookies([]byte("0123456789abcdef0123456789abcdef"))))
+	sessions.AbsoluteTimeout = 20 * time.Millisecond
+	router = testSessionsRouter(sessions)
+	_, cookie = serveWithCookie(router, "POST", "/login?user=alice", nil)
+	time.Sleep(30 * time.Millisecond)
+	body, _ = serveWithCookie(router, "GET", "/", cookie)
+	assert.Equal(t, "", body)
+}
+
+func TestSessionsDefaults(t *testing.T) {
+	router := testSessionsRouter(&Sessions{Store: NewMemorySessionStore()})
+	_, cookie := serveWithCookie(router, "POST", "/login?user=alice", nil)
+	assert.Equal(t, "_session", cookie.Name)
+	body, _ := serveWithCookie(router, "GET", "/", cookie)
+	assert.Equal(t, "alice", body)
+}
+
+func TestCookieSessionStoreTampering(t *testing.T) {
+	router := testSessionsRouter(NewSessions(NewCookieSessionStore(NewSecureCookies([]byte("0123456789abcdef0123456789abcdef")))))
+	_, cookie := serveWithCookie(router, "POST", "/login?user=alice", nil)
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	assert.NoError(t, err)
+	sealed[len(sealed)-1] ^= 1
+	cookie.Value = base64.RawURLEncoding.EncodeToString(sealed)
+	body, _ := serveWithCookie(router, "GET", "/", cookie)
+	assert.Equal(t, "", body)
+}
+
+func TestCookieSessionStoreKeyRotation(t *testing.T) {
+	oldKey, newKey := []byte("0123456789abcdef0123456789abcdef"), []byte("fedcba9876543210fedcba9876543210")
+	router := testSessionsRouter(NewSessions(NewCookieSessionStore(NewSecureCookies(oldKey))))
+	_, cookie := serveWithCookie(router, "POST", "/login?user=alice", nil)
+
+	router = testSessionsRouter(NewSessions(NewCookieSessionStore(NewSecureCookies(newKey, oldKey))))
+	body, _ := serveWithCookie(router, "GET", "/", cookie)
+	assert.Equal(t, "alice", body)
+
+	router = testSessionsRouter(NewSessions(NewCookieSessionStore(NewSecureCookies(newKey))))
+	body, _ = serveWithCookie(router, "GET", "/", cookie)
+	assert.Equal(t, "", body)
+}
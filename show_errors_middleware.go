@@ -1,3 +1,6 @@
+//go:build webdebug
+// +build webdebug
+
 package web
 
 import (
@@ -14,23 +17,19 @@ import (
 func ShowErrorsMiddleware(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
 	defer func() {
 		if err := recover(); err != nil {
-			const size = 4096
-			stack := make([]byte, size)
-			stack = stack[:runtime.Stack(stack, false)]
-
-			renderPrettyError(rw, req, err, stack)
+			renderPrettyError(rw, req, err, panicFrames())
 		}
 	}()
 
 	next(rw, req)
 }
 
-func renderPrettyError(rw ResponseWriter, req *Request, err interface{}, stack []byte) {
-	_, filePath, line, _ := runtime.Caller(5)
+func renderPrettyError(rw ResponseWriter, req *Request, err interface{}, stack []runtime.Frame) {
+	filePath, line := stack[0].File, stack[0].Line
 
 	data := map[string]interface{}{
 		"Error":    err,
-		"Stack":    string(stack),
+		"Stack":    formatFrames(stack),
 		"Params":   req.URL.Query(),
 		"Method":   req.Method,
 		"FilePath": filePath,
@@ -0,0 +1,10 @@
+//go:build !webdebug
+// +build !webdebug
+
+package web
+
+// ShowErrorsMiddleware renders an HTML page with the stack trace of panics, but only in binaries built with
+// the webdebug tag. In this binary it does nothing, and panics are handled like they are without it.
+func ShowErrorsMiddleware(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+	next(rw, req)
+}
@@ -1,3 +1,6 @@
+//go:build webdebug
+// +build webdebug
+
 package web
 
 import (
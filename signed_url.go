@@ -0,0 +1,89 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignURL builds the URL of the route named routeName (see Request.MappedUrlFor) and signs it with key, so it
+// can be handed out as a time-limited link. Routes behind SignedURLMiddleware only accept such URLs.
+// The signature covers the path and every query param; expiry is relative to now.
+func (r *Router) SignURL(routeName string, params map[string]string, expiry time.Duration, key []byte) (string, error) {
+	route := findNamedRoute(r, routeName)
+	if route == nil {
+		return "", fmt.Errorf("Route with name %s was not found.", routeName)
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	path, err := fillPathParams(route.path, params)
+	if err != nil {
+		return "", err
+	}
+	return signURL(key, path, time.Now().Add(expiry))
+}
+
+// SignURL is like Router.SignURL, for the router tree the request was routed through.
+func (r *Request) SignURL(routeName string, params map[string]string, expiry time.Duration, key []byte) (string, error) {
+	if r.route == nil {
+		return "", fmt.Errorf("Request to %s is not associated with any route.", r.RequestURI)
+	}
+	return r.route.router.SignURL(routeName, params, expiry, key)
+}
+
+// SignedURLMiddleware returns middleware that rejects requests whose URL wasn't signed with key (see SignURL),
+// or whose signature expired, with a 403.
+func SignedURLMiddleware(key []byte) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		if !verifySignedURL(key, req.URL) {
+			renderError(rw, req, http.StatusForbidden, DefaultForbiddenResponse)
+			return
+		}
+		next(rw, req)
+	}
+}
+
+// signURL adds "expires" and "signature" query params to rawURL, which is a path with an optional query string.
+func signURL(key []byte, rawURL string, expires time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Del("signature")
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", urlSignature(key, u.EscapedPath(), query))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// verifySignedURL reports whether u carries a valid, unexpired signature made with key.
+func verifySignedURL(key []byte, u *url.URL) bool {
+	query := u.Query()
+	signature := query.Get("signature")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if signature == "" || err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(urlSignature(key, u.EscapedPath(), query)))
+}
+
+// urlSignature signs path and every query param but "signature". url.Values.Encode sorts the params,
+// so the order they appear in the URL doesn't matter.
+func urlSignature(key []byte, path string, query url.Values) string {
+	signed := url.Values{}
+	for k, v := range query {
+		if k != "signature" {
+			signed[k] = v
+		}
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + signed.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURL(t *testing.T) {
+	key := []byte("secret")
+	router := New(Context{})
+	downloads := router.Subrouter(Context{}, "/downloads")
+	downloads.Middleware(SignedURLMiddleware(key))
+	downloads.Get("/:file_id:\\d+", (*Context).A).Named("download")
+
+	signed, err := router.SignURL("download", map[string]string{"file_id": "42"}, time.Minute, key)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "/downloads/42?expires="))
+
+	rw, req := newTestRequest("GET", signed)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/downloads/42")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	// A signature doesn't carry over to other paths.
+	rw, req = newTestRequest("GET", strings.Replace(signed, "/42?", "/43?", 1))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	rw, req = newTestRequest("GET", signed+"&extra=1")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	expired, err := downloads.SignURL("download", map[string]string{"file_id": "42"}, -time.Minute, key)
+	assert.NoError(t, err)
+	rw, req = newTestRequest("GET", expired)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Forbidden", http.StatusForbidden)
+
+	_, err = router.SignURL("nope", nil, time.Minute, key)
+	assert.Error(t, err)
+	_, err = router.SignURL("download", map[string]string{"file_id": "abc"}, time.Minute, key)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+type spaFallback struct {
+	prefix string
+	dir    http.FileSystem
+	index  string
+}
+
+// SPAFallback makes unrouted GET and HEAD requests under prefix serve the index file from dir, so that single-page
+// apps with client-side routing can be reloaded and deep-linked, and returns the router:
+//
+//	router.Static("/app/assets", http.Dir("dist/assets"), web.StaticOptions{})
+//	router.SPAFallback("/app", http.Dir("dist"), "index.html")
+//
+// Requests that don't look like page loads still get the NotFound handler: those whose last path segment has a
+// file extension (eg, a missing /app/logo.png) and those whose Accept header doesn't accept HTML (eg, API calls).
+// Requests outside of prefix aren't affected either. prefix is relative to the router's path prefix; when
+// fallbacks overlap, the longest prefix wins. The index file is served with "Cache-Control: no-cache", so clients
+// revalidate it after deploys. Fallbacks must be set up before serving requests.
+func (r *Router) SPAFallback(prefix string, dir http.FileSystem, index string) *Router {
+	rootRouter := getRootRouter(r)
+	prefix = strings.TrimSuffix(appendPath(r.pathPrefix, prefix), "/")
+	rootRouter.spaFallbacks = append(rootRouter.spaFallbacks, spaFallback{prefix: prefix, dir: dir, index: path.Clean("/" + index)})
+	sort.SliceStable(rootRouter.spaFallbacks, func(i, j int) bool {
+		return len(rootRouter.spaFallbacks[i].prefix) > len(rootRouter.spaFallbacks[j].prefix)
+	})
+	return r
+}
+
+// serveSPAFallback serves the index file of the fallback covering req, and returns false if there is none.
+func (rootRouter *Router) serveSPAFallback(rw ResponseWriter, req *Request) bool {
+	if len(rootRouter.spaFallbacks) == 0 || (req.Method != "GET" && req.Method != "HEAD") {
+		return false
+	}
+	p := req.URL.Path
+	if strings.Contains(path.Base(p), ".") || !acceptsHTML(req.Header.Get("Accept")) {
+		return false
+	}
+	for _, fallback := range rootRouter.spaFallbacks {
+		if p != fallback.prefix && !strings.HasPrefix(p, fallback.prefix+"/") {
+			continue
+		}
+		f, err := fallback.dir.Open(fallback.index)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			return false
+		}
+		rw.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(rw, req.Request, fi.Name(), fi.ModTime(), f)
+		return true
+	}
+	return false
+}
+
+// acceptsHTML returns true if accept, an Accept header, accepts text/html. An empty header accepts anything.
+func acceptsHTML(accept string) bool {
+	if accept == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, q := parseMediaRange(part)
+		if q > 0 && (mediaRange == "text/html" || mediaRange == "text/*" || mediaRange == "*/*") {
+			return true
+		}
+	}
+	return false
+}
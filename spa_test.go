@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSPAFallback(t *testing.T) {
+	dist := http.FS(fstest.MapFS{
+		"index.html":       {Data: []byte("<div id=app></div>")},
+		"admin/index.html": {Data: []byte("<div id=admin></div>")},
+	})
+
+	router := New(Context{})
+	router.NotFound(func(rw ResponseWriter, req *Request) {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte("custom not found"))
+	})
+	router.Get("/app/api/users", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("users"))
+	})
+	router.Post("/app/form", func(rw ResponseWriter, req *Request) {})
+	router.SPAFallback("/app", dist, "index.html")
+	router.Subrouter(Context{}, "/app").SPAFallback("/admin/", dist, "admin/index.html")
+
+	get := func(path, accept string) *http.Request {
+		_, req := newTestRequest("GET", path)
+		req.Header.Set("Accept", accept)
+		return req
+	}
+	for _, tt := range []struct {
+		req    *http.Request
+		body   string
+		status int
+	}{
+		{get("/app", ""), "<div id=app></div>", 200},
+		{get("/app/users/7/edit", "text/html,application/xhtml+xml,*/*;q=0.8"), "<div id=app></div>", 200},
+		{get("/app/admin/settings", "text/html"), "<div id=admin></div>", 200},
+		{get("/app/api/users", "text/html"), "users", 200},
+		{get("/app/logo.png", ""), "custom not found", 404},
+		{get("/app/users", "application/json"), "custom not found", 404},
+		{get("/application", ""), "custom not found", 404},
+		{get("/other", ""), "custom not found", 404},
+		{get("/app/form", ""), "Method Not Allowed", 405},
+	} {
+		rw, _ := newTestRequest("GET", "/")
+		router.ServeHTTP(rw, tt.req)
+		assertResponse(t, rw, tt.body, tt.status)
+	}
+
+	rw, req := newTestRequest("GET", "/app/users")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "no-cache", rw.Header().Get("Cache-Control"))
+	assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+
+	rw, req = newTestRequest("POST", "/app/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "custom not found", 404)
+}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -139,6 +141,26 @@ func BenchmarkGocraftWeb_Middleware(b *testing.B) {
 }
 
 // All middlweare/handlers don't accept context here.
+func BenchmarkGocraftWeb_Typed(b *testing.B) {
+	router := NewRouter[BenchContext]()
+	router.Middleware((*BenchContext).Middleware)
+	router.Middleware((*BenchContext).Middleware)
+	routerB := Subrouter[BenchContextB](router, "/b")
+	routerB.Middleware((*BenchContextB).Middleware)
+	routerB.Middleware((*BenchContextB).Middleware)
+	routerC := Subrouter[BenchContextC](routerB, "/c")
+	routerC.Middleware((*BenchContextC).Middleware)
+	routerC.Middleware((*BenchContextC).Middleware)
+	routerC.Get("/action", (*BenchContextC).Action)
+
+	rw, req := testRequest("GET", "/b/c/action")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(rw, req)
+	}
+}
+
 func BenchmarkGocraftWeb_Generic(b *testing.B) {
 	nextMw := func(rw ResponseWriter, r *Request, next NextMiddlewareFunc) {
 		next(rw, r)
@@ -257,3 +279,108 @@ func benchmarkRoutes(b *testing.B, handler http.Handler, requests []*http.Reques
 		reqID++
 	}
 }
+
+// Three levels of contexts with no middleware, so the cost measured is routing and context construction.
+func BenchmarkGocraftWeb_ContextChain(b *testing.B) {
+	router := New(BenchContext{})
+	routerB := router.Subrouter(BenchContextB{}, "/b")
+	routerC := routerB.Subrouter(BenchContextC{}, "/c")
+	routerC.Get("/action", (*BenchContextC).Action)
+
+	rw, req := testRequest("GET", "/b/c/action")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(rw, req)
+	}
+}
+
+// A route several subrouters down, with no settings, so the cost measured is walking the router chain, which
+// earlier versions did for every request.
+func BenchmarkGocraftWeb_DeepRoute(b *testing.B) {
+	router := New(BenchContext{})
+	sub := router
+	for i := 0; i < 5; i++ {
+		sub = sub.Subrouter(BenchContext{}, "/s")
+	}
+	sub.Get("/action", (*BenchContext).Action)
+
+	rw, req := testRequest("GET", "/s/s/s/s/s/action")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(rw, req)
+	}
+}
+
+// Settings inherited from the root router by a route several subrouters down, so the cost measured includes
+// finding the nearest router with cookie defaults and secure cookies, and that no router sets a timeout or a body
+// size limit.
+func BenchmarkGocraftWeb_InheritedSettings(b *testing.B) {
+	router := New(BenchContext{}).CookieDefaults(CookieDefaults{Domain: "example.com"}).SecureCookies(NewSecureCookies([]byte("0123456789abcdef0123456789abcdef")))
+	sub := router
+	for i := 0; i < 5; i++ {
+		sub = sub.Subrouter(BenchContext{}, "/s")
+	}
+	sub.Get("/action", (*BenchContext).Action)
+
+	rw, req := testRequest("GET", "/s/s/s/s/s/action")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(rw, req)
+	}
+}
+
+func BenchmarkGocraftWeb_Pooled(b *testing.B) {
+	router := New(BenchContext{}).PoolRequests(true)
+	router.Middleware((*BenchContext).Middleware)
+	routerB := router.Subrouter(BenchContextB{}, "/b")
+	routerB.Middleware((*BenchContextB).Middleware)
+	routerC := routerB.Subrouter(BenchContextC{}, "/c")
+	routerC.Get("/action", (*BenchContextC).Action)
+
+	rw, req := testRequest("GET", "/b/c/action")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(rw, req)
+	}
+}
+
+// A NullWriter that can take a whole body at once, like net/http's ResponseWriter does with sendfile.
+type NullReaderFromWriter struct {
+	NullWriter
+	header http.Header
+}
+
+func (w *NullReaderFromWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *NullReaderFromWriter) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(io.Discard, src)
+}
+
+// Serves a 1MB file through StaticMiddleware. The body should go through ReadFrom rather than 32KB Write calls.
+func BenchmarkGocraftWeb_StaticReadFrom(b *testing.B) {
+	dir := b.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, 1<<20), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	router := New(BenchContext{})
+	router.Middleware(StaticMiddleware(dir))
+	_, req := testRequest("GET", "/big.bin")
+
+	b.ReportAllocs()
+	b.SetBytes(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(&NullReaderFromWriter{header: http.Header{}}, req)
+	}
+}
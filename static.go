@@ -0,0 +1,134 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// StaticOptions configures Router.Static.
+type StaticOptions struct {
+	// IndexFiles are the files served for a directory, the first one that exists wins. Defaults to index.html.
+	IndexFiles []string
+
+	// ListDirectories lists the contents of directories that have no index file. When it's off (the default),
+	// such directories get a 404.
+	ListDirectories bool
+}
+
+// Static adds a GET route serving the files in dir under prefix, eg:
+//
+//	router.Static("/assets", http.Dir("./public"), web.StaticOptions{})
+//
+// serves ./public/css/site.css at /assets/css/site.css. Unlike StaticMiddleware, the files are served by a
+// route, so the router's middleware runs (and logs) for them like for any other route, and missing files get the
+// usual 404 response. The Content-Type comes from the file's extension, or from its contents if the extension is
+// unknown. Requests for a directory are redirected to the directory's path with a trailing slash, so that
+// relative links in index files and listings work.
+func (r *Router) Static(prefix string, dir http.FileSystem, opts StaticOptions) *Route {
+	if opts.IndexFiles == nil {
+		opts.IndexFiles = []string{"index.html"}
+	}
+
+	server := &staticServer{dir: dir, opts: opts}
+	return r.Get(strings.TrimSuffix(prefix, "/")+"/*path", server.serve)
+}
+
+// StaticFS is like Static, but serves the files in fsys, eg an embed.FS.
+func (r *Router) StaticFS(prefix string, fsys fs.FS, opts StaticOptions) *Route {
+	return r.Static(prefix, http.FS(fsys), opts)
+}
+
+type staticServer struct {
+	dir  http.FileSystem
+	opts StaticOptions
+}
+
+func (s *staticServer) serve(rw ResponseWriter, req *Request) {
+	name := path.Clean("/" + req.PathParams["path"])
+	f, err := s.dir.Open(name)
+	if err != nil {
+		s.renderOpenError(rw, req, err)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		s.renderOpenError(rw, req, err)
+		return
+	}
+
+	if fi.IsDir() {
+		if !strings.HasSuffix(req.URL.Path, "/") {
+			location := url.URL{Path: req.URL.Path + "/", RawQuery: req.URL.RawQuery}
+			http.Redirect(rw, req.Request, location.String(), http.StatusMovedPermanently)
+			return
+		}
+		for _, index := range s.opts.IndexFiles {
+			indexFile, err := s.dir.Open(path.Join(name, index))
+			if err != nil {
+				continue
+			}
+			defer indexFile.Close()
+			indexInfo, err := indexFile.Stat()
+			if err != nil || indexInfo.IsDir() {
+				continue
+			}
+			http.ServeContent(rw, req.Request, indexInfo.Name(), indexInfo.ModTime(), indexFile)
+			return
+		}
+		if !s.opts.ListDirectories {
+			renderError(rw, req, http.StatusNotFound, DefaultNotFoundResponse)
+			return
+		}
+		s.list(rw, req, f)
+		return
+	}
+
+	http.ServeContent(rw, req.Request, fi.Name(), fi.ModTime(), f)
+}
+
+// list writes an HTML listing of dir, with directories first and by name.
+func (s *staticServer) list(rw ResponseWriter, req *Request, dir http.File) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		renderError(rw, req, http.StatusInternalServerError, DefaultPanicResponse)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(rw, "<!doctype html>\n<title>%s</title>\n<pre>\n", html.EscapeString(req.URL.Path))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: name}
+		fmt.Fprintf(rw, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(name))
+	}
+	fmt.Fprint(rw, "</pre>\n")
+}
+
+func (s *staticServer) renderOpenError(rw ResponseWriter, req *Request, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		renderError(rw, req, http.StatusNotFound, DefaultNotFoundResponse)
+	case errors.Is(err, fs.ErrPermission):
+		renderError(rw, req, http.StatusForbidden, DefaultForbiddenResponse)
+	default:
+		renderError(rw, req, http.StatusInternalServerError, DefaultPanicResponse)
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatic(t *testing.T) {
+	files := fstest.MapFS{
+		"site.css":          {Data: []byte("body {}")},
+		"app.js":            {Data: []byte("run()")},
+		"docs/index.html":   {Data: []byte("<p>docs</p>")},
+		"docs/guide.txt":    {Data: []byte("read me")},
+		"raw/b.bin":         {Data: []byte{0, 1, 2}},
+		"raw/a dir/x.txt":   {Data: []byte("x")},
+		"raw/<script>.html": {Data: []byte("y")},
+	}
+
+	var logged []string
+	router := New(Context{})
+	router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		logged = append(logged, req.URL.Path)
+		next(rw, req)
+	})
+	router.StaticFS("/assets", files, StaticOptions{})
+	router.Static("/listed/", http.FS(files), StaticOptions{ListDirectories: true, IndexFiles: []string{"guide.txt"}})
+
+	rw, req := newTestRequest("GET", "/assets/site.css")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "body {}", http.StatusOK)
+	assert.Equal(t, "text/css; charset=utf-8", rw.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"/assets/site.css"}, logged)
+
+	rw, req = newTestRequest("GET", "/assets/app.js")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "run()", http.StatusOK)
+	assert.True(t, strings.HasSuffix(rw.Header().Get("Content-Type"), "javascript; charset=utf-8"), rw.Header().Get("Content-Type"))
+
+	rw, req = newTestRequest("HEAD", "/assets/site.css")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusOK)
+	assert.Equal(t, "7", rw.Header().Get("Content-Length"))
+
+	// Index files, and redirects for directories without a trailing slash.
+	rw, req = newTestRequest("GET", "/assets/docs/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "<p>docs</p>", http.StatusOK)
+	assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+
+	rw, req = newTestRequest("GET", "/assets/docs?v=2")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusMovedPermanently, rw.Code)
+	assert.Equal(t, "/assets/docs/?v=2", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("GET", "/assets")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusMovedPermanently, rw.Code)
+	assert.Equal(t, "/assets/", rw.Header().Get("Location"))
+
+	// Missing files and directories without an index file.
+	rw, req = newTestRequest("GET", "/assets/missing.css")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	rw, req = newTestRequest("GET", "/assets/raw/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+
+	rw, req = newTestRequest("GET", "/assets/../static_test.go")
+	router.ServeHTTP(rw, req)
+	assert.NotEqual(t, http.StatusOK, rw.Code)
+
+	rw, req = newTestRequest("POST", "/assets/site.css")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+
+	// Listings.
+	rw, req = newTestRequest("GET", "/listed/raw/")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+	body := rw.Body.String()
+	assert.True(t, strings.Index(body, `<a href="a%20dir/">a dir/</a>`) < strings.Index(body, `<a href="b.bin">b.bin</a>`), body)
+	assert.True(t, strings.Contains(body, `<a href="%3Cscript%3E.html">&lt;script&gt;.html</a>`), body)
+
+	rw, req = newTestRequest("GET", "/listed/docs/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "read me", http.StatusOK)
+}
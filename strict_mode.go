@@ -0,0 +1,88 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// strictState tracks a response in debug mode so misuse of the ResponseWriter is reported with the
+// offending call site, instead of net/http's bare "superfluous response.WriteHeader call" log line.
+type strictState struct {
+	path     string
+	hijacked bool
+	finished bool
+
+	// depth is how many middleware (and handler) frames are running, and completed the depth of the frame whose
+	// next() returned with the response written, or 0. Writes from that frame would be appended to a complete
+	// response.
+	depth     int
+	completed int
+}
+
+// errResponseCompleted is returned for writes dropped because next() already completed the response.
+var errResponseCompleted = errors.New("web: the response was completed by next(); the write was dropped")
+
+// enterFrame records that a middleware or handler frame is starting.
+func (s *strictState) enterFrame() {
+	s.depth++
+}
+
+// exitFrame records that a frame returned, to its caller's frame, with the response written or not.
+func (s *strictState) exitFrame(written bool) {
+	s.depth--
+	if written && s.depth > 0 {
+		s.completed = s.depth
+	}
+}
+
+// checkWriteHeader reports problems with a WriteHeader call. It returns false if the call must be dropped.
+func (w *appResponseWriter) checkWriteHeader(statusCode int) bool {
+	switch {
+	case w.strict().hijacked:
+		w.strictViolation(fmt.Sprintf("WriteHeader(%d) called after the connection was hijacked", statusCode))
+		return false
+	case w.statusCode != 0:
+		w.strictViolation(fmt.Sprintf("WriteHeader(%d) called after status %d was already written", statusCode, w.statusCode))
+		return false
+	case w.strict().finished:
+		w.strictViolation(fmt.Sprintf("WriteHeader(%d) called after the request finished", statusCode))
+	}
+	return true
+}
+
+// checkWrite reports problems with a Write call. It returns an error if the call must be dropped.
+func (w *appResponseWriter) checkWrite() error {
+	switch {
+	case w.strict().hijacked:
+		w.strictViolation("Write called after the connection was hijacked")
+		return http.ErrHijacked
+	case w.strict().finished:
+		w.strictViolation("Write called after the request finished")
+	case w.strict().completed > 0 && w.strict().depth == w.strict().completed:
+		w.strictViolation("Write called by middleware after next() completed the response")
+		return errResponseCompleted
+	}
+	return nil
+}
+
+func (w *appResponseWriter) strictViolation(msg string) {
+	Logger.Printf("[strict] '%s' %s at %s\n", w.strict().path, msg, writeCaller())
+}
+
+// writeCaller returns the file:line of the application code that called into the ResponseWriter.
+func writeCaller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) && !strings.HasPrefix(frame.Function, "fmt.") && !strings.HasPrefix(frame.Function, "net/http.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,117 @@
+//go:build webdebug
+// +build webdebug
+
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStrictDoubleWriteHeader(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{}).Debug(true)
+	router.Middleware(func(w ResponseWriter, r *Request, next NextMiddlewareFunc) {
+		next(w, r)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	router.Get("/action", (*Context).A)
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+
+	logged := buf.String()
+	if !strings.Contains(logged, "[strict] '/action' WriteHeader(418) called after status 200 was already written at ") ||
+		!strings.Contains(logged, "strict_mode_test.go:") {
+		t.Errorf("Expected a strict violation to be logged but got: %s", logged)
+	}
+}
+
+func TestStrictWriteAfterFinish(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	var leaked ResponseWriter
+	router := New(Context{}).Debug(true)
+	router.Get("/action", func(w ResponseWriter, r *Request) {
+		leaked = w
+	})
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	fmt.Fprintf(leaked, "too late")
+
+	if !strings.Contains(buf.String(), "[strict] '/action' Write called after the request finished at ") {
+		t.Errorf("Expected a strict violation to be logged but got: %s", buf.String())
+	}
+}
+
+type headeredHijackableResponse struct {
+	hijackableResponse
+	header http.Header
+}
+
+func (h *headeredHijackableResponse) Header() http.Header {
+	return h.header
+}
+
+func TestStrictWriteAfterHijack(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	router := New(Context{}).Debug(true)
+	router.Get("/action", func(w ResponseWriter, r *Request) {
+		w.Hijack()
+		_, err := w.Write([]byte("hi"))
+		if err != http.ErrHijacked {
+			t.Errorf("Expected ErrHijacked but got %v", err)
+		}
+	})
+
+	req, _ := http.NewRequest("GET", "/action", nil)
+	router.ServeHTTP(&headeredHijackableResponse{header: http.Header{}}, req)
+
+	if !strings.Contains(buf.String(), "[strict] '/action' Write called after the connection was hijacked at ") {
+		t.Errorf("Expected a strict violation to be logged but got: %s", buf.String())
+	}
+}
+
+func TestStrictWriteAfterNextCompleted(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = log.New(&buf, "", 0)
+
+	var writeErr error
+	router := New(Context{}).Debug(true)
+	router.Middleware(func(w ResponseWriter, r *Request, next NextMiddlewareFunc) {
+		next(w, r)
+		_, writeErr = w.Write([]byte(" footer"))
+	})
+	router.Get("/action", (*Context).A)
+	router.Get("/empty", func(w ResponseWriter, r *Request) {})
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", 200)
+	if writeErr != errResponseCompleted {
+		t.Errorf("Expected the write to be dropped but got %v", writeErr)
+	}
+	if !strings.Contains(buf.String(), "[strict] '/action' Write called by middleware after next() completed the response at ") {
+		t.Errorf("Expected a strict violation to be logged but got: %s", buf.String())
+	}
+
+	// Middleware may respond if nothing downstream did.
+	buf.Reset()
+	rw, req = newTestRequest("GET", "/empty")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "footer", 200)
+	if writeErr != nil || strings.Contains(buf.String(), "[strict]") {
+		t.Errorf("Expected no strict violation but got %v: %s", writeErr, buf.String())
+	}
+}
@@ -0,0 +1,254 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultTimeoutResponse is the default text rendered for requests that run out of time. See Router.Timeout.
+var DefaultTimeoutResponse = "Timeout"
+
+// Timeout limits how long the router's routes may take to respond, and returns the router. Their requests' context
+// is cancelled after d, so that slow calls to databases and other services give up, and the client gets a 503
+// with DefaultTimeoutResponse. The nearest router's Timeout applies, and Route.Timeout overrides it:
+//
+//	router.Timeout(5 * time.Second)
+//	router.Post("/reports", (*Context).Report).Timeout(time.Minute)
+//
+// The time starts once the request is routed, so it covers the routers' middleware, the route's middleware and
+// the handler, but not the root router's middleware. They run in a goroutine of their own, writing to a buffer
+// that's only copied to the client if they finish in time; after the deadline, their writes fail with
+// http.ErrHandlerTimeout. So streaming (Flush) and Hijack don't work under a Timeout.
+func (r *Router) Timeout(d time.Duration) *Router {
+	r.timeout = d
+	r.tables.settingsChanged()
+	return r
+}
+
+// Timeout overrides the router's Timeout for this route, and returns the route. A negative d turns it off.
+func (r *Route) Timeout(d time.Duration) *Route {
+	r.checkConfigurable()
+	r.timeout = d
+	r.router.tables.settingsChanged()
+	return r
+}
+
+// timeoutFor returns the timeout of route's requests, or 0 if they have none.
+func timeoutFor(route *Route) time.Duration {
+	d := route.timeout
+	for i := len(route.router.chain) - 1; d == 0 && i >= 0; i-- {
+		d = route.router.chain[i].timeout
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+type recoveredPanic struct {
+	value  interface{}
+	frames []runtime.Frame
+}
+
+// serveWithTimeout runs next with a timeoutWriter and a copy of req whose context is cancelled after timeout,
+// and copies the response to rw if it finishes in time. Otherwise, next is abandoned: it keeps running, but
+// the closure is no longer recycled, as next's goroutine still uses it.
+func (closure *middlewareClosure) serveWithTimeout(timeout time.Duration, rw ResponseWriter, req *Request, next func(ResponseWriter, *Request)) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	timedReq := *req
+	if req.more != nil {
+		more := *req.more
+		timedReq.more = &more
+	}
+	timedReq.SetContext(ctx)
+	tw := &timeoutWriter{ctx: ctx, header: rw.Header().Clone(), settings: req.settings}
+
+	done := make(chan *recoveredPanic, 1)
+	go func() {
+		var p *recoveredPanic
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				p = &recoveredPanic{value: recovered, frames: panicFrames()}
+			}
+			done <- p
+		}()
+		next(tw, &timedReq)
+	}()
+
+	select {
+	case p := <-done:
+		if p != nil {
+			closure.RootRouter.handlePanic(&closure.appResponseWriter, req, p.value, p.frames)
+			return
+		}
+		if tw.inTime() {
+			if timedReq.more != nil {
+				req.extras().bodySpool, req.more.afterSuccess = timedReq.more.bodySpool, timedReq.more.afterSuccess
+			}
+			tw.copyTo(rw)
+			return
+		}
+		// It finished, but tried to write after the deadline.
+	case <-ctx.Done():
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.mu.Unlock()
+		closure.Request.extras().abandoned = true
+		go reportAbandonedPanic(done, fmt.Sprint(req.URL))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		renderError(rw, req, http.StatusServiceUnavailable, DefaultTimeoutResponse)
+	}
+}
+
+// reportAbandonedPanic waits for an abandoned request to finish, and reports it to PanicHandler if it panicked.
+func reportAbandonedPanic(done <-chan *recoveredPanic, url string) {
+	p := <-done
+	if p == nil {
+		return
+	}
+	if _, isCoded := p.value.(*CodedError); isCoded || isDisconnectError(p.value) {
+		return
+	}
+	PanicHandler.Panic(url, p.value, formatFrames(p.frames))
+}
+
+// timeoutWriter buffers a response until it's known to be in time. It's safe to use from the goroutine of the
+// request while the request's own goroutine times it out.
+type timeoutWriter struct {
+	ctx         context.Context
+	mu          sync.Mutex
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	beforeWrite []func(http.Header)
+	settings    *routeSettings
+	timedOut    bool // set once anything is written after ctx is done
+}
+
+// inTime returns false if the response is too late. The caller must hold mu, or know the request is done.
+func (w *timeoutWriter) inTime() bool {
+	if !w.timedOut && w.ctx.Err() != nil {
+		w.timedOut = true
+	}
+	return !w.timedOut
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.inTime() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.writeHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.inTime() {
+		w.writeHeader(status)
+	}
+}
+
+func (w *timeoutWriter) writeHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	callbacks := w.beforeWrite
+	w.beforeWrite = nil
+	for _, fn := range callbacks {
+		fn(w.header)
+	}
+	w.status = status
+}
+
+// Flush does nothing: the response is only sent once it's complete.
+func (w *timeoutWriter) Flush() {}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("web: requests with a Timeout can't be hijacked")
+}
+
+func (w *timeoutWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (w *timeoutWriter) StatusCode() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Written() bool {
+	return w.StatusCode() != 0
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) BeforeWrite(fn func(http.Header)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.beforeWrite = append(w.beforeWrite, fn)
+	}
+}
+
+func (w *timeoutWriter) SetCookie(c Cookie) error {
+	if w.Written() {
+		return ErrHeadersWritten
+	}
+	cookie, err := c.httpCookie(w.settings.cookieDefaults)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+func (w *timeoutWriter) SetSecureCookie(c Cookie) error {
+	if w.settings.secureCookies == nil {
+		return errNoSecureCookies
+	}
+	c.Value = w.settings.secureCookies.Encode(c.Name, c.Value, secureCookieExpiry(c))
+	return w.SetCookie(c)
+}
+
+func (w *timeoutWriter) DeleteCookie(name string) error {
+	return w.SetCookie(Cookie{Name: name, MaxAge: -1, Expires: time.Unix(0, 0)})
+}
+
+// copyTo writes the buffered response to rw. It must only be called once the request's goroutine is done.
+func (w *timeoutWriter) copyTo(rw ResponseWriter) {
+	header := rw.Header()
+	for k := range header {
+		if _, ok := w.header[k]; !ok {
+			delete(header, k)
+		}
+	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.status != 0 {
+		rw.WriteHeader(w.status)
+		rw.Write(w.body.Bytes())
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	writeErrs := make(chan error, 1)
+	router := New(Context{}).PoolRequests(true)
+	router.Timeout(20 * time.Millisecond)
+	router.Get("/slow", func(rw ResponseWriter, req *Request) {
+		<-req.Context().Done()
+		_, err := rw.Write([]byte("too late"))
+		writeErrs <- err
+	})
+	router.Get("/fast", func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("X-Fast", "yes")
+		SetCookie(rw, Cookie{Name: "seen", Value: "1"})
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("fast"))
+	})
+	router.Get("/report", func(rw ResponseWriter, req *Request) {
+		time.Sleep(40 * time.Millisecond)
+		rw.Write([]byte("report"))
+	}).Timeout(-1)
+	api := router.Subrouter(Context{}, "/api").Timeout(time.Minute)
+	api.Get("/slow", func(rw ResponseWriter, req *Request) {
+		time.Sleep(40 * time.Millisecond)
+		rw.Write([]byte("api"))
+	})
+
+	rw, req := newTestRequest("GET", "/slow")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Timeout", http.StatusServiceUnavailable)
+	assert.Equal(t, http.ErrHandlerTimeout, <-writeErrs)
+
+	rw, req = newTestRequest("GET", "/fast")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "fast", http.StatusCreated)
+	assert.Equal(t, "yes", rw.Header().Get("X-Fast"))
+	assert.Equal(t, "seen", rw.Result().Cookies()[0].Name)
+
+	rw, req = newTestRequest("GET", "/report")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "report", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/api/slow")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "api", http.StatusOK)
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	reporter := &errCapturingReporter{}
+	oldHandler := PanicHandler
+	PanicHandler = reporter
+	defer func() {
+		PanicHandler = oldHandler
+	}()
+
+	router := New(Context{}).Timeout(time.Second)
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		rw.Write([]byte("partial"))
+		panic("boom")
+	})
+	rw, req := newTestRequest("GET", "/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Application Error", http.StatusInternalServerError)
+	assert.Equal(t, []interface{}{"boom"}, reporter.errs)
+}
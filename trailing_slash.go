@@ -0,0 +1,78 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TrailingSlashMode is how a router treats request paths whose trailing slash doesn't match their route's path.
+// See Router.TrailingSlash.
+type TrailingSlashMode int
+
+const (
+	// TrailingSlashLenient routes /users/ and /users to the same route, whichever of them it was registered with.
+	TrailingSlashLenient TrailingSlashMode = iota
+	// TrailingSlashStrict only routes a request if its path ends with a slash exactly when its route's path does.
+	// Other requests are not found.
+	TrailingSlashStrict
+	// TrailingSlashRedirect redirects requests to the path with the trailing slash of their route's path, eg
+	// /users/ to /users: with a 301 for GET and HEAD requests, and a 308, which keeps the method and the body,
+	// for others.
+	TrailingSlashRedirect
+)
+
+// TrailingSlash sets how the router treats trailing slashes and returns the router. Routes can't differ only by
+// their trailing slash, so a route's path is the canonical form of the paths it matches. The default is
+// TrailingSlashLenient. Note that only the root router can configure trailing slashes.
+func (r *Router) TrailingSlash(mode TrailingSlashMode) *Router {
+	if r.parent != nil {
+		panic("You can only configure trailing slashes on the root router.")
+	}
+	r.trailingSlash = mode
+	return r
+}
+
+// trailingSlashMismatch reports whether path's trailing slash differs from that of route's path.
+func trailingSlashMismatch(route *Route, path string) bool {
+	if route.path == "/" || path == "/" {
+		return false
+	}
+	return strings.HasSuffix(route.path, "/") != strings.HasSuffix(path, "/")
+}
+
+// canonicalLocation returns where to redirect req, whose route is route, to fix the trailing slash of its path if
+// the router has TrailingSlashRedirect, and the case of its literal segments if it has CanonicalCaseRedirects. It
+// returns false if req needs no redirect.
+func (rootRouter *Router) canonicalLocation(req *Request, route *Route) (string, bool) {
+	// Leading slashes are collapsed so that eg //evil.example/ can't become a protocol-relative redirect.
+	location := "/" + strings.TrimLeft(req.URL.EscapedPath(), "/")
+	changed := false
+	if rootRouter.canonicalCaseRedirects {
+		location, changed = fixCase(location, route.path)
+	}
+	if rootRouter.trailingSlash == TrailingSlashRedirect && trailingSlashMismatch(route, req.URL.Path) {
+		if strings.HasSuffix(route.path, "/") {
+			location += "/"
+		} else {
+			location = strings.TrimSuffix(location, "/")
+		}
+		changed = true
+	}
+	if !changed {
+		return "", false
+	}
+	if req.URL.RawQuery != "" {
+		location += "?" + req.URL.RawQuery
+	}
+	return location, true
+}
+
+// redirectCanonical redirects req to location: with a 301 for GET and HEAD requests, and a 308 for others.
+func redirectCanonical(rw ResponseWriter, req *Request, location string) {
+	code := http.StatusPermanentRedirect
+	if req.Method == string(httpMethodGet) || req.Method == string(httpMethodHead) {
+		code = http.StatusMovedPermanently
+	}
+	rw.Header().Set("Location", location)
+	rw.WriteHeader(code)
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrailingSlashLenient(t *testing.T) {
+	router := New(Context{})
+	router.Get("/users", (*Context).A)
+	router.Get("/posts/", (*Context).Z)
+
+	for _, path := range []string{"/users", "/users/"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "context-A", http.StatusOK)
+	}
+	for _, path := range []string{"/posts", "/posts/"} {
+		rw, req := newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "context-Z", http.StatusOK)
+	}
+}
+
+func TestTrailingSlashStrict(t *testing.T) {
+	router := New(Context{}).TrailingSlash(TrailingSlashStrict)
+	router.Get("/", func(rw ResponseWriter, req *Request) { fmt.Fprint(rw, "root") })
+	router.Get("/users", (*Context).A)
+	router.Post("/users", (*Context).A)
+	router.Get("/posts/", (*Context).Z)
+
+	rw, req := newTestRequest("GET", "/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "root", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	rw, req = newTestRequest("GET", "/posts/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-Z", http.StatusOK)
+
+	for _, path := range []string{"/users/", "/posts"} {
+		rw, req = newTestRequest("GET", path)
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "Not Found", http.StatusNotFound)
+	}
+
+	rw, req = newTestRequest("DELETE", "/users/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+}
+
+func TestTrailingSlashRedirect(t *testing.T) {
+	router := New(Context{}).TrailingSlash(TrailingSlashRedirect)
+	router.Get("/users", (*Context).A)
+	router.Post("/users", (*Context).A)
+	router.Get("/posts/:id/", (*Context).Z)
+
+	rw, req := newTestRequest("GET", "/users/?page=2")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusMovedPermanently)
+	assert.Equal(t, "/users?page=2", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("POST", "/users/")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusPermanentRedirect)
+	assert.Equal(t, "/users", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("GET", "/posts/caf%C3%A9")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusMovedPermanently)
+	assert.Equal(t, "/posts/caf%C3%A9/", rw.Header().Get("Location"))
+
+	rw, req = newTestRequest("GET", "/users")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	assert.Panics(t, func() {
+		router.Subrouter(Context{}, "/admin").TrailingSlash(TrailingSlashStrict)
+	})
+}
@@ -1,18 +1,26 @@
 package web
 
 import (
+	"fmt"
+	"net/url"
 	"regexp"
+	"regexp/syntax"
 	"strings"
 )
 
 type pathNode struct {
 
-	// Given the next segment s, if edges[s] exists, then we'll look there first.
-	edges map[string]*pathNode
+	// Given the next segment s, if edges has s, then we'll look there first. Most nodes have only a few edges,
+	// which are kept in a slice; nodes with more than maxSliceEdges use edgeMap instead. See child.
+	edges   []pathEdge
+	edgeMap map[string]*pathNode
 
 	// If set, failure to match on edges will match on wildcard
 	wildcard *pathNode
 
+	// If set, failure to match on wildcard will match on catchAll, which takes the remaining segments
+	catchAll *pathNode
+
 	// If set, and we have nothing left to match, then we match on this node
 	leaves []*pathLeaf
 }
@@ -20,63 +28,360 @@ type pathNode struct {
 // pathLeaf represents a leaf path segment that corresponds to a single route.
 // For the route /admin/forums/:forum_id:\d.*/suggestions/:suggestion_id:\d.*
 // We'd have wildcards = ["forum_id", "suggestion_id"]
-//         and regexps = [/\d.*/, /\d.*/]
-// For the route /admin/forums/:forum_id/suggestions/:suggestion_id:\d.*
+//         and constraints = [/\d.*/, /\d.*/]
+// For the route /admin/forums/:forum_id/suggestions/:suggestion_id:int
 // We'd have wildcards = ["forum_id", "suggestion_id"]
-//         and regexps = [nil, /\d.*/]
+//         and constraints = [nil, int]
 // For the route /admin/forums/:forum_id/suggestions/:suggestion_id
 // We'd have wildcards = ["forum_id", "suggestion_id"]
-//         and regexps = nil
+//         and constraints = nil
 type pathLeaf struct {
 	// names of wildcards that lead to this leaf. eg, ["category_id"] for the wildcard ":category_id"
 	wildcards []string
 
-	// regexps corresponding to wildcards. If a segment has regexp contraint, its entry will be nil.
-	// If the route has no regexp contraints on any segments, then regexps will be nil.
-	regexps []*regexp.Regexp
+	// constraints corresponding to wildcards (a regexp or a named constraint). If a segment has no constraint,
+	// its entry will be nil. If the route has no constraints on any segments, then constraints will be nil.
+	constraints []*paramConstraint
+
+	// If set, the leaf only matches requests for this host. See Router.Host.
+	host *hostPattern
 
 	// Pointer back to the route
 	route *Route
 }
 
+type pathEdge struct {
+	segment string
+	node    *pathNode
+}
+
+// maxSliceEdges is the number of edges above which a node switches from scanning a slice to a map.
+const maxSliceEdges = 8
+
 func newPathNode() *pathNode {
-	return &pathNode{edges: make(map[string]*pathNode)}
+	return &pathNode{}
+}
+
+// child returns the node the edge for seg leads to, or nil.
+func (pn *pathNode) child(seg string) *pathNode {
+	if pn.edgeMap != nil {
+		return pn.edgeMap[seg]
+	}
+	for i := range pn.edges {
+		if pn.edges[i].segment == seg {
+			return pn.edges[i].node
+		}
+	}
+	return nil
+}
+
+// addChild adds an edge for seg to a new node and returns the node.
+func (pn *pathNode) addChild(seg string) *pathNode {
+	child := newPathNode()
+	if pn.edgeMap == nil && len(pn.edges) < maxSliceEdges {
+		pn.edges = append(pn.edges, pathEdge{segment: seg, node: child})
+		return child
+	}
+	if pn.edgeMap == nil {
+		pn.edgeMap = make(map[string]*pathNode, len(pn.edges)+1)
+		for _, edge := range pn.edges {
+			pn.edgeMap[edge.segment] = edge.node
+		}
+		pn.edges = nil
+	}
+	pn.edgeMap[seg] = child
+	return child
+}
+
+// setChild points the existing edge for seg at node.
+func (pn *pathNode) setChild(seg string, node *pathNode) {
+	if pn.edgeMap != nil {
+		pn.edgeMap[seg] = node
+		return
+	}
+	for i := range pn.edges {
+		if pn.edges[i].segment == seg {
+			pn.edges[i].node = node
+			return
+		}
+	}
+}
+
+// clone returns a copy of pn that shares its children.
+func (pn *pathNode) clone() *pathNode {
+	c := &pathNode{wildcard: pn.wildcard, catchAll: pn.catchAll, leaves: pn.leaves[:len(pn.leaves):len(pn.leaves)]}
+	if pn.edgeMap != nil {
+		c.edgeMap = make(map[string]*pathNode, len(pn.edgeMap))
+		for seg, node := range pn.edgeMap {
+			c.edgeMap[seg] = node
+		}
+	} else {
+		c.edges = append([]pathEdge(nil), pn.edges...)
+	}
+	return c
 }
 
-func (pn *pathNode) add(path string, route *Route) {
-	pn.addInternal(splitPath(path), route, nil, nil)
+// add adds the route for path to the tree. It returns an error, and leaves the tree unchanged, if path has an
+// invalid wildcard or if the route could never be matched because an earlier route takes all of its requests.
+// If cow is true, pn must be a clone, and every other node on the way to the route is copied before it is
+// changed, so trees sharing those nodes are left as they were.
+// A path with optional groups (see expandOptional) adds the route once for each path it stands for.
+func (pn *pathNode) add(path string, route *Route, cow bool) error {
+	paths, err := expandOptional(path)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 1 {
+		return pn.addInternal(splitPath(path), route, nil, nil, cow)
+	}
+	return pn.update(func(c *pathNode) error {
+		for _, p := range paths {
+			if err := c.addInternal(splitPath(p), route, nil, nil, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// update calls fn with a clone of pn, whose nodes fn must copy before changing them (as if cow were true), and
+// makes pn the clone if fn succeeds. That way changes to several paths apply all at once or not at all.
+func (pn *pathNode) update(fn func(c *pathNode) error) error {
+	c := pn.clone()
+	if err := fn(c); err != nil {
+		return err
+	}
+	*pn = *c
+	return nil
+}
+
+// expandOptional returns the paths a pattern with optional groups stands for, longest first. An optional group
+// starts with "(/" and ends the path or its enclosing group: /articles/:year(/:month(/:day)) stands for
+// /articles/:year/:month/:day, /articles/:year/:month and /articles/:year. Other parentheses belong to wildcard
+// regexps. A path without optional groups stands for itself.
+func expandOptional(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "(/") {
+		return []string{pattern}, nil
+	}
+
+	var path []byte
+	var cuts []int // the length of path where each group starts
+	depth, regexpDepth := 0, 0
+	closed := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == ')' && regexpDepth == 0 {
+			if depth == 0 {
+				return nil, fmt.Errorf("an optional group has an unbalanced ')'")
+			}
+			depth--
+			closed = true
+			continue
+		}
+		if closed {
+			return nil, fmt.Errorf("an optional group must be at the end of the path or of its enclosing group")
+		}
+		switch {
+		case c == '(' && i+1 < len(pattern) && pattern[i+1] == '/':
+			cuts = append(cuts, len(path))
+			depth++
+			continue
+		case c == '(':
+			regexpDepth++
+		case c == ')':
+			regexpDepth--
+		}
+		path = append(path, c)
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("an optional group is missing its ')'")
+	}
+
+	full := string(path)
+	paths := []string{full}
+	for i := len(cuts) - 1; i >= 0; i-- {
+		if cuts[i] == 0 {
+			paths = append(paths, "/")
+		} else {
+			paths = append(paths, full[:cuts[i]])
+		}
+	}
+	return paths, nil
 }
 
-func (pn *pathNode) addInternal(segments []string, route *Route, wildcards []string, regexps []*regexp.Regexp) {
+func (pn *pathNode) addInternal(segments []string, route *Route, wildcards []string, constraints []*paramConstraint, cow bool) error {
 	if len(segments) == 0 {
-		allNilRegexps := true
-		for _, r := range regexps {
-			if r != nil {
-				allNilRegexps = false
+		allNilConstraints := true
+		for _, c := range constraints {
+			if c != nil {
+				allNilConstraints = false
 				break
 			}
 		}
-		if allNilRegexps {
-			regexps = nil
+		if allNilConstraints {
+			constraints = nil
+		}
+		leaf := &pathLeaf{route: route, wildcards: wildcards, constraints: constraints, host: route.host}
+		leaves, err := insertLeaf(pn.leaves, leaf)
+		if err != nil {
+			return err
+		}
+		pn.leaves = leaves
+		return nil
+	}
+
+	seg := segments[0]
+	if ca, caName := isCatchAll(seg); ca {
+		if caName == "" {
+			return fmt.Errorf("the catch-all segment '%s' has no name", seg)
+		}
+		if len(segments) > 1 {
+			return fmt.Errorf("the catch-all *%s must be the last segment", caName)
+		}
+		if containsString(wildcards, caName) {
+			return fmt.Errorf("the wildcard :%s is used more than once", caName)
+		}
+		if pn.catchAll == nil {
+			pn.catchAll = newPathNode()
+		} else if cow {
+			pn.catchAll = pn.catchAll.clone()
+		}
+		return pn.catchAll.addInternal(nil, route, append(wildcards[:len(wildcards):len(wildcards)], caName), append(constraints[:len(constraints):len(constraints)], nil), cow)
+	}
+
+	wc, wcName, wcRegexpStr := isWildcard(seg)
+	if wc {
+		if wcName == "" {
+			return fmt.Errorf("the wildcard segment '%s' has no name", seg)
+		}
+		for _, name := range wildcards {
+			if name == wcName {
+				return fmt.Errorf("the wildcard :%s is used more than once", wcName)
+			}
+		}
+		constraint, err := parseParamConstraint(wcRegexpStr)
+		if err != nil {
+			return fmt.Errorf("the wildcard :%s has an invalid regexp: %v", wcName, err)
+		}
+		if pn.wildcard == nil {
+			pn.wildcard = newPathNode()
+		} else if cow {
+			pn.wildcard = pn.wildcard.clone()
+		}
+		return pn.wildcard.addInternal(segments[1:], route, append(wildcards[:len(wildcards):len(wildcards)], wcName), append(constraints[:len(constraints):len(constraints)], constraint), cow)
+	}
+
+	subPn := pn.child(seg)
+	if subPn == nil {
+		subPn = pn.addChild(seg)
+	} else if cow {
+		subPn = subPn.clone()
+		pn.setChild(seg, subPn)
+	}
+	return subPn.addInternal(segments[1:], route, wildcards, constraints, cow)
+}
+
+// insertLeaf returns a copy of leaves with leaf added after the leaves with hosts at least as specific as its
+// own (see hostPattern.specificity), so leaves are tried from the most specific host to those without a host,
+// and in the order they were registered otherwise. It returns an error if leaf could never be matched.
+func insertLeaf(leaves []*pathLeaf, leaf *pathLeaf) ([]*pathLeaf, error) {
+	for _, name := range leaf.host.wildcards() {
+		if containsString(leaf.wildcards, name) {
+			return nil, fmt.Errorf("the wildcard :%s is used more than once", name)
+		}
+	}
+	i := 0
+	for i < len(leaves) && leaves[i].host.specificity() >= leaf.host.specificity() {
+		if leaves[i].shadows(leaf) {
+			return nil, fmt.Errorf("it is unreachable, because %s %s (registered earlier) matches every request it would", leaves[i].route.method, leaves[i].route.path)
+		}
+		i++
+	}
+	inserted := make([]*pathLeaf, 0, len(leaves)+1)
+	inserted = append(inserted, leaves[:i]...)
+	inserted = append(inserted, leaf)
+	return append(inserted, leaves[i:]...), nil
+}
+
+// setHost replaces the leaves for route with ones constrained to host. If cow is true, pn must be a clone, and
+// the nodes on the way to the leaves are copied like in add.
+func (pn *pathNode) setHost(route *Route, host *hostPattern, cow bool) error {
+	paths, err := expandOptional(route.path)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 1 {
+		return pn.setHostAt(splitPath(route.path), route, host, cow)
+	}
+	return pn.update(func(c *pathNode) error {
+		for _, p := range paths {
+			if err := c.setHostAt(splitPath(p), route, host, true); err != nil {
+				return err
+			}
 		}
-		pn.leaves = append(pn.leaves, &pathLeaf{route: route, wildcards: wildcards, regexps: regexps})
-	} else { // len(segments) >= 1
-		seg := segments[0]
-		wc, wcName, wcRegexpStr := isWildcard(seg)
-		if wc {
-			if pn.wildcard == nil {
-				pn.wildcard = newPathNode()
+		return nil
+	})
+}
+
+// setHostAt replaces the leaf for route at the end of segments with one constrained to host.
+func (pn *pathNode) setHostAt(segments []string, route *Route, host *hostPattern, cow bool) error {
+	for _, seg := range segments {
+		var next *pathNode
+		if ca, _ := isCatchAll(seg); ca {
+			next = pn.catchAll
+			if cow {
+				next = next.clone()
+				pn.catchAll = next
+			}
+		} else if wc, _, _ := isWildcard(seg); wc {
+			next = pn.wildcard
+			if cow {
+				next = next.clone()
+				pn.wildcard = next
 			}
-			pn.wildcard.addInternal(segments[1:], route, append(wildcards, wcName), append(regexps, compileRegexp(wcRegexpStr)))
 		} else {
-			subPn, ok := pn.edges[seg]
-			if !ok {
-				subPn = newPathNode()
-				pn.edges[seg] = subPn
+			next = pn.child(seg)
+			if cow {
+				next = next.clone()
+				pn.setChild(seg, next)
 			}
-			subPn.addInternal(segments[1:], route, wildcards, regexps)
 		}
+		pn = next
+	}
+	for i, leaf := range pn.leaves {
+		if leaf.route != route {
+			continue
+		}
+		hosted := *leaf
+		hosted.host = host
+		others := append(pn.leaves[:i:i], pn.leaves[i+1:]...)
+		leaves, err := insertLeaf(others, &hosted)
+		if err != nil {
+			return err
+		}
+		pn.leaves = leaves
+		return nil
 	}
+	return fmt.Errorf("bug: the route is missing from the tree")
+}
+
+// shadows returns true if leaf matches every request other would match. Both leaves must be at the same node.
+func (leaf *pathLeaf) shadows(other *pathLeaf) bool {
+	if leaf.host != nil && (other.host == nil || leaf.host.pattern != other.host.pattern) {
+		return false
+	}
+	if leaf.constraints == nil {
+		return true
+	}
+	if other.constraints == nil {
+		return false
+	}
+	for i, c := range leaf.constraints {
+		if c != nil && (other.constraints[i] == nil || c.source != other.constraints[i].source) {
+			return false
+		}
+	}
+	return true
 }
 
 func (pn *pathNode) Match(path string) (leaf *pathLeaf, wildcards map[string]string) {
@@ -86,62 +391,148 @@ func (pn *pathNode) Match(path string) (leaf *pathLeaf, wildcards map[string]str
 		return nil, nil
 	}
 
-	return pn.match(splitPath(path), nil)
+	return pn.match(splitPath(path), nil, "")
+}
+
+// requestSegments returns the decoded segments of u's path, or false if the path can't be routed. Segments are
+// split on the escaped path and then decoded exactly once, so an encoded slash (%2F) stays inside its segment.
+func requestSegments(u *url.URL) ([]string, bool) {
+	if u.RawPath == "" {
+		// The path has no ambiguous escapes, so splitting the decoded path gives the same segments.
+		if len(u.Path) == 0 || u.Path[0] != '/' {
+			return nil, false
+		}
+		return splitPath(u.Path), true
+	}
+
+	rawPath := u.EscapedPath()
+	if len(rawPath) == 0 || rawPath[0] != '/' {
+		return nil, false
+	}
+	segments := splitPath(rawPath)
+	for i, seg := range segments {
+		decoded, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, false
+		}
+		segments[i] = decoded
+	}
+	return segments, true
 }
 
 // Segments is like ["admin", "users"] representing "/admin/users"
 // wildcardValues are the actual values accumulated when we match on a wildcard.
-func (pn *pathNode) match(segments []string, wildcardValues []string) (leaf *pathLeaf, wildcardMap map[string]string) {
+// host is the request's host (see requestHost). "" only matches leaves without a host.
+func (pn *pathNode) match(segments []string, wildcardValues []string, host string) (leaf *pathLeaf, wildcardMap map[string]string) {
 	// Handle leaf nodes:
 	if len(segments) == 0 {
-		for _, leaf := range pn.leaves {
-			if leaf.match(wildcardValues) {
-				return leaf, makeWildcardMap(leaf, wildcardValues)
-			}
+		if leaf, wildcardMap = pn.matchLeaves(wildcardValues, host); leaf != nil {
+			return leaf, wildcardMap
 		}
-		return nil, nil
+		return pn.matchCatchAll(nil, wildcardValues, host)
 	}
 
-	var seg string
-	seg, segments = segments[0], segments[1:]
+	seg, rest := segments[0], segments[1:]
 
-	subPn, ok := pn.edges[seg]
-	if ok {
-		leaf, wildcardMap = subPn.match(segments, wildcardValues)
+	if subPn := pn.child(seg); subPn != nil {
+		leaf, wildcardMap = subPn.match(rest, wildcardValues, host)
 	}
 
 	if leaf == nil && pn.wildcard != nil {
-		leaf, wildcardMap = pn.wildcard.match(segments, append(wildcardValues, seg))
+		leaf, wildcardMap = pn.wildcard.match(rest, append(wildcardValues, seg), host)
+	}
+
+	if leaf == nil {
+		leaf, wildcardMap = pn.matchCatchAll(segments, wildcardValues, host)
 	}
 
 	return leaf, wildcardMap
 }
 
+// matchLeaves returns the first of pn's leaves that matches wildcardValues and host.
+func (pn *pathNode) matchLeaves(wildcardValues []string, host string) (*pathLeaf, map[string]string) {
+	for _, leaf := range pn.leaves {
+		if !leaf.match(wildcardValues) {
+			continue
+		}
+		if leaf.host == nil {
+			return leaf, makeWildcardMap(leaf, wildcardValues)
+		}
+		if hostValues, ok := leaf.host.match(host); ok {
+			return leaf, makeHostWildcardMap(leaf, wildcardValues, hostValues)
+		}
+	}
+	return nil, nil
+}
+
+// matchCatchAll matches pn's catch-all routes, if any, capturing segments joined with slashes.
+func (pn *pathNode) matchCatchAll(segments []string, wildcardValues []string, host string) (*pathLeaf, map[string]string) {
+	if pn.catchAll == nil {
+		return nil, nil
+	}
+	return pn.catchAll.matchLeaves(append(wildcardValues, strings.Join(segments, "/")), host)
+}
+
+// matchFold is like match, but literal segments match regardless of case. An edge that matches exactly is tried
+// before those that only match ignoring case.
+func (pn *pathNode) matchFold(segments []string, wildcardValues []string, host string) (leaf *pathLeaf, wildcardMap map[string]string) {
+	if len(segments) == 0 {
+		return pn.match(nil, wildcardValues, host)
+	}
+
+	seg, rest := segments[0], segments[1:]
+
+	if subPn := pn.child(seg); subPn != nil {
+		if leaf, wildcardMap = subPn.matchFold(rest, wildcardValues, host); leaf != nil {
+			return leaf, wildcardMap
+		}
+	}
+	for _, edge := range pn.edges {
+		if edge.segment != seg && strings.EqualFold(edge.segment, seg) {
+			if leaf, wildcardMap = edge.node.matchFold(rest, wildcardValues, host); leaf != nil {
+				return leaf, wildcardMap
+			}
+		}
+	}
+	for edgeSeg, node := range pn.edgeMap {
+		if edgeSeg != seg && strings.EqualFold(edgeSeg, seg) {
+			if leaf, wildcardMap = node.matchFold(rest, wildcardValues, host); leaf != nil {
+				return leaf, wildcardMap
+			}
+		}
+	}
+
+	if pn.wildcard != nil {
+		if leaf, wildcardMap = pn.wildcard.matchFold(rest, append(wildcardValues, seg), host); leaf != nil {
+			return leaf, wildcardMap
+		}
+	}
+	return pn.matchCatchAll(segments, wildcardValues, host)
+}
+
 func (leaf *pathLeaf) match(wildcardValues []string) bool {
-	if leaf.regexps == nil {
+	if leaf.constraints == nil {
 		return true
 	}
 
 	// Invariant:
-	if len(leaf.regexps) != len(wildcardValues) {
+	if len(leaf.constraints) != len(wildcardValues) {
 		panic("bug: invariant violated")
 	}
 
-	for i, r := range leaf.regexps {
-		if r != nil {
-			if !r.MatchString(wildcardValues[i]) {
-				return false
-			}
+	for i, c := range leaf.constraints {
+		if c != nil && !c.match(wildcardValues[i]) {
+			return false
 		}
 	}
 	return true
 }
 
-// key is a non-empty path segment like "admin" or ":category_id" or ":category_id:\d+"
+// key is a path segment like "admin" or ":category_id" or ":category_id:\d+". It's empty for "//".
 // Returns true if it's a wildcard, and if it is, also returns it's name / regexp.
 // Eg, (true, "category_id", "\d+")
 func isWildcard(key string) (bool, string, string) {
-	if key[0] == ':' {
+	if len(key) > 0 && key[0] == ':' {
 		substrs := strings.SplitN(key[1:], ":", 2)
 		if len(substrs) == 1 {
 			return true, substrs[0], ""
@@ -153,6 +544,14 @@ func isWildcard(key string) (bool, string, string) {
 	return false, "", ""
 }
 
+// isCatchAll returns true, and the name, if key is a catch-all segment like "*path".
+func isCatchAll(key string) (bool, string) {
+	if len(key) > 0 && key[0] == '*' {
+		return true, key[1:]
+	}
+	return false, ""
+}
+
 // "/" -> []
 // "/admin" -> ["admin"]
 // "/admin/" -> ["admin"]
@@ -188,10 +587,52 @@ func makeWildcardMap(leaf *pathLeaf, wildcards []string) map[string]string {
 	return assoc
 }
 
-func compileRegexp(regStr string) *regexp.Regexp {
+// MaxRegexpParamLength is the longest path param a regexp constraint is matched against. Longer params
+// don't match, so hostile requests can't make the router run constraints over huge inputs.
+var MaxRegexpParamLength = 1024
+
+// MaxRegexpProgramSize bounds the complexity of regexp constraints: routes whose constraint compiles to
+// more instructions than this (eg, a{1,1000}) are rejected when they are registered.
+var MaxRegexpProgramSize = 1000
+
+// compileRegexpErr compiles a regexp constraint, rejecting overly complex ones. Go's regexps (RE2) match in
+// linear time, so there is no catastrophic backtracking to guard against, and Perl-only features like
+// backreferences are compile errors.
+func compileRegexpErr(regStr string) (*regexp.Regexp, error) {
 	if regStr == "" {
-		return nil
+		return nil, nil
 	}
 
-	return regexp.MustCompile("^" + regStr + "$")
+	parsed, err := syntax.Parse(anchorRegexp(regStr), syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	if len(prog.Inst) > MaxRegexpProgramSize {
+		return nil, fmt.Errorf("it is too complex (%d instructions, the limit is %d)", len(prog.Inst), MaxRegexpProgramSize)
+	}
+	return regexp.Compile(anchorRegexp(regStr))
+}
+
+// anchorRegexp makes regStr match whole path segments, including alternations like "new|edit".
+func anchorRegexp(regStr string) string {
+	return "^(?:" + regStr + ")$"
+}
+
+// makeHostWildcardMap is like makeWildcardMap, and also adds the values of the leaf's host wildcards.
+func makeHostWildcardMap(leaf *pathLeaf, wildcards []string, hostValues []string) map[string]string {
+	assoc := makeWildcardMap(leaf, wildcards)
+	if len(hostValues) == 0 {
+		return assoc
+	}
+	if assoc == nil {
+		assoc = make(map[string]string, len(hostValues))
+	}
+	for i, name := range leaf.host.wildcards() {
+		assoc[name] = hostValues[i]
+	}
+	return assoc
 }
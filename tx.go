@@ -0,0 +1,125 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// Tx is a database transaction. *sql.Tx implements it.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// DBProvider begins the transactions of TransactionMiddleware, eg on a connection pool.
+type DBProvider interface {
+	Begin(ctx context.Context) (Tx, error)
+}
+
+// DBProviderFunc is a function that implements DBProvider.
+type DBProviderFunc func(ctx context.Context) (Tx, error)
+
+// Begin implements DBProvider.
+func (f DBProviderFunc) Begin(ctx context.Context) (Tx, error) {
+	return f(ctx)
+}
+
+// SQLProvider returns a DBProvider that begins transactions on db with opts, which may be nil. Handlers get the
+// *sql.Tx back with a type assertion:
+//
+//	tx, err := req.Tx()
+//	if err != nil {
+//		panic(err)
+//	}
+//	tx.(*sql.Tx).ExecContext(req.Context(), "UPDATE ...")
+func SQLProvider(db *sql.DB, opts *sql.TxOptions) DBProvider {
+	return DBProviderFunc(func(ctx context.Context) (Tx, error) {
+		return db.BeginTx(ctx, opts)
+	})
+}
+
+// ErrNoTransaction is returned by Request.Tx for requests that don't go through a TransactionMiddleware, or
+// whose route was added WithoutTransaction.
+var ErrNoTransaction = errors.New("web: request has no transaction")
+
+type txContextKey struct{}
+
+// requestTx is the transaction of a request. It's begun the first time it's asked for.
+type requestTx struct {
+	provider DBProvider
+	req      *Request
+	mu       sync.Mutex
+	tx       Tx
+}
+
+// TransactionMiddleware returns a middleware that gives each request a transaction from provider, which handlers
+// (and the code they pass req.Context() to) get with Request.Tx or TxFromContext. The transaction is only begun
+// the first time it's asked for, so requests that don't touch the database don't pay for it. When the rest of the
+// stack returns, the transaction is committed if the response status is a 2xx or 3xx (or wasn't written), and
+// rolled back otherwise or on a panic. A failed commit panics, so it's handled like any other error. Work that must
+// wait for the commit, like publishing events, can be registered with Request.AfterSuccess.
+//
+// Streaming endpoints, which would hold their transaction open for as long as they stream, can opt out with
+// Route.WithoutTransaction.
+func TransactionMiddleware(provider DBProvider) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		state := &requestTx{provider: provider, req: req}
+		req.SetContext(context.WithValue(req.Context(), txContextKey{}, state))
+
+		committed := false
+		defer func() {
+			if state.tx != nil && !committed {
+				state.tx.Rollback()
+			}
+		}()
+
+		next(rw, req)
+
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.tx != nil && successStatus(rw.StatusCode()) {
+			committed = true
+			if err := state.tx.Commit(); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// WithoutTransaction makes Request.Tx return ErrNoTransaction for the route's requests, and returns the route.
+func (r *Route) WithoutTransaction() *Route {
+	r.checkConfigurable()
+	r.withoutTransaction = true
+	return r
+}
+
+// Tx returns the request's transaction, beginning it if needed. See TransactionMiddleware.
+func (r *Request) Tx() (Tx, error) {
+	return TxFromContext(r.Context())
+}
+
+// TxFromContext returns the transaction of the request ctx belongs to, beginning it if needed. See
+// TransactionMiddleware.
+func TxFromContext(ctx context.Context) (Tx, error) {
+	state, _ := ctx.Value(txContextKey{}).(*requestTx)
+	if state == nil || (state.req.route != nil && state.req.route.withoutTransaction) {
+		return nil, ErrNoTransaction
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.tx == nil {
+		tx, err := state.provider.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		state.tx = tx
+	}
+	return state.tx, nil
+}
+
+// successStatus returns true for 2xx and 3xx statuses, and for 0 (nothing written yet, so a 200).
+func successStatus(status int) bool {
+	return status == 0 || (status >= 200 && status < 400)
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTx struct {
+	log       *[]string
+	commitErr error
+}
+
+func (tx *testTx) Commit() error {
+	*tx.log = append(*tx.log, "commit")
+	return tx.commitErr
+}
+
+func (tx *testTx) Rollback() error {
+	*tx.log = append(*tx.log, "rollback")
+	return nil
+}
+
+func TestTransactionMiddleware(t *testing.T) {
+	var log []string
+	var commitErr error
+	provider := DBProviderFunc(func(ctx context.Context) (Tx, error) {
+		log = append(log, "begin")
+		return &testTx{log: &log, commitErr: commitErr}, nil
+	})
+
+	router := New(Context{})
+	router.Middleware(TransactionMiddleware(provider))
+	useTx := func(status int) func(ResponseWriter, *Request) {
+		return func(rw ResponseWriter, req *Request) {
+			tx, err := req.Tx()
+			assert.NoError(t, err)
+			again, _ := TxFromContext(req.Context())
+			assert.Equal(t, tx, again)
+			if status != 0 {
+				rw.WriteHeader(status)
+			}
+		}
+	}
+	router.Get("/ok", useTx(0))
+	router.Get("/redirect", useTx(http.StatusFound))
+	router.Get("/invalid", useTx(http.StatusUnprocessableEntity))
+	router.Get("/untouched", func(rw ResponseWriter, req *Request) {})
+	router.Get("/panic", func(rw ResponseWriter, req *Request) {
+		req.Tx()
+		panic("boom")
+	})
+	router.Get("/stream", func(rw ResponseWriter, req *Request) {
+		_, err := req.Tx()
+		assert.Equal(t, ErrNoTransaction, err)
+	}).WithoutTransaction()
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		log    []string
+	}{
+		{"/ok", http.StatusOK, []string{"begin", "commit"}},
+		{"/redirect", http.StatusFound, []string{"begin", "commit"}},
+		{"/invalid", http.StatusUnprocessableEntity, []string{"begin", "rollback"}},
+		{"/untouched", http.StatusOK, nil},
+		{"/panic", http.StatusInternalServerError, []string{"begin", "rollback"}},
+		{"/stream", http.StatusOK, nil},
+	} {
+		log = nil
+		rw, req := newTestRequest("GET", tt.path)
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, tt.status, rw.Code, tt.path)
+		assert.Equal(t, tt.log, log, tt.path)
+	}
+
+	// A failed commit is an error like any other.
+	log, commitErr = nil, errors.New("serialization failure")
+	rw, req := newTestRequest("GET", "/ok")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.Equal(t, []string{"begin", "commit"}, log)
+}
+
+func TestTxWithoutMiddleware(t *testing.T) {
+	router := New(Context{})
+	router.Get("/", func(rw ResponseWriter, req *Request) {
+		_, err := req.Tx()
+		assert.Equal(t, ErrNoTransaction, err)
+	})
+	rw, req := newTestRequest("GET", "/")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
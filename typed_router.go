@@ -0,0 +1,100 @@
+package web
+
+import (
+	"reflect"
+)
+
+// TypedRouter is a Router whose context type is Ctx. Its Middleware and route methods take functions of
+// *Ctx, so middleware and handlers with the wrong context or signature are caught by the compiler instead of
+// panicking when the router is built. They're called without reflect.Value.Call, but contexts are still made,
+// and handed over, through reflection like for any router:
+//
+//	router := web.NewRouter[Context]()
+//	router.Middleware((*Context).SetUser)
+//	admin := web.Subrouter[AdminContext](router, "/admin")
+//	admin.Get("/users", (*AdminContext).Users)
+//
+// The embedded *Router is available for everything else, including context-free middleware such as
+// web.LoggerMiddleware: router.Router.Middleware(web.LoggerMiddleware).
+type TypedRouter[Ctx any] struct {
+	*Router
+}
+
+// NewRouter returns a new root router with context type Ctx, which must be a struct type.
+func NewRouter[Ctx any](opts ...RouterOption) TypedRouter[Ctx] {
+	var ctx Ctx
+	return TypedRouter[Ctx]{New(ctx, opts...)}
+}
+
+// Subrouter returns a subrouter of parent with context type Child, like Router.Subrouter. Child must be
+// Parent, or a struct embedding *Parent. Go's type parameters can't express that, so unlike handler signatures
+// it isn't checked by the compiler: Subrouter panics, like Router.Subrouter, if Child doesn't fit.
+func Subrouter[Child, Parent any](parent TypedRouter[Parent], pathPrefix string) TypedRouter[Child] {
+	var ctx Child
+	return TypedRouter[Child]{parent.Router.Subrouter(ctx, pathPrefix)}
+}
+
+// Middleware adds mw to the router and returns the router.
+func (r TypedRouter[Ctx]) Middleware(mw func(*Ctx, ResponseWriter, *Request, NextMiddlewareFunc)) TypedRouter[Ctx] {
+	r.Router.middleware = append(r.Router.middleware, &middlewareHandler{
+		TypedMiddleware: func(ctx reflect.Value, rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+			mw(ctx.Interface().(*Ctx), rw, req, next)
+		},
+		name: funcName(reflect.ValueOf(mw)),
+	})
+	return r
+}
+
+// Get adds a route matching GET requests for path.
+func (r TypedRouter[Ctx]) Get(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodGet, path, h)
+}
+
+// Post adds a route matching POST requests for path.
+func (r TypedRouter[Ctx]) Post(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodPost, path, h)
+}
+
+// Put adds a route matching PUT requests for path.
+func (r TypedRouter[Ctx]) Put(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodPut, path, h)
+}
+
+// Delete adds a route matching DELETE requests for path.
+func (r TypedRouter[Ctx]) Delete(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodDelete, path, h)
+}
+
+// Patch adds a route matching PATCH requests for path.
+func (r TypedRouter[Ctx]) Patch(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodPatch, path, h)
+}
+
+// Head adds a route matching HEAD requests for path.
+func (r TypedRouter[Ctx]) Head(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodHead, path, h)
+}
+
+// Options adds a route matching OPTIONS requests for path.
+func (r TypedRouter[Ctx]) Options(path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethodOptions, path, h)
+}
+
+// Handle adds a route matching requests with method for path. See Router.Handle.
+func (r TypedRouter[Ctx]) Handle(method string, path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	return r.addTypedRoute(httpMethod(method), path, h)
+}
+
+func (r TypedRouter[Ctx]) addTypedRoute(method httpMethod, path string, h func(*Ctx, ResponseWriter, *Request)) *Route {
+	handler := &actionHandler{
+		TypedHandler: func(ctx reflect.Value, rw ResponseWriter, req *Request) {
+			h(ctx.Interface().(*Ctx), rw, req)
+		},
+		name: funcName(reflect.ValueOf(h)),
+	}
+	route, err := r.Router.tryAddHandler(method, appendPath(r.Router.pathPrefix, path), handler)
+	if err != nil {
+		panic(err)
+	}
+	return route
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedRouter(t *testing.T) {
+	router := NewRouter[Context]()
+	router.Middleware((*Context).mwAlpha).
+		Middleware(func(c *Context, rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+			fmt.Fprintf(rw, "typed-mw ")
+			next(rw, req)
+		})
+	router.Router.Middleware(func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		fmt.Fprintf(rw, "generic-mw ")
+		next(rw, req)
+	})
+	router.Get("/action", (*Context).A).Named("action")
+
+	admin := Subrouter[AdminContext](router, "/admin")
+	admin.Middleware((*AdminContext).mwEpsilon)
+	admin.Post("/users/:id", func(c *AdminContext, rw ResponseWriter, req *Request) {
+		fmt.Fprintf(rw, "admin-user-%s", req.PathParams["id"])
+	})
+
+	rw, req := newTestRequest("GET", "/action")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Alpha typed-mw generic-mw context-A", 200)
+
+	rw, req = newTestRequest("POST", "/admin/users/4")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-mw-Alpha typed-mw generic-mw admin-mw-Epsilon admin-user-4", 200)
+
+	snapshot := router.Snapshot()
+	assert.Equal(t, "web.(*Context).A", snapshot.Routes[0].Handler)
+	assert.Equal(t, "web.(*AdminContext).mwEpsilon", snapshot.Routes[1].Middleware[3])
+
+	assert.Panics(t, func() { router.Get("/action", (*Context).Z) })
+	assert.Panics(t, func() { Subrouter[invalidSubcontext](router, "/bad") })
+	assert.Panics(t, func() { NewRouter[int]() })
+}
+
+type countingContext struct {
+	Count int
+}
+
+func TestTypedRouterSharedContext(t *testing.T) {
+	router := NewRouter[countingContext]()
+	router.Middleware(func(c *countingContext, rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		c.Count = 1
+		next(rw, req)
+	})
+	api := Subrouter[countingContext](router, "/api")
+	api.Middleware(func(c *countingContext, rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		c.Count++
+		next(rw, req)
+	})
+	api.Get("/count", func(c *countingContext, rw ResponseWriter, req *Request) {
+		fmt.Fprint(rw, c.Count)
+	})
+
+	rw, req := newTestRequest("GET", "/api/count")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "2", 200)
+}
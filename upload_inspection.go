@@ -0,0 +1,112 @@
+package web
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// UploadPolicy configures UploadInspectionMiddleware.
+type UploadPolicy struct {
+	// MaxMemory is passed to ParseMultipartForm. Defaults to 32MB.
+	MaxMemory int64
+
+	// AllowedExtensions lists accepted file name extensions, eg ".png". Empty accepts any extension.
+	AllowedExtensions []string
+
+	// AllowedTypes lists accepted media types, eg "image/png". They are matched against the type sniffed
+	// from the file's content (see http.DetectContentType), not the type the client claims. Empty accepts any type.
+	AllowedTypes []string
+
+	// Scan, if set, is called for each file that passed the checks above, eg to hand it to a virus scanner.
+	// The file is positioned at its start. Return an error to reject the request.
+	Scan func(field string, header *multipart.FileHeader, file multipart.File) error
+}
+
+// DefaultUnsupportedUploadResponse is the default text rendered when an upload has a disallowed extension or type.
+var DefaultUnsupportedUploadResponse = "Unsupported Media Type"
+
+// DefaultRejectedUploadResponse is the default text rendered when UploadPolicy.Scan rejects an upload.
+var DefaultRejectedUploadResponse = "Upload Rejected"
+
+// UploadInspectionMiddleware returns middleware that parses multipart requests and inspects every uploaded file
+// before handlers see it. Files with a disallowed extension or content type are rejected with 415, files rejected by
+// policy.Scan with 422. Handlers can then use req.MultipartForm as usual. Other requests pass through untouched.
+func UploadInspectionMiddleware(policy UploadPolicy) func(ResponseWriter, *Request, NextMiddlewareFunc) {
+	if policy.MaxMemory == 0 {
+		policy.MaxMemory = 32 << 20
+	}
+
+	return func(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+		// ParseMultipartForm accepts the media type in any case, so must this check.
+		if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+			next(rw, req)
+			return
+		}
+
+		if err := req.ParseMultipartForm(policy.MaxMemory); err != nil {
+			renderError(rw, req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			return
+		}
+
+		for field, headers := range req.MultipartForm.File {
+			for _, header := range headers {
+				if status := policy.inspect(field, header); status != 0 {
+					if status == http.StatusUnsupportedMediaType {
+						renderError(rw, req, status, DefaultUnsupportedUploadResponse)
+					} else {
+						renderError(rw, req, status, DefaultRejectedUploadResponse)
+					}
+					return
+				}
+			}
+		}
+
+		next(rw, req)
+	}
+}
+
+// inspect returns 0 if the file is acceptable, and the status to respond with otherwise.
+func (policy *UploadPolicy) inspect(field string, header *multipart.FileHeader) int {
+	if len(policy.AllowedExtensions) > 0 && !containsFold(policy.AllowedExtensions, filepath.Ext(header.Filename)) {
+		return http.StatusUnsupportedMediaType
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return http.StatusBadRequest
+	}
+	defer file.Close()
+
+	if len(policy.AllowedTypes) > 0 {
+		sniff := make([]byte, 512)
+		n, err := io.ReadFull(file, sniff)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return http.StatusBadRequest
+		}
+		mediaType := strings.TrimSpace(strings.SplitN(http.DetectContentType(sniff[:n]), ";", 2)[0])
+		if !containsFold(policy.AllowedTypes, mediaType) {
+			return http.StatusUnsupportedMediaType
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return http.StatusBadRequest
+		}
+	}
+
+	if policy.Scan != nil && policy.Scan(field, header, file) != nil {
+		return http.StatusUnprocessableEntity
+	}
+	return 0
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newUploadRequest(filename string, content []byte) (*httptest.ResponseRecorder, *http.Request) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write(content)
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return httptest.NewRecorder(), req
+}
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A rest of the image")
+
+func TestUploadInspection(t *testing.T) {
+	router := New(Context{})
+	router.Middleware(UploadInspectionMiddleware(UploadPolicy{
+		AllowedExtensions: []string{".png"},
+		AllowedTypes:      []string{"image/png"},
+		Scan: func(field string, header *multipart.FileHeader, file multipart.File) error {
+			content, _ := io.ReadAll(file)
+			if bytes.Contains(content, []byte("EICAR")) {
+				return errors.New("infected")
+			}
+			return nil
+		},
+	}))
+	router.Post("/upload", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "got %s", r.MultipartForm.File["file"][0].Filename)
+	})
+	router.Post("/other", (*Context).A)
+
+	rw, req := newUploadRequest("cat.png", pngHeader)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "got cat.png", http.StatusOK)
+
+	rw, req = newUploadRequest("cat.exe", pngHeader)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+
+	rw, req = newUploadRequest("cat.png", []byte("MZ not really a png"))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+
+	rw, req = newUploadRequest("cat.png", append(pngHeader, []byte("EICAR")...))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Upload Rejected", http.StatusUnprocessableEntity)
+
+	// The media type is case-insensitive, as it is to ParseMultipartForm.
+	rw, req = newUploadRequest("evil.exe", pngHeader)
+	req.Header.Set("Content-Type", strings.Replace(req.Header.Get("Content-Type"), "multipart/form-data", "Multipart/Form-Data", 1))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+
+	// Non-multipart requests pass through.
+	rw, req = newTestRequest("POST", "/other")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "context-A", http.StatusOK)
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Wizard carries the state of a multi-step form across requests in a cookie encrypted with SecureCookies.
+// Each step stores its own struct, which later steps (and the final submission) can load back:
+//
+//	var checkout = web.NewWizard("checkout", secureCookies)
+//
+//	func (c *Context) SaveShipping(rw web.ResponseWriter, req *web.Request) {
+//		shipping := Shipping{Address: req.FormValue("address")}
+//		if err := checkout.Save(rw, req, "shipping", &shipping); err != nil { ... }
+//		http.Redirect(rw, req.Request, "/checkout/payment", http.StatusSeeOther)
+//	}
+//
+// Save at most once per request, before the response is written.
+type Wizard struct {
+	// Name of the cookie holding the state.
+	Name string
+
+	// MaxSize is the largest encoded cookie the wizard writes. Browsers drop cookies over about 4KB.
+	MaxSize int
+
+	// MaxAge is how long the wizard's state is valid. Older state is discarded when loaded.
+	MaxAge time.Duration
+
+	cookies *SecureCookies
+}
+
+// ErrWizardStateTooLarge is returned by Wizard.Save when the state doesn't fit in MaxSize.
+var ErrWizardStateTooLarge = errors.New("web: wizard state is too large")
+
+type wizardState struct {
+	Steps map[string]json.RawMessage `json:"s"`
+}
+
+// NewWizard returns a Wizard storing its state in the cookie name, encrypted with cookies (whether or not its
+// Encrypt is set). MaxSize defaults to 4000 bytes and MaxAge to one hour.
+func NewWizard(name string, cookies *SecureCookies) *Wizard {
+	return &Wizard{Name: name, MaxSize: 4000, MaxAge: time.Hour, cookies: cookies.encrypting()}
+}
+
+// Load decodes the data saved for step into v. It returns false if there is no (valid, unexpired) data for step.
+func (wz *Wizard) Load(req *Request, step string, v interface{}) (bool, error) {
+	raw, ok := wz.load(req).Steps[step]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Save stores v as the data for step, keeping the data of other steps.
+func (wz *Wizard) Save(rw ResponseWriter, req *Request, step string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	state := wz.load(req)
+	state.Steps[step] = raw
+
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	value := wz.cookies.Encode(wz.Name, string(plaintext), time.Now().Add(wz.MaxAge))
+	if len(value) > wz.MaxSize {
+		return ErrWizardStateTooLarge
+	}
+
+	return SetCookie(rw, Cookie{Name: wz.Name, Value: value, MaxAge: int(wz.MaxAge / time.Second)})
+}
+
+// Clear deletes the wizard's state, eg once the final step was submitted.
+func (wz *Wizard) Clear(rw ResponseWriter) error {
+	return DeleteCookie(rw, wz.Name)
+}
+
+// load returns the state in the request's cookie, or an empty state if it's missing, tampered with, or expired.
+func (wz *Wizard) load(req *Request) *wizardState {
+	state := &wizardState{Steps: make(map[string]json.RawMessage)}
+
+	cookie, err := req.Cookie(wz.Name)
+	if err != nil || len(cookie.Value) > wz.MaxSize {
+		return state
+	}
+	plaintext, err := wz.cookies.Decode(wz.Name, cookie.Value)
+	if err != nil {
+		return state
+	}
+
+	var decoded wizardState
+	if json.Unmarshal([]byte(plaintext), &decoded) != nil || decoded.Steps == nil {
+		return state
+	}
+	return &decoded
+}
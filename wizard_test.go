@@ -0,0 +1,80 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type wizardShipping struct {
+	Address string
+}
+
+type wizardPayment struct {
+	Card string
+}
+
+func TestWizard(t *testing.T) {
+	wizard := NewWizard("checkout", NewSecureCookies([]byte("0123456789abcdef0123456789abcdef")))
+
+	router := New(Context{})
+	router.Post("/shipping", func(w ResponseWriter, r *Request) {
+		assert.NoError(t, wizard.Save(w, r, "shipping", &wizardShipping{Address: r.FormValue("address")}))
+	})
+	router.Post("/payment", func(w ResponseWriter, r *Request) {
+		assert.NoError(t, wizard.Save(w, r, "payment", &wizardPayment{Card: r.FormValue("card")}))
+	})
+	router.Get("/confirm", func(w ResponseWriter, r *Request) {
+		var shipping wizardShipping
+		var payment wizardPayment
+		okShipping, err := wizard.Load(r, "shipping", &shipping)
+		assert.NoError(t, err)
+		okPayment, err := wizard.Load(r, "payment", &payment)
+		assert.NoError(t, err)
+		fmt.Fprintf(w, "%v:%s %v:%s", okShipping, shipping.Address, okPayment, payment.Card)
+	})
+
+	rw, req := newTestRequest("POST", "/shipping?address=Main+St")
+	router.ServeHTTP(rw, req)
+	cookie := rw.Result().Cookies()[0]
+	assert.Equal(t, "checkout", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.False(t, strings.Contains(cookie.Value, "Main"))
+
+	rw, req = newTestRequest("POST", "/payment?card=4242")
+	req.AddCookie(cookie)
+	router.ServeHTTP(rw, req)
+	cookie = rw.Result().Cookies()[0]
+
+	rw, req = newTestRequest("GET", "/confirm")
+	req.AddCookie(cookie)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "true:Main St true:4242", http.StatusOK)
+
+	// Tampering discards the state.
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	assert.NoError(t, err)
+	sealed[len(sealed)-1] ^= 1
+	tampered := *cookie
+	tampered.Value = base64.RawURLEncoding.EncodeToString(sealed)
+	rw, req = newTestRequest("GET", "/confirm")
+	req.AddCookie(&tampered)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "false: false:", http.StatusOK)
+}
+
+func TestWizardTooLarge(t *testing.T) {
+	wizard := NewWizard("checkout", NewSecureCookies([]byte("0123456789abcdef0123456789abcdef")))
+	wizard.MaxSize = 64
+
+	router := New(Context{})
+	router.Post("/step", func(w ResponseWriter, r *Request) {
+		assert.Equal(t, ErrWizardStateTooLarge, wizard.Save(w, r, "step", strings.Repeat("x", 100)))
+	})
+	rw, req := newTestRequest("POST", "/step")
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, 0, len(rw.Result().Cookies()))
+}
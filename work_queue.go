@@ -0,0 +1,270 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WorkQueueOptions configures a WorkQueue.
+type WorkQueueOptions struct {
+	// Concurrency is how many requests run at once. Defaults to 1, which serializes them.
+	Concurrency int
+
+	// MaxWaiting is how many requests may wait for their turn with the client connected. Once that many are
+	// waiting, further requests are accepted with a 202 and run in the background.
+	MaxWaiting int
+
+	// MaxJobs is how many background requests may be queued or running at once. Further requests get a 503 with
+	// a Retry-After header. Defaults to 100.
+	MaxJobs int
+
+	// StatusRoute is the name of the route served by StatusHandler. Its path must have an :id param, eg
+	// "/reports/jobs/:id". The 202 response's Location header points to it.
+	StatusRoute string
+
+	// ResultTTL is how long the responses of background requests are kept for StatusHandler. Defaults to 10 minutes.
+	ResultTTL time.Duration
+
+	// MaxBodySize caps the request body kept for a background request; larger requests get a 413. Defaults to 1MB.
+	MaxBodySize int64
+}
+
+// DefaultQueueFullResponse is the default text rendered when a WorkQueue can't run a request in the background,
+// because it has MaxJobs of them already or the router pools requests.
+var DefaultQueueFullResponse = "Service Unavailable"
+
+// DefaultBodyTooLargeResponse is the default text rendered for request bodies over a limit, eg a MaxBodySize or
+// WorkQueueOptions.MaxBodySize.
+var DefaultBodyTooLargeResponse = "Request Entity Too Large"
+
+// WorkQueue bounds how many requests for expensive routes (eg, report generation) run at once. Requests beyond
+// that wait for their turn; when too many are waiting, the rest are accepted with a 202 and run in the background,
+// and their clients fetch the response from a status route later:
+//
+//	queue := web.NewWorkQueue(web.WorkQueueOptions{Concurrency: 2, MaxWaiting: 10, StatusRoute: "report_job"})
+//	router.Post("/reports", (*Context).Report).Use(queue.Middleware)
+//	router.Get("/reports/jobs/:id", queue.StatusHandler).Named("report_job")
+//
+// Background requests run after ServeHTTP returned: their body is spooled first (see Request.SpoolBody), their
+// context is no longer cancelled when the client goes away, and their response is recorded. Handlers must not rely
+// on the connection (eg, Hijack). Routers that pool requests can't hand requests over like that, so their deep
+// queues respond with a 503 instead.
+type WorkQueue struct {
+	opts  WorkQueueOptions
+	slots chan struct{}
+
+	mu      sync.Mutex
+	waiting int
+	running int // background requests that aren't done
+	jobs    map[string]*queuedJob
+}
+
+// queuedJob is a request run in the background, and its response once it's done.
+type queuedJob struct {
+	done     bool
+	response *jobRecorder
+}
+
+// NewWorkQueue returns a WorkQueue configured by opts.
+func NewWorkQueue(opts WorkQueueOptions) *WorkQueue {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxJobs <= 0 {
+		opts.MaxJobs = 100
+	}
+	if opts.ResultTTL == 0 {
+		opts.ResultTTL = 10 * time.Minute
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+	return &WorkQueue{
+		opts:  opts,
+		slots: make(chan struct{}, opts.Concurrency),
+		jobs:  make(map[string]*queuedJob),
+	}
+}
+
+// Middleware runs the rest of the middleware stack and the handler when it's the request's turn. Use it on the
+// expensive routes, or on a router that only has such routes.
+func (q *WorkQueue) Middleware(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+	select {
+	case q.slots <- struct{}{}:
+		defer q.release()
+		next(rw, req)
+		return
+	default:
+	}
+
+	q.mu.Lock()
+	wait := q.waiting < q.opts.MaxWaiting
+	if wait {
+		q.waiting++
+	}
+	q.mu.Unlock()
+
+	if !wait {
+		q.enqueue(rw, req, next)
+		return
+	}
+
+	select {
+	case q.slots <- struct{}{}:
+		q.stopWaiting()
+		defer q.release()
+		next(rw, req)
+	case <-req.Context().Done():
+		q.stopWaiting()
+	}
+}
+
+// StatusHandler responds with the response of the background request whose ID is the :id path param once it's
+// done, and with a 202 until then. Unknown or expired IDs are not found.
+func (q *WorkQueue) StatusHandler(rw ResponseWriter, req *Request) {
+	q.mu.Lock()
+	job := q.jobs[req.PathParams["id"]]
+	done := job != nil && job.done
+	q.mu.Unlock()
+
+	switch {
+	case job == nil:
+		renderError(rw, req, http.StatusNotFound, DefaultNotFoundResponse)
+	case !done:
+		rw.WriteHeader(http.StatusAccepted)
+	default:
+		for k, v := range job.response.header {
+			rw.Header()[k] = v
+		}
+		rw.WriteHeader(job.response.status)
+		rw.Write(job.response.body.Bytes())
+	}
+}
+
+func (q *WorkQueue) release() {
+	<-q.slots
+}
+
+func (q *WorkQueue) stopWaiting() {
+	q.mu.Lock()
+	q.waiting--
+	q.mu.Unlock()
+}
+
+// enqueue hands req over to a goroutine that runs it when it's its turn, and responds with a 202.
+func (q *WorkQueue) enqueue(rw ResponseWriter, req *Request, next NextMiddlewareFunc) {
+	if req.route == nil || (req.more != nil && req.more.pooled) {
+		rw.Header().Set("Retry-After", "1")
+		renderError(rw, req, http.StatusServiceUnavailable, DefaultQueueFullResponse)
+		return
+	}
+
+	spool, err := req.SpoolBody(q.opts.MaxBodySize)
+	if err == ErrBodyTooLarge {
+		renderError(rw, req, http.StatusRequestEntityTooLarge, DefaultBodyTooLargeResponse)
+		return
+	} else if err != nil {
+		renderError(rw, req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+		return
+	}
+	req.more.bodySpool = nil // run removes it once the background request is done, long after ServeHTTP returned
+
+	id := newRequestID()
+	location, err := req.MappedUrlFor(q.opts.StatusRoute, Query{"id": id})
+	if err != nil {
+		panic(err)
+	}
+
+	job := &queuedJob{}
+	q.mu.Lock()
+	full := q.running >= q.opts.MaxJobs
+	if !full {
+		q.running++
+		q.jobs[id] = job
+	}
+	q.mu.Unlock()
+	if full {
+		spool.close()
+		rw.Header().Set("Retry-After", "1")
+		renderError(rw, req, http.StatusServiceUnavailable, DefaultQueueFullResponse)
+		return
+	}
+
+	// The background request has its own extras, so its callbacks don't mix with this response's.
+	bg := *req
+	bg.more = nil
+	bg.SetContext(context.WithoutCancel(req.Context()))
+	bg.more.connCtx = bg.Context() // the client is gone by the time the request runs, and that's fine
+	go q.run(id, job, &bg, next, spool)
+
+	rw.Header().Set("Location", location)
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// run invokes next for a background request, whose body is spool, and records its response in job. The response
+// is forgotten ResultTTL later.
+func (q *WorkQueue) run(id string, job *queuedJob, req *Request, next NextMiddlewareFunc, spool *BodySpool) {
+	q.slots <- struct{}{}
+	defer q.release()
+	defer spool.close()
+
+	recorder := &jobRecorder{header: make(http.Header)}
+	rw := &appResponseWriter{ResponseWriter: recorder, req: req}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if !rw.Written() {
+				renderError(rw, req, http.StatusInternalServerError, DefaultPanicResponse)
+			}
+			PanicHandler.Panic(fmt.Sprint(req.URL), recovered, formatFrames(panicFrames()))
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		q.mu.Lock()
+		job.done = true
+		job.response = recorder
+		q.running--
+		q.mu.Unlock()
+		time.AfterFunc(q.opts.ResultTTL, func() {
+			q.mu.Lock()
+			delete(q.jobs, id)
+			q.mu.Unlock()
+		})
+	}()
+
+	next(rw, req)
+}
+
+// jobRecorder is the http.ResponseWriter of a background request.
+type jobRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *jobRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *jobRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(data)
+}
+
+func (r *jobRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// CloseNotify returns a channel that never fires: nobody is waiting on the connection.
+func (r *jobRecorder) CloseNotify() <-chan bool {
+	return nil
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkQueue(t *testing.T) {
+	queue := NewWorkQueue(WorkQueueOptions{Concurrency: 1, MaxWaiting: 0, StatusRoute: "report_job", MaxBodySize: 16})
+	started := make(chan bool)
+	unblock := make(chan bool)
+
+	router := New(Context{})
+	router.Post("/reports", func(rw ResponseWriter, req *Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if string(body) == "slow" {
+			started <- true
+			<-unblock
+		}
+		rw.Header().Set("Content-Type", "text/csv")
+		fmt.Fprintf(rw, "report %s", body)
+	}).Use(queue.Middleware)
+	router.Get("/reports/jobs/:id", queue.StatusHandler).Named("report_job")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rw, req := newTestRequest("POST", "/reports")
+		req.Body = ioutil.NopCloser(strings.NewReader("slow"))
+		router.ServeHTTP(rw, req)
+		assertResponse(t, rw, "report slow", http.StatusOK)
+	}()
+	<-started
+
+	// The only slot is taken and nobody may wait, so the request runs in the background.
+	rw, req := newTestRequest("POST", "/reports")
+	req.Body = ioutil.NopCloser(strings.NewReader("fast"))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusAccepted)
+	location := rw.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/reports/jobs/"), location)
+
+	rw, req = newTestRequest("GET", location)
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusAccepted)
+
+	rw, req = newTestRequest("POST", "/reports")
+	req.Body = ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 17)))
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+
+	unblock <- true
+	wg.Wait()
+
+	for i := 0; ; i++ {
+		rw, req = newTestRequest("GET", location)
+		router.ServeHTTP(rw, req)
+		if rw.Code != http.StatusAccepted || i == 100 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertResponse(t, rw, "report fast", http.StatusOK)
+	assert.Equal(t, "text/csv", rw.Header().Get("Content-Type"))
+
+	rw, req = newTestRequest("GET", "/reports/jobs/unknown")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Not Found", http.StatusNotFound)
+}
+
+func TestWorkQueuePooled(t *testing.T) {
+	queue := NewWorkQueue(WorkQueueOptions{StatusRoute: "job"})
+	queue.slots <- struct{}{} // busy
+
+	router := New(Context{}).PoolRequests(true)
+	router.Post("/reports", (*Context).A).Use(queue.Middleware)
+	router.Get("/jobs/:id", queue.StatusHandler).Named("job")
+
+	rw, req := newTestRequest("POST", "/reports")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Service Unavailable", http.StatusServiceUnavailable)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+}
+
+func TestWorkQueueMaxJobs(t *testing.T) {
+	queue := NewWorkQueue(WorkQueueOptions{MaxJobs: 1, StatusRoute: "job", ResultTTL: 10 * time.Millisecond})
+	queue.slots <- struct{}{} // busy
+
+	router := New(Context{})
+	router.Post("/reports", (*Context).A).Use(queue.Middleware)
+	router.Get("/jobs/:id", queue.StatusHandler).Named("job")
+
+	rw, req := newTestRequest("POST", "/reports")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "", http.StatusAccepted)
+	location := rw.Header().Get("Location")
+
+	rw, req = newTestRequest("POST", "/reports")
+	router.ServeHTTP(rw, req)
+	assertResponse(t, rw, "Service Unavailable", http.StatusServiceUnavailable)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+
+	queue.release()
+	for i := 0; ; i++ {
+		rw, req = newTestRequest("GET", location)
+		router.ServeHTTP(rw, req)
+		if rw.Code != http.StatusAccepted || i == 100 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assertResponse(t, rw, "context-A", http.StatusOK)
+
+	// The response is forgotten after ResultTTL, even though no other request came in.
+	time.Sleep(50 * time.Millisecond)
+	queue.mu.Lock()
+	assert.Equal(t, 0, len(queue.jobs))
+	queue.mu.Unlock()
+}